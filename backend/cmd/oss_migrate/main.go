@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/oss"
+	"go.uber.org/fx"
+)
+
+// 在不同对象存储之间复制所有对象，对象路径保持不变，数据库中保存的 /{bucket}/{path} 无需修改
+// 例如：oss_migrate -from minio -to local
+func main() {
+	from := flag.String("from", "minio", "source oss type: minio, s3, local")
+	to := flag.String("to", "", "destination oss type: minio, s3, local")
+	buckets := flag.String("buckets", "", "buckets to migrate, separated by comma, default all buckets of source")
+	flag.Parse()
+
+	if *to == "" || *to == *from {
+		glog.With("from", *from).With("to", *to).Error("invalid destination oss type")
+		os.Exit(1)
+	}
+
+	app := fx.New(
+		fx.NopLogger,
+		config.Module,
+		fx.Invoke(func(cfg config.Config) error {
+			return migrate(context.Background(), cfg, *from, *to, *buckets)
+		}),
+	)
+	if err := app.Err(); err != nil {
+		glog.WithErr(err).Error("migrate oss failed")
+		os.Exit(1)
+	}
+}
+
+func sourceBuckets(cfg config.Config, typ string) []string {
+	switch typ {
+	case "s3":
+		return cfg.OSS.S3.Buckets
+	case "local":
+		return cfg.OSS.Local.Buckets
+	default:
+		return cfg.OSS.Minio.Buckets
+	}
+}
+
+func migrate(ctx context.Context, cfg config.Config, from string, to string, buckets string) error {
+	src, err := oss.New(cfg, from)
+	if err != nil {
+		return err
+	}
+
+	dst, err := oss.New(cfg, to)
+	if err != nil {
+		return err
+	}
+
+	bucketList := sourceBuckets(cfg, from)
+	if buckets != "" {
+		bucketList = strings.Split(buckets, ",")
+	}
+
+	var failedTotal int
+	for _, bucket := range bucketList {
+		logger := glog.With("bucket", bucket)
+
+		var total, failed int
+		err = src.Walk(ctx, func(info oss.ObjectInfo) error {
+			total++
+
+			err := copyObject(ctx, src, dst, bucket, info)
+			if err != nil {
+				failed++
+				logger.WithErr(err).With("object", info.Path).Warn("copy object failed")
+			}
+
+			return nil
+		}, oss.WithBucket(bucket))
+		if err != nil {
+			return err
+		}
+
+		logger.With("total", total).With("failed", failed).Info("bucket migrated")
+		failedTotal += failed
+	}
+
+	if failedTotal > 0 {
+		return fmt.Errorf("%d objects failed to migrate", failedTotal)
+	}
+
+	return nil
+}
+
+func copyObject(ctx context.Context, src, dst oss.Client, bucket string, info oss.ObjectInfo) error {
+	reader, err := src.Download(ctx, info.Path, oss.WithBucket(bucket))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	dir := path.Dir(info.Path)
+	if dir == "." {
+		dir = ""
+	}
+
+	_, err = dst.Upload(ctx, dir, reader,
+		oss.WithBucket(bucket),
+		oss.WithFilename(path.Base(info.Path)),
+		oss.WithFileSize(int(info.Size)),
+	)
+	return err
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/chaitin/koalaqa/intercept"
 	"github.com/chaitin/koalaqa/migration"
 	"github.com/chaitin/koalaqa/model"
 	"github.com/chaitin/koalaqa/pkg/anydoc"
 	"github.com/chaitin/koalaqa/pkg/batch"
+	"github.com/chaitin/koalaqa/pkg/cache"
 	"github.com/chaitin/koalaqa/pkg/chat"
 	"github.com/chaitin/koalaqa/pkg/config"
 	"github.com/chaitin/koalaqa/pkg/cron"
@@ -19,6 +21,7 @@ import (
 	"github.com/chaitin/koalaqa/pkg/rag"
 	"github.com/chaitin/koalaqa/pkg/ratelimit"
 	"github.com/chaitin/koalaqa/pkg/third_auth"
+	"github.com/chaitin/koalaqa/pkg/upload"
 	"github.com/chaitin/koalaqa/pkg/version"
 	"github.com/chaitin/koalaqa/pkg/webhook"
 	"github.com/chaitin/koalaqa/repo"
@@ -31,6 +34,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		runMCP(os.Args[2:])
+		return
+	}
+
 	app := fx.New(
 		config.Module,
 		database.Moudle,
@@ -52,6 +60,8 @@ func main() {
 		cron.Module(),
 		fx.Provide(version.NewInfo),
 		ratelimit.Module,
+		cache.Module,
+		upload.Module,
 		batch.Module,
 		chat.Module,
 	)
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/chaitin/koalaqa/pkg/mcp"
+)
+
+// runMCP 以 stdio 方式运行 mcp server，消息转发到 KoalaQA 的 /mcp 接口，例如：
+//
+//	server mcp -url https://koala.example.com/mcp -token <api token>
+func runMCP(args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	url := fs.String("url", os.Getenv("KOALA_MCP_URL"), "KoalaQA mcp endpoint, env KOALA_MCP_URL")
+	token := fs.String("token", os.Getenv("KOALA_MCP_TOKEN"), "api token with mcp scope, env KOALA_MCP_TOKEN")
+	fs.Parse(args)
+
+	if *url == "" || *token == "" {
+		fmt.Fprintln(os.Stderr, "url and token are required")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := mcp.Stdio(ctx, *url, *token, os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
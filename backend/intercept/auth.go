@@ -17,17 +17,30 @@ const tokenPrefix = "Bearer"
 
 type auth struct {
 	freeAuth bool
+	expire   int
 	jwt      *jwt.Generator
 	user     *svc.User
+	session  *svc.UserSession
 	apiToken *repo.APIToken
 }
 
-func newAuth(cfg config.Config, generator *jwt.Generator, user *svc.User, apiToken *repo.APIToken) Interceptor {
-	return &auth{freeAuth: cfg.API.FreeAuth, jwt: generator, user: user, apiToken: apiToken}
+func newAuth(cfg config.Config, generator *jwt.Generator, user *svc.User, session *svc.UserSession, apiToken *repo.APIToken) *auth {
+	return &auth{
+		freeAuth: cfg.API.FreeAuth,
+		expire:   int(cfg.JWT.Expire),
+		jwt:      generator,
+		user:     user,
+		session:  session,
+		apiToken: apiToken,
+	}
+}
+
+func newAuthInterceptor(cfg config.Config, generator *jwt.Generator, user *svc.User, session *svc.UserSession, apiToken *repo.APIToken) Interceptor {
+	return newAuth(cfg, generator, user, session, apiToken)
 }
 
 func (a *auth) Intercept(ctx *context.Context) {
-	userInfo, err := authUser(ctx, a.freeAuth, a.jwt, a.user, a.apiToken)
+	userInfo, err := a.authUser(ctx)
 	if err != nil {
 		ctx.Unauthorized(err.Error())
 		ctx.Abort()
@@ -44,19 +57,40 @@ func (a *auth) Priority() int {
 }
 
 func init() {
-	registerAPIAuth(newAuth)
+	registerAPIAuth(newAuthInterceptor)
 }
 
-func authUser(ctx *context.Context, freeAuth bool, j *jwt.Generator, user *svc.User, apiToken *repo.APIToken) (*model.UserInfo, error) {
-	var token = ""
+// refreshCookie cookie 中的 access token 过期后自动使用 refresh token 续期，浏览器端无需感知
+func (a *auth) refreshCookie(ctx *context.Context) (*model.UserCore, error) {
+	refreshToken, _ := ctx.Cookie(context.RefreshTokenCookie)
+	if refreshToken == "" {
+		return nil, jwt.ErrExpired
+	}
+
+	token, err := a.session.Refresh(ctx, refreshToken)
+	if err != nil {
+		ctx.ClearAuthCookie()
+		return nil, err
+	}
+
+	ctx.SetAuthCookie(token.AccessToken, token.RefreshToken, a.expire)
+	return a.jwt.Verify(token.AccessToken)
+}
+
+func (a *auth) authUser(ctx *context.Context) (*model.UserInfo, error) {
+	var (
+		token       = ""
+		tokenCookie bool
+	)
 	if authToken := ctx.GetHeader("Authorization"); authToken != "" {
 		splitToken := strings.Split(authToken, " ")
 		if len(splitToken) == 2 && splitToken[0] == tokenPrefix {
 			token = splitToken[1]
 		}
 	}
-	if authToken, _ := ctx.Cookie("auth_token"); authToken != "" {
+	if authToken, _ := ctx.Cookie(context.AuthTokenCookie); authToken != "" {
 		token = authToken
+		tokenCookie = true
 	}
 
 	var (
@@ -65,14 +99,14 @@ func authUser(ctx *context.Context, freeAuth bool, j *jwt.Generator, user *svc.U
 		reqAPIToken = ctx.GetHeader("X-KOALA-TOKEN")
 	)
 	if token == "" {
-		if freeAuth {
+		if a.freeAuth {
 			var err error
-			item, err = user.Admin(ctx)
+			item, err = a.user.Admin(ctx)
 			if err != nil {
 				return nil, err
 			}
 		} else if reqAPIToken != "" && strings.HasPrefix(ctx.Request.RequestURI, "/api/admin") {
-			exist, err := apiToken.Exist(ctx, repo.QueryWithEqual("token", reqAPIToken))
+			exist, err := a.apiToken.ExistScope(ctx, reqAPIToken, model.APITokenScopeAdmin)
 			if err != nil {
 				return nil, errors.New("check api token failed")
 			}
@@ -99,7 +133,10 @@ func authUser(ctx *context.Context, freeAuth bool, j *jwt.Generator, user *svc.U
 			Salt:     item.Key,
 		}
 	} else {
-		userCore, err := j.Verify(token)
+		userCore, err := a.jwt.Verify(token)
+		if errors.Is(err, jwt.ErrExpired) && tokenCookie {
+			userCore, err = a.refreshCookie(ctx)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -109,7 +146,7 @@ func authUser(ctx *context.Context, freeAuth bool, j *jwt.Generator, user *svc.U
 			return nil, errors.New("request method permission deny")
 		}
 
-		item, err = user.Detail(ctx, userCore.UID)
+		item, err = a.user.Detail(ctx, userCore.UID)
 		if err != nil {
 			return nil, err
 		}
@@ -117,6 +154,11 @@ func authUser(ctx *context.Context, freeAuth bool, j *jwt.Generator, user *svc.U
 		if item.Key != userCore.Key {
 			return nil, errors.New("invalid key")
 		}
+
+		err = a.session.Active(ctx, *userCore)
+		if err != nil {
+			return nil, err
+		}
 		core = *userCore
 	}
 
@@ -3,15 +3,20 @@ package intercept
 import (
 	"net/http"
 	"slices"
+	"strings"
 
 	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/svc"
 )
 
+const widgetPathPrefix = "/api/widget/"
+
 type cors struct {
 	postAllows []string
+	widgetSite *svc.WidgetSite
 }
 
-func newCors() Interceptor {
+func newCors(widgetSite *svc.WidgetSite) Interceptor {
 	return &cors{
 		postAllows: []string{
 			// "/api/user/login/cors",
@@ -20,10 +25,16 @@ func newCors() Interceptor {
 			"/api/discussion/summary/content",
 			"/api/discussion/ask/session",
 		},
+		widgetSite: widgetSite,
 	}
 }
 
 func (c *cors) Intercept(ctx *context.Context) {
+	if key, ok := widgetSiteKey(ctx.Request.URL.Path); ok {
+		c.widget(ctx, key)
+		return
+	}
+
 	if ctx.Request.Header.Get("Origin") != "" &&
 		(ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodPost && slices.Contains(c.postAllows, ctx.Request.RequestURI)) {
 		ctx.Header("Access-Control-Allow-Origin", "*")
@@ -37,6 +48,39 @@ func (c *cors) Intercept(ctx *context.Context) {
 	ctx.Next()
 }
 
+func widgetSiteKey(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, widgetPathPrefix)
+	if !ok {
+		return "", false
+	}
+
+	key, _, _ := strings.Cut(rest, "/")
+	return key, key != ""
+}
+
+// widget 挂件接口只允许站点配置的来源跨域访问
+func (c *cors) widget(ctx *context.Context, key string) {
+	origin := ctx.Request.Header.Get("Origin")
+	if origin != "" {
+		if !c.widgetSite.AllowOrigin(ctx, key, origin, ctx.Request.Host) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		ctx.Header("Access-Control-Allow-Origin", origin)
+		ctx.Header("Access-Control-Allow-Methods", "GET, POST")
+		ctx.Header("Access-Control-Allow-Headers", "Content-Type")
+		ctx.Header("Access-Control-Allow-Credentials", "false")
+		ctx.Header("Vary", "Origin")
+	}
+
+	if ctx.Request.Method == http.MethodOptions {
+		ctx.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	ctx.Next()
+}
+
 func (c *cors) Priority() int {
 	return -20
 }
@@ -27,6 +27,22 @@ func registerNotGuestAPI(i any) {
 	register("api_not_guest_interceptors", i)
 }
 
+func registerSCIM(i any) {
+	register("scim_interceptors", i)
+}
+
+func registerRestAPI(i any) {
+	register("rest_api_interceptors", i)
+}
+
+func registerOpenAI(i any) {
+	register("openai_interceptors", i)
+}
+
+func registerMCP(i any) {
+	register("mcp_interceptors", i)
+}
+
 func register(group string, i any) {
 	modules = append(modules, util.ProvideGroup(group, i))
 }
@@ -0,0 +1,89 @@
+package intercept
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+// mcpAuth mcp 接口只允许带有 mcp 范围的 api token 访问，具体工具的范围在调用时校验
+// 请求以 token 代表的用户身份处理
+type mcpAuth struct {
+	apiToken *repo.APIToken
+	user     *repo.User
+}
+
+func newMCPAuth(apiToken *repo.APIToken, user *repo.User) Interceptor {
+	return &mcpAuth{apiToken: apiToken, user: user}
+}
+
+func (m *mcpAuth) Intercept(ctx *context.Context) {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), tokenPrefix+" ")
+	if !ok || token == "" {
+		ctx.Unauthorized("auth token is empty")
+		ctx.Abort()
+		return
+	}
+
+	apiToken, err := m.apiToken.GetByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			ctx.Unauthorized("invalid api token")
+		} else {
+			ctx.InternalError(err, "check api token failed")
+		}
+		ctx.Abort()
+		return
+	}
+
+	if !apiToken.HasScope(model.APITokenScopeMCPRead) && !apiToken.HasScope(model.APITokenScopeMCPWrite) {
+		ctx.Forbidden("api token scope not allowed")
+		ctx.Abort()
+		return
+	}
+
+	var user model.User
+	err = m.user.GetByID(ctx, &user, apiToken.UserID)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			ctx.Forbidden("api token user not found")
+		} else {
+			ctx.InternalError(err, "get api token user failed")
+		}
+		ctx.Abort()
+		return
+	}
+
+	if user.Blocked(time.Now()) {
+		ctx.Forbidden("api token user blocked")
+		ctx.Abort()
+		return
+	}
+
+	userInfo := model.UserInfo{
+		UserCore: model.UserCore{
+			UID:      user.ID,
+			Key:      user.Key,
+			AuthType: model.AuthTypeAPIToken,
+		},
+		UserBasic: user.UserBasic,
+	}
+	ctx.SetUser(userInfo)
+	ctx.Request = ctx.Request.WithContext(svc.MCPContext(ctx.Request.Context(), apiToken, userInfo))
+
+	ctx.Next()
+}
+
+func (m *mcpAuth) Priority() int {
+	return 0
+}
+
+func init() {
+	registerMCP(newMCPAuth)
+}
@@ -0,0 +1,61 @@
+package intercept
+
+import (
+	"strings"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/openai"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+// openAIAuth OpenAI 兼容接口只允许 api token 访问，错误使用 OpenAI 的格式返回
+type openAIAuth struct {
+	apiToken *repo.APIToken
+}
+
+func newOpenAIAuth(apiToken *repo.APIToken) Interceptor {
+	return &openAIAuth{apiToken: apiToken}
+}
+
+func (o *openAIAuth) Intercept(ctx *context.Context) {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), tokenPrefix+" ")
+	if !ok || token == "" {
+		o.abort(ctx, openai.InvalidAPIKey("missing api key"))
+		return
+	}
+
+	exist, err := o.apiToken.ExistScope(ctx, token, model.APITokenScopeOpenAI)
+	if err != nil {
+		o.abort(ctx, openai.ServerError("check api key failed"))
+		return
+	}
+
+	if !exist {
+		o.abort(ctx, openai.InvalidAPIKey("invalid api key"))
+		return
+	}
+
+	ctx.SetUser(model.UserInfo{
+		UserCore: model.UserCore{
+			AuthType: model.AuthTypeAPIToken,
+		},
+		UserBasic: model.UserBasic{
+			Role: model.UserRoleAdmin,
+		},
+	})
+
+	ctx.Next()
+}
+
+func (o *openAIAuth) abort(ctx *context.Context, err *openai.Error) {
+	ctx.AbortWithStatusJSON(err.StatusCode(), err)
+}
+
+func (o *openAIAuth) Priority() int {
+	return 0
+}
+
+func init() {
+	registerOpenAI(newOpenAIAuth)
+}
@@ -9,23 +9,14 @@ import (
 )
 
 type publicAccess struct {
-	svcAuth  *svc.Auth
-	intAuth  Interceptor
-	jwt      *jwt.Generator
-	svcUser  *svc.User
-	apiToken *repo.APIToken
-
-	freeAuth bool
+	svcAuth *svc.Auth
+	intAuth *auth
 }
 
-func newPublicAccess(auth *svc.Auth, cfg config.Config, generator *jwt.Generator, user *svc.User, apiToken *repo.APIToken) Interceptor {
+func newPublicAccess(auth *svc.Auth, cfg config.Config, generator *jwt.Generator, user *svc.User, session *svc.UserSession, apiToken *repo.APIToken) Interceptor {
 	return &publicAccess{
-		svcAuth:  auth,
-		intAuth:  newAuth(cfg, generator, user, apiToken),
-		jwt:      generator,
-		svcUser:  user,
-		freeAuth: cfg.API.FreeAuth,
-		apiToken: apiToken,
+		svcAuth: auth,
+		intAuth: newAuth(cfg, generator, user, session, apiToken),
 	}
 }
 
@@ -43,7 +34,7 @@ func (p *publicAccess) Intercept(ctx *context.Context) {
 		return
 	}
 
-	userInfo, err := authUser(ctx, p.freeAuth, p.jwt, p.svcUser, p.apiToken)
+	userInfo, err := p.intAuth.authUser(ctx)
 	if err == nil {
 		ctx.SetUser(*userInfo)
 	}
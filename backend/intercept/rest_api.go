@@ -0,0 +1,91 @@
+package intercept
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/restapi"
+	tracePkg "github.com/chaitin/koalaqa/pkg/trace"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+// restAPIAuth 对外 api 使用 Authorization: Bearer <api token> 认证，
+// 开启公开访问时允许匿名访问，权限与未登录用户一致
+type restAPIAuth struct {
+	svcAuth  *svc.Auth
+	apiToken *repo.APIToken
+}
+
+func newRestAPIAuth(auth *svc.Auth, apiToken *repo.APIToken) Interceptor {
+	return &restAPIAuth{svcAuth: auth, apiToken: apiToken}
+}
+
+func (r *restAPIAuth) Intercept(ctx *context.Context) {
+	// openapi 文档不需要认证
+	if strings.HasSuffix(ctx.FullPath(), "/openapi.json") {
+		ctx.Next()
+		return
+	}
+
+	header := ctx.GetHeader("Authorization")
+	if header == "" {
+		auth, err := r.svcAuth.Get(ctx)
+		if err != nil {
+			r.abort(ctx, restapi.Internal("get auth info failed"))
+			return
+		}
+
+		if !auth.PublicAccess {
+			r.abort(ctx, restapi.Unauthorized("auth token is empty"))
+			return
+		}
+
+		ctx.Next()
+		return
+	}
+
+	token, ok := strings.CutPrefix(header, tokenPrefix+" ")
+	if !ok || token == "" {
+		r.abort(ctx, restapi.Unauthorized("invalid authorization header"))
+		return
+	}
+
+	exist, err := r.apiToken.ExistScope(ctx, token, model.APITokenScopeRestAPI)
+	if err != nil {
+		r.abort(ctx, restapi.Internal("check api token failed"))
+		return
+	}
+
+	if !exist {
+		r.abort(ctx, restapi.Unauthorized("invalid api token"))
+		return
+	}
+
+	ctx.SetUser(model.UserInfo{
+		UserCore: model.UserCore{
+			AuthType: model.AuthTypeAPIToken,
+		},
+		UserBasic: model.UserBasic{
+			Role: model.UserRoleAdmin,
+		},
+	})
+
+	ctx.Next()
+}
+
+func (r *restAPIAuth) abort(ctx *context.Context, err *restapi.Error) {
+	data, _ := json.Marshal(err.WithTraceID(tracePkg.TraceIDString(ctx)))
+	ctx.Data(err.StatusCode(), "application/json", data)
+	ctx.Abort()
+}
+
+func (r *restAPIAuth) Priority() int {
+	return 0
+}
+
+func init() {
+	registerRestAPI(newRestAPIAuth)
+}
@@ -0,0 +1,65 @@
+package intercept
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/scim"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+// scimAuth SCIM 客户端通过 Authorization: Bearer <api token> 认证
+type scimAuth struct {
+	apiToken *repo.APIToken
+}
+
+func newSCIMAuth(apiToken *repo.APIToken) Interceptor {
+	return &scimAuth{apiToken: apiToken}
+}
+
+func (s *scimAuth) Intercept(ctx *context.Context) {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), tokenPrefix+" ")
+	if !ok || token == "" {
+		s.abort(ctx, scim.NewError(http.StatusUnauthorized, "", "auth token is empty"))
+		return
+	}
+
+	exist, err := s.apiToken.ExistScope(ctx, token, model.APITokenScopeSCIM)
+	if err != nil {
+		s.abort(ctx, scim.NewError(http.StatusInternalServerError, "", "check api token failed"))
+		return
+	}
+
+	if !exist {
+		s.abort(ctx, scim.NewError(http.StatusUnauthorized, "", "invalid api token"))
+		return
+	}
+
+	ctx.SetUser(model.UserInfo{
+		UserCore: model.UserCore{
+			AuthType: model.AuthTypeAPIToken,
+		},
+		UserBasic: model.UserBasic{
+			Role: model.UserRoleAdmin,
+		},
+	})
+
+	ctx.Next()
+}
+
+func (s *scimAuth) abort(ctx *context.Context, err *scim.Error) {
+	data, _ := json.Marshal(err)
+	ctx.Data(err.StatusCode(), scim.ContentType, data)
+	ctx.Abort()
+}
+
+func (s *scimAuth) Priority() int {
+	return 0
+}
+
+func init() {
+	registerSCIM(newSCIMAuth)
+}
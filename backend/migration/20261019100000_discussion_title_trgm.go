@@ -0,0 +1,30 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/chaitin/koalaqa/migration/migrator"
+)
+
+type discussionTitleTrgm struct{}
+
+func (m *discussionTitleTrgm) Version() int64 {
+	return 20261019100000
+}
+
+func (m *discussionTitleTrgm) Migrate(tx *gorm.DB) error {
+	err := tx.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm;").Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec("CREATE INDEX IF NOT EXISTS idx_discussion_title_trgm ON discussions USING GIN (title gin_trgm_ops);").Error
+}
+
+func newDiscussionTitleTrgm() migrator.Migrator {
+	return &discussionTitleTrgm{}
+}
+
+func init() {
+	registerDBMigrator(newDiscussionTitleTrgm)
+}
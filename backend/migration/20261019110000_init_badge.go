@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/chaitin/koalaqa/migration/migrator"
+	"github.com/chaitin/koalaqa/model"
+)
+
+type initBadge struct{}
+
+func (m *initBadge) Version() int64 {
+	return 20261019110000
+}
+
+func (m *initBadge) Migrate(tx *gorm.DB) error {
+	return tx.Create(&[]model.Badge{
+		{
+			Name:        "初露锋芒",
+			Description: "第一个回答被采纳",
+			Kind:        model.BadgeKindAnswerAccepted,
+			Threshold:   1,
+		},
+		{
+			Name:        "百问百答",
+			Description: "累计回答 100 次",
+			Kind:        model.BadgeKindAnswer,
+			Threshold:   100,
+		},
+		{
+			Name:        "周榜之星",
+			Description: "成为周贡献榜第一名",
+			Kind:        model.BadgeKindContributeRank,
+			Threshold:   1,
+		},
+	}).Error
+}
+
+func newInitBadge() migrator.Migrator {
+	return &initBadge{}
+}
+
+func init() {
+	registerDBMigrator(newInitBadge)
+}
@@ -0,0 +1,28 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/chaitin/koalaqa/migration/migrator"
+	"github.com/chaitin/koalaqa/model"
+)
+
+// apiTokenAdminScope 未设置范围的旧 token 只用于管理接口，迁移为显式的 admin 范围
+type apiTokenAdminScope struct{}
+
+func (m *apiTokenAdminScope) Version() int64 {
+	return 20261019120000
+}
+
+func (m *apiTokenAdminScope) Migrate(tx *gorm.DB) error {
+	return tx.Model(&model.APIToken{}).Where("scopes IS NULL OR cardinality(scopes) = 0").
+		UpdateColumn("scopes", model.StringArray{model.APITokenScopeAdmin}).Error
+}
+
+func newAPITokenAdminScope() migrator.Migrator {
+	return &apiTokenAdminScope{}
+}
+
+func init() {
+	registerDBMigrator(newAPITokenAdminScope)
+}
@@ -1,10 +1,29 @@
 package model
 
+import "slices"
+
+const (
+	APITokenScopeAdmin    = "admin"
+	APITokenScopeRestAPI  = "rest_api"
+	APITokenScopeSCIM     = "scim"
+	APITokenScopeOpenAI   = "openai"
+	APITokenScopeMCPRead  = "mcp:read"
+	APITokenScopeMCPWrite = "mcp:write"
+)
+
 type APIToken struct {
 	Base
 
 	Name  string `gorm:"column:name;type:text" json:"name"`
 	Token string `gorm:"column:token;type:text;index" json:"token"`
+	// Scopes 可访问的接口范围，为空时不能访问任何接口
+	Scopes StringArray `gorm:"column:scopes;type:text[]" json:"scopes"`
+	// UserID token 代表的用户，mcp 以该用户的身份和板块权限访问
+	UserID uint `gorm:"column:user_id;type:bigint;default:0" json:"user_id"`
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
 }
 
 func init() {
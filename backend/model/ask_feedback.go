@@ -0,0 +1,48 @@
+package model
+
+type AskFeedbackReason uint
+
+const (
+	AskFeedbackReasonWrong AskFeedbackReason = iota + 1
+	AskFeedbackReasonOutdated
+	AskFeedbackReasonIncomplete
+	AskFeedbackReasonIrrelevant
+)
+
+type AskFeedbackStatus uint
+
+const (
+	AskFeedbackStatusPending AskFeedbackStatus = iota
+	AskFeedbackStatusIgnored
+	// AskFeedbackStatusCorrected 已根据反馈创建修正后的问答对
+	AskFeedbackStatusCorrected
+)
+
+// AskFeedback 用户对智能问答中 AI 回答的评价，每条 AI 回答只保留最后一次评价
+type AskFeedback struct {
+	Base
+
+	AskID     uint              `json:"ask_id" gorm:"column:ask_id;type:bigint;uniqueIndex"`
+	SessionID string            `json:"session_id" gorm:"column:session_id;type:text;index"`
+	UserID    uint              `json:"user_id" gorm:"column:user_id;type:bigint"`
+	State     AskFunnelFeedback `json:"state" gorm:"column:state"`
+	Reasons   Int64Array        `json:"reasons" gorm:"column:reasons;type:bigint[]"`
+	Comment   string            `json:"comment" gorm:"column:comment;type:text"`
+	// DocIDs 回答时引用的知识库文档
+	DocIDs     Int64Array        `json:"doc_ids" gorm:"column:doc_ids;type:bigint[]"`
+	Status     AskFeedbackStatus `json:"status" gorm:"column:status;default:0"`
+	ReviewerID uint              `json:"reviewer_id" gorm:"column:reviewer_id;type:bigint;default:0"`
+	QAID       uint              `json:"qa_id" gorm:"column:qa_id;type:bigint;default:0"`
+}
+
+type AskFeedbackListItem struct {
+	AskFeedback
+
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Username string `json:"username"`
+}
+
+func init() {
+	registerAutoMigrate(&AskFeedback{})
+}
@@ -0,0 +1,99 @@
+package model
+
+type AskFunnelFeedback uint
+
+const (
+	AskFunnelFeedbackNone AskFunnelFeedback = iota
+	AskFunnelFeedbackLike
+	AskFunnelFeedbackDislike
+)
+
+type AskEscalateReason uint
+
+const (
+	AskEscalateReasonNone AskEscalateReason = iota
+	// AskEscalateReasonNeedHuman 用户要求转人工
+	AskEscalateReasonNeedHuman
+	// AskEscalateReasonUnknown AI 无法回答
+	AskEscalateReasonUnknown
+	// AskEscalateReasonDislike 用户对 AI 回答不满意
+	AskEscalateReasonDislike
+	// AskEscalateReasonManual 用户主动发帖求助
+	AskEscalateReasonManual
+)
+
+func (r AskEscalateReason) Name() string {
+	switch r {
+	case AskEscalateReasonNeedHuman:
+		return "要求转人工"
+	case AskEscalateReasonUnknown:
+		return "AI 无法回答"
+	case AskEscalateReasonDislike:
+		return "对回答不满意"
+	case AskEscalateReasonManual:
+		return "主动发帖"
+	default:
+		return "未转人工"
+	}
+}
+
+// AskFunnel 记录一次智能问答会话从提问到解决的各个阶段，CreatedAt 为首次提问时间，其余时间为空表示未到达该阶段
+type AskFunnel struct {
+	Base
+
+	UUID     string           `json:"uuid" gorm:"column:uuid;type:text;uniqueIndex"`
+	UserID   uint             `json:"user_id" gorm:"column:user_id;type:bigint"`
+	Source   AskSessionSource `json:"source" gorm:"column:source"`
+	ForumID  uint             `json:"forum_id" gorm:"column:forum_id;type:bigint;default:0;index"`
+	GroupIDs Int64Array       `json:"group_ids" gorm:"column:group_ids;type:bigint[]"`
+
+	Questions    int               `json:"questions" gorm:"column:questions;default:0"`
+	AnsweredAt   Timestamp         `json:"answered_at" gorm:"column:answered_at;type:timestamp with time zone;default:null"`
+	Unknown      bool              `json:"unknown" gorm:"column:unknown;default:false"`
+	NeedHuman    bool              `json:"need_human" gorm:"column:need_human;default:false"`
+	Feedback     AskFunnelFeedback `json:"feedback" gorm:"column:feedback;default:0"`
+	FeedbackAt   Timestamp         `json:"feedback_at" gorm:"column:feedback_at;type:timestamp with time zone;default:null"`
+	EscalatedAt  Timestamp         `json:"escalated_at" gorm:"column:escalated_at;type:timestamp with time zone;default:null"`
+	Reason       AskEscalateReason `json:"reason" gorm:"column:reason;default:0"`
+	DiscussionID uint              `json:"discussion_id" gorm:"column:discussion_id;type:bigint;default:0;index"`
+	// HumanAnsweredAt 帖子收到第一个非发帖人的人工回答
+	HumanAnsweredAt Timestamp `json:"human_answered_at" gorm:"column:human_answered_at;type:timestamp with time zone;default:null"`
+	AcceptedAt      Timestamp `json:"accepted_at" gorm:"column:accepted_at;type:timestamp with time zone;default:null"`
+}
+
+// AskFunnelStat 漏斗各阶段的会话数
+type AskFunnelStat struct {
+	Sessions      int64 `json:"sessions"`
+	Answered      int64 `json:"answered"`
+	Unknown       int64 `json:"unknown"`
+	Feedback      int64 `json:"feedback"`
+	Like          int64 `json:"like"`
+	Escalated     int64 `json:"escalated"`
+	Discussions   int64 `json:"discussions"`
+	HumanAnswered int64 `json:"human_answered"`
+	Accepted      int64 `json:"accepted"`
+	// Deflected AI 回答后未转人工的会话
+	Deflected      int64   `json:"deflected"`
+	DeflectionRate float64 `json:"deflection_rate"`
+	// DeflectedTime AI 解决的平均耗时，单位秒
+	DeflectedTime int64 `json:"deflected_time"`
+	// AcceptedTime 人工解决（回答被采纳）的平均耗时，单位秒
+	AcceptedTime int64 `json:"accepted_time"`
+}
+
+type AskFunnelBreakdown struct {
+	AskFunnelStat
+
+	ID   uint   `json:"id"`
+	Name string `json:"name" gorm:"-"`
+}
+
+type AskFunnelTrend struct {
+	AskFunnelStat
+
+	Ts int64 `json:"ts"`
+}
+
+func init() {
+	registerAutoMigrate(&AskFunnel{})
+}
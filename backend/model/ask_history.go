@@ -13,8 +13,26 @@ const (
 	AskSessionSourcePlugin
 	AskSessionSourceBot
 	AskSessionSourceWecomService
+	AskSessionSourceOpenAI
 )
 
+func (s AskSessionSource) Name() string {
+	switch s {
+	case AskSessionSourceWeb:
+		return "网页"
+	case AskSessionSourcePlugin:
+		return "网页挂件"
+	case AskSessionSourceBot:
+		return "机器人"
+	case AskSessionSourceWecomService:
+		return "企业微信客服"
+	case AskSessionSourceOpenAI:
+		return "OpenAI 兼容接口"
+	default:
+		return ""
+	}
+}
+
 type AskSession struct {
 	Base
 
@@ -26,7 +44,11 @@ type AskSession struct {
 	Summary      bool                           `json:"summary" gorm:"column:summary"`
 	NeedHuman    bool                           `json:"need_human" gorm:"column:need_human;default:false"`
 	SummaryDiscs JSONB[[]AskSessionSummaryDisc] `json:"summary_discs" gorm:"column:summary_discs;type:jsonb"`
-	Content      string                         `json:"content" gorm:"column:content"`
+	// DocIDs AI 回答时检索到的知识库文档
+	DocIDs  Int64Array `json:"doc_ids,omitempty" gorm:"column:doc_ids;type:bigint[]"`
+	Content string     `json:"content" gorm:"column:content"`
+	// SiteID 通过网页挂件站点提问时的站点
+	SiteID uint `json:"site_id" gorm:"column:site_id;type:bigint;default:0;index"`
 }
 
 func init() {
@@ -0,0 +1,44 @@
+package model
+
+type BadgeKind string
+
+const (
+	BadgeKindAnswerAccepted BadgeKind = "answer_accepted" // 回答被采纳次数达到阈值
+	BadgeKindAnswer         BadgeKind = "answer"          // 回答次数达到阈值
+	BadgeKindPoint          BadgeKind = "point"           // 积分达到阈值
+	BadgeKindContributeRank BadgeKind = "contribute_rank" // 周贡献榜排名不低于阈值
+)
+
+// Badge 徽章，用户满足 Kind 与 Threshold 定义的条件时获得
+type Badge struct {
+	Base
+
+	Name        string    `json:"name" gorm:"column:name;type:text"`
+	Description string    `json:"description" gorm:"column:description;type:text"`
+	Icon        string    `json:"icon" gorm:"column:icon;type:text"`
+	Kind        BadgeKind `json:"kind" gorm:"column:kind;type:text;index"`
+	Threshold   int       `json:"threshold" gorm:"column:threshold;type:bigint;default:0"`
+	Disabled    bool      `json:"disabled" gorm:"column:disabled;default:false"`
+}
+
+type UserBadge struct {
+	Base
+
+	UserID  uint `json:"user_id" gorm:"column:user_id;type:bigint;uniqueIndex:udx_user_badge_user_badge"`
+	BadgeID uint `json:"badge_id" gorm:"column:badge_id;type:bigint;uniqueIndex:udx_user_badge_user_badge;index"`
+}
+
+type UserBadgeItem struct {
+	Badge
+
+	AwardedAt Timestamp `json:"awarded_at"`
+}
+
+type BadgeHeader struct {
+	BadgeName string `gorm:"column:badge_name;type:text" json:"badge_name"`
+}
+
+func init() {
+	registerAutoMigrate(&Badge{})
+	registerAutoMigrate(&UserBadge{})
+}
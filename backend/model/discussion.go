@@ -38,6 +38,16 @@ const (
 	DiscussionStateInProgress
 )
 
+// BountyState 悬赏状态，悬赏积分在发帖时冻结，采纳回答时发放，关闭时退回
+type BountyState uint
+
+const (
+	BountyStateNone BountyState = iota
+	BountyStateOpen
+	BountyStateAwarded
+	BountyStateRefunded
+)
+
 type Discussion struct {
 	Base
 
@@ -63,9 +73,18 @@ type Discussion struct {
 	ForumID     uint            `json:"forum_id" gorm:"column:forum_id;type:bigint;index"`
 	Members     Int64Array      `json:"members" gorm:"column:members;type:bigint[]"`
 	AssociateID uint            `json:"associate_id" gorm:"column:associate_id;type:bigint;default:0;index"`
-	BotUnknown  bool            `json:"bot_unknown" gorm:"column:bot_unknown"`
-	Visit       int             `json:"visit" gorm:"column:visit;default:0"`                                   // 发帖人访问次数
-	LastVisited Timestamp       `json:"last_visited" gorm:"column:last_visited;type:timestamp with time zone"` // 发帖人上次访问时间
+	MergedID    uint            `json:"merged_id" gorm:"column:merged_id;type:bigint;default:0;index"` // 被合并到的帖子
+	Bounty      uint            `json:"bounty" gorm:"column:bounty;type:bigint;default:0"`
+	BountyState BountyState     `json:"bounty_state" gorm:"column:bounty_state;type:integer;default:0;index"`
+	// 以下字段仅 Issue 使用
+	Assignees    Int64Array    `json:"assignees" gorm:"column:assignees;type:bigint[]"`
+	Priority     IssuePriority `json:"priority" gorm:"column:priority;type:integer;default:0"`
+	Severity     IssueSeverity `json:"severity" gorm:"column:severity;type:integer;default:0"`
+	DueAt        Timestamp     `json:"due_at" gorm:"column:due_at;type:timestamp with time zone"`
+	IssueStateID uint          `json:"issue_state_id" gorm:"column:issue_state_id;type:bigint;default:0;index"`
+	BotUnknown   bool          `json:"bot_unknown" gorm:"column:bot_unknown"`
+	Visit        int           `json:"visit" gorm:"column:visit;default:0"`                                   // 发帖人访问次数
+	LastVisited  Timestamp     `json:"last_visited" gorm:"column:last_visited;type:timestamp with time zone"` // 发帖人上次访问时间
 }
 
 type DiscMetadata struct {
@@ -175,6 +194,7 @@ type DiscussionDetail struct {
 	Groups        []DiscussionGroup   `json:"groups" gorm:"-"`
 	Comments      []DiscussionComment `json:"comments" gorm:"-"`
 	Associate     DiscussionListItem  `json:"associate"`
+	Merged        DiscussionListItem  `json:"merged"`
 	UserLike      bool                `json:"user_like"`
 	Alert         bool                `json:"alert"`
 }
@@ -0,0 +1,18 @@
+package model
+
+// EmailMessage 邮件与帖子、评论的关联，用于按 In-Reply-To/References 归并回复
+// CommentID 为 0 表示发帖的邮件
+type EmailMessage struct {
+	Base
+
+	MessageID    string `json:"message_id" gorm:"column:message_id;type:text;uniqueIndex"`
+	DiscussionID uint   `json:"discussion_id" gorm:"column:discussion_id;type:bigint;uniqueIndex:udx_email_message_discussion_comment"`
+	CommentID    uint   `json:"comment_id" gorm:"column:comment_id;type:bigint;uniqueIndex:udx_email_message_discussion_comment"`
+	UserID       uint   `json:"user_id" gorm:"column:user_id;type:bigint"`
+	// Inbound 是否为收到的邮件，否则为回信
+	Inbound bool `json:"inbound" gorm:"column:inbound;default:false"`
+}
+
+func init() {
+	registerAutoMigrate(&EmailMessage{})
+}
@@ -0,0 +1,14 @@
+package model
+
+// ImageDescription 视觉模型对 OSS 图片的描述及识别出的文字，按对象路径缓存
+type ImageDescription struct {
+	Base
+
+	Path    string `json:"path" gorm:"column:path;type:text;uniqueIndex"`
+	Model   string `json:"model" gorm:"column:model;type:text"`
+	Content string `json:"content" gorm:"column:content;type:text"`
+}
+
+func init() {
+	registerAutoMigrate(&ImageDescription{})
+}
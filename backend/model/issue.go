@@ -0,0 +1,107 @@
+package model
+
+import "strconv"
+
+type IssuePriority uint
+
+const (
+	IssuePriorityNone IssuePriority = iota
+	IssuePriorityLow
+	IssuePriorityMedium
+	IssuePriorityHigh
+	IssuePriorityUrgent
+)
+
+type IssueSeverity uint
+
+const (
+	IssueSeverityNone IssueSeverity = iota
+	IssueSeverityMinor
+	IssueSeverityMajor
+	IssueSeverityCritical
+	IssueSeverityBlocker
+)
+
+var issueCategoryNameM = map[DiscussionState]string{
+	DiscussionStateNone:       "待处理",
+	DiscussionStateInProgress: "进行中",
+	DiscussionStateResolved:   "已完成",
+}
+
+// IssueCategoryName 未配置自定义状态时 Issue 的状态名
+func IssueCategoryName(state DiscussionState) string {
+	name, ok := issueCategoryNameM[state]
+	if !ok {
+		return strconv.FormatUint(uint64(state), 10)
+	}
+
+	return name
+}
+
+// IssueState 论坛自定义的 Issue 状态，Category 决定帖子的 resolved 字段，不影响已有的筛选与知识库元数据
+type IssueState struct {
+	Base
+
+	ForumID  uint            `json:"forum_id" gorm:"column:forum_id;type:bigint;index"`
+	Name     string          `json:"name" gorm:"column:name;type:text"`
+	Index    uint            `json:"index" gorm:"column:index;default:0"`
+	Category DiscussionState `json:"category" gorm:"column:category;type:integer;default:1"`
+	Initial  bool            `json:"initial" gorm:"column:initial;default:false"`   // 新建 Issue 的初始状态
+	NextIDs  Int64Array      `json:"next_ids" gorm:"column:next_ids;type:bigint[]"` // 允许流转到的状态，为空时不限制
+}
+
+// CanTransit 是否允许从当前状态流转到 stateID
+func (s *IssueState) CanTransit(stateID uint) bool {
+	if s.ID == stateID {
+		return false
+	}
+
+	if len(s.NextIDs) == 0 {
+		return true
+	}
+
+	for _, id := range s.NextIDs {
+		if uint(id) == stateID {
+			return true
+		}
+	}
+
+	return false
+}
+
+type IssueHistoryField string
+
+const (
+	IssueHistoryFieldState    IssueHistoryField = "state"
+	IssueHistoryFieldAssignee IssueHistoryField = "assignee"
+	IssueHistoryFieldPriority IssueHistoryField = "priority"
+	IssueHistoryFieldSeverity IssueHistoryField = "severity"
+	IssueHistoryFieldDueAt    IssueHistoryField = "due_at"
+)
+
+// IssueHistory Issue 字段变更记录
+type IssueHistory struct {
+	Base
+
+	DiscussionID uint              `json:"discussion_id" gorm:"column:discussion_id;type:bigint;index"`
+	UserID       uint              `json:"user_id" gorm:"column:user_id;type:bigint"`
+	Field        IssueHistoryField `json:"field" gorm:"column:field;type:text"`
+	From         string            `json:"from" gorm:"column:from;type:text"`
+	To           string            `json:"to" gorm:"column:to;type:text"`
+}
+
+type IssueHistoryItem struct {
+	IssueHistory
+
+	UserName   string `json:"user_name"`
+	UserAvatar string `json:"user_avatar"`
+}
+
+type IssueHeader struct {
+	IssueState string `gorm:"column:issue_state;type:text" json:"issue_state"`
+}
+
+func init() {
+	registerAutoMigrate(&IssueState{})
+	registerAutoMigrate(&IssueHistory{})
+}
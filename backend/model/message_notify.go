@@ -24,6 +24,10 @@ const (
 	MsgNotifyTypeIssueResolved
 	MsgNotifyTypeUserPoint
 	MsgNotifyTypeFollowDiscuss
+	MsgNotifyTypeMergeDiscussion
+	MsgNotifyTypeBadge
+	MsgNotifyTypeIssueAssigned
+	MsgNotifyTypeIssueStateChange
 )
 
 type MessageNotify struct {
@@ -52,6 +56,8 @@ type MessageNotifyCommon struct {
 	CommentHeader
 	UserReviewHeader
 	UserPointHeader
+	BadgeHeader
+	IssueHeader
 
 	Type     MsgNotifyType `gorm:"column:type" json:"type"`
 	FromID   uint          `gorm:"column:from_id" json:"from_id"`
@@ -84,6 +90,9 @@ var (
 			MsgNotifyTypeCloseDiscussion: {
 				"你有新的帖子进展", "关闭了你的帖子",
 			},
+			MsgNotifyTypeMergeDiscussion: {
+				"你有新的帖子进展", "将你的问题合并到",
+			},
 		},
 		DiscussionTypeBlog: {
 			MsgNotifyTypeReplyDiscuss: {
@@ -109,6 +118,12 @@ var (
 			MsgNotifyTypeIssueResolved: {
 				"你有新的帖子进展", "将 Issue 状态变更为已完成",
 			},
+			MsgNotifyTypeIssueAssigned: {
+				"你有新的 Issue", "将 Issue 指派给你",
+			},
+			MsgNotifyTypeIssueStateChange: {
+				"你有新的帖子进展", "将 Issue 状态变更为",
+			},
 		},
 	}
 )
@@ -123,6 +138,10 @@ func (c *MessageNotifyCommon) TitleOperateText(mdFormat bool, fromName bool) (st
 		return title, "管理员拒绝了您的账号激活申请"
 	}
 
+	if c.Type == MsgNotifyTypeBadge {
+		return "你获得了新徽章", "恭喜！你获得了徽章 " + c.BadgeName
+	}
+
 	tOp, ok := titleOperateM[c.DiscussionType]
 	if !ok {
 		return "", ""
@@ -143,7 +162,12 @@ func (c *MessageNotifyCommon) TitleOperateText(mdFormat bool, fromName bool) (st
 		prefix = mdData + c.FromName + mdData + " "
 	}
 
-	return text[0], prefix + text[1] + " " + mdData + c.DiscussTitle + mdData
+	operate := text[1]
+	if c.Type == MsgNotifyTypeIssueStateChange {
+		operate += c.IssueState + "："
+	}
+
+	return text[0], prefix + operate + " " + mdData + c.DiscussTitle + mdData
 }
 
 type AccessAddrCallback func(ctx context.Context, path string) (string, error)
@@ -18,6 +18,31 @@ const (
 	StatTypeKnowledgeHit
 )
 
+func (t StatType) Name() string {
+	switch t {
+	case StatTypeVisit:
+		return "访问"
+	case StatTypeSearch:
+		return "搜索"
+	case StatTypeBotUnknown:
+		return "AI 无法回答"
+	case StatTypeBotAccept:
+		return "AI 回答被采纳"
+	case StatTypeDiscussionQA:
+		return "问题"
+	case StatTypeDiscussionBlog:
+		return "文章"
+	case StatTypeDiscussionIssue:
+		return "Issue"
+	case StatTypeBotUnknownComment:
+		return "AI 无法回答评论"
+	case StatTypeKnowledgeHit:
+		return "知识命中"
+	default:
+		return "未知"
+	}
+}
+
 var DiscussionType2StatType = map[DiscussionType]StatType{
 	DiscussionTypeQA:    StatTypeDiscussionQA,
 	DiscussionTypeBlog:  StatTypeDiscussionBlog,
@@ -44,11 +69,36 @@ type StatTrendItem struct {
 	Count int64    `json:"count"`
 }
 
+type StatTrendDayItem struct {
+	StatTrendItem
+
+	Ts int64 `json:"ts"`
+}
+
 type StatTrend struct {
 	Ts    int64                  `json:"ts"`
 	Items JSONB[[]StatTrendItem] `json:"items" gorm:"type:jsonb"`
 }
 
+type StatBreakdown struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name" gorm:"-"`
+	Discussions int64  `json:"discussions"`
+	// BotUnknown 当前仍处于 AI 无法回答状态的帖子数
+	BotUnknown int64 `json:"bot_unknown"`
+	Accept     int64 `json:"accept"`
+	BotAccept  int64 `json:"bot_accept"`
+}
+
+// StatWidgetSite 网页挂件站点的提问统计
+type StatWidgetSite struct {
+	SiteID    uint   `json:"site_id"`
+	Name      string `json:"name" gorm:"-"`
+	Sessions  int64  `json:"sessions"`
+	Questions int64  `json:"questions"`
+	NeedHuman int64  `json:"need_human"`
+}
+
 type StatInvalidKnowledgeDoc struct {
 	Title        string    `json:"title"`
 	SpaceID      uint      `json:"space_id"`
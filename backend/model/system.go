@@ -32,6 +32,11 @@ const (
 	SystemKeyChatDingtalk     = "chat_dingtalk"
 	SystemKeyChatWecom        = "chat_wecom"
 	SystemKeyChatWecomService = "chat_webcom_service"
+	SystemKeyChatFeishu       = "chat_feishu"
+	SystemKeyChatSlack        = "chat_slack"
+	SystemKeyChatTeams        = "chat_teams"
+	SystemKeyUserPoint        = "user_point"
+	SystemKeyEmail            = "email"
 )
 
 type PublicAddress struct {
@@ -58,10 +63,14 @@ const (
 	AuthTypeWechat
 	AuthTypeDingtalk
 	AuthTypeAPIToken
+	AuthTypeSAML
+	AuthTypeLDAP
+	// AuthTypeSCIM 仅用于关联 SCIM 同步的用户，不能用于登录
+	AuthTypeSCIM
 )
 
 type AuthInfo struct {
-	Type           AuthType   `json:"type" binding:"min=1,max=4"`
+	Type           AuthType   `json:"type" binding:"oneof=1 2 3 4 7 8"`
 	Config         AuthConfig `json:"config"`
 	ButtonDesc     string     `json:"button_desc"`
 	EnableRegister bool       `json:"enable_register"`
@@ -76,6 +85,8 @@ type Auth struct {
 	// Deprecated: only use in migration
 	PublicForumIDs []uint     `json:"public_forum_ids"`
 	AuthInfos      []AuthInfo `json:"auth_infos" binding:"omitempty,dive"`
+	// RequireTOTP 管理员和运营必须开启两步验证后才能使用密码登录
+	RequireTOTP bool `json:"require_totp"`
 }
 
 func (a *Auth) CanRegister(typ AuthType) bool {
@@ -102,6 +113,8 @@ func (a *Auth) CanAuth(t AuthType) bool {
 
 type AuthConfig struct {
 	Oauth AuthConfigOauth `json:"oauth"`
+	SAML  AuthConfigSAML  `json:"saml"`
+	LDAP  AuthConfigLDAP  `json:"ldap"`
 }
 
 type AuthConfigOauth struct {
@@ -111,6 +124,51 @@ type AuthConfigOauth struct {
 	CorpID       string `json:"corp_id,omitempty"`
 }
 
+type AuthConfigSAML struct {
+	// IDPMetadataURL 和 IDPMetadata 二选一，优先使用 IDPMetadata
+	IDPMetadataURL    string                  `json:"idp_metadata_url,omitempty"`
+	IDPMetadata       string                  `json:"idp_metadata,omitempty"`
+	EntityID          string                  `json:"entity_id,omitempty"`
+	Certificate       string                  `json:"certificate,omitempty"`
+	PrivateKey        string                  `json:"private_key,omitempty"`
+	AllowIDPInitiated bool                    `json:"allow_idp_initiated"`
+	Attribute         AuthConfigSAMLAttribute `json:"attribute"`
+}
+
+type AuthConfigSAMLAttribute struct {
+	Email        string `json:"email,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Avatar       string `json:"avatar,omitempty"`
+	Role         string `json:"role,omitempty"`
+	Group        string `json:"group,omitempty"`
+	AdminRole    string `json:"admin_role,omitempty"`
+	OperatorRole string `json:"operator_role,omitempty"`
+}
+
+type AuthConfigLDAP struct {
+	// URL 例如 ldap://ldap.example.com:389 或 ldaps://ldap.example.com:636
+	URL                string `json:"url,omitempty"`
+	StartTLS           bool   `json:"start_tls"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	BindDN             string `json:"bind_dn,omitempty"`
+	BindPassword       string `json:"bind_password,omitempty"`
+	BaseDN             string `json:"base_dn,omitempty"`
+	// UserFilter 登录时查找用户的过滤条件，{username} 会被替换为登录名
+	UserFilter string `json:"user_filter,omitempty"`
+	// SyncFilter 定时同步时列出所有用户的过滤条件
+	SyncFilter string                  `json:"sync_filter,omitempty"`
+	Attribute  AuthConfigLDAPAttribute `json:"attribute"`
+}
+
+type AuthConfigLDAPAttribute struct {
+	ID     string `json:"id,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+	// Group 用户所属组的属性，值为组 DN 时取第一个 RDN 的值作为组织名称
+	Group string `json:"group,omitempty"`
+}
+
 type SystemBrand struct {
 	Logo  string `json:"logo"`
 	Text  string `json:"text"`
@@ -122,6 +180,36 @@ type SystemDiscussion struct {
 	ContentPlaceholder string `json:"content_placeholder"`
 }
 
+// SystemUserPoint 积分全局设置
+type SystemUserPoint struct {
+	// DailyCap 每人每天最多获得的积分，不包含悬赏，0 表示不限制
+	DailyCap int `json:"daily_cap" binding:"min=0"`
+}
+
+// SystemEmail 邮件网关设置，收到的新邮件在指定板块发布为问题
+type SystemEmail struct {
+	Enabled bool `json:"enabled"`
+	ForumID uint `json:"forum_id"`
+	// Secret 入站 webhook 密钥，MTA 投递时通过 secret 参数传入
+	Secret string `json:"secret"`
+	// AuthServID 受信任 MTA 写入 Authentication-Results 使用的 authserv-id，只认可该 MTA 的 SPF/DKIM 校验结果
+	AuthServID string `json:"authserv_id"`
+	// Name 回信的发件人名称
+	Name string          `json:"name"`
+	SMTP SystemEmailSMTP `json:"smtp"`
+}
+
+type SystemEmailSMTP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port" binding:"min=0,max=65535"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// From 发件地址，用户直接回复该地址即可继续追问
+	From string `json:"from" binding:"omitempty,email"`
+	// SSL 使用 465 端口等隐式 TLS 连接，否则在服务端支持时使用 STARTTLS
+	SSL bool `json:"ssl"`
+}
+
 type SystemSEO struct {
 	Desc     string   `json:"desc"`
 	Keywords []string `json:"keywords"`
@@ -157,6 +245,8 @@ type SystemChatConfig struct {
 	CorpID       string `json:"corp_id"`
 	Token        string `json:"client_token"`
 	AESKey       string `json:"aes_key"`
+	// Domain 飞书开放平台地址，为空时使用 https://open.feishu.cn，Lark 使用 https://open.larksuite.com
+	Domain string `json:"domain"`
 }
 
 type EnabledCallback func(context.Context) (bool, error)
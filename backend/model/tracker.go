@@ -0,0 +1,60 @@
+package model
+
+type TrackerType uint
+
+const (
+	TrackerTypeGithub TrackerType = iota + 1
+	TrackerTypeGitlab
+	TrackerTypeJira
+)
+
+// Tracker 论坛对接的外部 Issue 系统，每个论坛最多一个
+type Tracker struct {
+	Base
+
+	ForumID uint `json:"forum_id" gorm:"column:forum_id;type:bigint;uniqueIndex"`
+	Enabled bool `json:"enabled" gorm:"column:enabled;default:false"`
+	// AutoCreate 新建 Issue 时自动同步到外部系统
+	AutoCreate bool `json:"auto_create" gorm:"column:auto_create;default:false"`
+	TrackerConfig
+}
+
+type TrackerConfig struct {
+	Type TrackerType `json:"type" gorm:"column:type" binding:"required,min=1,max=3"`
+	// URL API 地址，如 https://api.github.com、https://gitlab.com、https://xxx.atlassian.net
+	URL string `json:"url" gorm:"column:url;type:text" binding:"required,http_url"`
+	// Project GitHub 为 owner/repo，GitLab 为项目 ID 或路径，Jira 为项目 Key
+	Project string `json:"project" gorm:"column:project;type:text" binding:"required"`
+	// Username Jira 账号邮箱，GitHub/GitLab 不需要
+	Username string `json:"username" gorm:"column:username;type:text"`
+	Token    string `json:"token" gorm:"column:token;type:text" binding:"required"`
+	// Secret 回调签名密钥
+	Secret string `json:"secret" gorm:"column:secret;type:text"`
+	// IssueType Jira 新建 Issue 的类型，默认 Task
+	IssueType string `json:"issue_type" gorm:"column:issue_type;type:text"`
+}
+
+// TrackerIssue KoalaQA Issue 与外部 Issue 的关联
+type TrackerIssue struct {
+	Base
+
+	TrackerID    uint   `json:"tracker_id" gorm:"column:tracker_id;type:bigint;uniqueIndex:udx_tracker_issue_external"`
+	DiscussionID uint   `json:"discussion_id" gorm:"column:discussion_id;type:bigint;uniqueIndex"`
+	ExternalID   string `json:"external_id" gorm:"column:external_id;type:text;uniqueIndex:udx_tracker_issue_external"`
+	URL          string `json:"url" gorm:"column:url;type:text"`
+}
+
+// TrackerComment 已同步的评论，CommentID 为 KoalaQA 评论
+type TrackerComment struct {
+	Base
+
+	TrackerIssueID uint   `json:"tracker_issue_id" gorm:"column:tracker_issue_id;type:bigint;uniqueIndex:udx_tracker_comment_external"`
+	CommentID      uint   `json:"comment_id" gorm:"column:comment_id;type:bigint;index"`
+	ExternalID     string `json:"external_id" gorm:"column:external_id;type:text;uniqueIndex:udx_tracker_comment_external"`
+}
+
+func init() {
+	registerAutoMigrate(&Tracker{})
+	registerAutoMigrate(&TrackerIssue{})
+	registerAutoMigrate(&TrackerComment{})
+}
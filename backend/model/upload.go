@@ -0,0 +1,24 @@
+package model
+
+import "github.com/chaitin/koalaqa/pkg/upload"
+
+// Upload 用户上传的附件，用于统计存储配额和清理未被引用的对象
+type Upload struct {
+	Base
+
+	UserID      uint         `json:"user_id" gorm:"column:user_id;type:bigint;index"`
+	Scene       upload.Scene `json:"scene" gorm:"column:scene"`
+	Path        string       `json:"path" gorm:"column:path;type:text;uniqueIndex"`
+	Size        int64        `json:"size" gorm:"column:size;type:bigint"`
+	ContentType string       `json:"content_type" gorm:"column:content_type;type:text"`
+}
+
+type UploadUsage struct {
+	Size  int64 `json:"size"`
+	Count int64 `json:"count"`
+	Today int64 `json:"today"`
+}
+
+func init() {
+	registerAutoMigrate(&Upload{})
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type UserRole uint
 
 const (
@@ -27,6 +29,8 @@ type UserCore struct {
 	Cors     bool     `json:"cors"`
 	Key      string   `json:"key"`
 	Salt     string   `json:"salt"`
+	// SessionID 登录会话 id，为 0 时是升级前签发的 token 或免登录用户
+	SessionID uint `json:"session_id"`
 }
 
 type UserBasic struct {
@@ -42,6 +46,11 @@ type UserBasic struct {
 	BlockUntil int64      `gorm:"column:block_until;type:bigint" json:"block_until"`
 }
 
+// Blocked 用户在 now 时是否处于封禁状态，BlockUntil 为 -1 时表示永久封禁
+func (ub UserBasic) Blocked(now time.Time) bool {
+	return ub.BlockUntil < 0 || ub.BlockUntil > now.Unix()
+}
+
 type UserInfo struct {
 	UserCore
 	UserBasic
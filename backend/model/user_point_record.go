@@ -15,9 +15,36 @@ const (
 	UserPointTypeUserRole
 	UserPointTypeUserAvatar
 	UserPointTypeUserIntro
+	// 悬赏积分随帖子设置，不在 UserPointTypePointM 中
+	UserPointTypeBountyEscrow // 发布悬赏冻结积分
+	UserPointTypeBountyAward  // 回答被采纳获得悬赏
+	UserPointTypeBountyRefund // 悬赏退回
 )
 
+// ForeignComment ForeignID 是否为评论 ID
+func (t UserPointType) ForeignComment() bool {
+	switch t {
+	case UserPointTypeAnswerAccepted, UserPointTypeAnswerLiked, UserPointTypeAnswerQA,
+		UserPointTypeAnswerDisliked, UserPointTypeDislikeAnswer:
+		return true
+	default:
+		return false
+	}
+}
+
+// ForeignDiscussion ForeignID 是否为帖子 ID
+func (t UserPointType) ForeignDiscussion() bool {
+	switch t {
+	case UserPointTypeCreateBlog, UserPointTypeLikeBlog, UserPointTypeAssociateIssue, UserPointTypeAcceptAnswer,
+		UserPointTypeBountyEscrow, UserPointTypeBountyAward, UserPointTypeBountyRefund:
+		return true
+	default:
+		return false
+	}
+}
+
 var (
+	// UserPointTypePointM 默认积分，可被 UserPointRule 覆盖
 	UserPointTypePointM = map[UserPointType]int{
 		UserPointTypeCreateBlog:     10,
 		UserPointTypeAnswerAccepted: 10,
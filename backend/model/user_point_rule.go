@@ -0,0 +1,17 @@
+package model
+
+// UserPointRule 积分规则，ForumID 为 0 时为全局规则，论坛规则优先于全局规则
+// 没有规则的类型使用 UserPointTypePointM 中的默认积分
+type UserPointRule struct {
+	Base
+
+	ForumID  uint          `json:"forum_id" gorm:"column:forum_id;type:bigint;default:0;uniqueIndex:udx_user_point_rule_forum_type"`
+	Type     UserPointType `json:"type" gorm:"column:type;uniqueIndex:udx_user_point_rule_forum_type"`
+	Point    int           `json:"point" gorm:"column:point;type:bigint;default:0"`
+	DailyCap int           `json:"daily_cap" gorm:"column:daily_cap;type:bigint;default:0"` // 每人每天通过该行为最多获得的积分，0 表示不限制
+	Disabled bool          `json:"disabled" gorm:"column:disabled;default:false"`
+}
+
+func init() {
+	registerAutoMigrate(&UserPointRule{})
+}
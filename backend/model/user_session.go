@@ -0,0 +1,25 @@
+package model
+
+type UserSession struct {
+	Base
+
+	UserID    uint      `gorm:"column:user_id;index" json:"user_id"`
+	AuthType  AuthType  `gorm:"column:auth_type" json:"auth_type"`
+	Cors      bool      `gorm:"column:cors" json:"cors"`
+	Device    string    `gorm:"column:device;type:text" json:"device"`
+	IP        string    `gorm:"column:ip;type:text" json:"ip"`
+	UserAgent string    `gorm:"column:user_agent;type:text" json:"user_agent"`
+	LastSeen  Timestamp `gorm:"column:last_seen;type:timestamp with time zone" json:"last_seen"`
+	ExpireAt  Timestamp `gorm:"column:expire_at;type:timestamp with time zone;index" json:"expire_at"`
+	// Key 创建会话时用户的 key，用户 key 变更后会话失效
+	Key string `gorm:"column:key;type:text" json:"-"`
+	// RefreshHash 当前 refresh token 的 sha256
+	RefreshHash string `gorm:"column:refresh_hash;type:text;uniqueIndex" json:"-"`
+	// PrevRefreshHash 上一个 refresh token，用于识别 refresh token 被重复使用
+	PrevRefreshHash string    `gorm:"column:prev_refresh_hash;type:text;index" json:"-"`
+	RotatedAt       Timestamp `gorm:"column:rotated_at;type:timestamp with time zone" json:"-"`
+}
+
+func init() {
+	registerAutoMigrate(&UserSession{})
+}
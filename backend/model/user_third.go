@@ -6,6 +6,12 @@ type UserThird struct {
 	UserID  uint     `gorm:"column:user_id;uniqueIndex:udx_user_thirds_user_third_type"`
 	ThirdID string   `gorm:"column:third_id;type:text;uniqueIndex:udx_user_thirds_user_third_type"`
 	Type    AuthType `gorm:"column:type;uniqueIndex:udx_user_thirds_user_third_type"`
+	// ExternalID SCIM 中 IdP 侧的用户 id
+	ExternalID string `gorm:"column:external_id;type:text"`
+	// OrgIDs 由第三方分组同步加入的组织，同步时只增删这部分组织
+	OrgIDs Int64Array `gorm:"column:org_ids;type:bigint[]"`
+	// SyncBlocked 用户因不在目录中被同步禁用，重新出现时自动解除
+	SyncBlocked bool `gorm:"column:sync_blocked;default:false"`
 }
 
 func init() {
@@ -0,0 +1,17 @@
+package model
+
+type UserTOTP struct {
+	Base
+
+	UserID  uint   `gorm:"column:user_id;uniqueIndex" json:"user_id"`
+	Secret  string `gorm:"column:secret;type:text" json:"-"`
+	Enabled bool   `gorm:"column:enabled" json:"enabled"`
+	// LastCounter 最近一次使用的验证码计数器，防止验证码重放
+	LastCounter int64 `gorm:"column:last_counter" json:"-"`
+	// RecoveryCodes 恢复码的 sha256，使用后删除
+	RecoveryCodes StringArray `gorm:"column:recovery_codes;type:text[]" json:"-"`
+}
+
+func init() {
+	registerAutoMigrate(&UserTOTP{})
+}
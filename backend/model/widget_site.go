@@ -0,0 +1,44 @@
+package model
+
+// WidgetSite 网页挂件站点，每个嵌入挂件的产品站点使用独立的 site key 和配置
+type WidgetSite struct {
+	Base
+
+	Name    string `json:"name" gorm:"column:name;type:text"`
+	SiteKey string `json:"site_key" gorm:"column:site_key;type:text;uniqueIndex"`
+	Enabled bool   `json:"enabled" gorm:"column:enabled;default:false"`
+	// AllowedOrigins 允许嵌入的来源，例如 https://example.com，* 表示不限制
+	AllowedOrigins StringArray `json:"allowed_origins" gorm:"column:allowed_origins;type:text[]"`
+	// ForumIDs 检索使用的板块范围，为空时使用默认组织的板块
+	ForumIDs Int64Array `json:"forum_ids" gorm:"column:forum_ids;type:bigint[]"`
+	// GroupIDs 检索使用的分类范围，为空时不限制
+	GroupIDs         Int64Array             `json:"group_ids" gorm:"column:group_ids;type:bigint[]"`
+	Brand            JSONB[WidgetSiteBrand] `json:"brand" gorm:"column:brand;type:jsonb"`
+	QuestionType     SuggestQuestionType    `json:"question_type" gorm:"column:question_type;default:0"`
+	SuggestQuestions StringArray            `json:"suggest_questions" gorm:"column:suggest_questions;type:text[]"`
+}
+
+type WidgetSiteBrand struct {
+	Title       string `json:"title"`
+	Logo        string `json:"logo"`
+	ThemeColor  string `json:"theme_color"`
+	Welcome     string `json:"welcome"`
+	Placeholder string `json:"placeholder"`
+}
+
+func (s *WidgetSite) GetID() uint {
+	if s == nil {
+		return 0
+	}
+
+	return s.ID
+}
+
+// Scoped 是否限制了检索范围
+func (s *WidgetSite) Scoped() bool {
+	return s != nil && (len(s.ForumIDs) > 0 || len(s.GroupIDs) > 0)
+}
+
+func init() {
+	registerAutoMigrate(&WidgetSite{})
+}
@@ -10,7 +10,10 @@ import (
 type Cache[T any] interface {
 	SetTTL(key string, value T, dur time.Duration) error
 	Set(key string, value T) error
+	// Add key 不存在时写入，返回 false 表示 key 已存在
+	Add(key string, value T) (bool, error)
 	Get(key string) (T, bool)
+	Delete(key string)
 	Range(fn func(key string, value T) bool)
 	Renewal(key string) error
 }
@@ -38,6 +41,10 @@ func (c *cache[T]) Set(key string, value T) error {
 	return nil
 }
 
+func (c *cache[T]) Add(key string, value T) (bool, error) {
+	return c.store.Add(key, value, c.dur) == nil, nil
+}
+
 func (c *cache[T]) Get(key string) (T, bool) {
 	var zero T
 
@@ -53,6 +60,10 @@ func (c *cache[T]) Get(key string) (T, bool) {
 	return zero, false
 }
 
+func (c *cache[T]) Delete(key string) {
+	c.store.Delete(key)
+}
+
 func (c *cache[T]) Range(fn func(key string, value T) bool) {
 	items := c.store.Items()
 	for key, item := range items {
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/fx"
+)
+
+type backendIn struct {
+	fx.In
+
+	LC   fx.Lifecycle
+	Cfg  config.Config
+	DB   *database.DB
+	Conn *nats.Conn
+}
+
+type backendOut struct {
+	fx.Out
+
+	Backend     Backend
+	Broadcaster Broadcaster
+}
+
+func clearLoop(ctx context.Context, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(time.Minute * 10)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
+
+func newBackend(in backendIn) (backendOut, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in.LC.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+
+	switch in.Cfg.Cache.Type {
+	case "", "memory":
+		return backendOut{Backend: memoryBackend{}, Broadcaster: memoryBackend{}}, nil
+	case "nats":
+		backend, err := newNatsBackend(ctx, in.Conn)
+		if err != nil {
+			cancel()
+			return backendOut{}, err
+		}
+
+		go clearLoop(ctx, backend.clearExpired)
+		return backendOut{Backend: backend, Broadcaster: backend}, nil
+	case "postgres":
+		backend, err := newPGBackend(in.DB, in.Cfg.DB.DSN)
+		if err != nil {
+			cancel()
+			return backendOut{}, err
+		}
+
+		go clearLoop(ctx, backend.clearExpired)
+		go backend.listenLoop(ctx)
+		return backendOut{Backend: backend, Broadcaster: backend}, nil
+	default:
+		cancel()
+		return backendOut{}, fmt.Errorf("unsupported cache type: %s", in.Cfg.Cache.Type)
+	}
+}
+
+var Module = fx.Options(
+	fx.Provide(newBackend),
+)
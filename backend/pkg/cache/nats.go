@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/trace"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsCacheBucket       = "koala_cache"
+	natsInvalidateSubject = "koala.cache.invalidate"
+)
+
+type natsEntry struct {
+	Value    []byte `json:"v"`
+	ExpireAt int64  `json:"e"`
+}
+
+func (e natsEntry) expired() bool {
+	return e.ExpireAt > 0 && time.Now().UnixNano() > e.ExpireAt
+}
+
+type invalidateMsg struct {
+	Topic    string `json:"topic"`
+	Instance string `json:"instance"`
+}
+
+type natsBackend struct {
+	conn     *nats.Conn
+	kv       jetstream.KeyValue
+	instance string
+	logger   *glog.Logger
+
+	lock     sync.RWMutex
+	handlers map[string][]func(ctx context.Context)
+}
+
+// kv key 只允许部分字符，统一编码
+func natsKey(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func (n *natsBackend) get(ctx context.Context, key string) (jetstream.KeyValueEntry, *natsEntry, error) {
+	entry, err := n.kv.Get(ctx, natsKey(key))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	var data natsEntry
+	err = json.Unmarshal(entry.Value(), &data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if data.expired() {
+		return entry, nil, nil
+	}
+
+	return entry, &data, nil
+}
+
+func (n *natsBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	_, data, err := n.get(ctx, key)
+	if err != nil || data == nil {
+		return nil, false, err
+	}
+
+	return data.Value, true, nil
+}
+
+func (n *natsBackend) Set(ctx context.Context, key string, value []byte, dur time.Duration) error {
+	data := natsEntry{Value: value}
+	if dur > 0 {
+		data.ExpireAt = time.Now().Add(dur).UnixNano()
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.kv.Put(ctx, natsKey(key), raw)
+	return err
+}
+
+func (n *natsBackend) Add(ctx context.Context, key string, value []byte, dur time.Duration) (bool, error) {
+	data := natsEntry{Value: value}
+	if dur > 0 {
+		data.ExpireAt = time.Now().Add(dur).UnixNano()
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = n.kv.Create(ctx, natsKey(key), raw)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, jetstream.ErrKeyExists) {
+		return false, err
+	}
+
+	// 已过期但未被清理的 key 视为不存在，按版本号更新避免并发写入
+	entry, existing, err := n.get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		return false, nil
+	}
+	if entry == nil {
+		_, err = n.kv.Create(ctx, natsKey(key), raw)
+	} else {
+		_, err = n.kv.Update(ctx, entry.Key(), raw, entry.Revision())
+	}
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyExists) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (n *natsBackend) Delete(ctx context.Context, key string) error {
+	err := n.kv.Delete(ctx, natsKey(key))
+	if err != nil && !errors.Is(err, jetstream.ErrKeyNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (n *natsBackend) Expire(ctx context.Context, key string, dur time.Duration) error {
+	entry, data, err := n.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return errors.New("key not found")
+	}
+
+	data.ExpireAt = time.Now().Add(dur).UnixNano()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.kv.Update(ctx, entry.Key(), raw, entry.Revision())
+	return err
+}
+
+func (n *natsBackend) Range(ctx context.Context, prefix string, fn func(key string, value []byte) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lister, err := n.kv.ListKeys(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return nil
+		}
+		return err
+	}
+	defer lister.Stop()
+
+	for encoded := range lister.Keys() {
+		raw, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+
+		key := string(raw)
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		value, ok, err := n.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if !fn(key, value) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// clearExpired kv 不支持单个 key 的过期时间，定期清理已过期的数据
+func (n *natsBackend) clearExpired(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lister, err := n.kv.ListKeys(ctx)
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrNoKeysFound) {
+			n.logger.WithContext(ctx).WithErr(err).Warn("list cache keys failed")
+		}
+		return
+	}
+	defer lister.Stop()
+
+	for encoded := range lister.Keys() {
+		entry, err := n.kv.Get(ctx, encoded)
+		if err != nil {
+			continue
+		}
+
+		var data natsEntry
+		if json.Unmarshal(entry.Value(), &data) == nil && !data.expired() {
+			continue
+		}
+
+		err = n.kv.Delete(ctx, encoded, jetstream.LastRevision(entry.Revision()))
+		if err != nil && !errors.Is(err, jetstream.ErrKeyExists) {
+			n.logger.WithContext(ctx).WithErr(err).Warn("delete expired cache failed")
+		}
+	}
+}
+
+func (n *natsBackend) Broadcast(ctx context.Context, topic string) error {
+	raw, err := json.Marshal(invalidateMsg{Topic: topic, Instance: n.instance})
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(natsInvalidateSubject)
+	msg.Header["X-Trace-ID"] = trace.TraceID(ctx)
+	msg.Data = raw
+
+	return n.conn.PublishMsg(msg)
+}
+
+func (n *natsBackend) Subscribe(topic string, fn func(ctx context.Context)) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.handlers[topic] = append(n.handlers[topic], fn)
+}
+
+func (n *natsBackend) handle(msg *nats.Msg) {
+	var data invalidateMsg
+	err := json.Unmarshal(msg.Data, &data)
+	if err != nil {
+		n.logger.WithErr(err).Warn("unmarshal invalidate message failed")
+		return
+	}
+
+	if data.Instance == n.instance {
+		return
+	}
+
+	n.lock.RLock()
+	handlers := n.handlers[data.Topic]
+	n.lock.RUnlock()
+
+	ctx := trace.Context(context.Background(), msg.Header.Values("X-Trace-ID")...)
+	n.logger.WithContext(ctx).With("topic", data.Topic).Debug("receive cache invalidate")
+	for _, handler := range handlers {
+		handler(ctx)
+	}
+}
+
+func newNatsBackend(ctx context.Context, conn *nats.Conn) (*natsBackend, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      natsCacheBucket,
+		Description: "koala shared cache",
+		History:     1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backend := &natsBackend{
+		conn:     conn,
+		kv:       kv,
+		instance: uuid.NewString(),
+		logger:   glog.Module("cache", "nats"),
+		handlers: make(map[string][]func(ctx context.Context)),
+	}
+
+	// 不使用队列组，每个副本都能收到
+	_, err = conn.Subscribe(natsInvalidateSubject, backend.handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend, nil
+}
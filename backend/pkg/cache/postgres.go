@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm/clause"
+)
+
+const pgInvalidateChannel = "koala_cache_invalidate"
+
+type cacheEntry struct {
+	Key      string    `gorm:"column:key;type:text;primaryKey"`
+	Value    []byte    `gorm:"column:value;type:bytea"`
+	ExpireAt time.Time `gorm:"column:expire_at;type:timestamp with time zone;index"`
+}
+
+func (cacheEntry) TableName() string {
+	return "cache_entries"
+}
+
+type pgBackend struct {
+	db       *database.DB
+	dsn      string
+	instance string
+	logger   *glog.Logger
+
+	lock     sync.RWMutex
+	handlers map[string][]func(ctx context.Context)
+}
+
+func (p *pgBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var entries []cacheEntry
+	err := p.db.WithContext(ctx).Where("key = ? AND expire_at > now()", key).Limit(1).Find(&entries).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(entries) == 0 {
+		return nil, false, nil
+	}
+
+	return entries[0].Value, true, nil
+}
+
+func (p *pgBackend) Set(ctx context.Context, key string, value []byte, dur time.Duration) error {
+	expireAt := time.Now().Add(dur)
+	if dur <= 0 {
+		expireAt = time.Now().AddDate(100, 0, 0)
+	}
+
+	return p.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expire_at"}),
+	}).Create(&cacheEntry{
+		Key:      key,
+		Value:    value,
+		ExpireAt: expireAt,
+	}).Error
+}
+
+func (p *pgBackend) Add(ctx context.Context, key string, value []byte, dur time.Duration) (bool, error) {
+	expireAt := time.Now().Add(dur)
+	if dur <= 0 {
+		expireAt = time.Now().AddDate(100, 0, 0)
+	}
+
+	// 已过期但未被清理的 key 视为不存在
+	res := p.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expire_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "cache_entries.expire_at <= now()"}}},
+	}).Create(&cacheEntry{
+		Key:      key,
+		Value:    value,
+		ExpireAt: expireAt,
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (p *pgBackend) Delete(ctx context.Context, key string) error {
+	return p.db.WithContext(ctx).Where("key = ?", key).Delete(&cacheEntry{}).Error
+}
+
+func (p *pgBackend) Expire(ctx context.Context, key string, dur time.Duration) error {
+	res := p.db.WithContext(ctx).Model(&cacheEntry{}).
+		Where("key = ? AND expire_at > now()", key).
+		Update("expire_at", time.Now().Add(dur))
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.New("key not found")
+	}
+
+	return nil
+}
+
+func (p *pgBackend) Range(ctx context.Context, prefix string, fn func(key string, value []byte) bool) error {
+	rows, err := p.db.WithContext(ctx).Model(&cacheEntry{}).
+		Where("starts_with(key, ?) AND expire_at > now()", prefix).
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry cacheEntry
+		err = p.db.ScanRows(rows, &entry)
+		if err != nil {
+			return err
+		}
+
+		if !fn(entry.Key, entry.Value) {
+			return nil
+		}
+	}
+
+	return rows.Err()
+}
+
+func (p *pgBackend) clearExpired(ctx context.Context) {
+	err := p.db.WithContext(ctx).Where("expire_at <= now()").Delete(&cacheEntry{}).Error
+	if err != nil {
+		p.logger.WithContext(ctx).WithErr(err).Warn("delete expired cache failed")
+	}
+}
+
+func (p *pgBackend) Broadcast(ctx context.Context, topic string) error {
+	raw, err := json.Marshal(invalidateMsg{Topic: topic, Instance: p.instance})
+	if err != nil {
+		return err
+	}
+
+	return p.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", pgInvalidateChannel, string(raw)).Error
+}
+
+func (p *pgBackend) Subscribe(topic string, fn func(ctx context.Context)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.handlers[topic] = append(p.handlers[topic], fn)
+}
+
+func (p *pgBackend) handle(ctx context.Context, payload string) {
+	var data invalidateMsg
+	err := json.Unmarshal([]byte(payload), &data)
+	if err != nil {
+		p.logger.WithContext(ctx).WithErr(err).Warn("unmarshal invalidate message failed")
+		return
+	}
+
+	if data.Instance == p.instance {
+		return
+	}
+
+	p.lock.RLock()
+	handlers := p.handlers[data.Topic]
+	p.lock.RUnlock()
+
+	p.logger.WithContext(ctx).With("topic", data.Topic).Debug("receive cache invalidate")
+	for _, handler := range handlers {
+		handler(ctx)
+	}
+}
+
+func (p *pgBackend) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, p.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{pgInvalidateChannel}.Sanitize())
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		p.handle(ctx, notification.Payload)
+	}
+}
+
+// listenLoop LISTEN 需要独占连接，断开后重连
+func (p *pgBackend) listenLoop(ctx context.Context) {
+	for {
+		err := p.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.logger.WithContext(ctx).WithErr(err).Warn("listen cache invalidate failed, retry later")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second * 5):
+		}
+	}
+}
+
+func newPGBackend(db *database.DB, dsn string) (*pgBackend, error) {
+	err := db.AutoMigrate(&cacheEntry{})
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(dsn) == "" {
+		return nil, errors.New("empty postgres dsn")
+	}
+
+	return &pgBackend{
+		db:       db,
+		dsn:      dsn,
+		instance: uuid.NewString(),
+		logger:   glog.Module("cache", "postgres"),
+		handlers: make(map[string][]func(ctx context.Context)),
+	}, nil
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+)
+
+const opTimeout = time.Second * 3
+
+// Backend 多副本共享的缓存存储
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, dur time.Duration) error
+	// Add key 不存在或已过期时写入，返回 false 表示 key 已存在
+	Add(ctx context.Context, key string, value []byte, dur time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+	Expire(ctx context.Context, key string, dur time.Duration) error
+	Range(ctx context.Context, prefix string, fn func(key string, value []byte) bool) error
+}
+
+// Broadcaster 写入后通知其他副本丢弃进程内缓存，不会通知到自身
+type Broadcaster interface {
+	Broadcast(ctx context.Context, topic string) error
+	Subscribe(topic string, fn func(ctx context.Context))
+}
+
+type memoryBackend struct{}
+
+func (memoryBackend) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.ErrUnsupported
+}
+
+func (memoryBackend) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.ErrUnsupported
+}
+
+func (memoryBackend) Add(context.Context, string, []byte, time.Duration) (bool, error) {
+	return false, errors.ErrUnsupported
+}
+
+func (memoryBackend) Delete(context.Context, string) error {
+	return errors.ErrUnsupported
+}
+
+func (memoryBackend) Expire(context.Context, string, time.Duration) error {
+	return errors.ErrUnsupported
+}
+
+func (memoryBackend) Range(context.Context, string, func(string, []byte) bool) error {
+	return errors.ErrUnsupported
+}
+
+// Broadcast 单副本部署，写入方已经更新了自身缓存，无需通知
+func (memoryBackend) Broadcast(context.Context, string) error {
+	return nil
+}
+
+func (memoryBackend) Subscribe(string, func(context.Context)) {}
+
+type shared[T any] struct {
+	backend Backend
+	prefix  string
+	dur     time.Duration
+	logger  *glog.Logger
+}
+
+// NewShared 创建副本间共享的缓存，name 用于区分不同缓存的 key，backend 为 memory 时退化为进程内缓存
+func NewShared[T any](backend Backend, name string, dur time.Duration) Cache[T] {
+	if _, ok := backend.(memoryBackend); ok || backend == nil {
+		return New[T](dur)
+	}
+
+	return &shared[T]{
+		backend: backend,
+		prefix:  name + ":",
+		dur:     dur,
+		logger:  glog.Module("cache", name),
+	}
+}
+
+func (s *shared[T]) SetTTL(key string, value T, dur time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	return s.backend.Set(ctx, s.prefix+key, data, dur)
+}
+
+func (s *shared[T]) Set(key string, value T) error {
+	return s.SetTTL(key, value, s.dur)
+}
+
+func (s *shared[T]) Add(key string, value T) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	return s.backend.Add(ctx, s.prefix+key, data, s.dur)
+}
+
+func (s *shared[T]) Get(key string) (T, bool) {
+	var res T
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	data, ok, err := s.backend.Get(ctx, s.prefix+key)
+	if err != nil {
+		s.logger.WithErr(err).With("key", key).Warn("get cache failed")
+		return res, false
+	}
+	if !ok {
+		return res, false
+	}
+
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		s.logger.WithErr(err).With("key", key).Warn("unmarshal cache failed")
+		return res, false
+	}
+
+	return res, true
+}
+
+func (s *shared[T]) Delete(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	err := s.backend.Delete(ctx, s.prefix+key)
+	if err != nil {
+		s.logger.WithErr(err).With("key", key).Warn("delete cache failed")
+	}
+}
+
+func (s *shared[T]) Range(fn func(key string, value T) bool) {
+	err := s.backend.Range(context.Background(), s.prefix, func(key string, data []byte) bool {
+		var value T
+		if err := json.Unmarshal(data, &value); err != nil {
+			return true
+		}
+
+		return fn(strings.TrimPrefix(key, s.prefix), value)
+	})
+	if err != nil {
+		s.logger.WithErr(err).Warn("range cache failed")
+	}
+}
+
+func (s *shared[T]) Renewal(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	return s.backend.Expire(ctx, s.prefix+key, s.dur)
+}
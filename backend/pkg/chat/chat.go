@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
 	"github.com/chaitin/koalaqa/pkg/llm"
 	"golang.org/x/sync/singleflight"
 )
@@ -84,6 +85,53 @@ func newStateManager() *StateManager {
 	}
 }
 
+// streamUpdate 读取回答流，每 2 秒全量更新一次消息，结束后再更新最终内容
+func streamUpdate(ctx context.Context, logger *glog.Logger, stream *llm.Stream[string], prefix string, update func(content string) error) {
+	var builder strings.Builder
+	builder.WriteString(prefix)
+	cur := time.Now()
+	stream.Read(ctx, func(msg string) {
+		if msg == "" {
+			return
+		}
+
+		builder.WriteString(msg)
+
+		now := time.Now()
+		if now.Before(cur.Add(time.Second * 2)) {
+			return
+		}
+
+		cur = now
+		err := update(builder.String())
+		if err != nil {
+			logger.WithErr(err).Warn("update stream message failed")
+		}
+	})
+
+	err := update(builder.String())
+	if err != nil {
+		logger.WithErr(err).Warn("finish stream message failed")
+	}
+}
+
+// trimAskCommand 去掉消息开头的 /koala ask 命令前缀
+func trimAskCommand(text string) string {
+	text = strings.TrimSpace(text)
+	for _, prefix := range []string{"/koala", "koala"} {
+		if len(text) <= len(prefix) || !strings.EqualFold(text[:len(prefix)], prefix) {
+			continue
+		}
+
+		rest := strings.TrimSpace(text[len(prefix):])
+		if len(rest) >= 3 && strings.EqualFold(rest[:3], "ask") && (len(rest) == 3 || rest[3] == ' ') {
+			return strings.TrimSpace(rest[3:])
+		}
+	}
+
+	return text
+}
+
 type Type uint
 
 const (
@@ -92,6 +140,9 @@ const (
 	TypeWecom
 	TypeWecomIntelligent
 	TypeWecomService
+	TypeFeishu
+	TypeSlack
+	TypeTeams
 )
 
 type BotReq struct {
@@ -113,6 +164,8 @@ type VerifyReq struct {
 
 	Content    string
 	OnlyVerify bool
+	// Command 是否为斜杠命令请求
+	Command bool
 }
 
 type Bot interface {
@@ -132,6 +185,12 @@ func New(typ Type, cfg model.SystemChatConfig, callback BotCallback,
 		return newWecomIntelligent(cfg, callback)
 	case TypeWecomService:
 		return newWecomService(cfg, callback, accessAddrCallback, enabledCallback)
+	case TypeFeishu:
+		return newFeishu(cfg, callback)
+	case TypeSlack:
+		return newSlack(cfg, callback)
+	case TypeTeams:
+		return newTeams(cfg, callback)
 	default:
 		return nil, errors.ErrUnsupported
 	}
@@ -0,0 +1,35 @@
+package chat
+
+import (
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/cache"
+	"github.com/chaitin/koalaqa/pkg/glog"
+)
+
+// dedupBackend 由 fx 注入，未注入时（如单元测试）退化为进程内去重
+var dedupBackend cache.Backend
+
+// eventDedup 开放平台未及时收到响应时会重推事件，按事件 id 去重，多副本部署时通过共享缓存去重
+type eventDedup struct {
+	events cache.Cache[bool]
+	logger *glog.Logger
+}
+
+func newEventDedup(name string, expire time.Duration) *eventDedup {
+	return &eventDedup{
+		events: cache.NewShared[bool](dedupBackend, "chat_event_"+name, expire),
+		logger: glog.Module("chat", name),
+	}
+}
+
+// duplicated 共享缓存不可用时不去重，避免丢失消息
+func (d *eventDedup) duplicated(eventID string) bool {
+	added, err := d.events.Add(eventID, true)
+	if err != nil {
+		d.logger.WithErr(err).With("event_id", eventID).Warn("dedup event failed")
+		return false
+	}
+
+	return !added
+}
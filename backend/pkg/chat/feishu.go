@@ -0,0 +1,536 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/util"
+)
+
+const (
+	feishuDefaultDomain = "https://open.feishu.cn"
+	feishuEventExpire   = time.Hour
+	// feishuSignExpire 签名时间戳的有效期，超过后视为重放
+	feishuSignExpire = 5 * time.Minute
+)
+
+type feishuResp struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+type feishuAccessToken struct {
+	Code              int    `json:"code"`
+	Msg               string `json:"msg"`
+	TenantAccessToken string `json:"tenant_access_token"`
+	Expire            int64  `json:"expire"`
+}
+
+type feishuBotInfo struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Bot  struct {
+		OpenID string `json:"open_id"`
+	} `json:"bot"`
+}
+
+type feishuEncrypt struct {
+	Encrypt string `json:"encrypt"`
+}
+
+type feishuCallback struct {
+	// url_verification
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+
+	Schema string `json:"schema"`
+	Header struct {
+		EventID   string `json:"event_id"`
+		EventType string `json:"event_type"`
+		Token     string `json:"token"`
+		AppID     string `json:"app_id"`
+	} `json:"header"`
+	Event json.RawMessage `json:"event"`
+}
+
+type feishuMention struct {
+	Key string `json:"key"`
+	ID  struct {
+		OpenID string `json:"open_id"`
+	} `json:"id"`
+	Name string `json:"name"`
+}
+
+type feishuMessageEvent struct {
+	Sender struct {
+		SenderID struct {
+			OpenID string `json:"open_id"`
+		} `json:"sender_id"`
+		SenderType string `json:"sender_type"`
+	} `json:"sender"`
+	Message struct {
+		MessageID   string          `json:"message_id"`
+		ChatID      string          `json:"chat_id"`
+		ChatType    string          `json:"chat_type"`
+		MessageType string          `json:"message_type"`
+		Content     string          `json:"content"`
+		Mentions    []feishuMention `json:"mentions"`
+	} `json:"message"`
+}
+
+type feishu struct {
+	cfg         model.SystemChatConfig
+	botCallback BotCallback
+
+	logger     *glog.Logger
+	domain     string
+	tokenCache token
+	botOpenID  string
+	events     *eventDedup
+}
+
+func (f *feishu) accessToken() (string, error) {
+	if f.tokenCache.expired() {
+		_, err, _ := sf.Do("access_token_feishu", func() (interface{}, error) {
+			if !f.tokenCache.expired() {
+				return nil, nil
+			}
+
+			body, err := json.Marshal(map[string]string{
+				"app_id":     f.cfg.ClientID,
+				"app_secret": f.cfg.ClientSecret,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := util.HTTPClient.Post(f.domain+"/open-apis/auth/v3/tenant_access_token/internal",
+				"application/json; charset=utf-8", bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			var tokenResp feishuAccessToken
+			err = json.NewDecoder(resp.Body).Decode(&tokenResp)
+			if err != nil {
+				return nil, err
+			}
+
+			if tokenResp.Code != 0 {
+				return nil, fmt.Errorf("get feishu access token failed, code: %d, msg: %s", tokenResp.Code, tokenResp.Msg)
+			}
+
+			f.tokenCache = token{
+				token:    tokenResp.TenantAccessToken,
+				expireAt: time.Now().Add(time.Duration(tokenResp.Expire) * time.Second),
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return f.tokenCache.token, nil
+}
+
+func (f *feishu) request(ctx context.Context, method string, path string, body any, res any) error {
+	accessToken, err := f.accessToken()
+	if err != nil {
+		return err
+	}
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, f.domain+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := util.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var ret feishuResp
+	err = json.NewDecoder(resp.Body).Decode(&ret)
+	if err != nil {
+		return err
+	}
+
+	if ret.Code != 0 {
+		return fmt.Errorf("feishu request %s failed, code: %d, msg: %s", path, ret.Code, ret.Msg)
+	}
+
+	if res == nil || len(ret.Data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(ret.Data, res)
+}
+
+func (f *feishu) getBotOpenID(ctx context.Context) (string, error) {
+	if f.botOpenID != "" {
+		return f.botOpenID, nil
+	}
+
+	accessToken, err := f.accessToken()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.domain+"/open-apis/bot/v3/info", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := util.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var info feishuBotInfo
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	if err != nil {
+		return "", err
+	}
+
+	if info.Code != 0 {
+		return "", fmt.Errorf("get feishu bot info failed, code: %d, msg: %s", info.Code, info.Msg)
+	}
+
+	f.botOpenID = info.Bot.OpenID
+	return f.botOpenID, nil
+}
+
+func feishuCard(content string) (string, error) {
+	card := map[string]any{
+		"config": map[string]any{
+			"wide_screen_mode": true,
+			"update_multi":     true,
+		},
+		"elements": []map[string]any{
+			{
+				"tag":     "markdown",
+				"content": content,
+			},
+		},
+	}
+
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (f *feishu) replyCard(ctx context.Context, messageID string, content string) (string, error) {
+	card, err := feishuCard(content)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		MessageID string `json:"message_id"`
+	}
+	err = f.request(ctx, http.MethodPost, fmt.Sprintf("/open-apis/im/v1/messages/%s/reply", messageID), map[string]any{
+		"msg_type": "interactive",
+		"content":  card,
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return res.MessageID, nil
+}
+
+func (f *feishu) updateCard(ctx context.Context, messageID string, content string) error {
+	card, err := feishuCard(content)
+	if err != nil {
+		return err
+	}
+
+	return f.request(ctx, http.MethodPatch, "/open-apis/im/v1/messages/"+messageID, map[string]any{
+		"content": card,
+	}, nil)
+}
+
+func (f *feishu) decrypt(encrypt string) ([]byte, error) {
+	if f.cfg.AESKey == "" {
+		return nil, errors.New("empty feishu encrypt key")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid feishu encrypt data")
+	}
+
+	key := sha256.Sum256([]byte(f.cfg.AESKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	iv := data[:aes.BlockSize]
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data[aes.BlockSize:])
+
+	if len(plain) == 0 {
+		return nil, errors.New("empty feishu decrypt data")
+	}
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(plain) {
+		return nil, errors.New("invalid feishu decrypt padding")
+	}
+
+	return plain[:len(plain)-padding], nil
+}
+
+func (f *feishu) verifySign(req VerifyReq) bool {
+	h := sha256.New()
+	h.Write([]byte(req.Timestamp + req.Nonce + f.cfg.AESKey + req.Content))
+	sign := hex.EncodeToString(h.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(sign), []byte(req.MsgSignature)) == 1
+}
+
+func (f *feishu) StreamText(ctx context.Context, req VerifyReq) (string, error) {
+	logger := f.logger.WithContext(ctx)
+
+	// 配置了 Encrypt Key 时飞书会对事件签名，除 url 校验外必须校验签名和时间戳
+	signed := f.cfg.AESKey != "" && req.MsgSignature != ""
+	if signed {
+		if !f.verifySign(req) {
+			err := errors.New("invalid feishu signature")
+			logger.WithErr(err).With("req", req.VerifySign).Error("verify failed")
+			return "", err
+		}
+
+		ts, err := strconv.ParseInt(req.Timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(ts, 0)).Abs() > feishuSignExpire {
+			err = errors.New("feishu signature expired")
+			logger.WithErr(err).With("timestamp", req.Timestamp).Error("verify failed")
+			return "", err
+		}
+	}
+
+	body := []byte(req.Content)
+
+	var encrypt feishuEncrypt
+	err := json.Unmarshal(body, &encrypt)
+	if err != nil {
+		logger.WithErr(err).Error("unmarshal feishu callback failed")
+		return "", err
+	}
+
+	if encrypt.Encrypt != "" {
+		body, err = f.decrypt(encrypt.Encrypt)
+		if err != nil {
+			logger.WithErr(err).Error("decrypt feishu callback failed")
+			return "", err
+		}
+	}
+
+	var callback feishuCallback
+	err = json.Unmarshal(body, &callback)
+	if err != nil {
+		logger.WithErr(err).Error("unmarshal feishu callback failed")
+		return "", err
+	}
+
+	verifyToken := callback.Token
+	if verifyToken == "" {
+		verifyToken = callback.Header.Token
+	}
+	if f.cfg.Token != "" && subtle.ConstantTimeCompare([]byte(verifyToken), []byte(f.cfg.Token)) != 1 {
+		err = errors.New("invalid feishu verification token")
+		logger.WithErr(err).Error("verify failed")
+		return "", err
+	}
+
+	if callback.Type == "url_verification" {
+		res, err := json.Marshal(map[string]string{"challenge": callback.Challenge})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res), nil
+	}
+
+	if f.cfg.AESKey != "" && !signed {
+		err = errors.New("missing feishu signature")
+		logger.WithErr(err).Error("verify failed")
+		return "", err
+	}
+
+	if callback.Header.EventType != "im.message.receive_v1" {
+		logger.With("event_type", callback.Header.EventType).Debug("ignore feishu event")
+		return "{}", nil
+	}
+
+	if callback.Header.EventID != "" && f.events.duplicated(callback.Header.EventID) {
+		logger.With("event_id", callback.Header.EventID).Info("duplicated feishu event, skip")
+		return "{}", nil
+	}
+
+	var event feishuMessageEvent
+	err = json.Unmarshal(callback.Event, &event)
+	if err != nil {
+		logger.WithErr(err).Error("unmarshal feishu message event failed")
+		return "", err
+	}
+
+	go f.chat(context.Background(), &event)
+
+	return "{}", nil
+}
+
+func (f *feishu) question(ctx context.Context, event *feishuMessageEvent) (string, bool) {
+	if event.Message.MessageType != "text" {
+		return "", false
+	}
+
+	var content struct {
+		Text string `json:"text"`
+	}
+	err := json.Unmarshal([]byte(event.Message.Content), &content)
+	if err != nil {
+		f.logger.WithContext(ctx).WithErr(err).With("content", event.Message.Content).Warn("unmarshal feishu text failed")
+		return "", false
+	}
+
+	// 群聊中只回复 @ 机器人的消息
+	if event.Message.ChatType != "p2p" {
+		botOpenID, err := f.getBotOpenID(ctx)
+		if err != nil {
+			f.logger.WithContext(ctx).WithErr(err).Warn("get feishu bot info failed")
+		}
+
+		mentioned := false
+		for _, mention := range event.Message.Mentions {
+			if botOpenID == "" || mention.ID.OpenID == botOpenID {
+				mentioned = true
+				break
+			}
+		}
+
+		if !mentioned {
+			return "", false
+		}
+	}
+
+	question := content.Text
+	for _, mention := range event.Message.Mentions {
+		question = strings.ReplaceAll(question, mention.Key, "")
+	}
+	question = strings.TrimSpace(question)
+
+	return question, question != ""
+}
+
+func (f *feishu) chat(ctx context.Context, event *feishuMessageEvent) {
+	logger := f.logger.WithContext(ctx).With("chat_id", event.Message.ChatID).
+		With("chat_type", event.Message.ChatType).With("message_id", event.Message.MessageID)
+
+	if event.Sender.SenderType != "user" {
+		return
+	}
+
+	question, ok := f.question(ctx, event)
+	if !ok {
+		logger.Debug("ignore feishu message")
+		return
+	}
+
+	logger = logger.With("question", question)
+	logger.Info("receive bot message")
+
+	cardID, err := f.replyCard(ctx, event.Message.MessageID, fmt.Sprintf("**%s**\n\n正在查找相关信息", question))
+	if err != nil {
+		logger.WithErr(err).Error("reply card failed")
+		return
+	}
+
+	stream, err := f.botCallback(ctx, BotReq{
+		Type:      TypeFeishu,
+		SessionID: "feishu_" + event.Message.ChatID,
+		Question:  question,
+	})
+	if err != nil {
+		logger.WithErr(err).Error("callback failed")
+		e := f.updateCard(ctx, cardID, fmt.Sprintf("**%s**\n\n出错了，请稍后再试", question))
+		if e != nil {
+			logger.WithErr(e).Error("finish card failed")
+		}
+		return
+	}
+
+	streamUpdate(ctx, logger, stream, fmt.Sprintf("**%s**\n\n", question), func(content string) error {
+		return f.updateCard(ctx, cardID, content)
+	})
+}
+
+func (f *feishu) Start() error {
+	_, err := f.getBotOpenID(context.Background())
+	return err
+}
+
+func (f *feishu) Stop() {}
+
+func newFeishu(cfg model.SystemChatConfig, callback BotCallback) (Bot, error) {
+	// 事件回调依赖 verification token 或 encrypt key 校验来源，均为空时任何人都可以伪造事件
+	if cfg.Token == "" && cfg.AESKey == "" {
+		return nil, errors.New("empty feishu verification token and encrypt key")
+	}
+
+	domain := strings.TrimSuffix(cfg.Domain, "/")
+	if domain == "" {
+		domain = feishuDefaultDomain
+	}
+
+	return &feishu{
+		cfg:         cfg,
+		botCallback: callback,
+		logger:      glog.Module("chat", "feishu"),
+		domain:      domain,
+		events:      newEventDedup("feishu", feishuEventExpire),
+	}, nil
+}
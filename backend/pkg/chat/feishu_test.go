@@ -0,0 +1,153 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+)
+
+func feishuEncryptForTest(t *testing.T, key string, plain []byte) string {
+	t.Helper()
+
+	k := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	iv := bytes.Repeat([]byte{1}, aes.BlockSize)
+	data := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, plain)
+
+	return base64.StdEncoding.EncodeToString(append(iv, data...))
+}
+
+func feishuSignedReq(key string, body string, t time.Time) VerifyReq {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	h := sha256.Sum256([]byte(ts + "nonce" + key + body))
+
+	return VerifyReq{
+		VerifySign: VerifySign{
+			MsgSignature: hex.EncodeToString(h[:]),
+			Timestamp:    ts,
+			Nonce:        "nonce",
+		},
+		Content: body,
+	}
+}
+
+func TestFeishuURLVerification(t *testing.T) {
+	bot, err := newFeishu(model.SystemChatConfig{
+		Token:  "verify-token",
+		AESKey: "encrypt-key",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypt := feishuEncryptForTest(t, "encrypt-key",
+		[]byte(`{"type":"url_verification","challenge":"c-123","token":"verify-token"}`))
+	body := `{"encrypt":"` + encrypt + `"}`
+
+	req := feishuSignedReq("encrypt-key", body, time.Now())
+	res, err := bot.StreamText(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != `{"challenge":"c-123"}` {
+		t.Fatalf("unexpected challenge response: %s", res)
+	}
+
+	req.MsgSignature = "invalid"
+	_, err = bot.StreamText(context.Background(), req)
+	if err == nil {
+		t.Fatal("expect signature error")
+	}
+}
+
+func TestFeishuEventSignature(t *testing.T) {
+	bot, err := newFeishu(model.SystemChatConfig{
+		Token:  "verify-token",
+		AESKey: "encrypt-key",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypt := feishuEncryptForTest(t, "encrypt-key",
+		[]byte(`{"schema":"2.0","header":{"event_id":"e-1","event_type":"im.chat.updated_v1","token":"verify-token"}}`))
+	body := `{"encrypt":"` + encrypt + `"}`
+
+	_, err = bot.StreamText(context.Background(), VerifyReq{Content: body})
+	if err == nil {
+		t.Fatal("expect missing signature error")
+	}
+
+	_, err = bot.StreamText(context.Background(), feishuSignedReq("encrypt-key", body, time.Now().Add(-time.Hour)))
+	if err == nil {
+		t.Fatal("expect expired signature error")
+	}
+
+	res, err := bot.StreamText(context.Background(), feishuSignedReq("encrypt-key", body, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "{}" {
+		t.Fatalf("unexpected response: %s", res)
+	}
+}
+
+func TestFeishuEmptyVerification(t *testing.T) {
+	_, err := newFeishu(model.SystemChatConfig{ClientID: "cli_id", ClientSecret: "secret"}, nil)
+	if err == nil {
+		t.Fatal("expect empty verification error")
+	}
+}
+
+func TestFeishuInvalidToken(t *testing.T) {
+	bot, err := newFeishu(model.SystemChatConfig{Token: "verify-token"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = bot.StreamText(context.Background(), VerifyReq{
+		Content: `{"type":"url_verification","challenge":"c-123","token":"other"}`,
+	})
+	if err == nil {
+		t.Fatal("expect token error")
+	}
+}
+
+func TestFeishuQuestion(t *testing.T) {
+	f := &feishu{botOpenID: "ou_bot"}
+
+	var event feishuMessageEvent
+	event.Message.ChatType = "group"
+	event.Message.MessageType = "text"
+	event.Message.Content = `{"text":"@_user_1 如何部署？"}`
+
+	_, ok := f.question(context.Background(), &event)
+	if ok {
+		t.Fatal("group message without mention should be ignored")
+	}
+
+	event.Message.Mentions = []feishuMention{{Key: "@_user_1"}}
+	event.Message.Mentions[0].ID.OpenID = "ou_bot"
+
+	question, ok := f.question(context.Background(), &event)
+	if !ok || question != "如何部署？" {
+		t.Fatalf("unexpected question: %q", question)
+	}
+}
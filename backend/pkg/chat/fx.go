@@ -1,7 +1,13 @@
 package chat
 
-import "go.uber.org/fx"
+import (
+	"github.com/chaitin/koalaqa/pkg/cache"
+	"go.uber.org/fx"
+)
 
 var Module = fx.Options(
 	fx.Provide(newStateManager),
+	fx.Invoke(func(backend cache.Backend) {
+		dedupBackend = backend
+	}),
 )
@@ -0,0 +1,311 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/util"
+)
+
+const (
+	slackAPIURL        = "https://slack.com/api"
+	slackSignExpire    = time.Minute * 5
+	slackEventExpire   = time.Hour
+	slackCommandUsage  = "用法：/koala ask <问题>"
+	slackChannelTypeIM = "im"
+)
+
+var (
+	slackMentionReg = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+	slackBoldReg    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	slackLinkReg    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// slackMrkdwn 将回答中的 markdown 转换为 slack mrkdwn
+func slackMrkdwn(text string) string {
+	text = slackBoldReg.ReplaceAllString(text, "*$1*")
+	return slackLinkReg.ReplaceAllString(text, "<$2|$1>")
+}
+
+type slackCallback struct {
+	Type      string          `json:"type"`
+	Challenge string          `json:"challenge"`
+	APIAppID  string          `json:"api_app_id"`
+	EventID   string          `json:"event_id"`
+	Event     json.RawMessage `json:"event"`
+}
+
+type slackMessageEvent struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	BotID       string `json:"bot_id"`
+	User        string `json:"user"`
+	Text        string `json:"text"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"`
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"`
+}
+
+type slackMessage struct {
+	Channel  string `json:"channel"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	TS       string `json:"ts,omitempty"`
+	Text     string `json:"text"`
+}
+
+type slackResp struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+type slack struct {
+	cfg         model.SystemChatConfig
+	botCallback BotCallback
+
+	logger *glog.Logger
+	apiURL string
+	events *eventDedup
+}
+
+func (s *slack) request(ctx context.Context, method string, body any) (string, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := util.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var ret slackResp
+	err = json.NewDecoder(resp.Body).Decode(&ret)
+	if err != nil {
+		return "", err
+	}
+
+	if !ret.OK {
+		return "", fmt.Errorf("slack %s failed: %s", method, ret.Error)
+	}
+
+	return ret.TS, nil
+}
+
+func (s *slack) postMessage(ctx context.Context, channel string, threadTS string, text string) (string, error) {
+	return s.request(ctx, "chat.postMessage", slackMessage{
+		Channel:  channel,
+		ThreadTS: threadTS,
+		Text:     slackMrkdwn(text),
+	})
+}
+
+func (s *slack) updateMessage(ctx context.Context, channel string, ts string, text string) error {
+	_, err := s.request(ctx, "chat.update", slackMessage{
+		Channel: channel,
+		TS:      ts,
+		Text:    slackMrkdwn(text),
+	})
+	return err
+}
+
+func (s *slack) verifySign(req VerifyReq) error {
+	ts, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid slack timestamp: %w", err)
+	}
+
+	if time.Since(time.Unix(ts, 0)).Abs() > slackSignExpire {
+		return errors.New("slack request expired")
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.cfg.ClientSecret))
+	mac.Write([]byte("v0:" + req.Timestamp + ":" + req.Content))
+	sign := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(sign), []byte(req.MsgSignature)) {
+		return errors.New("invalid slack signature")
+	}
+
+	return nil
+}
+
+func (s *slack) StreamText(ctx context.Context, req VerifyReq) (string, error) {
+	logger := s.logger.WithContext(ctx)
+
+	err := s.verifySign(req)
+	if err != nil {
+		logger.WithErr(err).With("req", req.VerifySign).Error("verify failed")
+		return "", err
+	}
+
+	if req.Command {
+		return s.command(ctx, req.Content)
+	}
+
+	var callback slackCallback
+	err = json.Unmarshal([]byte(req.Content), &callback)
+	if err != nil {
+		logger.WithErr(err).Error("unmarshal slack callback failed")
+		return "", err
+	}
+
+	switch callback.Type {
+	case "url_verification":
+		res, err := json.Marshal(map[string]string{"challenge": callback.Challenge})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res), nil
+	case "event_callback":
+	default:
+		return "", nil
+	}
+
+	if s.cfg.ClientID != "" && callback.APIAppID != s.cfg.ClientID {
+		logger.With("api_app_id", callback.APIAppID).Warn("ignore slack event of other app")
+		return "", nil
+	}
+
+	if callback.EventID != "" && s.events.duplicated(callback.EventID) {
+		logger.With("event_id", callback.EventID).Info("duplicated slack event, skip")
+		return "", nil
+	}
+
+	var event slackMessageEvent
+	err = json.Unmarshal(callback.Event, &event)
+	if err != nil {
+		logger.WithErr(err).Error("unmarshal slack event failed")
+		return "", err
+	}
+
+	// 频道中通过 app_mention 触发，私聊通过 message.im 触发，忽略机器人自身及消息编辑等事件
+	if event.BotID != "" || event.Subtype != "" {
+		return "", nil
+	}
+	if event.Type != "app_mention" && !(event.Type == "message" && event.ChannelType == slackChannelTypeIM) {
+		return "", nil
+	}
+
+	question := trimAskCommand(slackMentionReg.ReplaceAllString(event.Text, ""))
+	if question == "" {
+		return "", nil
+	}
+
+	threadTS := event.ThreadTS
+	if threadTS == "" {
+		threadTS = event.TS
+	}
+
+	go s.chat(context.Background(), event.Channel, threadTS, question)
+
+	return "", nil
+}
+
+func (s *slack) command(ctx context.Context, content string) (string, error) {
+	values, err := url.ParseQuery(content)
+	if err != nil {
+		return "", err
+	}
+
+	text := values.Get("command") + " " + strings.TrimSpace(values.Get("text"))
+	question := trimAskCommand(text)
+	if question == "" || question == strings.TrimSpace(text) {
+		res, err := json.Marshal(map[string]string{
+			"response_type": "ephemeral",
+			"text":          slackCommandUsage,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res), nil
+	}
+
+	s.logger.WithContext(ctx).With("command", values.Get("command")).With("channel", values.Get("channel_id")).
+		Info("receive slack command")
+
+	go s.chat(context.Background(), values.Get("channel_id"), "", question)
+
+	res, err := json.Marshal(map[string]string{"response_type": "in_channel"})
+	if err != nil {
+		return "", err
+	}
+
+	return string(res), nil
+}
+
+func (s *slack) chat(ctx context.Context, channel string, threadTS string, question string) {
+	logger := s.logger.WithContext(ctx).With("channel", channel).With("thread_ts", threadTS).With("question", question)
+	logger.Info("receive bot message")
+
+	ts, err := s.postMessage(ctx, channel, threadTS, fmt.Sprintf("**%s**\n\n正在查找相关信息", question))
+	if err != nil {
+		logger.WithErr(err).Error("post slack message failed")
+		return
+	}
+
+	stream, err := s.botCallback(ctx, BotReq{
+		Type:      TypeSlack,
+		SessionID: "slack_" + channel,
+		Question:  question,
+	})
+	if err != nil {
+		logger.WithErr(err).Error("callback failed")
+		e := s.updateMessage(ctx, channel, ts, fmt.Sprintf("**%s**\n\n出错了，请稍后再试", question))
+		if e != nil {
+			logger.WithErr(e).Error("finish slack message failed")
+		}
+		return
+	}
+
+	streamUpdate(ctx, logger, stream, fmt.Sprintf("**%s**\n\n", question), func(content string) error {
+		return s.updateMessage(ctx, channel, ts, content)
+	})
+}
+
+func (s *slack) Start() error {
+	_, err := s.request(context.Background(), "auth.test", map[string]string{})
+	return err
+}
+
+func (s *slack) Stop() {}
+
+func newSlack(cfg model.SystemChatConfig, callback BotCallback) (Bot, error) {
+	// ClientSecret 为 signing secret，为空时无法校验请求签名
+	if cfg.ClientSecret == "" {
+		return nil, errors.New("empty slack signing secret")
+	}
+
+	return &slack{
+		cfg:         cfg,
+		botCallback: callback,
+		logger:      glog.Module("chat", "slack"),
+		apiURL:      slackAPIURL,
+		events:      newEventDedup("slack", slackEventExpire),
+	}, nil
+}
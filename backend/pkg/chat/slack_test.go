@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+)
+
+func slackReqForTest(secret string, body string, command bool) VerifyReq {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+
+	return VerifyReq{
+		VerifySign: VerifySign{
+			MsgSignature: "v0=" + hex.EncodeToString(mac.Sum(nil)),
+			Timestamp:    ts,
+		},
+		Content: body,
+		Command: command,
+	}
+}
+
+func TestTrimAskCommand(t *testing.T) {
+	cases := map[string]string{
+		"/koala ask 如何部署？": "如何部署？",
+		"Koala ASK  如何部署？": "如何部署？",
+		"asking for help":  "asking for help",
+		"ask 如何部署？":        "ask 如何部署？",
+		"/koala ask":       "",
+	}
+
+	for text, expect := range cases {
+		if res := trimAskCommand(text); res != expect {
+			t.Errorf("trimAskCommand(%q) = %q, expect %q", text, res, expect)
+		}
+	}
+}
+
+func TestSlackEmptySigningSecret(t *testing.T) {
+	_, err := newSlack(model.SystemChatConfig{Token: "xoxb-token"}, nil)
+	if err == nil {
+		t.Fatal("expect empty signing secret error")
+	}
+}
+
+func TestSlackURLVerification(t *testing.T) {
+	bot, err := newSlack(model.SystemChatConfig{ClientSecret: "signing-secret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := slackReqForTest("signing-secret", `{"type":"url_verification","challenge":"c-123"}`, false)
+	res, err := bot.StreamText(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != `{"challenge":"c-123"}` {
+		t.Fatalf("unexpected challenge response: %s", res)
+	}
+
+	req.Content = `{"type":"url_verification","challenge":"other"}`
+	_, err = bot.StreamText(context.Background(), req)
+	if err == nil {
+		t.Fatal("expect signature error")
+	}
+}
+
+func TestSlackCommandUsage(t *testing.T) {
+	bot, err := newSlack(model.SystemChatConfig{ClientSecret: "signing-secret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := bot.StreamText(context.Background(), slackReqForTest("signing-secret", "command=%2Fkoala&text=help", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res, "ephemeral") {
+		t.Fatalf("unexpected command response: %s", res)
+	}
+}
+
+func TestSlackMrkdwn(t *testing.T) {
+	res := slackMrkdwn("**问题**\n\n[前往社区](https://example.com/)")
+	if res != "*问题*\n\n<https://example.com/|前往社区>" {
+		t.Fatalf("unexpected mrkdwn: %s", res)
+	}
+}
@@ -0,0 +1,315 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+const (
+	teamsIssuer        = "https://api.botframework.com"
+	teamsKeysURL       = "https://login.botframework.com/v1/.well-known/keys"
+	teamsTokenURL      = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	teamsDefaultTenant = "botframework.com"
+	teamsScope         = "https://api.botframework.com/.default"
+)
+
+var teamsMentionReg = regexp.MustCompile(`<at>[^<]*</at>`)
+
+type teamsAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type teamsConversation struct {
+	ID               string `json:"id"`
+	ConversationType string `json:"conversationType,omitempty"`
+}
+
+type teamsEntity struct {
+	Type      string       `json:"type"`
+	Mentioned teamsAccount `json:"mentioned"`
+	Text      string       `json:"text"`
+}
+
+type teamsActivity struct {
+	Type         string            `json:"type"`
+	ID           string            `json:"id,omitempty"`
+	ServiceURL   string            `json:"serviceUrl,omitempty"`
+	ChannelID    string            `json:"channelId,omitempty"`
+	From         teamsAccount      `json:"from"`
+	Recipient    teamsAccount      `json:"recipient"`
+	Conversation teamsConversation `json:"conversation"`
+	ReplyToID    string            `json:"replyToId,omitempty"`
+	Text         string            `json:"text"`
+	TextFormat   string            `json:"textFormat,omitempty"`
+	Entities     []teamsEntity     `json:"entities,omitempty"`
+}
+
+type teamsAccessToken struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type teams struct {
+	cfg         model.SystemChatConfig
+	botCallback BotCallback
+
+	logger     *glog.Logger
+	verifier   *oidc.IDTokenVerifier
+	tokenURL   string
+	tokenCache token
+}
+
+func (t *teams) accessToken() (string, error) {
+	if t.tokenCache.expired() {
+		_, err, _ := sf.Do("access_token_teams", func() (interface{}, error) {
+			if !t.tokenCache.expired() {
+				return nil, nil
+			}
+
+			resp, err := util.HTTPClient.PostForm(t.tokenURL, url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {t.cfg.ClientID},
+				"client_secret": {t.cfg.ClientSecret},
+				"scope":         {teamsScope},
+			})
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			var tokenResp teamsAccessToken
+			err = json.NewDecoder(resp.Body).Decode(&tokenResp)
+			if err != nil {
+				return nil, err
+			}
+
+			if tokenResp.AccessToken == "" {
+				return nil, fmt.Errorf("get teams access token failed, error: %s, msg: %s", tokenResp.Error, tokenResp.ErrorDescription)
+			}
+
+			t.tokenCache = token{
+				token:    tokenResp.AccessToken,
+				expireAt: time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return t.tokenCache.token, nil
+}
+
+func (t *teams) request(ctx context.Context, method string, u string, activity *teamsActivity) (string, error) {
+	accessToken, err := t.accessToken()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := util.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", fmt.Errorf("teams request failed, status code: %d", resp.StatusCode)
+	}
+
+	var res struct {
+		ID string `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return res.ID, nil
+}
+
+func (t *teams) activityURL(activity *teamsActivity, activityID string) string {
+	return fmt.Sprintf("%s/v3/conversations/%s/activities/%s", strings.TrimSuffix(activity.ServiceURL, "/"),
+		url.PathEscape(activity.Conversation.ID), url.PathEscape(activityID))
+}
+
+func (t *teams) reply(activity *teamsActivity, text string) *teamsActivity {
+	return &teamsActivity{
+		Type:         "message",
+		From:         activity.Recipient,
+		Recipient:    activity.From,
+		Conversation: activity.Conversation,
+		ReplyToID:    activity.ID,
+		Text:         text,
+		TextFormat:   "markdown",
+	}
+}
+
+func (t *teams) replyMessage(ctx context.Context, activity *teamsActivity, text string) (string, error) {
+	return t.request(ctx, http.MethodPost, t.activityURL(activity, activity.ID), t.reply(activity, text))
+}
+
+func (t *teams) updateMessage(ctx context.Context, activity *teamsActivity, replyID string, text string) error {
+	_, err := t.request(ctx, http.MethodPut, t.activityURL(activity, replyID), t.reply(activity, text))
+	return err
+}
+
+// verify 校验 Bot Framework 签发的 jwt，serviceurl 声明需与活动中的 serviceUrl 一致
+func (t *teams) verify(ctx context.Context, authorization string, serviceURL string) error {
+	rawToken, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || rawToken == "" {
+		return errors.New("empty teams authorization")
+	}
+
+	idToken, err := t.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return err
+	}
+
+	var claims struct {
+		ServiceURL string `json:"serviceurl"`
+	}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return err
+	}
+
+	if claims.ServiceURL != serviceURL {
+		return errors.New("teams service url mismatch")
+	}
+
+	return nil
+}
+
+func (t *teams) StreamText(ctx context.Context, req VerifyReq) (string, error) {
+	logger := t.logger.WithContext(ctx)
+
+	var activity teamsActivity
+	err := json.Unmarshal([]byte(req.Content), &activity)
+	if err != nil {
+		logger.WithErr(err).Error("unmarshal teams activity failed")
+		return "", err
+	}
+
+	err = t.verify(ctx, req.MsgSignature, activity.ServiceURL)
+	if err != nil {
+		logger.WithErr(err).Error("verify failed")
+		return "", err
+	}
+
+	if activity.Type != "message" {
+		return "", nil
+	}
+
+	question, ok := t.question(&activity)
+	if !ok {
+		return "", nil
+	}
+
+	go t.chat(context.Background(), &activity, question)
+
+	return "", nil
+}
+
+func (t *teams) question(activity *teamsActivity) (string, bool) {
+	// 群聊和频道中只回复 @ 机器人的消息
+	if activity.Conversation.ConversationType != "" && activity.Conversation.ConversationType != "personal" {
+		mentioned := false
+		for _, entity := range activity.Entities {
+			if entity.Type == "mention" && entity.Mentioned.ID == activity.Recipient.ID {
+				mentioned = true
+				break
+			}
+		}
+
+		if !mentioned {
+			return "", false
+		}
+	}
+
+	question := trimAskCommand(teamsMentionReg.ReplaceAllString(activity.Text, ""))
+	return question, question != ""
+}
+
+func (t *teams) chat(ctx context.Context, activity *teamsActivity, question string) {
+	logger := t.logger.WithContext(ctx).With("conversation_id", activity.Conversation.ID).
+		With("conversation_type", activity.Conversation.ConversationType).With("question", question)
+	logger.Info("receive bot message")
+
+	replyID, err := t.replyMessage(ctx, activity, fmt.Sprintf("**%s**\n\n正在查找相关信息", question))
+	if err != nil {
+		logger.WithErr(err).Error("reply teams message failed")
+		return
+	}
+
+	stream, err := t.botCallback(ctx, BotReq{
+		Type:      TypeTeams,
+		SessionID: "teams_" + activity.Conversation.ID,
+		Question:  question,
+	})
+	if err != nil {
+		logger.WithErr(err).Error("callback failed")
+		e := t.updateMessage(ctx, activity, replyID, fmt.Sprintf("**%s**\n\n出错了，请稍后再试", question))
+		if e != nil {
+			logger.WithErr(e).Error("finish teams message failed")
+		}
+		return
+	}
+
+	streamUpdate(ctx, logger, stream, fmt.Sprintf("**%s**\n\n", question), func(content string) error {
+		return t.updateMessage(ctx, activity, replyID, content)
+	})
+}
+
+func (t *teams) Start() error {
+	_, err := t.accessToken()
+	return err
+}
+
+func (t *teams) Stop() {}
+
+func newTeams(cfg model.SystemChatConfig, callback BotCallback) (Bot, error) {
+	tenant := cfg.CorpID
+	if tenant == "" {
+		tenant = teamsDefaultTenant
+	}
+
+	keySet := oidc.NewRemoteKeySet(oidc.ClientContext(context.Background(), util.HTTPClient), teamsKeysURL)
+
+	return &teams{
+		cfg:         cfg,
+		botCallback: callback,
+		logger:      glog.Module("chat", "teams"),
+		verifier:    oidc.NewVerifier(teamsIssuer, keySet, &oidc.Config{ClientID: cfg.ClientID}),
+		tokenURL:    fmt.Sprintf(teamsTokenURL, url.PathEscape(tenant)),
+	}, nil
+}
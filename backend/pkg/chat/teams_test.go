@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func teamsTokenForTest(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signing))
+	sign, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sign)
+}
+
+func TestTeamsVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot := &teams{
+		verifier: oidc.NewVerifier(teamsIssuer, &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&key.PublicKey}},
+			&oidc.Config{ClientID: "app-id"}),
+	}
+
+	const serviceURL = "https://smba.trafficmanager.net/teams/"
+	claims := func(exp time.Time) map[string]any {
+		return map[string]any{
+			"iss":        teamsIssuer,
+			"aud":        "app-id",
+			"exp":        exp.Unix(),
+			"iat":        time.Now().Add(-time.Minute).Unix(),
+			"serviceurl": serviceURL,
+		}
+	}
+
+	valid := teamsTokenForTest(t, key, claims(time.Now().Add(time.Hour)))
+	err = bot.verify(context.Background(), "Bearer "+valid, serviceURL)
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	for name, c := range map[string]struct {
+		authorization string
+		serviceURL    string
+	}{
+		"empty":   {"", serviceURL},
+		"forged":  {"Bearer " + teamsTokenForTest(t, forgedKey, claims(time.Now().Add(time.Hour))), serviceURL},
+		"expired": {"Bearer " + teamsTokenForTest(t, key, claims(time.Now().Add(-time.Hour))), serviceURL},
+		"service": {"Bearer " + valid, "https://evil.example.com/"},
+	} {
+		err = bot.verify(context.Background(), c.authorization, c.serviceURL)
+		if err == nil {
+			t.Fatalf("%s token should be rejected", name)
+		}
+	}
+}
@@ -18,10 +18,16 @@ type Config struct {
 	API    API    `envPrefix:"API_"`
 	RAG    Rag    `envPrefix:"RAG_"`
 	OSS    OSS    `envPrefix:"OSS_"`
+	Cache  Cache  `envPrefix:"CACHE_"`
+	Upload Upload `envPrefix:"UPLOAD_"`
 }
 
 type OSS struct {
-	Minio Minio `envPrefix:"MINIO_"`
+	// Type 对象存储类型，可选 minio、s3、local
+	Type  string `env:"TYPE" envDefault:"minio"`
+	Minio Minio  `envPrefix:"MINIO_"`
+	S3    S3     `envPrefix:"S3_"`
+	Local Local  `envPrefix:"LOCAL_"`
 }
 
 type Minio struct {
@@ -32,10 +38,35 @@ type Minio struct {
 	MaxFileSize int      `env:"MAX_FILE_SIZE" envDefault:"104857600"`
 }
 
+// S3 兼容 AWS S3、阿里云 OSS、腾讯云 COS 等，未配置 AccessKey 时从环境变量或 IAM 角色获取凭证
+type S3 struct {
+	Endpoint     string   `env:"ENDPOINT" envDefault:"s3.amazonaws.com"`
+	Region       string   `env:"REGION"`
+	AccessKey    string   `env:"ACCESS_KEY"`
+	SecretKey    string   `env:"SECRET_KEY"`
+	SessionToken string   `env:"SESSION_TOKEN"`
+	UseSSL       bool     `env:"USE_SSL" envDefault:"true"`
+	PathStyle    bool     `env:"PATH_STYLE" envDefault:"false"`
+	Buckets      []string `env:"BUCKETS" envDefault:"koala,anydoc"` // first one is default bucket
+	MaxFileSize  int      `env:"MAX_FILE_SIZE" envDefault:"104857600"`
+}
+
+type Local struct {
+	Dir         string   `env:"DIR" envDefault:"/data/oss"`
+	Buckets     []string `env:"BUCKETS" envDefault:"koala,anydoc"` // first one is default bucket
+	MaxFileSize int      `env:"MAX_FILE_SIZE" envDefault:"104857600"`
+	// SignKey 签名 url 的密钥，为空时从 JWT_SECRET 派生
+	SignKey string `env:"SIGN_KEY"`
+	// SignURL 未指定访问地址时签名 url 使用的地址，需要能被 anydoc 等内部服务访问
+	SignURL string `env:"SIGN_URL" envDefault:"http://koala-qa-api:8080"`
+}
+
 type Rag struct {
 	BaseURL string `env:"BASE_URL" envDefault:"http://koala-qa-raglite:5050"`
 	APIKey  string `env:"API_KEY" envDefault:"koala"`
 	DEBUG   bool   `env:"DEBUG" envDefault:"false"`
+	// ImageIndex 索引帖子时是否包含视觉模型识别出的图片内容
+	ImageIndex bool `env:"IMAGE_INDEX" envDefault:"false"`
 }
 
 type DB struct {
@@ -59,9 +90,33 @@ type NATS struct {
 	Streams   map[string]string `env:"STREAMS"`
 }
 
+// Upload 用户上传附件的限制
+type Upload struct {
+	// UserQuota 每个用户占用的存储上限，单位字节，0 表示不限制
+	UserQuota int64 `env:"USER_QUOTA" envDefault:"1073741824"`
+	// DailyCount 每个用户每天的上传次数，0 表示不限制
+	DailyCount int    `env:"DAILY_COUNT" envDefault:"200"`
+	ClamAV     ClamAV `envPrefix:"CLAMAV_"`
+}
+
+type ClamAV struct {
+	// Addr clamd 地址，如 unix:///run/clamav/clamd.ctl、tcp://clamav:3310，为空时不扫描
+	Addr    string `env:"ADDR"`
+	Timeout int    `env:"TIMEOUT" envDefault:"60"`
+}
+
+// Cache 限流与缓存的存储，多副本部署时需要使用 nats 或 postgres 在副本间共享
+type Cache struct {
+	// Type 可选 memory、nats、postgres
+	Type string `env:"TYPE" envDefault:"memory"`
+}
+
 type JWT struct {
-	Expire int64  `env:"EXPIRE" envDefault:"604800"`
-	Secret string `env:"SECRET"`
+	// Expire 登录会话（refresh token）有效期
+	Expire int64 `env:"EXPIRE" envDefault:"604800"`
+	// AccessExpire access token 有效期，过期后使用 refresh token 换取
+	AccessExpire int64  `env:"ACCESS_EXPIRE" envDefault:"900"`
+	Secret       string `env:"SECRET"`
 }
 
 type Anydoc struct {
@@ -78,11 +78,16 @@ func (ctx *Context) IsAdmin() bool {
 }
 
 func (c *Context) Success(obj any) {
-	c.Context.JSON(http.StatusOK, Response{
+	c.Context.JSON(http.StatusOK, c.SuccessResponse(obj))
+}
+
+// SuccessResponse 构造成功的响应，需要在 data 之外附加字段时嵌入使用
+func (c *Context) SuccessResponse(obj any) Response {
+	return Response{
 		Success: true,
 		Data:    obj,
 		TraceID: trace.TraceIDString(c),
-	})
+	}
 }
 
 func (c *Context) InternalError(err error, msg string) {
@@ -151,3 +156,21 @@ func (c *Context) QueryUint(key string) (uint, error) {
 	}
 	return uint(val), nil
 }
+
+const (
+	AuthTokenCookie    = "auth_token"
+	RefreshTokenCookie = "refresh_token"
+)
+
+// SetAuthCookie refreshToken 为空时只更新 access token
+func (c *Context) SetAuthCookie(accessToken string, refreshToken string, maxAge int) {
+	c.SetCookie(AuthTokenCookie, accessToken, maxAge, "/", "", false, true)
+	if refreshToken != "" {
+		c.SetCookie(RefreshTokenCookie, refreshToken, maxAge, "/", "", false, true)
+	}
+}
+
+func (c *Context) ClearAuthCookie() {
+	c.SetCookie(AuthTokenCookie, "", -1, "/", "", false, true)
+	c.SetCookie(RefreshTokenCookie, "", -1, "/", "", false, true)
+}
@@ -14,6 +14,7 @@ import (
 type closeDiscussion struct {
 	logger     *glog.Logger
 	repoDisc   *repo.Discussion
+	svcDisc    *svc.Discussion
 	svcSysDisc *svc.SystemDiscussion
 }
 
@@ -25,6 +26,9 @@ func (c *closeDiscussion) Run() {
 	c.logger.Info("closing expired discussion...")
 	ctx := context.Background()
 
+	// 无论是否开启自动关闭，都退回已关闭问题中未结算的悬赏
+	defer c.refundBounty(ctx)
+
 	sysDisc, err := c.svcSysDisc.Get(ctx)
 	if err != nil {
 		c.logger.WithErr(err).Warn("get system discussion failed")
@@ -55,10 +59,20 @@ func (c *closeDiscussion) Run() {
 	}
 }
 
-func newCloseDiscussion(disc *repo.Discussion, sysDisc *svc.SystemDiscussion) Task {
+func (c *closeDiscussion) refundBounty(ctx context.Context) {
+	refund, err := c.svcDisc.RefundClosedBounty(ctx)
+	if err != nil {
+		c.logger.WithErr(err).Warn("refund closed disc bounty failed")
+		return
+	}
+	c.logger.With("count", refund).Info("refund closed disc bounty")
+}
+
+func newCloseDiscussion(disc *repo.Discussion, svcDisc *svc.Discussion, sysDisc *svc.SystemDiscussion) Task {
 	return &closeDiscussion{
 		logger:     glog.Module("cron", "close_discussion"),
 		repoDisc:   disc,
+		svcDisc:    svcDisc,
 		svcSysDisc: sysDisc,
 	}
 }
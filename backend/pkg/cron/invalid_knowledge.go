@@ -82,6 +82,15 @@ func (i *invalidKnowledge) Run() {
 		return
 	}
 
+	askKnowledges, err := i.stat.AskInvalidKnowledge(ctx, lastMonth, util.DayTrunc(now),
+		repo.QueryWithEqual("kb_documents.updated_at", now.AddDate(0, 0, -30), repo.EqualOPLT),
+	)
+	if err != nil {
+		logger.WithErr(err).Error("query ask invalid knowledge failed")
+		return
+	}
+	knowledges = mergeInvalidKnowledge(knowledges, askKnowledges)
+
 	ranks := make([]model.Rank, 0)
 	knowledgeDocIDs := make(model.Int64Array, 0)
 	docExtra := make(map[string]model.StatInvalidKnowledgeDoc)
@@ -239,6 +248,28 @@ func (i *invalidKnowledge) Run() {
 	}
 }
 
+// mergeInvalidKnowledge 合并帖子和智能问答中同一文档的命中和点踩次数
+func mergeInvalidKnowledge(knowledges []model.StatInvalidKnowledge, others []model.StatInvalidKnowledge) []model.StatInvalidKnowledge {
+	idx := make(map[string]int, len(knowledges))
+	for i, knowledge := range knowledges {
+		idx[knowledge.Key] = i
+	}
+
+	for _, other := range others {
+		i, ok := idx[other.Key]
+		if !ok {
+			idx[other.Key] = len(knowledges)
+			knowledges = append(knowledges, other)
+			continue
+		}
+
+		knowledges[i].DislikeCount += other.DislikeCount
+		knowledges[i].HitCount += other.HitCount
+	}
+
+	return knowledges
+}
+
 func newInvalidKnowledge(stat *repo.Stat, doc *repo.KBDocument,
 	rank *repo.Rank, gen *message.Generator, webhook *svc.Webhook) Task {
 	return &invalidKnowledge{
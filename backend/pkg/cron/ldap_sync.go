@@ -0,0 +1,40 @@
+package cron
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type ldapSync struct {
+	logger  *glog.Logger
+	svcUser *svc.User
+}
+
+func (l *ldapSync) Period() string {
+	return "0 30 * * *"
+}
+
+func (l *ldapSync) Run() {
+	l.logger.Info("ldap sync task begin...")
+
+	err := l.svcUser.SyncLDAP(context.Background())
+	if err != nil {
+		l.logger.WithErr(err).Warn("sync ldap users failed")
+		return
+	}
+
+	l.logger.Info("ldap sync task finished")
+}
+
+func newLDAPSync(user *svc.User) Task {
+	return &ldapSync{
+		logger:  glog.Module("cron", "ldap_sync"),
+		svcUser: user,
+	}
+}
+
+func init() {
+	register(newLDAPSync)
+}
@@ -16,6 +16,7 @@ type rankContribute struct {
 	logger    *glog.Logger
 	userPoint *repo.UserPointRecord
 	svcBot    *svc.Bot
+	svcBadge  *svc.Badge
 	repoRank  *repo.Rank
 }
 
@@ -75,13 +76,20 @@ func (r *rankContribute) Run() {
 		}
 	}
 
+	for _, v := range data {
+		err = r.svcBadge.Evaluate(ctx, v.UserID)
+		if err != nil {
+			r.logger.WithErr(err).With("user_id", v.UserID).Warn("evaluate user badge failed")
+		}
+	}
 }
 
-func newRankContribute(userPoint *repo.UserPointRecord, bot *svc.Bot, rank *repo.Rank) Task {
+func newRankContribute(userPoint *repo.UserPointRecord, bot *svc.Bot, badge *svc.Badge, rank *repo.Rank) Task {
 	return &rankContribute{
 		logger:    glog.Module("cron", "rank_contribute"),
 		userPoint: userPoint,
 		svcBot:    bot,
+		svcBadge:  badge,
 		repoRank:  rank,
 	}
 }
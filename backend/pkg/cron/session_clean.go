@@ -0,0 +1,40 @@
+package cron
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type sessionClean struct {
+	logger     *glog.Logger
+	svcSession *svc.UserSession
+}
+
+func (s *sessionClean) Period() string {
+	return "0 10 3 * *"
+}
+
+func (s *sessionClean) Run() {
+	s.logger.Info("session clean task begin...")
+
+	err := s.svcSession.CleanExpired(context.Background())
+	if err != nil {
+		s.logger.WithErr(err).Warn("clean expired session failed")
+		return
+	}
+
+	s.logger.Info("session clean task finished")
+}
+
+func newSessionClean(session *svc.UserSession) Task {
+	return &sessionClean{
+		logger:     glog.Module("cron", "session_clean"),
+		svcSession: session,
+	}
+}
+
+func init() {
+	register(newSessionClean)
+}
@@ -0,0 +1,40 @@
+package cron
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type uploadClean struct {
+	logger    *glog.Logger
+	svcUpload *svc.Upload
+}
+
+func (u *uploadClean) Period() string {
+	return "0 40 3 * *"
+}
+
+func (u *uploadClean) Run() {
+	u.logger.Info("upload clean task begin...")
+
+	total, err := u.svcUpload.CleanOrphan(context.Background())
+	if err != nil {
+		u.logger.WithErr(err).With("total", total).Warn("clean orphan upload failed")
+		return
+	}
+
+	u.logger.With("total", total).Info("upload clean task finished")
+}
+
+func newUploadClean(upload *svc.Upload) Task {
+	return &uploadClean{
+		logger:    glog.Module("cron", "upload_clean"),
+		svcUpload: upload,
+	}
+}
+
+func init() {
+	register(newUploadClean)
+}
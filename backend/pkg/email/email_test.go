@@ -0,0 +1,172 @@
+package email
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+const testMail = "From: =?UTF-8?B?5byg5LiJ?= <ZhangSan@Example.com>\r\n" +
+	"To: support@koala.example.com\r\n" +
+	"Subject: =?UTF-8?B?5aaC5L2V6YOo572y77yf?=\r\n" +
+	"Message-ID: <abc@example.com>\r\n" +
+	"In-Reply-To: <out-1@koala.example.com>\r\n" +
+	"References: <root@example.com> <out-1@koala.example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"b2\"\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"=E8=BF=98=E6=98=AF=E4=B8=8D=E8=A1=8C\r\n" +
+	"\r\n" +
+	"On Mon, 1 Jan 2026 at 10:00, Koala wrote:\r\n" +
+	"> old content\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/html; charset=UTF-8\r\n" +
+	"\r\n" +
+	"<p>html</p>\r\n" +
+	"--b2--\r\n" +
+	"--b1\r\n" +
+	"Content-Type: image/png; name=\"a.png\"\r\n" +
+	"Content-Disposition: attachment; filename=\"a.png\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0KGgo=\r\n" +
+	"--b1--\r\n"
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(testMail))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.From.Address != "zhangsan@example.com" || m.From.Name != "张三" {
+		t.Errorf("unexpected from: %v", m.From)
+	}
+	if m.Subject != "如何部署？" {
+		t.Errorf("unexpected subject: %s", m.Subject)
+	}
+	if m.MessageID != "<abc@example.com>" {
+		t.Errorf("unexpected message id: %s", m.MessageID)
+	}
+
+	ids := m.ThreadIDs()
+	if len(ids) != 3 || ids[0] != "<out-1@koala.example.com>" {
+		t.Errorf("unexpected thread ids: %v", ids)
+	}
+
+	if TrimQuote(m.Text) != "还是不行" {
+		t.Errorf("unexpected text: %q", TrimQuote(m.Text))
+	}
+
+	if len(m.Attachments) != 1 || m.Attachments[0].Filename != "a.png" || len(m.Attachments[0].Data) != 8 {
+		t.Errorf("unexpected attachments: %+v", m.Attachments)
+	}
+
+	if m.AutoReply {
+		t.Error("unexpected auto reply")
+	}
+}
+
+func TestParseHTMLAndAutoReply(t *testing.T) {
+	raw := "From: a@example.com\r\n" +
+		"Subject: out of office\r\n" +
+		"Auto-Submitted: auto-replied\r\n" +
+		"Content-Type: text/html; charset=UTF-8\r\n" +
+		"\r\n" +
+		"<div>hello<br>world &amp; more</div><style>p{}</style>\r\n"
+
+	m, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.AutoReply {
+		t.Error("expect auto reply")
+	}
+	if m.Text != "hello\nworld & more" {
+		t.Errorf("unexpected text: %q", m.Text)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	data := Build("Koala", "support@koala.example.com", OutMail{
+		MessageID:  "<out-2@koala.example.com>",
+		To:         mail.Address{Name: "张三", Address: "zhangsan@example.com"},
+		Subject:    "Re: 如何部署？",
+		Text:       strings.Repeat("回答", 100),
+		InReplyTo:  "<abc@example.com>",
+		References: []string{"<abc@example.com>"},
+	})
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Subject != "Re: 如何部署？" || m.Text != strings.Repeat("回答", 100) || !m.AutoReply {
+		t.Errorf("unexpected mail: %+v", m)
+	}
+	if len(m.InReplyTo) != 1 || m.InReplyTo[0] != "<abc@example.com>" {
+		t.Errorf("unexpected in-reply-to: %v", m.InReplyTo)
+	}
+}
+
+func TestAuthenticated(t *testing.T) {
+	raw := "From: a@mail.example.com\r\n" +
+		"To: Koala <support+r1.2.abc@koala.example.com>\r\n" +
+		"Delivered-To: support@koala.example.com\r\n" +
+		"Authentication-Results: mx.koala.example.com;\r\n" +
+		" spf=pass (sender permitted) smtp.mailfrom=bounce@example.com;\r\n" +
+		" dkim=fail header.d=mail.example.com\r\n" +
+		"Authentication-Results: evil.example.net; dkim=pass header.d=mail.example.com\r\n" +
+		"Subject: hi\r\n" +
+		"\r\n" +
+		"hello\r\n"
+
+	m, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Recipients) != 2 || m.Recipients[0] != "support+r1.2.abc@koala.example.com" {
+		t.Errorf("unexpected recipients: %v", m.Recipients)
+	}
+
+	if !m.Authenticated("mx.koala.example.com") {
+		t.Error("expect authenticated by aligned spf")
+	}
+	if m.Authenticated("") || m.Authenticated("evil.example.org") || m.Authenticated("evil.example.net") {
+		t.Error("untrusted authserv-id should be ignored")
+	}
+
+	m.From.Address = "a@other.example.org"
+	if m.Authenticated("mx.koala.example.com") {
+		t.Error("unaligned domain should not be authenticated")
+	}
+}
+
+func TestAuthenticatedInjected(t *testing.T) {
+	// 发件方伪造的头位于接收 MTA 添加的头下方，即使 authserv-id 相同也不应被信任
+	raw := "From: a@other.example.org\r\n" +
+		"To: support@koala.example.com\r\n" +
+		"Authentication-Results: mx.koala.example.com; spf=fail smtp.mailfrom=other.example.org\r\n" +
+		"Authentication-Results: mx.koala.example.com; dkim=pass header.d=other.example.org\r\n" +
+		"Subject: hi\r\n" +
+		"\r\n" +
+		"hello\r\n"
+
+	m, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Authenticated("mx.koala.example.com") {
+		t.Error("injected lower header should be ignored")
+	}
+}
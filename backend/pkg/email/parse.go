@@ -0,0 +1,336 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+var (
+	errNoSender = errors.New("email sender not found")
+
+	msgIDReg       = regexp.MustCompile(`<[^<>\s]+>`)
+	htmlTagReg     = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]+>`)
+	htmlBrReg      = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>`)
+	authCommentReg = regexp.MustCompile(`\([^()]*\)`)
+	quoteReg       = regexp.MustCompile(`^(On .+ wrote:|在 .+写道[：:]|-{2,} ?Original Message ?-{2,}|-{2,} ?原始邮件 ?-{2,}|From: .+|发件人[：:].+)$`)
+
+	wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+)
+
+// charsetReader 将 gbk 等非 utf-8 字符集转换为 utf-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return input, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.NewDecoder().Reader(input), nil
+}
+
+func decodeCharset(charset string, data []byte) []byte {
+	r, err := charsetReader(charset, bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+
+	res, err := io.ReadAll(r)
+	if err != nil {
+		return data
+	}
+
+	return res
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type Mail struct {
+	MessageID  string
+	InReplyTo  []string
+	References []string
+	From       mail.Address
+	Subject    string
+	Text       string
+	// AutoReply 自动回复、退信等邮件，不应当作为用户提问
+	AutoReply   bool
+	Attachments []Attachment
+	// Recipients To/Cc/Delivered-To 中的收件地址，用于解析回复令牌
+	Recipients []string
+	// AuthResults MTA 写入的 Authentication-Results
+	AuthResults []string
+}
+
+// ThreadIDs 返回可用于关联已有帖子的 Message-ID，In-Reply-To 优先
+func (m *Mail) ThreadIDs() []string {
+	return append(append([]string{}, m.InReplyTo...), m.References...)
+}
+
+// domainAligned 按 DMARC 宽松对齐规则判断校验域与发件域是否一致
+func domainAligned(domain string, fromDomain string) bool {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.LastIndex(domain, "@"); i >= 0 {
+		domain = domain[i+1:]
+	}
+	if domain == "" {
+		return false
+	}
+
+	return domain == fromDomain || strings.HasSuffix(fromDomain, "."+domain)
+}
+
+// Authenticated 发件人是否通过 SPF 或 DKIM 校验且校验域与 From 对齐
+// 只认可最上方且 authserv-id 为 authservID 的 Authentication-Results，即接收邮件的 MTA 添加的头，
+// 下方的同名头可能由发件方伪造
+func (m *Mail) Authenticated(authservID string) bool {
+	_, fromDomain, ok := strings.Cut(m.From.Address, "@")
+	if authservID == "" || !ok || fromDomain == "" || len(m.AuthResults) == 0 {
+		return false
+	}
+
+	items := strings.Split(authCommentReg.ReplaceAllString(m.AuthResults[0], ""), ";")
+	id, _, _ := strings.Cut(strings.TrimSpace(items[0]), " ")
+	if !strings.EqualFold(id, authservID) {
+		return false
+	}
+
+	for _, item := range items[1:] {
+		fields := strings.Fields(strings.ToLower(item))
+		if len(fields) == 0 {
+			continue
+		}
+
+		props := make(map[string]string, len(fields)-1)
+		for _, field := range fields[1:] {
+			k, v, _ := strings.Cut(field, "=")
+			props[k] = v
+		}
+
+		switch fields[0] {
+		case "dmarc=pass":
+			if domainAligned(props["header.from"], fromDomain) {
+				return true
+			}
+		case "dkim=pass":
+			if domainAligned(props["header.d"], fromDomain) || domainAligned(props["header.i"], fromDomain) {
+				return true
+			}
+		case "spf=pass":
+			if domainAligned(props["smtp.mailfrom"], fromDomain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func parseMsgIDs(value string) []string {
+	return msgIDReg.FindAllString(value, -1)
+}
+
+func decodeHeader(value string) string {
+	res, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+
+	return res
+}
+
+func decodeBody(encoding string, r io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, newLineSkipReader(r)))
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(r))
+	default:
+		return io.ReadAll(r)
+	}
+}
+
+// lineSkipReader 去掉 base64 内容中的换行
+type lineSkipReader struct {
+	r io.Reader
+}
+
+func newLineSkipReader(r io.Reader) io.Reader {
+	return &lineSkipReader{r: r}
+}
+
+func (l *lineSkipReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	j := 0
+	for i := 0; i < n; i++ {
+		if p[i] == '\r' || p[i] == '\n' {
+			continue
+		}
+		p[j] = p[i]
+		j++
+	}
+
+	return j, err
+}
+
+// HTMLToText 粗略去除 html 标签
+func HTMLToText(content string) string {
+	content = htmlBrReg.ReplaceAllString(content, "\n")
+	content = htmlTagReg.ReplaceAllString(content, "")
+	return strings.TrimSpace(html.UnescapeString(content))
+}
+
+// TrimQuote 去掉回复邮件中引用的历史内容
+func TrimQuote(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, ">") || quoteReg.MatchString(line) {
+			lines = lines[:i]
+			break
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+type parser struct {
+	mail *Mail
+	html string
+}
+
+func (p *parser) part(header mail.Header, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+		params = map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+
+			err = p.part(mail.Header(part.Header), part)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := decodeBody(header.Get("Content-Transfer-Encoding"), body)
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	if disposition == "attachment" || (filename != "" && !strings.HasPrefix(mediaType, "text/")) {
+		p.mail.Attachments = append(p.mail.Attachments, Attachment{
+			Filename:    decodeHeader(filename),
+			ContentType: mediaType,
+			Data:        data,
+		})
+		return nil
+	}
+
+	switch mediaType {
+	case "text/plain":
+		if p.mail.Text == "" {
+			p.mail.Text = string(decodeCharset(params["charset"], data))
+		}
+	case "text/html":
+		if p.html == "" {
+			p.html = string(decodeCharset(params["charset"], data))
+		}
+	}
+
+	return nil
+}
+
+// Parse 解析 RFC 5322 格式的原始邮件
+func Parse(raw []byte) (*Mail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := (&mail.AddressParser{WordDecoder: wordDecoder}).ParseList(msg.Header.Get("From"))
+	if err != nil || len(from) == 0 {
+		return nil, errNoSender
+	}
+
+	res := &Mail{
+		From:       *from[0],
+		Subject:    strings.TrimSpace(decodeHeader(msg.Header.Get("Subject"))),
+		InReplyTo:  parseMsgIDs(msg.Header.Get("In-Reply-To")),
+		References: parseMsgIDs(msg.Header.Get("References")),
+	}
+	res.From.Address = strings.ToLower(res.From.Address)
+
+	addrParser := mail.AddressParser{WordDecoder: wordDecoder}
+	for _, key := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, value := range msg.Header[key] {
+			addrs, err := addrParser.ParseList(value)
+			if err != nil {
+				continue
+			}
+
+			for _, addr := range addrs {
+				res.Recipients = append(res.Recipients, strings.ToLower(addr.Address))
+			}
+		}
+	}
+	res.AuthResults = msg.Header["Authentication-Results"]
+
+	ids := parseMsgIDs(msg.Header.Get("Message-ID"))
+	if len(ids) > 0 {
+		res.MessageID = ids[0]
+	}
+
+	autoSubmitted := strings.ToLower(msg.Header.Get("Auto-Submitted"))
+	precedence := strings.ToLower(msg.Header.Get("Precedence"))
+	res.AutoReply = (autoSubmitted != "" && autoSubmitted != "no") ||
+		precedence == "bulk" || precedence == "junk" || precedence == "auto_reply" ||
+		msg.Header.Get("X-Autoreply") != "" || msg.Header.Get("X-Autorespond") != ""
+
+	p := parser{mail: res}
+	err = p.part(msg.Header, msg.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Text == "" && p.html != "" {
+		res.Text = HTMLToText(p.html)
+	}
+	res.Text = strings.TrimSpace(strings.ReplaceAll(res.Text, "\r\n", "\n"))
+
+	return res, nil
+}
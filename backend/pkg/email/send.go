@@ -0,0 +1,146 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/google/uuid"
+)
+
+type OutMail struct {
+	MessageID string
+	To        mail.Address
+	// ReplyTo 回复地址，为空时回复到发件地址
+	ReplyTo    string
+	Subject    string
+	Text       string
+	InReplyTo  string
+	References []string
+}
+
+// NewMessageID 生成发件使用的 Message-ID
+func NewMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+
+	return fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)
+}
+
+// Build 生成 RFC 5322 格式的纯文本邮件
+func Build(fromName string, from string, msg OutMail) []byte {
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		if value == "" {
+			return
+		}
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+
+	writeHeader("From", (&mail.Address{Name: fromName, Address: from}).String())
+	writeHeader("To", msg.To.String())
+	writeHeader("Reply-To", msg.ReplyTo)
+	writeHeader("Subject", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", msg.MessageID)
+	writeHeader("In-Reply-To", msg.InReplyTo)
+	writeHeader("References", strings.Join(msg.References, " "))
+	writeHeader("Auto-Submitted", "auto-replied")
+	writeHeader("MIME-Version", "1.0")
+	writeHeader("Content-Type", "text/plain; charset=UTF-8")
+	writeHeader("Content-Transfer-Encoding", "base64")
+	buf.WriteString("\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Text))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+
+	return buf.Bytes()
+}
+
+// Send 通过 smtp 发送邮件
+func Send(cfg model.SystemEmailSMTP, fromName string, msg OutMail) error {
+	if cfg.Host == "" || cfg.From == "" {
+		return errors.New("smtp not configured")
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 25
+		if cfg.SSL {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	data := Build(fromName, cfg.From, msg)
+	if !cfg.SSL {
+		return smtp.SendMail(addr, auth, cfg.From, []string{msg.To.Address}, data)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second * 10}, "tcp", addr, &tls.Config{ServerName: cfg.Host})
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if auth != nil {
+		err = c.Auth(auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(cfg.From)
+	if err != nil {
+		return err
+	}
+
+	err = c.Rcpt(msg.To.Address)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,147 @@
+// Package export 将表格数据导出为 csv 或 xlsx
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+type Table struct {
+	// Name xlsx 中的 sheet 名，csv 中作为表格标题
+	Name   string
+	Header []string
+	Rows   [][]string
+}
+
+func (t *Table) Append(row ...any) {
+	strRow := make([]string, len(row))
+	for i := range row {
+		strRow[i] = fmt.Sprint(row[i])
+	}
+
+	t.Rows = append(t.Rows, strRow)
+}
+
+func Write(w io.Writer, format Format, tables ...Table) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, tables...)
+	case FormatXLSX:
+		return WriteXLSX(w, tables...)
+	default:
+		return errors.New("unsupported export format")
+	}
+}
+
+// WriteCSV 多个表格之间以空行分隔，写入 BOM 以便 Excel 正确识别 utf-8
+func WriteCSV(w io.Writer, tables ...Table) error {
+	_, err := w.Write([]byte("\xef\xbb\xbf"))
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	for i, table := range tables {
+		if i > 0 {
+			err = cw.Write(nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(tables) > 1 && table.Name != "" {
+			err = cw.Write([]string{table.Name})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = cw.Write(table.Header)
+		if err != nil {
+			return err
+		}
+
+		err = cw.WriteAll(table.Rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteXLSX(w io.Writer, tables ...Table) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	defaultSheet := f.GetSheetName(0)
+	for i, table := range tables {
+		name := table.Name
+		if name == "" {
+			name = fmt.Sprintf("Sheet%d", i+1)
+		}
+
+		if i == 0 {
+			err := f.SetSheetName(defaultSheet, name)
+			if err != nil {
+				return err
+			}
+		} else {
+			_, err := f.NewSheet(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		sw, err := f.NewStreamWriter(name)
+		if err != nil {
+			return err
+		}
+
+		rows := append([][]string{table.Header}, table.Rows...)
+		for j, row := range rows {
+			cells := make([]any, len(row))
+			for k := range row {
+				cells[k] = row[k]
+			}
+
+			cell, err := excelize.CoordinatesToCellName(1, j+1)
+			if err != nil {
+				return err
+			}
+
+			err = sw.SetRow(cell, cells)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = sw.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	return f.Write(w)
+}
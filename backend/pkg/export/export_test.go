@@ -0,0 +1,61 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var tables = []Table{
+	{
+		Name:   "visit",
+		Header: []string{"uv", "pv"},
+		Rows:   [][]string{{"1", "2"}},
+	},
+	{
+		Name:   "previous",
+		Header: []string{"uv", "pv"},
+		Rows:   [][]string{{"3", "4"}},
+	},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, FormatCSV, tables...)
+	if err != nil {
+		t.Fatalf("write csv failed: %v", err)
+	}
+
+	expect := "\xef\xbb\xbfvisit\nuv,pv\n1,2\n\nprevious\nuv,pv\n3,4\n"
+	if buf.String() != expect {
+		t.Fatalf("unexpected csv: %q", buf.String())
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, FormatXLSX, tables...)
+	if err != nil {
+		t.Fatalf("write xlsx failed: %v", err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatalf("open xlsx failed: %v", err)
+	}
+	defer f.Close()
+
+	if sheets := f.GetSheetList(); len(sheets) != 2 || sheets[1] != "previous" {
+		t.Fatalf("unexpected sheets: %v", sheets)
+	}
+
+	val, err := f.GetCellValue("previous", "B2")
+	if err != nil || val != "4" {
+		t.Fatalf("unexpected cell value: %s %v", val, err)
+	}
+
+	if err := Write(&buf, Format("pdf")); err == nil {
+		t.Fatal("expect unsupported format error")
+	}
+}
@@ -0,0 +1,267 @@
+package gql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/cache"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+const (
+	// ListArg 列表字段的分页参数，计算复杂度时作为子字段的倍数
+	ListArg          = "first"
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var (
+	ErrPersistedQueryNotFound    = errors.New("PersistedQueryNotFound")
+	ErrPersistedQueryMismatch    = errors.New("provided sha does not match query")
+	ErrPersistedQueryUnsupported = errors.New("PersistedQueryNotSupported")
+	ErrEmptyQuery                = errors.New("must provide query string")
+)
+
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+type Extensions struct {
+	PersistedQuery *PersistedQuery `json:"persistedQuery,omitempty"`
+}
+
+type Request struct {
+	Query         string         `json:"query" form:"query"`
+	OperationName string         `json:"operationName" form:"operationName"`
+	Variables     map[string]any `json:"variables" form:"-"`
+	Extensions    Extensions     `json:"extensions" form:"-"`
+}
+
+// PersistedStore 保存 Automatic Persisted Queries，客户端只需要上传 query 的 sha256
+type PersistedStore struct {
+	cache cache.Cache[string]
+}
+
+func NewPersistedStore(backend cache.Backend, dur time.Duration) *PersistedStore {
+	return &PersistedStore{cache: cache.NewShared[string](backend, "graphql_persisted_query", dur)}
+}
+
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Resolve 根据 persistedQuery 扩展补全或保存 query
+func (p *PersistedStore) Resolve(req *Request) error {
+	pq := req.Extensions.PersistedQuery
+	if pq == nil {
+		if req.Query == "" {
+			return ErrEmptyQuery
+		}
+		return nil
+	}
+
+	if pq.Version != 1 {
+		return ErrPersistedQueryUnsupported
+	}
+
+	hash := strings.ToLower(pq.Sha256Hash)
+	if req.Query == "" {
+		query, ok := p.cache.Get(hash)
+		if !ok {
+			return ErrPersistedQueryNotFound
+		}
+
+		req.Query = query
+		return nil
+	}
+
+	if Hash(req.Query) != hash {
+		return ErrPersistedQueryMismatch
+	}
+
+	return p.cache.Set(hash, req.Query)
+}
+
+func Parse(query string) (*ast.Document, error) {
+	return parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{
+			Body: []byte(query),
+			Name: "GraphQL request",
+		}),
+	})
+}
+
+type Limit struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+type analyzer struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	visiting  map[string]bool
+}
+
+// Check 校验查询深度与复杂度，每个字段复杂度为 1，列表字段的子字段按 first 参数放大
+// 字段在 schema 中接受 first 参数但查询未传时按 DefaultListLimit 放大，与解析时的默认值一致
+func (l Limit) Check(schema *graphql.Schema, doc *ast.Document, operationName string, variables map[string]any) error {
+	a := analyzer{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+
+	var ops []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				ops = append(ops, d)
+			}
+		}
+	}
+
+	for _, op := range ops {
+		var root graphql.Type
+		if schema != nil && op.Operation == ast.OperationTypeQuery {
+			root = schema.QueryType()
+		}
+
+		depth, complexity := a.selectionSet(op.SelectionSet, root)
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return fmt.Errorf("query depth %d exceeds limit %d", depth, l.MaxDepth)
+		}
+		if l.MaxComplexity > 0 && complexity > l.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds limit %d", complexity, l.MaxComplexity)
+		}
+	}
+
+	return nil
+}
+
+// fieldDef 查询字段在 schema 中的定义，schema 为空或无法确定父类型时返回 nil
+func (a *analyzer) fieldDef(parent graphql.Type, name string) *graphql.FieldDefinition {
+	switch t := parent.(type) {
+	case *graphql.Object:
+		return t.Fields()[name]
+	case *graphql.Interface:
+		return t.Fields()[name]
+	}
+
+	return nil
+}
+
+func (a *analyzer) namedType(typeCondition *ast.Named, parent graphql.Type) graphql.Type {
+	if typeCondition == nil || typeCondition.Name == nil {
+		return parent
+	}
+	if a.schema == nil {
+		return nil
+	}
+
+	return a.schema.Type(typeCondition.Name.Value)
+}
+
+func (a *analyzer) selectionSet(set *ast.SelectionSet, parent graphql.Type) (depth int, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, c int
+		switch s := selection.(type) {
+		case *ast.Field:
+			// 内省查询不计入限制
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+
+			def := a.fieldDef(parent, s.Name.Value)
+			var child graphql.Type
+			if def != nil {
+				child, _ = graphql.GetNamed(def.Type).(graphql.Type)
+			}
+
+			d, c = a.selectionSet(s.SelectionSet, child)
+			d++
+			c = 1 + c*a.listSize(s, def)
+		case *ast.InlineFragment:
+			d, c = a.selectionSet(s.SelectionSet, a.namedType(s.TypeCondition, parent))
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment, ok := a.fragments[name]
+			if !ok || a.visiting[name] {
+				continue
+			}
+
+			a.visiting[name] = true
+			d, c = a.selectionSet(fragment.SelectionSet, a.namedType(fragment.TypeCondition, parent))
+			a.visiting[name] = false
+		}
+
+		depth = max(depth, d)
+		complexity += c
+	}
+
+	return depth, complexity
+}
+
+func (a *analyzer) listSize(field *ast.Field, def *graphql.FieldDefinition) int {
+	if field.SelectionSet == nil {
+		return 1
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != ListArg {
+			continue
+		}
+
+		var n int
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			n, _ = strconv.Atoi(v.Value)
+		case *ast.Variable:
+			switch val := a.variables[v.Name.Value].(type) {
+			case float64:
+				n = int(val)
+			case int:
+				n = val
+			}
+		}
+
+		return ListLimit(n)
+	}
+
+	if def != nil {
+		for _, arg := range def.Args {
+			if arg.Name() == ListArg {
+				return DefaultListLimit
+			}
+		}
+	}
+
+	return 1
+}
+
+// ListLimit 修正列表字段的 first 参数
+func ListLimit(n int) int {
+	if n <= 0 {
+		return DefaultListLimit
+	}
+
+	return min(n, MaxListLimit)
+}
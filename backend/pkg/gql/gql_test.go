@@ -0,0 +1,176 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestLimitCheck(t *testing.T) {
+	query := `
+query Q($n: Int) {
+	forums {
+		discussions(first: $n) {
+			nodes { ...disc }
+		}
+	}
+	__schema { types { name } }
+}
+
+fragment disc on Discussion {
+	id
+	comments(first: 10) {
+		nodes { id author { name } }
+	}
+}`
+
+	doc, err := Parse(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 深度 7，复杂度 2 + (2 + 41) * n，first 超过 100 时按 100 计算
+	cases := []struct {
+		n     float64
+		limit Limit
+		fail  bool
+	}{
+		{n: 2, limit: Limit{MaxDepth: 7, MaxComplexity: 88}},
+		{n: 2, limit: Limit{MaxDepth: 6}, fail: true},
+		{n: 2, limit: Limit{MaxComplexity: 87}, fail: true},
+		{n: 1000, limit: Limit{MaxComplexity: 4302}},
+		{n: 1000, limit: Limit{MaxComplexity: 4301}, fail: true},
+	}
+
+	for _, c := range cases {
+		err = c.limit.Check(nil, doc, "Q", map[string]any{"n": c.n})
+		if (err != nil) != c.fail {
+			t.Errorf("n=%v limit=%+v: unexpected result %v", c.n, c.limit, err)
+		}
+	}
+}
+
+func TestLimitCheckDefaultFirst(t *testing.T) {
+	listArgs := graphql.FieldConfigArgument{ListArg: &graphql.ArgumentConfig{Type: graphql.Int}}
+	comment := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "Comment",
+		Fields: graphql.Fields{"id": &graphql.Field{Type: graphql.Int}},
+	})
+	discussion := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Discussion",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.Int},
+			"comments": &graphql.Field{
+				Args: listArgs,
+				Type: graphql.NewObject(graphql.ObjectConfig{
+					Name:   "CommentConnection",
+					Fields: graphql.Fields{"nodes": &graphql.Field{Type: graphql.NewList(comment)}},
+				}),
+			},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"discussions": &graphql.Field{
+					Args: listArgs,
+					Type: graphql.NewObject(graphql.ObjectConfig{
+						Name:   "DiscussionConnection",
+						Fields: graphql.Fields{"nodes": &graphql.Field{Type: graphql.NewList(discussion)}},
+					}),
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := Parse(`
+{
+	discussions {
+		nodes { ...disc }
+	}
+}
+
+fragment disc on Discussion {
+	id
+	comments { nodes { id } }
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未传 first 时按 DefaultListLimit 计算，复杂度 1 + (1 + (1 + (1 + 2 * 20))) * 20
+	err = Limit{MaxComplexity: 861}.Check(&schema, doc, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Limit{MaxComplexity: 860}.Check(&schema, doc, "", nil)
+	if err == nil {
+		t.Fatal("expect complexity error when first is omitted")
+	}
+}
+
+func TestPersistedStore(t *testing.T) {
+	store := NewPersistedStore(nil, time.Minute)
+	query := "{ me { id } }"
+	hash := Hash(query)
+
+	req := Request{Extensions: Extensions{PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: hash}}}
+	if err := store.Resolve(&req); !errors.Is(err, ErrPersistedQueryNotFound) {
+		t.Fatalf("expect not found, got %v", err)
+	}
+
+	req.Query = "{ me { name } }"
+	if err := store.Resolve(&req); !errors.Is(err, ErrPersistedQueryMismatch) {
+		t.Fatalf("expect mismatch, got %v", err)
+	}
+
+	req.Query = query
+	if err := store.Resolve(&req); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Query = ""
+	if err := store.Resolve(&req); err != nil || req.Query != query {
+		t.Fatalf("unexpected query %q: %v", req.Query, err)
+	}
+
+	if err := store.Resolve(&Request{}); !errors.Is(err, ErrEmptyQuery) {
+		t.Fatalf("expect empty query, got %v", err)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	var batches [][]int
+	loader := NewLoader(context.Background(), func(_ context.Context, keys []int) (map[int]int, error) {
+		slices.Sort(keys)
+		batches = append(batches, keys)
+
+		res := make(map[int]int, len(keys))
+		for _, k := range keys {
+			res[k] = k * 10
+		}
+		return res, nil
+	})
+
+	thunks := []func() (int, error){loader.Load(1), loader.Load(2), loader.Load(1)}
+	for i, want := range []int{10, 20, 10} {
+		got, err := thunks[i]()
+		if err != nil || got != want {
+			t.Fatalf("unexpected value %d: %v", got, err)
+		}
+	}
+
+	got, _ := loader.Load(2)()
+	if got != 20 || len(batches) != 1 || !slices.Equal(batches[0], []int{1, 2}) {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+}
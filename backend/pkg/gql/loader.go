@@ -0,0 +1,69 @@
+package gql
+
+import (
+	"context"
+	"sync"
+)
+
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+type loaderResult[V any] struct {
+	value V
+	err   error
+}
+
+// Loader 请求级别的批量加载器，Load 只记录 key，
+// 第一次执行返回的 thunk 时把所有待加载的 key 合并为一次查询，避免 N+1
+type Loader[K comparable, V any] struct {
+	ctx   context.Context
+	fetch BatchFunc[K, V]
+
+	mu      sync.Mutex
+	pending map[K]struct{}
+	results map[K]*loaderResult[V]
+}
+
+func NewLoader[K comparable, V any](ctx context.Context, fetch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		ctx:     ctx,
+		fetch:   fetch,
+		pending: make(map[K]struct{}),
+		results: make(map[K]*loaderResult[V]),
+	}
+}
+
+func (l *Loader[K, V]) Load(key K) func() (V, error) {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.pending[key] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, ok := l.results[key]; !ok {
+			l.dispatch()
+		}
+
+		res := l.results[key]
+		return res.value, res.err
+	}
+}
+
+func (l *Loader[K, V]) dispatch() {
+	keys := make([]K, 0, len(l.pending))
+	for key := range l.pending {
+		keys = append(keys, key)
+	}
+	l.pending = make(map[K]struct{})
+
+	values, err := l.fetch(l.ctx, keys)
+	for _, key := range keys {
+		l.results[key] = &loaderResult[V]{
+			value: values[key],
+			err:   err,
+		}
+	}
+}
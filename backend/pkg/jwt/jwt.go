@@ -17,6 +17,9 @@ type Claims struct {
 	jwt.RegisteredClaims `json:"-"`
 }
 
+// ErrExpired access token 已过期，可以使用 refresh token 换取新的 token
+var ErrExpired = errors.New("token expired")
+
 type Generator struct {
 	cfg    config.JWT
 	logger *glog.Logger
@@ -38,7 +41,7 @@ func (g *Generator) Gen(ctx context.Context, userCore model.UserCore) (string, e
 		userCore,
 		jwt.RegisteredClaims{
 			Issuer:    "chaitin-koala",
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(g.cfg.Expire) * time.Second)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(g.TokenExpire(userCore.Cors))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	})
@@ -52,6 +55,24 @@ func (g *Generator) Gen(ctx context.Context, userCore model.UserCore) (string, e
 
 }
 
+// AccessExpire 未配置时沿用会话有效期
+func (g *Generator) AccessExpire() time.Duration {
+	if g.cfg.AccessExpire > 0 {
+		return time.Duration(g.cfg.AccessExpire) * time.Second
+	}
+
+	return time.Duration(g.cfg.Expire) * time.Second
+}
+
+// TokenExpire cors 登录的 token 保存在插件或第三方页面，无法通过 cookie 刷新，沿用会话有效期
+func (g *Generator) TokenExpire(cors bool) time.Duration {
+	if cors {
+		return time.Duration(g.cfg.Expire) * time.Second
+	}
+
+	return g.AccessExpire()
+}
+
 func (g *Generator) Verify(token string) (*model.UserCore, error) {
 	var claims Claims
 	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
@@ -62,6 +83,10 @@ func (g *Generator) Verify(token string) (*model.UserCore, error) {
 		return []byte(g.cfg.Secret), nil
 	})
 	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpired
+		}
+
 		return nil, err
 	}
 
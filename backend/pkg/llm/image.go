@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var ImageDescribePrompt = `
+你是图片理解助手，用户在提问时附带了这张图片。请完成以下任务：
+
+1. 如果图片中有文字（如报错弹窗、日志、终端输出、配置界面），逐字提取其中的关键文字，保留错误码、报错信息、版本号等原文
+2. 用一到三句话描述图片内容，例如软件界面、操作步骤、异常现象
+3. 如果是照片或与技术问题无关的图片，只做简短描述
+
+直接输出结果，不要添加解释，不要使用 Markdown 标题，总长度不超过 500 字。
+`
+
+var imageRe = regexp.MustCompile(`!\[([^\]]*)\]\(\s*([^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+
+// ImageURLs 按出现顺序返回 Markdown 内容中去重后的图片地址
+func ImageURLs(content string) []string {
+	var (
+		res  []string
+		seen = make(map[string]struct{})
+	)
+	for _, match := range imageRe.FindAllStringSubmatch(content, -1) {
+		if _, ok := seen[match[2]]; ok {
+			continue
+		}
+		seen[match[2]] = struct{}{}
+		res = append(res, match[2])
+	}
+
+	return res
+}
+
+// InlineImageDescriptions 在 Markdown 图片后追加图片描述，没有描述的图片保持不变
+func InlineImageDescriptions(content string, descriptions map[string]string) string {
+	if len(descriptions) == 0 {
+		return content
+	}
+
+	return imageRe.ReplaceAllStringFunc(content, func(s string) string {
+		match := imageRe.FindStringSubmatch(s)
+		desc := strings.TrimSpace(descriptions[match[2]])
+		if desc == "" {
+			return s
+		}
+
+		return s + "\n<image_content>\n" + desc + "\n</image_content>\n"
+	})
+}
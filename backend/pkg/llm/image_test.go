@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImageURLs(t *testing.T) {
+	content := "登录报错\n![err](/koala/public/a.png)\n![](/koala/public/b.jpg \"title\")\n再贴一次 ![err](/koala/public/a.png)"
+
+	urls := ImageURLs(content)
+	if !slices.Equal(urls, []string{"/koala/public/a.png", "/koala/public/b.jpg"}) {
+		t.Fatalf("unexpected urls: %v", urls)
+	}
+
+	if len(ImageURLs("[link](/koala/public/a.png)")) != 0 {
+		t.Fatal("plain link should not be treated as image")
+	}
+}
+
+func TestInlineImageDescriptions(t *testing.T) {
+	content := "![err](/koala/public/a.png)\n![](/koala/public/b.jpg)"
+
+	res := InlineImageDescriptions(content, map[string]string{
+		"/koala/public/a.png": "Error 502 Bad Gateway",
+	})
+
+	if !strings.Contains(res, "![err](/koala/public/a.png)\n<image_content>\nError 502 Bad Gateway\n</image_content>") {
+		t.Fatalf("description not inlined: %q", res)
+	}
+	if strings.Count(res, "<image_content>") != 1 {
+		t.Fatalf("image without description should be unchanged: %q", res)
+	}
+}
@@ -13,6 +13,17 @@ type AskSessionStreamItem struct {
 type Stream[T any] struct {
 	c    chan T
 	stop chan struct{}
+	err  error
+}
+
+// SetErr 记录生成过程中的错误，需要在输出结束前调用
+func (l *Stream[T]) SetErr(err error) {
+	l.err = err
+}
+
+// Err 输出结束后返回生成过程中的错误，正常结束时为 nil
+func (l *Stream[T]) Err() error {
+	return l.err
 }
 
 func (l *Stream[T]) Close() {
@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+// 读取单条消息的最大长度
+const maxMessageSize = 10 << 20
+
+type message struct {
+	ID     *mcpgo.RequestId `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+// Stdio 将 stdio 上按行分隔的 JSON-RPC 消息转发到服务端的 streamable http 接口，
+// 供只支持 stdio 的 agent 通过 api token 访问远端的 KoalaQA
+func Stdio(ctx context.Context, url string, token string, in io.Reader, out io.Writer) error {
+	client, err := transport.NewStreamableHTTP(url, transport.WithHTTPHeaders(map[string]string{
+		"Authorization": "Bearer " + token,
+	}))
+	if err != nil {
+		return err
+	}
+
+	err = client.Start(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var mu sync.Mutex
+	write := func(v any) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		out.Write(append(data, '\n'))
+	}
+
+	client.SetNotificationHandler(func(notification mcpgo.JSONRPCNotification) {
+		write(notification)
+	})
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg message
+		err = json.Unmarshal(line, &msg)
+		if err != nil {
+			write(mcpgo.NewJSONRPCError(mcpgo.NewRequestId(nil), mcpgo.PARSE_ERROR, err.Error(), nil))
+			continue
+		}
+
+		// 不支持服务端向客户端发起的请求，客户端的响应直接忽略
+		if msg.Method == "" {
+			continue
+		}
+
+		var params any
+		if len(msg.Params) > 0 {
+			params = msg.Params
+		}
+
+		if msg.ID == nil {
+			var notification mcpgo.JSONRPCNotification
+			err = json.Unmarshal(line, &notification)
+			if err == nil {
+				err = client.SendNotification(ctx, notification)
+			}
+			if err != nil {
+				write(mcpgo.NewJSONRPCError(mcpgo.NewRequestId(nil), mcpgo.INTERNAL_ERROR, err.Error(), nil))
+			}
+			continue
+		}
+
+		req := transport.JSONRPCRequest{
+			JSONRPC: mcpgo.JSONRPC_VERSION,
+			ID:      *msg.ID,
+			Method:  msg.Method,
+			Params:  params,
+		}
+
+		// initialize 需要先拿到会话 id，其余请求并发转发
+		if msg.Method == string(mcpgo.MethodInitialize) {
+			forward(ctx, client, req, write)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			forward(ctx, client, req, write)
+		}()
+	}
+
+	err = scanner.Err()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+func forward(ctx context.Context, client *transport.StreamableHTTP, req transport.JSONRPCRequest, write func(any)) {
+	res, err := client.SendRequest(ctx, req)
+	if err != nil {
+		write(mcpgo.NewJSONRPCError(req.ID, mcpgo.INTERNAL_ERROR, err.Error(), nil))
+		return
+	}
+
+	write(res)
+}
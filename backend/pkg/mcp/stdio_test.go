@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestStdio(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(false))
+	s.AddTool(mcpgo.NewTool("echo", mcpgo.WithString("text")), func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		return mcpgo.NewToolResultText(req.GetString("text", "")), nil
+	})
+
+	handler := server.NewStreamableHTTPServer(s)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1.0.0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hello"}}}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	err := Stdio(context.Background(), ts.URL, "token", strings.NewReader(in), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := make(map[string]map[string]any)
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var msg map[string]any
+		err = json.Unmarshal(scanner.Bytes(), &msg)
+		if err != nil {
+			t.Fatalf("invalid output %q: %v", scanner.Text(), err)
+		}
+
+		id, _ := json.Marshal(msg["id"])
+		res[string(id)] = msg
+	}
+
+	if _, ok := res["1"]["result"]; !ok {
+		t.Fatalf("initialize failed: %v", res["1"])
+	}
+
+	if _, ok := res["null"]["error"]; !ok {
+		t.Fatalf("expect parse error: %v", res)
+	}
+
+	data, _ := json.Marshal(res["2"]["result"])
+	if !strings.Contains(string(data), `"text":"hello"`) {
+		t.Fatalf("unexpected tool result: %s", data)
+	}
+}
+
+func TestStdioUnauthorized(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	err := Stdio(context.Background(), ts.URL, "bad", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), `"error"`) || !strings.Contains(out.String(), `"id":1`) {
+		t.Fatalf("expect error response: %s", out.String())
+	}
+}
@@ -204,8 +204,14 @@ func newNatsPublisher(js *natsJS) (Publisher, error) {
 	}, nil
 }
 
+// natsConn 供缓存、限流等需要直接访问 nats 的组件使用
+func natsConn(js *natsJS) *nats.Conn {
+	return js.conn
+}
+
 var natsModule = fx.Options(
 	fx.Provide(newNatsJS),
+	fx.Provide(natsConn),
 	fx.Provide(newNatsPublisher),
 	fx.Provide(newNatsSubscriber),
 	fx.Invoke(func(lc fx.Lifecycle, cfg config.Config, inJS *natsJS, subscriber Subscriber) error {
@@ -0,0 +1,182 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// 与 OpenAI Chat Completions 接口兼容的请求与响应结构，只实现问答需要的字段
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+
+	ObjectCompletion = "chat.completion"
+	ObjectChunk      = "chat.completion.chunk"
+
+	FinishReasonStop = "stop"
+)
+
+// Content 兼容字符串和 [{"type":"text","text":"..."}] 两种格式，非文本内容会被忽略
+type Content string
+
+func (c *Content) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*c = Content(text)
+		return nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	err := json.Unmarshal(data, &parts)
+	if err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+
+	*c = Content(strings.Join(texts, "\n"))
+	return nil
+}
+
+type Message struct {
+	Role    string  `json:"role" binding:"required"`
+	Content Content `json:"content"`
+}
+
+type Request struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages" binding:"required,min=1,dive"`
+	Stream   bool      `json:"stream"`
+	User     string    `json:"user"`
+}
+
+// Split 拆分出最后一条用户消息作为问题，之前的用户与助手消息作为上下文，system 消息会被忽略
+func (r *Request) Split() (string, []Message, error) {
+	last := -1
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == RoleUser {
+			last = i
+			break
+		}
+	}
+
+	if last < 0 || strings.TrimSpace(string(r.Messages[last].Content)) == "" {
+		return "", nil, errors.New("messages must contain a non-empty user message")
+	}
+
+	history := make([]Message, 0, last)
+	for _, msg := range r.Messages[:last] {
+		if msg.Role != RoleUser && msg.Role != RoleAssistant {
+			continue
+		}
+
+		history = append(history, msg)
+	}
+
+	return string(r.Messages[last].Content), history, nil
+}
+
+type Citation struct {
+	ID    uint   `json:"id"`
+	KBID  uint   `json:"kb_id"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+}
+
+// Extension 响应中的扩展字段，流式响应只在最后一个 chunk 返回
+type Extension struct {
+	SessionID string     `json:"session_id"`
+	Citations []Citation `json:"citations"`
+}
+
+type Delta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type Choice struct {
+	Index        int      `json:"index"`
+	Message      *Message `json:"message,omitempty"`
+	Delta        *Delta   `json:"delta,omitempty"`
+	FinishReason *string  `json:"finish_reason"`
+}
+
+type Response struct {
+	ID      string     `json:"id"`
+	Object  string     `json:"object"`
+	Created int64      `json:"created"`
+	Model   string     `json:"model"`
+	Choices []Choice   `json:"choices"`
+	Koala   *Extension `json:"koala,omitempty"`
+}
+
+func NewID() string {
+	return "chatcmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
+
+type ErrorBody struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    string  `json:"code"`
+}
+
+// Error OpenAI 格式的错误响应
+type Error struct {
+	Body ErrorBody `json:"error"`
+
+	status int
+}
+
+func (e *Error) Error() string {
+	return e.Body.Message
+}
+
+func (e *Error) StatusCode() int {
+	return e.status
+}
+
+func NewError(status int, typ string, code string, msg string) *Error {
+	return &Error{
+		Body:   ErrorBody{Message: msg, Type: typ, Code: code},
+		status: status,
+	}
+}
+
+func InvalidRequest(msg string) *Error {
+	return NewError(http.StatusBadRequest, "invalid_request_error", "invalid_request", msg)
+}
+
+func InvalidAPIKey(msg string) *Error {
+	return NewError(http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", msg)
+}
+
+func ServerError(msg string) *Error {
+	return NewError(http.StatusInternalServerError, "server_error", "internal_error", msg)
+}
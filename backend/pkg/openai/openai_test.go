@@ -0,0 +1,66 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestContentUnmarshal(t *testing.T) {
+	var req Request
+	err := json.Unmarshal([]byte(`{"messages":[
+		{"role":"user","content":"hello"},
+		{"role":"user","content":[{"type":"text","text":"a"},{"type":"image_url","image_url":{"url":"x"}},{"type":"text","text":"b"}]}
+	]}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Messages[0].Content != "hello" || req.Messages[1].Content != "a\nb" {
+		t.Fatalf("unexpected messages: %+v", req.Messages)
+	}
+
+	err = json.Unmarshal([]byte(`{"role":"user","content":1}`), &Message{})
+	if err == nil {
+		t.Fatal("expect invalid content error")
+	}
+}
+
+func TestRequestSplit(t *testing.T) {
+	req := Request{Messages: []Message{
+		{Role: RoleSystem, Content: "be brief"},
+		{Role: RoleUser, Content: "q1"},
+		{Role: RoleAssistant, Content: "a1"},
+		{Role: "tool", Content: "ignored"},
+		{Role: RoleUser, Content: "q2"},
+		{Role: RoleAssistant, Content: "prefill"},
+	}}
+
+	question, history, err := req.Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if question != "q2" || len(history) != 2 || history[0].Content != "q1" || history[1].Content != "a1" {
+		t.Fatalf("unexpected split: %q %+v", question, history)
+	}
+
+	for _, msgs := range [][]Message{
+		{{Role: RoleSystem, Content: "x"}},
+		{{Role: RoleUser, Content: "  "}},
+	} {
+		_, _, err = (&Request{Messages: msgs}).Split()
+		if err == nil {
+			t.Errorf("expect error for %+v", msgs)
+		}
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	data, err := json.Marshal(InvalidAPIKey("bad key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"error":{"message":"bad key","type":"invalid_request_error","param":null,"code":"invalid_api_key"}}` {
+		t.Fatalf("unexpected error json: %s", data)
+	}
+}
@@ -1,9 +1,36 @@
 package oss
 
-import "go.uber.org/fx"
+import (
+	"fmt"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+	"go.uber.org/fx"
+)
+
+// New 根据类型创建对象存储客户端，typ 为空时使用配置中的类型
+func New(cfg config.Config, typ string) (Client, error) {
+	if typ == "" {
+		typ = cfg.OSS.Type
+	}
+
+	switch typ {
+	case "", "minio":
+		return newMinio(cfg)
+	case "s3":
+		return newS3(cfg.OSS.S3)
+	case "local":
+		return newLocal(cfg.OSS.Local, cfg.JWT.Secret)
+	default:
+		return nil, fmt.Errorf("unsupported oss type: %s", typ)
+	}
+}
+
+func newClient(cfg config.Config) (Client, error) {
+	return New(cfg, "")
+}
 
 var Module = fx.Options(
-	fx.Provide(newMinio),
+	fx.Provide(newClient),
 	fx.Invoke(func(client Client) {
 		global = client
 	}),
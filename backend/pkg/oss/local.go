@@ -0,0 +1,353 @@
+package oss
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/google/uuid"
+)
+
+const localTempPrefix = ".upload-"
+
+var errInvalidPath = errors.New("invalid object path")
+
+// localClient 本地磁盘存储，每个 bucket 对应 Dir 下的一个目录，对象通过 API 服务访问
+type localClient struct {
+	logger      *glog.Logger
+	roots       map[string]*os.Root
+	buckets     []string
+	maxFileSize int
+	signKey     []byte
+	signURL     string
+}
+
+func (l *localClient) root(bucket string) (string, *os.Root, error) {
+	if bucket == "" {
+		bucket = l.buckets[0]
+	}
+
+	root, ok := l.roots[bucket]
+	if !ok {
+		return "", nil, ErrBucketNotConfigure
+	}
+
+	return bucket, root, nil
+}
+
+// cleanKey 规范化对象路径，os.Root 会拒绝越过 bucket 目录的访问，这里提前拒绝明显非法的路径
+func cleanKey(key string) (string, error) {
+	key = path.Clean("/" + key)
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || key == "." {
+		return "", errInvalidPath
+	}
+
+	return key, nil
+}
+
+func (l *localClient) Upload(ctx context.Context, dir string, reader io.Reader, optFuncs ...optFunc) (string, error) {
+	o := getOpt(optFuncs...)
+
+	key, err := uploadKey(&o, dir, l.buckets)
+	if err != nil {
+		return "", err
+	}
+
+	key, err = cleanKey(filepath.ToSlash(key))
+	if err != nil {
+		return "", err
+	}
+
+	var limit int64 = -1
+	if o.limitSize {
+		if l.maxFileSize == 0 {
+			l.logger.Warn("max_file_size not configure, skip check file_size")
+		} else if o.fileSize > l.maxFileSize {
+			return "", ErrFileSizeOverflow
+		} else {
+			limit = int64(l.maxFileSize)
+		}
+	}
+
+	_, root, err := l.root(o.bucket)
+	if err != nil {
+		return "", err
+	}
+
+	err = root.MkdirAll(path.Dir(key), 0o755)
+	if err != nil {
+		return "", err
+	}
+
+	// 先写入临时文件再重命名，避免读到未写完的对象
+	tmp := path.Join(path.Dir(key), localTempPrefix+uuid.NewString())
+	f, err := root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+
+	if limit >= 0 {
+		reader = io.LimitReader(reader, limit+1)
+	}
+
+	n, err := io.Copy(f, reader)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && limit >= 0 && n > limit {
+		err = ErrFileSizeOverflow
+	}
+	if err == nil {
+		err = root.Rename(tmp, key)
+	}
+	if err != nil {
+		if removeErr := root.Remove(tmp); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			l.logger.WithContext(ctx).WithErr(removeErr).With("path", tmp).Warn("remove temp file failed")
+		}
+		return "", err
+	}
+
+	if o.retURL {
+		return l.Sign(ctx, key, WithBucket(o.bucket), WithSignTimeout(o.signTimeout), WithSignURL(o.signURL))
+	}
+
+	return path.Join("/", o.bucket, key), nil
+}
+
+func (l *localClient) Delete(ctx context.Context, p string, optFuncs ...optFunc) error {
+	o := getOpt(optFuncs...)
+
+	bucket, root, err := l.root(o.bucket)
+	if err != nil {
+		return err
+	}
+
+	key, err := cleanKey(p)
+	if err != nil {
+		return err
+	}
+
+	l.logger.WithContext(ctx).With("bucket", bucket).With("path", key).Info("remove local objects")
+
+	err = root.RemoveAll(key)
+	if err != nil {
+		l.logger.WithContext(ctx).WithErr(err).With("object", key).Warn("delete object failed")
+	}
+	return nil
+}
+
+func (l *localClient) Download(ctx context.Context, p string, optFuncs ...optFunc) (io.ReadCloser, error) {
+	o := getOpt(optFuncs...)
+
+	_, root, err := l.root(o.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := cleanKey(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return root.Open(key)
+}
+
+func (l *localClient) signature(bucket string, key string, expires int64) string {
+	h := hmac.New(sha256.New, l.signKey)
+	fmt.Fprintf(h, "%s/%s:%d", bucket, key, expires)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (l *localClient) verify(bucket string, key string, query url.Values) bool {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return false
+	}
+
+	expect, _ := hex.DecodeString(l.signature(bucket, key, expires))
+	return hmac.Equal(signature, expect)
+}
+
+func (l *localClient) Sign(ctx context.Context, p string, optFuncs ...optFunc) (string, error) {
+	o := getOpt(optFuncs...)
+
+	bucket, _, err := l.root(o.bucket)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := cleanKey(p)
+	if err != nil {
+		return "", err
+	}
+
+	if o.signURL == "" {
+		o.signURL = l.signURL
+	}
+
+	u, err := util.ParseHTTP(o.signURL)
+	if err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(o.signTimeout).Unix()
+	u.Path = path.Join("/", bucket, key)
+	u.RawQuery = url.Values{
+		"expires":   []string{strconv.FormatInt(expires, 10)},
+		"signature": []string{l.signature(bucket, key, expires)},
+	}.Encode()
+
+	l.logger.WithContext(ctx).With("bucket", bucket).With("sign_object", key).With("sign_url", u.String()).Debug("sign object url")
+
+	return u.String(), nil
+}
+
+func (l *localClient) Walk(ctx context.Context, fn func(ObjectInfo) error, optFuncs ...optFunc) error {
+	o := getOpt(optFuncs...)
+
+	_, root, err := l.root(o.bucket)
+	if err != nil {
+		return err
+	}
+
+	return fs.WalkDir(root.FS(), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() || strings.HasPrefix(d.Name(), localTempPrefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return fn(ObjectInfo{
+			Path: p,
+			Size: info.Size(),
+		})
+	})
+}
+
+func (l *localClient) Buckets() []string {
+	return l.buckets
+}
+
+// ServeObject public 目录下的对象可直接访问，其余对象需要校验签名
+func (l *localClient) ServeObject(w http.ResponseWriter, r *http.Request, bucket string, p string) {
+	_, root, err := l.root(bucket)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	key, err := cleanKey(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !strings.HasPrefix(key, "public/") && !l.verify(bucket, key, r.URL.Query()) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	f, err := root.Open(key)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			l.logger.WithContext(r.Context()).WithErr(err).With("bucket", bucket).With("path", key).Warn("open object failed")
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if ct := contentType(path.Ext(key)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	http.ServeContent(w, r, path.Base(key), info.ModTime(), f)
+}
+
+func newLocal(cfg config.Local, jwtSecret string) (*localClient, error) {
+	glog.With("dir", cfg.Dir).With("buckets", cfg.Buckets).Debug("open local oss")
+
+	if len(cfg.Buckets) == 0 {
+		return nil, ErrBucketNotConfigure
+	}
+
+	signKey := cfg.SignKey
+	if signKey == "" {
+		if jwtSecret == "" {
+			return nil, errors.New("local oss sign key not configure")
+		}
+
+		// 未配置时从 JWT_SECRET 派生独立的密钥，签名 url 不能用于伪造 jwt
+		mac := hmac.New(sha256.New, []byte(jwtSecret))
+		mac.Write([]byte("koala-oss-sign-key"))
+		signKey = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	roots := make(map[string]*os.Root, len(cfg.Buckets))
+	for _, bucket := range slices.Compact(slices.Clone(cfg.Buckets)) {
+		if bucket == "" || bucket != path.Base(bucket) || bucket == "." || bucket == ".." {
+			return nil, fmt.Errorf("invalid bucket name: %s", bucket)
+		}
+
+		dir := filepath.Join(cfg.Dir, bucket)
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return nil, err
+		}
+
+		roots[bucket], err = os.OpenRoot(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &localClient{
+		logger:      glog.Module("oss", "local"),
+		roots:       roots,
+		buckets:     cfg.Buckets,
+		maxFileSize: cfg.MaxFileSize,
+		signKey:     []byte(signKey),
+		signURL:     cfg.SignURL,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package oss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+)
+
+func newTestLocal(t *testing.T) (*localClient, string) {
+	dir := t.TempDir()
+	client, err := newLocal(config.Local{
+		Dir:         dir,
+		Buckets:     []string{"koala", "anydoc"},
+		MaxFileSize: 8,
+		SignKey:     "test",
+		SignURL:     "http://koala-qa-api:8080",
+	}, "")
+	if err != nil {
+		t.Fatalf("new local oss failed: %v", err)
+	}
+
+	return client, dir
+}
+
+func TestLocalUpload(t *testing.T) {
+	client, dir := newTestLocal(t)
+	ctx := context.Background()
+
+	p, err := client.Upload(ctx, "avatar", strings.NewReader("data"), WithPublic(), WithFilename("a.png"))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if p != "/koala/public/avatar/a.png" {
+		t.Fatalf("unexpected path: %s", p)
+	}
+
+	_, err = client.Upload(ctx, "avatar", strings.NewReader("123456789"), WithLimitSize(), WithBucket("anydoc"))
+	if err != ErrFileSizeOverflow {
+		t.Fatalf("expect file size overflow, got: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "anydoc", "avatar"))
+	if err != nil {
+		t.Fatalf("read dir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("temp file not removed: %v", entries)
+	}
+
+	var objects []string
+	err = client.Walk(ctx, func(info ObjectInfo) error {
+		objects = append(objects, info.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+	if len(objects) != 1 || objects[0] != "public/avatar/a.png" {
+		t.Fatalf("unexpected objects: %v", objects)
+	}
+}
+
+func TestLocalPathTraversal(t *testing.T) {
+	client, dir := newTestLocal(t)
+	ctx := context.Background()
+
+	err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o644)
+	if err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+
+	for _, p := range []string{"../secret", "public/../../secret", "/../../secret"} {
+		reader, err := client.Download(ctx, p)
+		if err == nil {
+			reader.Close()
+			t.Fatalf("download %s should fail", p)
+		}
+	}
+
+	err = os.Symlink(filepath.Join(dir, "secret"), filepath.Join(dir, "koala", "link"))
+	if err != nil {
+		t.Fatalf("symlink failed: %v", err)
+	}
+
+	_, err = client.Download(ctx, "link")
+	if err == nil {
+		t.Fatal("download symlink outside bucket should fail")
+	}
+
+	_, err = client.Upload(ctx, "../../", strings.NewReader("data"), WithFilename("escape"))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape")); err == nil {
+		t.Fatal("upload escaped bucket dir")
+	}
+}
+
+func TestLocalServeObject(t *testing.T) {
+	client, _ := newTestLocal(t)
+	ctx := context.Background()
+
+	_, err := client.Upload(ctx, "doc", strings.NewReader("private"), WithFilename("a.md"))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		client.ServeObject(w, httptest.NewRequest(http.MethodGet, target, nil), "koala", strings.SplitN(strings.TrimPrefix(target, "/koala"), "?", 2)[0])
+		return w
+	}
+
+	if w := serve("/koala/doc/a.md"); w.Code != http.StatusForbidden {
+		t.Fatalf("unsigned object should be forbidden, got %d", w.Code)
+	}
+
+	signed, err := client.Sign(ctx, "doc/a.md")
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("parse sign url failed: %v", err)
+	}
+	if u.Host != "koala-qa-api:8080" || u.Path != "/koala/doc/a.md" {
+		t.Fatalf("unexpected sign url: %s", signed)
+	}
+
+	w := serve(u.RequestURI())
+	if w.Code != http.StatusOK {
+		t.Fatalf("signed object should be ok, got %d", w.Code)
+	}
+	body, _ := io.ReadAll(w.Body)
+	if string(body) != "private" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	query := u.Query()
+	query.Set("expires", "1")
+	if w := serve("/koala/doc/a.md?" + query.Encode()); w.Code != http.StatusForbidden {
+		t.Fatalf("tampered signature should be forbidden, got %d", w.Code)
+	}
+}
@@ -3,21 +3,17 @@ package oss
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
 	"net/url"
 	"path"
-	"path/filepath"
-	"slices"
 	"strings"
 
 	"github.com/chaitin/koalaqa/pkg/config"
 	"github.com/chaitin/koalaqa/pkg/glog"
 	"github.com/chaitin/koalaqa/pkg/util"
-	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
@@ -30,15 +26,11 @@ type minioClient struct {
 }
 
 func (mc *minioClient) Upload(ctx context.Context, dir string, reader io.Reader, optFuncs ...optFunc) (string, error) {
-	dir = strings.TrimPrefix(dir, "/")
 	o := getOpt(optFuncs...)
 
-	if o.public {
-		dir = path.Join("public", dir)
-	}
-
-	if o.ext != "" && !strings.HasPrefix(o.ext, ".") {
-		return "", errors.New("invalid ext format")
+	fullFilename, err := uploadKey(&o, dir, mc.buckets)
+	if err != nil {
+		return "", err
 	}
 
 	if o.fileSize < 1 {
@@ -59,29 +51,11 @@ func (mc *minioClient) Upload(ctx context.Context, dir string, reader io.Reader,
 		}
 	}
 
-	if o.bucket == "" {
-		o.bucket = mc.buckets[0]
-	} else if !slices.Contains(mc.buckets, o.bucket) {
-		return "", ErrBucketNotConfigure
-	}
-
 	putOpt := minio.PutObjectOptions{
 		ContentType: "application/octet-stream",
 	}
-	if o.filename == "" {
-		o.filename = uuid.New().String()
-		if o.ext != "" {
-			o.filename += o.ext
-		}
-	} else {
-		filenameExt := filepath.Ext(o.filename)
-		if filenameExt != "" && o.ext == "" {
-			o.ext = filenameExt
-		}
-	}
-
 	if o.ext != "" {
-		contentType := mime.TypeByExtension(strings.ToLower(o.ext))
+		contentType := contentType(o.ext)
 		if contentType != "" {
 			putOpt.ContentType = contentType
 		} else {
@@ -91,9 +65,7 @@ func (mc *minioClient) Upload(ctx context.Context, dir string, reader io.Reader,
 		mc.logger.Warn("content-type not found, use application/octet-stream")
 	}
 
-	fullFilename := filepath.Join(dir, o.filename)
-
-	_, err := mc.mc.PutObject(ctx, o.bucket, fullFilename, reader, int64(o.fileSize), putOpt)
+	_, err = mc.mc.PutObject(ctx, o.bucket, fullFilename, reader, int64(o.fileSize), putOpt)
 	if err != nil {
 		return "", err
 	}
@@ -175,6 +147,30 @@ func (mc *minioClient) Sign(ctx context.Context, path string, optFuncs ...optFun
 	return u.String(), nil
 }
 
+func (mc *minioClient) Walk(ctx context.Context, fn func(ObjectInfo) error, optFuncs ...optFunc) error {
+	o := getOpt(optFuncs...)
+
+	if o.bucket == "" {
+		o.bucket = mc.buckets[0]
+	}
+
+	for object := range mc.mc.ListObjects(ctx, o.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+
+		err := fn(ObjectInfo{
+			Path: object.Key,
+			Size: object.Size,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func initMinio(ctx context.Context, mc *minio.Client, buckets ...string) error {
 	if len(buckets) == 0 {
 		return ErrBucketNotConfigure
@@ -4,7 +4,15 @@ import (
 	"context"
 	"errors"
 	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 var (
@@ -94,11 +102,66 @@ func WithSignURL(url string) optFunc {
 	}
 }
 
+// uploadKey 校验上传参数并补全 bucket、文件名，返回对象在 bucket 中的路径
+func uploadKey(o *opt, dir string, buckets []string) (string, error) {
+	dir = strings.TrimPrefix(dir, "/")
+	if o.public {
+		dir = path.Join("public", dir)
+	}
+
+	if o.ext != "" && !strings.HasPrefix(o.ext, ".") {
+		return "", errors.New("invalid ext format")
+	}
+
+	if o.bucket == "" {
+		o.bucket = buckets[0]
+	} else if !slices.Contains(buckets, o.bucket) {
+		return "", ErrBucketNotConfigure
+	}
+
+	if o.filename == "" {
+		o.filename = uuid.New().String()
+		if o.ext != "" {
+			o.filename += o.ext
+		}
+	} else {
+		filenameExt := filepath.Ext(o.filename)
+		if filenameExt != "" && o.ext == "" {
+			o.ext = filenameExt
+		}
+	}
+
+	return filepath.Join(dir, o.filename), nil
+}
+
+// contentType 根据扩展名获取 content-type，未知类型返回空
+func contentType(ext string) string {
+	if ext == "" {
+		return ""
+	}
+
+	return mime.TypeByExtension(strings.ToLower(ext))
+}
+
+type ObjectInfo struct {
+	// Path 对象在 bucket 中的路径
+	Path string
+	Size int64
+}
+
 type Client interface {
 	Upload(ctx context.Context, dir string, reader io.Reader, optFuncs ...optFunc) (string, error)
 	Delete(ctx context.Context, path string, optFuncs ...optFunc) error
 	Download(ctx context.Context, path string, optFuncs ...optFunc) (io.ReadCloser, error)
 	Sign(ctx context.Context, path string, optFuncs ...optFunc) (string, error)
+	// Walk 遍历 bucket 中的所有对象
+	Walk(ctx context.Context, fn func(ObjectInfo) error, optFuncs ...optFunc) error
+}
+
+// Handler 由无法直接对外提供访问的存储实现，对象通过 API 服务以 /{bucket}/{path} 访问
+type Handler interface {
+	Buckets() []string
+	ServeObject(w http.ResponseWriter, r *http.Request, bucket string, path string)
 }
 
 func Upload(ctx context.Context, dir string, reader io.Reader, optFuncs ...optFunc) (string, error) {
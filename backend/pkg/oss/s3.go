@@ -0,0 +1,110 @@
+package oss
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Client 通用 S3 存储，读写与 minio 一致，公开对象通过 API 服务重定向到签名 url 访问
+type s3Client struct {
+	*minioClient
+}
+
+func (s *s3Client) Buckets() []string {
+	return s.buckets
+}
+
+func (s *s3Client) ServeObject(w http.ResponseWriter, r *http.Request, bucket string, path string) {
+	if !strings.HasPrefix(path, "public/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	u, err := s.Sign(r.Context(), path, WithBucket(bucket))
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithErr(err).With("bucket", bucket).With("path", path).Warn("sign public object failed")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// Sign 签名 url 直接访问 S3，WithSignURL 会导致签名失效，忽略
+func (s *s3Client) Sign(ctx context.Context, path string, optFuncs ...optFunc) (string, error) {
+	return s.minioClient.Sign(ctx, path, append(optFuncs, WithSignURL(""))...)
+}
+
+func initS3(ctx context.Context, mc *minio.Client, region string, buckets ...string) error {
+	if len(buckets) == 0 {
+		return ErrBucketNotConfigure
+	}
+
+	for _, bucket := range buckets {
+		exist, err := mc.BucketExists(ctx, bucket)
+		if err != nil {
+			return err
+		}
+
+		if exist {
+			continue
+		}
+
+		err = mc.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newS3(cfg config.S3) (*s3Client, error) {
+	glog.With("endpoint", cfg.Endpoint).With("region", cfg.Region).With("buckets", cfg.Buckets).Debug("connect s3")
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	mc, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.Static{
+				Value: credentials.Value{
+					AccessKeyID:     cfg.AccessKey,
+					SecretAccessKey: cfg.SecretKey,
+					SessionToken:    cfg.SessionToken,
+					SignerType:      credentials.SignatureV4,
+				},
+			},
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		}),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = initS3(context.Background(), mc, cfg.Region, cfg.Buckets...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Client{
+		minioClient: &minioClient{
+			mc:          mc,
+			logger:      glog.Module("oss", "s3"),
+			buckets:     cfg.Buckets,
+			maxFileSize: cfg.MaxFileSize,
+		},
+	}, nil
+}
@@ -1,6 +1,44 @@
 package ratelimit
 
-import "go.uber.org/fx"
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/fx"
+)
+
+// New 根据配置选择限流器，多副本部署时使用 nats 或 postgres 共享限流状态
+func New(lc fx.Lifecycle, cfg config.Config, db *database.DB, conn *nats.Conn) (Limiter, error) {
+	switch cfg.Cache.Type {
+	case "", "memory":
+		return newMemory(), nil
+	case "nats":
+		return newNatsLimiter(conn)
+	case "postgres":
+		l, err := newPGLimiter(db)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go l.clearLoop(ctx)
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				cancel()
+				return nil
+			},
+		})
+		return l, nil
+	default:
+		return nil, fmt.Errorf("unsupported ratelimit type: %s", cfg.Cache.Type)
+	}
+}
 
 var Module = fx.Options(
 	fx.Provide(New),
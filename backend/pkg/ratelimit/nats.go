@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsBucket   = "koala_ratelimit"
+	natsRetry    = 5
+	storeTimeout = time.Second * 3
+)
+
+type bucketState struct {
+	Tokens float64 `json:"t"`
+	Update int64   `json:"u"`
+}
+
+// refill 与 rate.Limiter 一致，每个 period 补充一个令牌，最多 num 个
+func (s bucketState) refill(now time.Time, period time.Duration, num int) float64 {
+	elapsed := now.Sub(time.Unix(0, s.Update))
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(num), s.Tokens+float64(elapsed)/float64(period))
+}
+
+// natsLimiter 令牌桶状态保存在 nats kv，通过 revision 做乐观锁
+type natsLimiter struct {
+	kv     jetstream.KeyValue
+	logger *glog.Logger
+}
+
+func (l *natsLimiter) Allow(key string, period time.Duration, num int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	allow, err := l.allow(ctx, base64.RawURLEncoding.EncodeToString([]byte(key)), period, num)
+	if err != nil {
+		// 存储不可用时放行，避免影响正常请求
+		l.logger.WithErr(err).With("key", key).Warn("check rate limit failed, allow request")
+		return true
+	}
+
+	return allow
+}
+
+func (l *natsLimiter) AllowStrict(key string, period time.Duration, num int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	allow, err := l.allow(ctx, base64.RawURLEncoding.EncodeToString([]byte(key)), period, num)
+	if err != nil {
+		l.logger.WithErr(err).With("key", key).Warn("check rate limit failed, deny request")
+		return false
+	}
+
+	return allow
+}
+
+func (l *natsLimiter) allow(ctx context.Context, key string, period time.Duration, num int) (bool, error) {
+	for range natsRetry {
+		now := time.Now()
+
+		entry, err := l.kv.Get(ctx, key)
+		if err != nil {
+			if !errors.Is(err, jetstream.ErrKeyNotFound) {
+				return false, err
+			}
+
+			raw, _ := json.Marshal(bucketState{Tokens: float64(num - 1), Update: now.UnixNano()})
+			_, err = l.kv.Create(ctx, key, raw)
+			if err == nil {
+				return num > 0, nil
+			}
+			if errors.Is(err, jetstream.ErrKeyExists) {
+				continue
+			}
+			return false, err
+		}
+
+		var state bucketState
+		err = json.Unmarshal(entry.Value(), &state)
+		if err != nil {
+			return false, err
+		}
+
+		tokens := state.refill(now, period, num)
+		if tokens < 1 {
+			return false, nil
+		}
+
+		raw, _ := json.Marshal(bucketState{Tokens: tokens - 1, Update: now.UnixNano()})
+		_, err = l.kv.Update(ctx, key, raw, entry.Revision())
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, jetstream.ErrKeyExists) {
+			return false, err
+		}
+	}
+
+	// 竞争过于激烈，说明请求频率已经很高，直接拒绝
+	return false, nil
+}
+
+func newNatsLimiter(conn *nats.Conn) (*natsLimiter, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      natsBucket,
+		Description: "koala rate limit",
+		History:     1,
+		// 与内存实现一致，一小时未使用的限流器会被清理
+		TTL: time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &natsLimiter{
+		kv:     kv,
+		logger: glog.Module("ratelimit", "nats"),
+	}, nil
+}
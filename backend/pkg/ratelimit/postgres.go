@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
+)
+
+type rateLimit struct {
+	Key       string    `gorm:"column:key;type:text;primaryKey"`
+	Tokens    float64   `gorm:"column:tokens"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp with time zone;index"`
+}
+
+func (rateLimit) TableName() string {
+	return "rate_limits"
+}
+
+// pgLimiter 令牌桶状态保存在 postgres，单条 upsert 完成补充与扣减
+type pgLimiter struct {
+	db     *database.DB
+	logger *glog.Logger
+}
+
+func (l *pgLimiter) Allow(key string, period time.Duration, num int) bool {
+	allow, err := l.allow(key, period, num)
+	if err != nil {
+		l.logger.WithErr(err).With("key", key).Warn("check rate limit failed, allow request")
+		return true
+	}
+
+	return allow
+}
+
+func (l *pgLimiter) AllowStrict(key string, period time.Duration, num int) bool {
+	allow, err := l.allow(key, period, num)
+	if err != nil {
+		l.logger.WithErr(err).With("key", key).Warn("check rate limit failed, deny request")
+		return false
+	}
+
+	return allow
+}
+
+func (l *pgLimiter) allow(key string, period time.Duration, num int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	res := l.db.WithContext(ctx).Exec(`INSERT INTO rate_limits (key, tokens, updated_at) VALUES (@key, CAST(@num AS double precision) - 1, now())
+ON CONFLICT (key) DO UPDATE SET
+	tokens = LEAST(CAST(@num AS double precision), rate_limits.tokens + EXTRACT(EPOCH FROM now() - rate_limits.updated_at) / CAST(@period AS double precision)) - 1,
+	updated_at = now()
+WHERE LEAST(CAST(@num AS double precision), rate_limits.tokens + EXTRACT(EPOCH FROM now() - rate_limits.updated_at) / CAST(@period AS double precision)) >= 1`,
+		map[string]any{
+			"key":    key,
+			"num":    num,
+			"period": period.Seconds(),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (l *pgLimiter) clearLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.db.WithContext(ctx).Where("updated_at < ?", time.Now().Add(-time.Hour)).Delete(&rateLimit{}).Error
+			if err != nil {
+				l.logger.WithErr(err).Warn("clear rate limit failed")
+			}
+		}
+	}
+}
+
+func newPGLimiter(db *database.DB) (*pgLimiter, error) {
+	err := db.AutoMigrate(&rateLimit{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgLimiter{
+		db:     db,
+		logger: glog.Module("ratelimit", "postgres"),
+	}, nil
+}
@@ -8,7 +8,10 @@ import (
 )
 
 type Limiter interface {
+	// Allow 存储不可用时放行
 	Allow(key string, period time.Duration, num int) bool
+	// AllowStrict 存储不可用时拒绝，用于验证码、密码等防暴力破解的场景
+	AllowStrict(key string, period time.Duration, num int) bool
 }
 
 type limiter struct {
@@ -35,6 +38,10 @@ func (l *multiLimiter) Allow(key string, period time.Duration, num int) bool {
 	return cacheLimiter.l.Allow()
 }
 
+func (l *multiLimiter) AllowStrict(key string, period time.Duration, num int) bool {
+	return l.Allow(key, period, num)
+}
+
 func (l *multiLimiter) clearLoop() {
 	for {
 		time.Sleep(time.Hour)
@@ -53,7 +60,7 @@ func (l *multiLimiter) clearLoop() {
 	}
 }
 
-func New() Limiter {
+func newMemory() *multiLimiter {
 	l := &multiLimiter{
 		l: &sync.Map{},
 	}
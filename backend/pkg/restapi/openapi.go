@@ -0,0 +1,323 @@
+package restapi
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const OpenAPIVersion = "3.0.3"
+
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+}
+
+// Operation 描述一个对外接口，Query 与 Response 传入对应类型的零值用于生成 schema
+type Operation struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	Params  []Param
+	Query   any
+	// Response 为 nil 时没有响应体
+	Response any
+	// List 响应使用 List 包装
+	List bool
+	// Public 开启公开访问后匿名用户也可以调用
+	Public bool
+}
+
+type Spec struct {
+	title    string
+	version  string
+	basePath string
+	ops      []Operation
+}
+
+func NewSpec(title string, version string, basePath string) *Spec {
+	return &Spec{
+		title:    title,
+		version:  version,
+		basePath: basePath,
+	}
+}
+
+func (s *Spec) Add(op Operation) {
+	s.ops = append(s.ops, op)
+}
+
+// Build 根据已注册的接口生成 OpenAPI 3 文档
+func (s *Spec) Build() map[string]any {
+	g := schemaGen{schemas: map[string]any{}}
+	g.schemas["Error"] = g.schema(reflect.TypeOf(Error{}))
+
+	errRes := map[string]any{
+		"description": "error",
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+			},
+		},
+	}
+
+	paths := map[string]any{}
+	for _, op := range s.ops {
+		params := make([]any, 0, len(op.Params))
+		for _, p := range op.Params {
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Description,
+				"required":    p.Required || p.In == "path",
+				"schema":      map[string]any{"type": "string"},
+			})
+		}
+		if op.Query != nil {
+			params = append(params, g.queryParams(reflect.TypeOf(op.Query))...)
+		}
+
+		responses := map[string]any{
+			"400":     errRes,
+			"401":     errRes,
+			"default": errRes,
+		}
+		if op.Response != nil {
+			schema := g.schema(reflect.TypeOf(op.Response))
+			if op.List {
+				schema = map[string]any{
+					"type":     "object",
+					"required": []string{"data"},
+					"properties": map[string]any{
+						"data":        map[string]any{"type": "array", "items": schema},
+						"next_cursor": map[string]any{"type": "string"},
+					},
+				}
+			}
+			responses["200"] = map[string]any{
+				"description": "OK",
+				"headers": map[string]any{
+					"ETag": map[string]any{"schema": map[string]any{"type": "string"}},
+				},
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schema},
+				},
+			}
+			responses["304"] = map[string]any{"description": "Not Modified"}
+		} else {
+			responses["204"] = map[string]any{"description": "No Content"}
+		}
+		if op.Method == http.MethodGet && strings.Contains(op.Path, "{") {
+			responses["404"] = errRes
+		}
+
+		item := map[string]any{
+			"operationId": operationID(op),
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"parameters":  params,
+			"responses":   responses,
+		}
+		if op.Public {
+			item["security"] = []any{
+				map[string]any{"bearerAuth": []string{}},
+				map[string]any{},
+			}
+		}
+
+		path, ok := paths[op.Path].(map[string]any)
+		if !ok {
+			path = map[string]any{}
+			paths[op.Path] = path
+		}
+		path[strings.ToLower(op.Method)] = item
+	}
+
+	return map[string]any{
+		"openapi": OpenAPIVersion,
+		"info": map[string]any{
+			"title":   s.title,
+			"version": s.version,
+		},
+		"servers": []any{map[string]any{"url": s.basePath}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "api token",
+				},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []string{}}},
+	}
+}
+
+func operationID(op Operation) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(op.Method))
+	for _, item := range strings.Split(op.Path, "/") {
+		item = strings.Trim(item, "{}")
+		for _, word := range strings.Split(item, "_") {
+			if word == "" {
+				continue
+			}
+			sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+
+	return sb.String()
+}
+
+type schemaGen struct {
+	schemas map[string]any
+}
+
+func (g *schemaGen) queryParams(t reflect.Type) []any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var res []any
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous {
+			res = append(res, g.queryParams(f.Type)...)
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		param := map[string]any{
+			"name":   name,
+			"in":     "query",
+			"schema": g.schema(f.Type),
+		}
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			param["required"] = true
+		}
+		res = append(res, param)
+	}
+
+	return res
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var res map[string]any
+	switch t.Kind() {
+	case reflect.Bool:
+		res = map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		res = map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		res = map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		res = map[string]any{"type": "number"}
+	case reflect.String:
+		res = map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			res = map[string]any{"type": "string", "format": "byte"}
+			break
+		}
+		res = map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		res = map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			res = g.object(t)
+			break
+		}
+
+		if _, ok := g.schemas[name]; !ok {
+			// 先占位，避免递归类型死循环
+			g.schemas[name] = map[string]any{}
+			g.schemas[name] = g.object(t)
+		}
+		res = map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		res = map[string]any{}
+	}
+
+	if nullable {
+		if _, ok := res["$ref"]; ok {
+			return map[string]any{"allOf": []any{res}, "nullable": true}
+		}
+		res["nullable"] = true
+	}
+
+	return res
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				for ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+
+			if name == "" {
+				name = f.Name
+			}
+
+			schema := g.schema(f.Type)
+			if desc := f.Tag.Get("description"); desc != "" {
+				schema["description"] = desc
+			}
+			properties[name] = schema
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+
+	res := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		res["required"] = required
+	}
+
+	return res
+}
@@ -0,0 +1,72 @@
+package restapi
+
+// 以下为 v1 对外稳定的资源结构，只允许新增字段，不允许修改或删除已有字段
+
+type Forum struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	RouteName string `json:"route_name" description:"论坛访问路径"`
+	Index     uint   `json:"index" description:"排序"`
+}
+
+type UserRef struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+}
+
+type Discussion struct {
+	ID          uint    `json:"id"`
+	UUID        string  `json:"uuid"`
+	ForumID     uint    `json:"forum_id"`
+	Type        string  `json:"type" description:"qa / feedback / blog / issue"`
+	Title       string  `json:"title"`
+	Summary     string  `json:"summary"`
+	Content     string  `json:"content,omitempty" description:"markdown 内容，仅详情接口返回"`
+	TagIDs      []int64 `json:"tag_ids"`
+	Resolved    bool    `json:"resolved"`
+	Closed      bool    `json:"closed"`
+	Author      UserRef `json:"author"`
+	Like        uint    `json:"like"`
+	View        uint    `json:"view"`
+	CommentsNum uint    `json:"comments_num"`
+	CreatedAt   int64   `json:"created_at" description:"unix 秒级时间戳"`
+	UpdatedAt   int64   `json:"updated_at" description:"unix 秒级时间戳"`
+}
+
+type Comment struct {
+	ID           uint    `json:"id"`
+	DiscussionID uint    `json:"discussion_id"`
+	ParentID     uint    `json:"parent_id" description:"为 0 表示回答，否则为对回答的评论"`
+	Content      string  `json:"content"`
+	Accepted     bool    `json:"accepted"`
+	Bot          bool    `json:"bot" description:"是否为 AI 生成"`
+	Author       UserRef `json:"author"`
+	Like         uint    `json:"like"`
+	Dislike      uint    `json:"dislike"`
+	CreatedAt    int64   `json:"created_at"`
+	UpdatedAt    int64   `json:"updated_at"`
+}
+
+type User struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Avatar    string `json:"avatar"`
+	Intro     string `json:"intro"`
+	Role      string `json:"role" description:"admin / operator / user / guest"`
+	Point     uint   `json:"point"`
+	Email     string `json:"email,omitempty" description:"仅 api token 可见"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type Document struct {
+	ID        uint   `json:"id"`
+	KBID      uint   `json:"kb_id"`
+	ParentID  uint   `json:"parent_id"`
+	Type      string `json:"type" description:"question / document / space / web"`
+	Title     string `json:"title"`
+	Desc      string `json:"desc"`
+	Markdown  string `json:"markdown,omitempty" description:"仅详情接口返回"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
@@ -0,0 +1,182 @@
+package restapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidRequest ErrorCode = "invalid_request"
+	ErrorCodeInvalidCursor  ErrorCode = "invalid_cursor"
+	ErrorCodeUnauthorized   ErrorCode = "unauthorized"
+	ErrorCodeForbidden      ErrorCode = "forbidden"
+	ErrorCodeNotFound       ErrorCode = "not_found"
+	ErrorCodeInternal       ErrorCode = "internal_error"
+)
+
+type ErrorBody struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	TraceID string    `json:"trace_id,omitempty"`
+}
+
+// Error 对外 api 统一的错误响应
+type Error struct {
+	Body ErrorBody `json:"error"`
+
+	status int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rest api error %d(%s): %s", e.status, e.Body.Code, e.Body.Message)
+}
+
+func (e *Error) StatusCode() int {
+	return e.status
+}
+
+func (e *Error) WithTraceID(traceID string) *Error {
+	res := *e
+	res.Body.TraceID = traceID
+	return &res
+}
+
+func NewError(status int, code ErrorCode, message string) *Error {
+	return &Error{
+		Body: ErrorBody{
+			Code:    code,
+			Message: message,
+		},
+		status: status,
+	}
+}
+
+func BadRequest(message string) *Error {
+	return NewError(http.StatusBadRequest, ErrorCodeInvalidRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return NewError(http.StatusUnauthorized, ErrorCodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return NewError(http.StatusForbidden, ErrorCodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return NewError(http.StatusNotFound, ErrorCodeNotFound, message)
+}
+
+func Internal(message string) *Error {
+	return NewError(http.StatusInternalServerError, ErrorCodeInternal, message)
+}
+
+// List 列表响应，next_cursor 为空表示没有更多数据
+type List[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Page struct {
+	Cursor string `form:"cursor" json:"cursor"`
+	Limit  int    `form:"limit" json:"limit"`
+}
+
+// Parse 返回游标中记录的上一页最后一条数据的 id 以及本页数量
+func (p Page) Parse() (after uint, limit int, err error) {
+	limit = p.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	if p.Cursor == "" {
+		return 0, limit, nil
+	}
+
+	after, err = DecodeCursor(p.Cursor)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return after, limit, nil
+}
+
+const cursorPrefix = "id:"
+
+func EncodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(uint64(id), 10)))
+}
+
+func DecodeCursor(cursor string) (uint, error) {
+	invalid := NewError(http.StatusBadRequest, ErrorCodeInvalidCursor, "invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, invalid
+	}
+
+	idStr, ok := strings.CutPrefix(string(data), cursorPrefix)
+	if !ok {
+		return 0, invalid
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil || id == 0 {
+		return 0, invalid
+	}
+
+	return uint(id), nil
+}
+
+// NewList items 需要多查询一条用于判断是否还有下一页
+func NewList[T any](items []T, limit int, id func(T) uint) *List[T] {
+	res := &List[T]{Data: items}
+	if len(items) > limit {
+		res.Data = items[:limit]
+		res.NextCursor = EncodeCursor(id(res.Data[limit-1]))
+	}
+
+	if res.Data == nil {
+		res.Data = make([]T, 0)
+	}
+
+	return res
+}
+
+// ETag 根据响应内容生成弱校验的 ETag
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Match 判断 If-None-Match 是否命中
+func Match(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	weak := strings.TrimPrefix(etag, "W/")
+	for _, item := range strings.Split(ifNoneMatch, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || strings.TrimPrefix(item, "W/") == weak {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	after, limit, err := Page{Cursor: EncodeCursor(42), Limit: 1000}.Parse()
+	if err != nil || after != 42 || limit != MaxLimit {
+		t.Fatalf("unexpected page: %d %d %v", after, limit, err)
+	}
+
+	_, limit, err = Page{}.Parse()
+	if err != nil || limit != DefaultLimit {
+		t.Fatalf("unexpected default limit: %d %v", limit, err)
+	}
+
+	for _, cursor := range []string{"!!", "MTIz", EncodeCursor(0)} {
+		_, _, err = Page{Cursor: cursor}.Parse()
+		var restErr *Error
+		if !errors.As(err, &restErr) || restErr.Body.Code != ErrorCodeInvalidCursor {
+			t.Errorf("expect invalid cursor for %q, got %v", cursor, err)
+		}
+	}
+}
+
+func TestNewList(t *testing.T) {
+	id := func(i uint) uint { return i }
+
+	res := NewList([]uint{5, 4, 3}, 2, id)
+	if len(res.Data) != 2 || res.NextCursor != EncodeCursor(4) {
+		t.Fatalf("unexpected list: %+v", res)
+	}
+
+	res = NewList([]uint(nil), 2, id)
+	if res.Data == nil || res.NextCursor != "" {
+		t.Fatalf("unexpected empty list: %+v", res)
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	etag := ETag([]byte(`{"id":1}`))
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("unexpected etag: %s", etag)
+	}
+
+	if !Match(strings.TrimPrefix(etag, "W/"), etag) || !Match(`"x", `+etag, etag) || !Match("*", etag) {
+		t.Error("expect etag match")
+	}
+	if Match("", etag) || Match(`W/"other"`, etag) {
+		t.Error("unexpected etag match")
+	}
+}
+
+type testQuery struct {
+	Page
+
+	ForumID uint `form:"forum_id" binding:"required"`
+}
+
+type testItem struct {
+	ID    uint      `json:"id"`
+	Tags  []string  `json:"tags,omitempty"`
+	Child *testItem `json:"child"`
+	Skip  string    `json:"-"`
+}
+
+func TestSpecBuild(t *testing.T) {
+	spec := NewSpec("test", "1.0.0", "/api/v1")
+	spec.Add(Operation{
+		Method:   http.MethodGet,
+		Path:     "/items/{item_id}",
+		Summary:  "list items",
+		Tag:      "item",
+		Params:   []Param{{Name: "item_id", In: "path"}},
+		Query:    testQuery{},
+		Response: testItem{},
+		List:     true,
+	})
+
+	data, err := json.Marshal(spec.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+				Required   []string       `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op, ok := doc.Paths["/items/{item_id}"]["get"]
+	if !ok || op.OperationID != "getItemsItemId" {
+		t.Fatalf("unexpected operation: %s", data)
+	}
+	if len(op.Parameters) != 4 || op.Parameters[0].In != "path" || op.Parameters[3].Name != "forum_id" || !op.Parameters[3].Required {
+		t.Fatalf("unexpected parameters: %+v", op.Parameters)
+	}
+
+	item, ok := doc.Components.Schemas["testItem"]
+	if !ok || len(item.Properties) != 3 || len(item.Required) != 2 {
+		t.Fatalf("unexpected schema: %+v", item)
+	}
+	if _, ok := doc.Components.Schemas["Error"]; !ok {
+		t.Fatal("expect error schema")
+	}
+}
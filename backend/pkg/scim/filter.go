@@ -0,0 +1,280 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Op string
+
+const (
+	OpEq Op = "eq"
+	OpNe Op = "ne"
+	OpCo Op = "co"
+	OpSw Op = "sw"
+	OpEw Op = "ew"
+	OpPr Op = "pr"
+	OpGt Op = "gt"
+	OpGe Op = "ge"
+	OpLt Op = "lt"
+	OpLe Op = "le"
+)
+
+var validOps = map[Op]bool{
+	OpEq: true, OpNe: true, OpCo: true, OpSw: true, OpEw: true,
+	OpPr: true, OpGt: true, OpGe: true, OpLt: true, OpLe: true,
+}
+
+// Expr 单个比较表达式，Attr 为去掉 schema 前缀后的小写属性路径，例如 username、emails.value
+type Expr struct {
+	Attr  string
+	Op    Op
+	Value any
+}
+
+// Filter 由 and 连接的表达式，暂不支持 or、not 和括号
+type Filter []Expr
+
+func (f Filter) Match(get func(attr string) any) bool {
+	for _, expr := range f {
+		if !expr.match(get(expr.Attr)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e Expr) match(v any) bool {
+	if e.Op == OpPr {
+		return v != nil && v != ""
+	}
+
+	switch val := v.(type) {
+	case string:
+		target, ok := e.Value.(string)
+		if !ok {
+			return false
+		}
+
+		val = strings.ToLower(val)
+		target = strings.ToLower(target)
+		switch e.Op {
+		case OpEq:
+			return val == target
+		case OpNe:
+			return val != target
+		case OpCo:
+			return strings.Contains(val, target)
+		case OpSw:
+			return strings.HasPrefix(val, target)
+		case OpEw:
+			return strings.HasSuffix(val, target)
+		}
+	case bool:
+		target, ok := e.Value.(bool)
+		if !ok {
+			return false
+		}
+
+		switch e.Op {
+		case OpEq:
+			return val == target
+		case OpNe:
+			return val != target
+		}
+	}
+
+	return false
+}
+
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var (
+		filter Filter
+		i      int
+	)
+	for {
+		if i >= len(tokens) {
+			return nil, invalidFilter(s)
+		}
+
+		attr := normalizeAttr(tokens[i].raw)
+		if tokens[i].quoted || attr == "" {
+			return nil, invalidFilter(s)
+		}
+		i++
+
+		if i >= len(tokens) || tokens[i].quoted {
+			return nil, invalidFilter(s)
+		}
+
+		op := Op(strings.ToLower(tokens[i].raw))
+		if !validOps[op] {
+			return nil, invalidFilter(s)
+		}
+		i++
+
+		expr := Expr{Attr: attr, Op: op}
+		if op != OpPr {
+			if i >= len(tokens) {
+				return nil, invalidFilter(s)
+			}
+
+			expr.Value, err = tokens[i].value()
+			if err != nil {
+				return nil, invalidFilter(s)
+			}
+			i++
+		}
+		filter = append(filter, expr)
+
+		if i == len(tokens) {
+			return filter, nil
+		}
+
+		if tokens[i].quoted || !strings.EqualFold(tokens[i].raw, "and") {
+			return nil, invalidFilter(s)
+		}
+		i++
+	}
+}
+
+func invalidFilter(s string) error {
+	return BadRequest(ErrorTypeInvalidFilter, fmt.Sprintf("unsupported filter: %s", s))
+}
+
+// normalizeAttr 去掉 urn:ietf:params:scim:schemas:core:2.0:User: 这类 schema 前缀并转为小写
+func normalizeAttr(attr string) string {
+	if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+		idx := strings.LastIndex(attr, ":")
+		attr = attr[idx+1:]
+	}
+
+	return strings.ToLower(attr)
+}
+
+type token struct {
+	raw    string
+	quoted bool
+}
+
+func (t token) value() (any, error) {
+	if t.quoted {
+		return t.raw, nil
+	}
+
+	switch strings.ToLower(t.raw) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	return strconv.ParseFloat(t.raw, 64)
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case ' ', '\t', '\n':
+			i++
+		case '(', ')', '[', ']':
+			return nil, invalidFilter(s)
+		case '"':
+			end := i + 1
+			for ; end < len(s); end++ {
+				if s[end] == '\\' {
+					end++
+					continue
+				}
+				if s[end] == '"' {
+					break
+				}
+			}
+			if end >= len(s) {
+				return nil, invalidFilter(s)
+			}
+
+			var str string
+			err := json.Unmarshal([]byte(s[i:end+1]), &str)
+			if err != nil {
+				return nil, invalidFilter(s)
+			}
+
+			tokens = append(tokens, token{raw: str, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n()[]\"", rune(s[end])) {
+				end++
+			}
+
+			tokens = append(tokens, token{raw: s[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+// Path patch 操作的属性路径，例如 members[value eq "1"] 或 emails[type eq "work"].value
+type Path struct {
+	Attr   string
+	Filter Filter
+	Sub    string
+}
+
+func ParsePath(path string) (Path, error) {
+	var p Path
+
+	attr := path
+	if start := strings.Index(path, "["); start >= 0 {
+		end := strings.LastIndex(path, "]")
+		if end < start {
+			return p, BadRequest(ErrorTypeInvalidPath, fmt.Sprintf("invalid path: %s", path))
+		}
+
+		filter, err := ParseFilter(path[start+1 : end])
+		if err != nil {
+			return p, BadRequest(ErrorTypeInvalidPath, fmt.Sprintf("invalid path: %s", path))
+		}
+
+		p.Filter = filter
+		attr = path[:start]
+		if rest := path[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return p, BadRequest(ErrorTypeInvalidPath, fmt.Sprintf("invalid path: %s", path))
+			}
+			p.Sub = strings.ToLower(rest[1:])
+		}
+	}
+
+	attr = normalizeAttr(attr)
+	if p.Sub == "" {
+		if idx := strings.Index(attr, "."); idx >= 0 {
+			p.Sub = attr[idx+1:]
+			attr = attr[:idx]
+		}
+	}
+	p.Attr = attr
+
+	if p.Attr == "" {
+		return p, BadRequest(ErrorTypeInvalidPath, fmt.Sprintf("invalid path: %s", path))
+	}
+
+	return p, nil
+}
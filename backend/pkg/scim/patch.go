@@ -0,0 +1,365 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type PatchOp string
+
+const (
+	PatchOpAdd     PatchOp = "add"
+	PatchOpReplace PatchOp = "replace"
+	PatchOpRemove  PatchOp = "remove"
+)
+
+type PatchOperation struct {
+	Op    string          `json:"op" binding:"required"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations" binding:"required,min=1"`
+}
+
+type patchFunc func(op PatchOp, path Path, value json.RawMessage) error
+
+// applyPatch 将 patch 操作拆分成单个属性的修改，path 为空时 value 中的每个 key 都视为 path
+func applyPatch(ops []PatchOperation, fn patchFunc) error {
+	for _, item := range ops {
+		op := PatchOp(strings.ToLower(item.Op))
+		switch op {
+		case PatchOpAdd, PatchOpReplace, PatchOpRemove:
+		default:
+			return BadRequest(ErrorTypeInvalidSyntax, fmt.Sprintf("unsupported patch op: %s", item.Op))
+		}
+
+		if item.Path != "" {
+			path, err := ParsePath(item.Path)
+			if err != nil {
+				return err
+			}
+
+			err = fn(op, path, item.Value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if op == PatchOpRemove {
+			return BadRequest(ErrorTypeNoTarget, "remove op requires path")
+		}
+
+		var values map[string]json.RawMessage
+		err := json.Unmarshal(item.Value, &values)
+		if err != nil {
+			return BadRequest(ErrorTypeInvalidValue, "patch value must be an object when path is empty")
+		}
+
+		for key, value := range values {
+			if strings.EqualFold(key, "schemas") {
+				continue
+			}
+
+			path, err := ParsePath(key)
+			if err != nil {
+				return err
+			}
+
+			err = fn(op, path, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func unmarshalValue(path Path, value json.RawMessage, v any) error {
+	err := json.Unmarshal(value, v)
+	if err != nil {
+		return BadRequest(ErrorTypeInvalidValue, fmt.Sprintf("invalid value of %s", path.Attr))
+	}
+
+	return nil
+}
+
+func stringValue(op PatchOp, path Path, value json.RawMessage) (string, error) {
+	if op == PatchOpRemove {
+		return "", nil
+	}
+
+	var str string
+	err := unmarshalValue(path, value, &str)
+	return str, err
+}
+
+// boolValue 部分 IdP（例如 Azure AD）会用字符串 "True"/"False" 表示布尔值
+func boolValue(path Path, value json.RawMessage) (bool, error) {
+	var b bool
+	if json.Unmarshal(value, &b) == nil {
+		return b, nil
+	}
+
+	var str string
+	err := unmarshalValue(path, value, &str)
+	if err != nil {
+		return false, err
+	}
+
+	b, err = strconv.ParseBool(str)
+	if err != nil {
+		return false, BadRequest(ErrorTypeInvalidValue, fmt.Sprintf("invalid value of %s", path.Attr))
+	}
+
+	return b, nil
+}
+
+// refValues 兼容单个对象和对象数组两种写法
+func refValues(path Path, value json.RawMessage) ([]Ref, error) {
+	var refs []Ref
+	if json.Unmarshal(value, &refs) == nil {
+		return refs, nil
+	}
+
+	var ref Ref
+	err := unmarshalValue(path, value, &ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Ref{ref}, nil
+}
+
+func (u *User) Patch(ops []PatchOperation) error {
+	return applyPatch(ops, u.patch)
+}
+
+func (u *User) patch(op PatchOp, path Path, value json.RawMessage) (err error) {
+	switch path.Attr {
+	case "active":
+		if op == PatchOpRemove {
+			return BadRequest(ErrorTypeMutability, "active can not be removed")
+		}
+
+		active, err := boolValue(path, value)
+		if err != nil {
+			return err
+		}
+		u.Active = &active
+	case "username":
+		if op == PatchOpRemove {
+			return BadRequest(ErrorTypeMutability, "userName can not be removed")
+		}
+
+		u.UserName, err = stringValue(op, path, value)
+	case "displayname":
+		u.DisplayName, err = stringValue(op, path, value)
+	case "externalid":
+		u.ExternalID, err = stringValue(op, path, value)
+	case "name":
+		err = u.patchName(op, path, value)
+	case "emails":
+		err = u.patchEmails(op, path, value)
+	}
+
+	// 忽略不支持的属性，例如 title、企业扩展等
+	return err
+}
+
+func (u *User) patchName(op PatchOp, path Path, value json.RawMessage) error {
+	if path.Sub == "" {
+		if op == PatchOpRemove {
+			u.Name = nil
+			return nil
+		}
+
+		var name Name
+		err := unmarshalValue(path, value, &name)
+		if err != nil {
+			return err
+		}
+		u.Name = &name
+		return nil
+	}
+
+	if u.Name == nil {
+		u.Name = &Name{}
+	}
+
+	str, err := stringValue(op, path, value)
+	if err != nil {
+		return err
+	}
+
+	switch path.Sub {
+	case "formatted":
+		u.Name.Formatted = str
+	case "givenname":
+		u.Name.GivenName = str
+	case "familyname":
+		u.Name.FamilyName = str
+	}
+
+	return nil
+}
+
+func (u *User) patchEmails(op PatchOp, path Path, value json.RawMessage) error {
+	if path.Filter == nil {
+		if op == PatchOpRemove {
+			u.Emails = nil
+			return nil
+		}
+
+		var emails []Email
+		if json.Unmarshal(value, &emails) != nil {
+			var email Email
+			err := unmarshalValue(path, value, &email)
+			if err != nil {
+				return err
+			}
+			emails = []Email{email}
+		}
+
+		if op == PatchOpAdd {
+			u.Emails = append(u.Emails, emails...)
+		} else {
+			u.Emails = emails
+		}
+		return nil
+	}
+
+	idx := slices.IndexFunc(u.Emails, func(email Email) bool {
+		return path.Filter.Match(func(attr string) any {
+			switch attr {
+			case "value":
+				return email.Value
+			case "type":
+				return email.Type
+			case "primary":
+				return email.Primary
+			}
+			return nil
+		})
+	})
+
+	if op == PatchOpRemove {
+		if idx >= 0 {
+			u.Emails = slices.Delete(u.Emails, idx, idx+1)
+		}
+		return nil
+	}
+
+	if idx < 0 {
+		// 例如 emails[type eq "work"].value，不存在时按过滤条件新建
+		email := Email{}
+		for _, expr := range path.Filter {
+			if expr.Op != OpEq {
+				continue
+			}
+
+			switch expr.Attr {
+			case "type":
+				email.Type, _ = expr.Value.(string)
+			case "primary":
+				email.Primary, _ = expr.Value.(bool)
+			}
+		}
+		u.Emails = append(u.Emails, email)
+		idx = len(u.Emails) - 1
+	}
+
+	if path.Sub == "" || path.Sub == "value" {
+		if path.Sub == "" {
+			return unmarshalValue(path, value, &u.Emails[idx])
+		}
+
+		str, err := stringValue(op, path, value)
+		if err != nil {
+			return err
+		}
+		u.Emails[idx].Value = str
+	}
+
+	return nil
+}
+
+func (g *Group) Patch(ops []PatchOperation) error {
+	return applyPatch(ops, g.patch)
+}
+
+func (g *Group) patch(op PatchOp, path Path, value json.RawMessage) (err error) {
+	switch path.Attr {
+	case "displayname":
+		if op == PatchOpRemove {
+			return BadRequest(ErrorTypeMutability, "displayName can not be removed")
+		}
+
+		g.DisplayName, err = stringValue(op, path, value)
+	case "externalid":
+		g.ExternalID, err = stringValue(op, path, value)
+	case "members":
+		err = g.patchMembers(op, path, value)
+	}
+
+	return err
+}
+
+func (g *Group) patchMembers(op PatchOp, path Path, value json.RawMessage) error {
+	switch op {
+	case PatchOpAdd:
+		refs, err := refValues(path, value)
+		if err != nil {
+			return err
+		}
+
+		for _, ref := range refs {
+			if !slices.ContainsFunc(g.Members, func(m Ref) bool { return m.Value == ref.Value }) {
+				g.Members = append(g.Members, ref)
+			}
+		}
+	case PatchOpReplace:
+		refs, err := refValues(path, value)
+		if err != nil {
+			return err
+		}
+
+		g.Members = refs
+	case PatchOpRemove:
+		if path.Filter != nil {
+			g.Members = slices.DeleteFunc(g.Members, func(m Ref) bool {
+				return path.Filter.Match(func(attr string) any {
+					if attr == "value" {
+						return m.Value
+					}
+					return nil
+				})
+			})
+			return nil
+		}
+
+		// Azure AD 会把要移除的成员放在 value 中
+		if len(value) > 0 && string(value) != "null" {
+			refs, err := refValues(path, value)
+			if err != nil {
+				return err
+			}
+
+			g.Members = slices.DeleteFunc(g.Members, func(m Ref) bool {
+				return slices.ContainsFunc(refs, func(ref Ref) bool { return ref.Value == m.Value })
+			})
+			return nil
+		}
+
+		g.Members = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const ContentType = "application/scim+json"
+
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+const (
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
+)
+
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type ListResponse[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []T      `json:"Resources"`
+}
+
+func NewListResponse[T any](items []T, total int64, startIndex int) *ListResponse[T] {
+	if items == nil {
+		items = make([]T, 0)
+	}
+
+	return &ListResponse[T]{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(items),
+		Resources:    items,
+	}
+}
+
+type ErrorType string
+
+const (
+	ErrorTypeInvalidFilter ErrorType = "invalidFilter"
+	ErrorTypeInvalidPath   ErrorType = "invalidPath"
+	ErrorTypeInvalidValue  ErrorType = "invalidValue"
+	ErrorTypeInvalidSyntax ErrorType = "invalidSyntax"
+	ErrorTypeNoTarget      ErrorType = "noTarget"
+	ErrorTypeUniqueness    ErrorType = "uniqueness"
+	ErrorTypeMutability    ErrorType = "mutability"
+)
+
+// Error SCIM 协议规定的错误响应，见 RFC 7644 3.12
+type Error struct {
+	Schemas  []string  `json:"schemas"`
+	Status   string    `json:"status"`
+	ScimType ErrorType `json:"scimType,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+
+	status int
+}
+
+func (e *Error) Error() string {
+	if e.ScimType == "" {
+		return fmt.Sprintf("scim error %d: %s", e.status, e.Detail)
+	}
+
+	return fmt.Sprintf("scim error %d(%s): %s", e.status, e.ScimType, e.Detail)
+}
+
+func (e *Error) StatusCode() int {
+	return e.status
+}
+
+func NewError(status int, scimType ErrorType, detail string) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   fmt.Sprint(status),
+		ScimType: scimType,
+		Detail:   detail,
+		status:   status,
+	}
+}
+
+func BadRequest(scimType ErrorType, detail string) *Error {
+	return NewError(http.StatusBadRequest, scimType, detail)
+}
+
+func NotFound(detail string) *Error {
+	return NewError(http.StatusNotFound, "", detail)
+}
+
+func Conflict(detail string) *Error {
+	return NewError(http.StatusConflict, ErrorTypeUniqueness, detail)
+}
@@ -0,0 +1,140 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter(`userName eq "Alice@Example.com" and urn:ietf:params:scim:schemas:core:2.0:User:active eq true and name.familyName pr`)
+	if err != nil {
+		t.Fatalf("parse filter failed: %v", err)
+	}
+
+	if len(filter) != 3 {
+		t.Fatalf("unexpected filter: %+v", filter)
+	}
+	if filter[0].Attr != "username" || filter[0].Op != OpEq || filter[0].Value != "Alice@Example.com" {
+		t.Fatalf("unexpected expr: %+v", filter[0])
+	}
+	if filter[1].Attr != "active" || filter[1].Value != true {
+		t.Fatalf("unexpected expr: %+v", filter[1])
+	}
+	if filter[2].Attr != "name.familyname" || filter[2].Op != OpPr {
+		t.Fatalf("unexpected expr: %+v", filter[2])
+	}
+
+	filter, err = ParseFilter(`displayName eq "say \"hi\""`)
+	if err != nil || filter[0].Value != `say "hi"` {
+		t.Fatalf("parse escaped filter failed: %v %+v", err, filter)
+	}
+
+	for _, s := range []string{
+		`userName eq`,
+		`userName foo "a"`,
+		`userName eq "a" or userName eq "b"`,
+		`emails[type eq "work"]`,
+		`"userName" eq "a"`,
+		`userName eq "a`,
+	} {
+		_, err := ParseFilter(s)
+		var scimErr *Error
+		if !errors.As(err, &scimErr) || scimErr.ScimType != ErrorTypeInvalidFilter {
+			t.Fatalf("expect invalid filter error for %s, got %v", s, err)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := ParsePath(`emails[type eq "work"].value`)
+	if err != nil {
+		t.Fatalf("parse path failed: %v", err)
+	}
+	if path.Attr != "emails" || path.Sub != "value" || len(path.Filter) != 1 {
+		t.Fatalf("unexpected path: %+v", path)
+	}
+
+	path, err = ParsePath("urn:ietf:params:scim:schemas:core:2.0:User:name.givenName")
+	if err != nil || path.Attr != "name" || path.Sub != "givenname" {
+		t.Fatalf("unexpected path: %+v %v", path, err)
+	}
+}
+
+func patchOps(t *testing.T, raw string) []PatchOperation {
+	t.Helper()
+
+	var req PatchRequest
+	err := json.Unmarshal([]byte(raw), &req)
+	if err != nil {
+		t.Fatalf("unmarshal patch failed: %v", err)
+	}
+
+	return req.Operations
+}
+
+func TestUserPatch(t *testing.T) {
+	u := User{
+		UserName: "alice",
+		Emails:   []Email{{Value: "alice@example.com", Type: "work", Primary: true}},
+	}
+
+	err := u.Patch(patchOps(t, `{"Operations":[
+		{"op":"Replace","path":"active","value":"False"},
+		{"op":"replace","value":{"displayName":"Alice","name.givenName":"Alice"}},
+		{"op":"replace","path":"emails[type eq \"work\"].value","value":"alice@corp.com"},
+		{"op":"add","path":"title","value":"engineer"}
+	]}`))
+	if err != nil {
+		t.Fatalf("patch user failed: %v", err)
+	}
+
+	if u.IsActive() {
+		t.Fatal("user should be inactive")
+	}
+	if u.FormattedName() != "Alice" || u.Name.GivenName != "Alice" {
+		t.Fatalf("unexpected name: %s %+v", u.FormattedName(), u.Name)
+	}
+	if u.PrimaryEmail() != "alice@corp.com" || len(u.Emails) != 1 {
+		t.Fatalf("unexpected emails: %+v", u.Emails)
+	}
+
+	err = u.Patch(patchOps(t, `{"Operations":[{"op":"remove","path":"userName"}]}`))
+	if err == nil {
+		t.Fatal("expect remove userName error")
+	}
+
+	err = u.Patch(patchOps(t, `{"Operations":[{"op":"move","path":"userName","value":"bob"}]}`))
+	if err == nil {
+		t.Fatal("expect unsupported op error")
+	}
+}
+
+func TestGroupPatch(t *testing.T) {
+	g := Group{
+		DisplayName: "dev",
+		Members:     []Ref{{Value: "1"}, {Value: "2"}},
+	}
+
+	err := g.Patch(patchOps(t, `{"Operations":[
+		{"op":"add","path":"members","value":[{"value":"2"},{"value":"3"}]},
+		{"op":"remove","path":"members[value eq \"1\"]"},
+		{"op":"Remove","path":"members","value":[{"value":"3"}]},
+		{"op":"replace","value":{"displayName":"ops"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("patch group failed: %v", err)
+	}
+
+	if g.DisplayName != "ops" {
+		t.Fatalf("unexpected display name: %s", g.DisplayName)
+	}
+	if len(g.Members) != 1 || g.Members[0].Value != "2" {
+		t.Fatalf("unexpected members: %+v", g.Members)
+	}
+
+	err = g.Patch(patchOps(t, `{"Operations":[{"op":"remove","path":"members"}]}`))
+	if err != nil || len(g.Members) != 0 {
+		t.Fatalf("remove all members failed: %v %+v", err, g.Members)
+	}
+}
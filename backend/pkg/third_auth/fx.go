@@ -1,7 +1,13 @@
 package third_auth
 
-import "go.uber.org/fx"
+import (
+	"github.com/chaitin/koalaqa/pkg/cache"
+	"go.uber.org/fx"
+)
 
 var Module = fx.Options(
 	fx.Provide(newManager),
+	fx.Invoke(func(backend cache.Backend) {
+		assertionBackend = backend
+	}),
 )
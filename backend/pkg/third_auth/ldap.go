@@ -0,0 +1,242 @@
+package third_auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapTimeout    = time.Second * 10
+	ldapPagingSize = 500
+)
+
+type ldapAuth struct {
+	logger *glog.Logger
+	cfg    model.AuthConfigLDAP
+}
+
+func (l *ldapAuth) Check(ctx context.Context) error {
+	if l.cfg.URL == "" {
+		return errors.New("empty ldap url")
+	}
+
+	if l.cfg.BaseDN == "" {
+		return errors.New("empty ldap base_dn")
+	}
+
+	if !strings.Contains(l.userFilter(), "{username}") {
+		return errors.New("ldap user_filter must contain {username}")
+	}
+
+	conn, err := l.conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return nil
+}
+
+// AuthURL ldap 不需要跳转登录
+func (l *ldapAuth) AuthURL(ctx context.Context, state string, optFuncs ...authURLOptFunc) (string, error) {
+	return "", errors.ErrUnsupported
+}
+
+func (l *ldapAuth) User(ctx context.Context, code string, optFuncs ...userOptFunc) (*User, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// Verify 先用服务账号查找用户，再使用用户的 DN 和密码进行 bind 校验
+func (l *ldapAuth) Verify(ctx context.Context, username string, password string) (*User, error) {
+	// 空密码会被部分服务端当作匿名 bind 处理
+	if username == "" || password == "" {
+		return nil, errors.New("empty ldap username or password")
+	}
+
+	conn, err := l.conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	filter := strings.ReplaceAll(l.userFilter(), "{username}", ldap.EscapeFilter(username))
+	res, err := conn.Search(l.searchRequest(filter, 2))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Entries) != 1 {
+		l.logger.WithContext(ctx).With("username", username).With("count", len(res.Entries)).Info("ldap user not found or not unique")
+		return nil, errors.New("ldap user not found")
+	}
+
+	entry := res.Entries[0]
+	err = conn.Bind(entry.DN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errors.New("invalid ldap username or password")
+		}
+
+		return nil, err
+	}
+
+	return l.entryUser(entry), nil
+}
+
+func (l *ldapAuth) ListUsers(ctx context.Context) ([]*User, error) {
+	conn, err := l.conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	res, err := conn.SearchWithPaging(l.searchRequest(l.syncFilter(), 0), ldapPagingSize)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0, len(res.Entries))
+	for _, entry := range res.Entries {
+		users = append(users, l.entryUser(entry))
+	}
+
+	l.logger.WithContext(ctx).With("count", len(users)).Debug("list ldap users")
+	return users, nil
+}
+
+func (l *ldapAuth) conn() (*ldap.Conn, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: l.cfg.InsecureSkipVerify,
+	}
+
+	conn, err := ldap.DialURL(l.cfg.URL, ldap.DialWithTLSConfig(tlsCfg))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if l.cfg.StartTLS {
+		err = conn.StartTLS(tlsCfg)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if l.cfg.BindDN != "" {
+		err = conn.Bind(l.cfg.BindDN, l.cfg.BindPassword)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (l *ldapAuth) searchRequest(filter string, sizeLimit int) *ldap.SearchRequest {
+	attr := l.attribute()
+
+	var attributes []string
+	for _, name := range []string{attr.ID, attr.Email, attr.Name, attr.Avatar, attr.Group} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+
+	return ldap.NewSearchRequest(
+		l.cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, sizeLimit, int(ldapTimeout.Seconds()), false,
+		filter, attributes, nil,
+	)
+}
+
+func (l *ldapAuth) entryUser(entry *ldap.Entry) *User {
+	attr := l.attribute()
+
+	user := &User{
+		ThirdID: entry.GetEqualFoldAttributeValue(attr.ID),
+		Type:    model.AuthTypeLDAP,
+		Role:    model.UserRoleUser,
+		Email:   entry.GetEqualFoldAttributeValue(attr.Email),
+		Name:    entry.GetEqualFoldAttributeValue(attr.Name),
+	}
+
+	if attr.Avatar != "" {
+		user.Avatar = entry.GetEqualFoldAttributeValue(attr.Avatar)
+	}
+
+	if user.ThirdID == "" {
+		user.ThirdID = entry.DN
+	}
+
+	if user.Name == "" {
+		user.Name = user.ThirdID
+	}
+
+	for _, group := range entry.GetEqualFoldAttributeValues(attr.Group) {
+		if name := ldapGroupName(group); name != "" {
+			user.OrgNames = append(user.OrgNames, name)
+		}
+	}
+
+	return user
+}
+
+func (l *ldapAuth) userFilter() string {
+	if l.cfg.UserFilter == "" {
+		return "(uid={username})"
+	}
+
+	return l.cfg.UserFilter
+}
+
+func (l *ldapAuth) syncFilter() string {
+	if l.cfg.SyncFilter == "" {
+		return "(objectClass=person)"
+	}
+
+	return l.cfg.SyncFilter
+}
+
+func (l *ldapAuth) attribute() model.AuthConfigLDAPAttribute {
+	attr := l.cfg.Attribute
+	if attr.ID == "" {
+		attr.ID = "uid"
+	}
+	if attr.Email == "" {
+		attr.Email = "mail"
+	}
+	if attr.Name == "" {
+		attr.Name = "cn"
+	}
+	if attr.Group == "" {
+		attr.Group = "memberOf"
+	}
+
+	return attr
+}
+
+// ldapGroupName 组为 DN 时取第一个 RDN 的值，例如 cn=dev,ou=groups,dc=example,dc=com 取 dev
+func ldapGroupName(group string) string {
+	dn, err := ldap.ParseDN(group)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return group
+	}
+
+	return dn.RDNs[0].Attributes[0].Value
+}
+
+func newLDAP(cfg Config) Author {
+	return &ldapAuth{
+		logger: glog.Module("third_auth", "ldap"),
+		cfg:    cfg.Config.LDAP,
+	}
+}
@@ -0,0 +1,225 @@
+package third_auth
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/jimlambrt/gldap"
+)
+
+const (
+	ldapTestBaseDN   = "dc=example,dc=org"
+	ldapTestBindDN   = "cn=admin,dc=example,dc=org"
+	ldapTestBindPass = "admin-pass"
+)
+
+type ldapTestUser struct {
+	dn       string
+	uid      string
+	password string
+	attrs    map[string][]string
+}
+
+var ldapTestUsers = []ldapTestUser{
+	{
+		dn:       "uid=alice,ou=people,dc=example,dc=org",
+		uid:      "alice",
+		password: "alice-pass",
+		attrs: map[string][]string{
+			"uid":      {"alice"},
+			"cn":       {"Alice"},
+			"mail":     {"alice@example.org"},
+			"memberOf": {"cn=dev,ou=groups,dc=example,dc=org", "cn=ops,ou=groups,dc=example,dc=org"},
+		},
+	},
+	{
+		dn:       "uid=bob,ou=people,dc=example,dc=org",
+		uid:      "bob",
+		password: "bob-pass",
+		attrs: map[string][]string{
+			"uid":  {"bob"},
+			"mail": {"bob@example.org"},
+		},
+	},
+}
+
+func ldapTestServer(t *testing.T) string {
+	t.Helper()
+
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatalf("create ldap mux failed: %v", err)
+	}
+
+	mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+		defer w.Write(resp)
+
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			return
+		}
+
+		if m.UserName == ldapTestBindDN && string(m.Password) == ldapTestBindPass {
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+
+		for _, user := range ldapTestUsers {
+			if m.UserName == user.dn && string(m.Password) == user.password {
+				resp.SetResultCode(gldap.ResultSuccess)
+				return
+			}
+		}
+	})
+
+	mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+		defer w.Write(resp)
+
+		m, err := r.GetSearchMessage()
+		if err != nil {
+			resp.SetResultCode(gldap.ResultOperationsError)
+			return
+		}
+
+		for _, user := range ldapTestUsers {
+			if m.Filter != "(objectClass=person)" && m.Filter != "(uid="+user.uid+")" {
+				continue
+			}
+
+			w.Write(r.NewSearchResponseEntry(user.dn, gldap.WithAttributes(user.attrs)))
+		}
+	})
+
+	s, err := gldap.NewServer()
+	if err != nil {
+		t.Fatalf("create ldap server failed: %v", err)
+	}
+	s.Router(mux)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	go s.Run(addr)
+	t.Cleanup(func() {
+		s.Stop()
+	})
+
+	for i := 0; i < 100 && !s.Ready(); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !s.Ready() {
+		t.Fatal("ldap server not ready")
+	}
+
+	return "ldap://" + addr
+}
+
+func newLDAPTestAuthor(t *testing.T) *ldapAuth {
+	t.Helper()
+
+	author := newLDAP(Config{
+		Config: model.AuthConfig{
+			LDAP: model.AuthConfigLDAP{
+				URL:          ldapTestServer(t),
+				BindDN:       ldapTestBindDN,
+				BindPassword: ldapTestBindPass,
+				BaseDN:       ldapTestBaseDN,
+			},
+		},
+	}).(*ldapAuth)
+
+	err := author.Check(context.Background())
+	if err != nil {
+		t.Fatalf("check ldap config failed: %v", err)
+	}
+
+	return author
+}
+
+func TestLDAPVerify(t *testing.T) {
+	author := newLDAPTestAuthor(t)
+
+	user, err := author.Verify(context.Background(), "alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("verify ldap user failed: %v", err)
+	}
+
+	if user.ThirdID != "alice" || user.Email != "alice@example.org" || user.Name != "Alice" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	if strings.Join(user.OrgNames, ",") != "dev,ops" {
+		t.Fatalf("unexpected user orgs: %v", user.OrgNames)
+	}
+	if user.Type != model.AuthTypeLDAP {
+		t.Fatalf("unexpected user type: %d", user.Type)
+	}
+
+	user, err = author.Verify(context.Background(), "bob", "bob-pass")
+	if err != nil {
+		t.Fatalf("verify ldap user failed: %v", err)
+	}
+	if user.Name != "bob" || len(user.OrgNames) != 0 {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+}
+
+func TestLDAPVerifyInvalid(t *testing.T) {
+	author := newLDAPTestAuthor(t)
+
+	cases := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{name: "wrong password", username: "alice", password: "bob-pass"},
+		{name: "empty password", username: "alice", password: ""},
+		{name: "unknown user", username: "eve", password: "eve-pass"},
+		{name: "filter injection", username: "*", password: "alice-pass"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := author.Verify(context.Background(), c.username, c.password)
+			if err == nil {
+				t.Fatal("expect verify error")
+			}
+		})
+	}
+}
+
+func TestLDAPListUsers(t *testing.T) {
+	author := newLDAPTestAuthor(t)
+
+	users, err := author.ListUsers(context.Background())
+	if err != nil {
+		t.Fatalf("list ldap users failed: %v", err)
+	}
+
+	if len(users) != 2 || users[0].ThirdID != "alice" || users[1].ThirdID != "bob" {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+}
+
+func TestLDAPGroupName(t *testing.T) {
+	cases := map[string]string{
+		"cn=dev,ou=groups,dc=example,dc=org": "dev",
+		"CN=Domain Users,CN=Users,DC=corp":   "Domain Users",
+		"plain":                              "plain",
+	}
+
+	for group, expect := range cases {
+		if name := ldapGroupName(group); name != expect {
+			t.Fatalf("group %s expect %s, got %s", group, expect, name)
+		}
+	}
+}
@@ -53,13 +53,55 @@ func (m *Manager) AuthURL(ctx context.Context, t model.AuthType, state string, o
 	return author.AuthURL(ctx, state, optFuncs...)
 }
 
-func (o *Manager) User(ctx context.Context, t model.AuthType, code string) (*User, error) {
+func (o *Manager) User(ctx context.Context, t model.AuthType, code string, optFuncs ...userOptFunc) (*User, error) {
 	author, ok := o.author(t)
 	if !ok {
 		return nil, errors.ErrUnsupported
 	}
 
-	return author.User(ctx, code)
+	return author.User(ctx, code, optFuncs...)
+}
+
+func (o *Manager) Metadata(ctx context.Context, t model.AuthType) ([]byte, error) {
+	author, ok := o.author(t)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	provider, ok := author.(MetadataProvider)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	return provider.Metadata(ctx)
+}
+
+func (o *Manager) Verify(ctx context.Context, t model.AuthType, username string, password string) (*User, error) {
+	author, ok := o.author(t)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	verifier, ok := author.(PasswordVerifier)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	return verifier.Verify(ctx, username, password)
+}
+
+func (o *Manager) ListUsers(ctx context.Context, t model.AuthType) ([]*User, error) {
+	author, ok := o.author(t)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	lister, ok := author.(UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	return lister.ListUsers(ctx)
 }
 
 func New(t model.AuthType, cfg Config) (Author, error) {
@@ -72,6 +114,10 @@ func New(t model.AuthType, cfg Config) (Author, error) {
 		return newWechat(cfg), nil
 	case model.AuthTypeDingtalk:
 		return newDingtalk(cfg), nil
+	case model.AuthTypeSAML:
+		return newSAML(cfg), nil
+	case model.AuthTypeLDAP:
+		return newLDAP(cfg), nil
 	default:
 		return nil, errors.ErrUnsupported
 	}
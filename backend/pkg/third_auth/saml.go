@@ -0,0 +1,388 @@
+package third_auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/cache"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	samlMetadataPath = "/api/user/login/third/saml/metadata"
+	samlACSPath      = "/api/user/login/third/callback/saml"
+
+	samlMetadataExpire = time.Hour
+	// samlAssertionExpire 超过 IssueInstant + MaxIssueDelay 的断言会被拒绝，记录的断言 id 只需保留到此之后
+	samlAssertionExpire = time.Minute * 10
+)
+
+// assertionBackend 由 fx 注入，未注入时（如单元测试）退化为进程内缓存
+var assertionBackend cache.Backend
+
+type samlAuth struct {
+	logger      *glog.Logger
+	cfg         model.AuthConfigSAML
+	callbackURL model.AccessAddrCallback
+	// assertions 已使用的断言 id，防止截获的 SAMLResponse 被重放
+	assertions cache.Cache[bool]
+
+	metadataLock sync.Mutex
+	metadata     *saml.EntityDescriptor
+	metadataAt   time.Time
+}
+
+func (s *samlAuth) Check(ctx context.Context) error {
+	if s.callbackURL == nil {
+		return errors.New("callback url func is nil")
+	}
+
+	if s.cfg.IDPMetadata == "" && s.cfg.IDPMetadataURL == "" {
+		return errors.New("empty saml idp metadata")
+	}
+
+	if s.cfg.IDPMetadata == "" {
+		_, err := util.ParseHTTP(s.cfg.IDPMetadataURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return err
+	}
+
+	if sp.GetSSOBindingLocation(saml.HTTPRedirectBinding) == "" {
+		return errors.New("saml idp not support redirect binding")
+	}
+
+	return nil
+}
+
+func (s *samlAuth) AuthURL(ctx context.Context, state string, optFuncs ...authURLOptFunc) (string, error) {
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", err
+	}
+	req.ID = samlRequestID(state)
+
+	authURL, err := req.Redirect(url.QueryEscape(state), sp)
+	if err != nil {
+		return "", err
+	}
+
+	return authURL.String(), nil
+}
+
+// User code 为 IdP 通过 HTTP-POST 绑定回传的 SAMLResponse
+func (s *samlAuth) User(ctx context.Context, code string, optFuncs ...userOptFunc) (*User, error) {
+	opt := getUserOpt(optFuncs...)
+	logger := s.logger.WithContext(ctx)
+
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawResp, err := base64.StdEncoding.DecodeString(code)
+	if err != nil {
+		return nil, err
+	}
+
+	var requestIDs []string
+	if opt.State != "" {
+		requestIDs = append(requestIDs, samlRequestID(opt.State))
+	} else if !s.cfg.AllowIDPInitiated {
+		return nil, errors.New("saml idp initiated login is disabled")
+	}
+
+	assertion, err := sp.ParseXMLResponse(rawResp, requestIDs, sp.AcsURL)
+	if err != nil {
+		var invalidErr *saml.InvalidResponseError
+		if errors.As(err, &invalidErr) {
+			logger.WithErr(invalidErr.PrivateErr).Warn("invalid saml response")
+			return nil, fmt.Errorf("invalid saml response: %w", invalidErr.PrivateErr)
+		}
+
+		return nil, err
+	}
+
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, errors.New("empty saml name_id")
+	}
+
+	if assertion.ID == "" {
+		return nil, errors.New("empty saml assertion id")
+	}
+
+	added, err := s.assertions.Add(assertion.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		logger.With("assertion_id", assertion.ID).Warn("saml assertion replayed")
+		return nil, errors.New("saml assertion already used")
+	}
+
+	attr := s.attribute()
+	nameID := assertion.Subject.NameID.Value
+
+	user := &User{
+		ThirdID:  nameID,
+		Type:     model.AuthTypeSAML,
+		Role:     model.UserRoleUser,
+		Name:     samlFirstValue(assertion, attr.Name),
+		Email:    samlFirstValue(assertion, attr.Email),
+		Avatar:   samlFirstValue(assertion, attr.Avatar),
+		OrgNames: samlValues(assertion, attr.Group),
+	}
+
+	if user.Email == "" && strings.Contains(nameID, "@") {
+		user.Email = nameID
+	}
+
+	if user.Name == "" {
+		user.Name = nameID
+	}
+
+	roles := samlValues(assertion, attr.Role)
+	if attr.AdminRole != "" && slices.Contains(roles, attr.AdminRole) {
+		user.Role = model.UserRoleAdmin
+	} else if attr.OperatorRole != "" && slices.Contains(roles, attr.OperatorRole) {
+		user.Role = model.UserRoleOperator
+	}
+
+	return user, nil
+}
+
+func (s *samlAuth) Metadata(ctx context.Context) ([]byte, error) {
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return xml.MarshalIndent(sp.Metadata(), "", "  ")
+}
+
+func (s *samlAuth) attribute() model.AuthConfigSAMLAttribute {
+	attr := s.cfg.Attribute
+	if attr.Email == "" {
+		attr.Email = "email"
+	}
+	if attr.Name == "" {
+		attr.Name = "name"
+	}
+	if attr.Avatar == "" {
+		attr.Avatar = "avatar"
+	}
+	if attr.Role == "" {
+		attr.Role = "role"
+	}
+	if attr.Group == "" {
+		attr.Group = "groups"
+	}
+
+	return attr
+}
+
+// idpMetadata 缓存解析后的 IdP 元数据，远程元数据按 samlMetadataExpire 刷新，配置变更时会重新创建 samlAuth
+func (s *samlAuth) idpMetadata(ctx context.Context) (*saml.EntityDescriptor, error) {
+	s.metadataLock.Lock()
+	defer s.metadataLock.Unlock()
+
+	if s.metadata != nil && (s.cfg.IDPMetadata != "" || time.Since(s.metadataAt) < samlMetadataExpire) {
+		return s.metadata, nil
+	}
+
+	var (
+		metadata *saml.EntityDescriptor
+		err      error
+	)
+	if s.cfg.IDPMetadata != "" {
+		metadata, err = samlsp.ParseMetadata([]byte(s.cfg.IDPMetadata))
+	} else {
+		var metadataURL *url.URL
+		metadataURL, err = util.ParseHTTP(s.cfg.IDPMetadataURL)
+		if err != nil {
+			return nil, err
+		}
+
+		metadata, err = samlsp.FetchMetadata(ctx, util.HTTPClient, *metadataURL)
+	}
+	if err != nil {
+		// 刷新失败时继续使用旧的元数据，避免 IdP 元数据地址短暂不可用影响登录
+		if s.metadata != nil {
+			s.logger.WithContext(ctx).WithErr(err).Warn("refresh saml idp metadata failed, use cached")
+			return s.metadata, nil
+		}
+
+		return nil, err
+	}
+
+	s.metadata = metadata
+	s.metadataAt = time.Now()
+
+	return metadata, nil
+}
+
+func (s *samlAuth) serviceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	cert, err := parseSAMLCertificate(s.cfg.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parseSAMLPrivateKey(s.cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var signatureMethod string
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		signatureMethod = dsig.RSASHA256SignatureMethod
+	case *ecdsa.PrivateKey:
+		signatureMethod = dsig.ECDSASHA256SignatureMethod
+	default:
+		return nil, errors.New("unsupported saml private key type")
+	}
+
+	metadataURL, err := s.fullURL(ctx, samlMetadataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	acsURL, err := s.fullURL(ctx, samlACSPath)
+	if err != nil {
+		return nil, err
+	}
+
+	idpMetadata, err := s.idpMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &saml.ServiceProvider{
+		EntityID:          s.cfg.EntityID,
+		Key:               key,
+		Certificate:       cert,
+		HTTPClient:        util.HTTPClient,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idpMetadata,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		AllowIDPInitiated: s.cfg.AllowIDPInitiated,
+		SignatureMethod:   signatureMethod,
+	}, nil
+}
+
+func (s *samlAuth) fullURL(ctx context.Context, p string) (*url.URL, error) {
+	rawURL, err := s.callbackURL(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.ParseHTTP(rawURL)
+}
+
+func samlRequestID(state string) string {
+	return "id-" + state
+}
+
+func samlValues(assertion *saml.Assertion, name string) []string {
+	var res []string
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if attr.Name != name && attr.FriendlyName != name {
+				continue
+			}
+
+			for _, value := range attr.Values {
+				if value.Value == "" {
+					continue
+				}
+
+				res = append(res, value.Value)
+			}
+		}
+	}
+
+	return res
+}
+
+func samlFirstValue(assertion *saml.Assertion, name string) string {
+	values := samlValues(assertion, name)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func parseSAMLCertificate(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid saml certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func parseSAMLPrivateKey(data string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid saml private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported saml private key type")
+	}
+
+	return signer, nil
+}
+
+func newSAML(cfg Config) Author {
+	return &samlAuth{
+		logger:      glog.Module("third_auth", "saml"),
+		cfg:         cfg.Config.SAML,
+		callbackURL: cfg.CallbackURL,
+		assertions:  cache.NewShared[bool](assertionBackend, "saml_assertion", samlAssertionExpire),
+	}
+}
@@ -0,0 +1,288 @@
+package third_auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/chaitin/koalaqa/model"
+	"github.com/crewjam/saml"
+)
+
+const samlTestAddress = "https://koala.example.com"
+
+func samlTestKeyPair(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "koala test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate failed: %v", err)
+	}
+
+	return key, cert
+}
+
+type samlTestEnv struct {
+	idp    *saml.IdentityProvider
+	author *samlAuth
+}
+
+func newSAMLTestEnv(t *testing.T, allowIDPInitiated bool) *samlTestEnv {
+	t.Helper()
+
+	idpKey, idpCert := samlTestKeyPair(t)
+	idp := &saml.IdentityProvider{
+		Key:         idpKey,
+		Certificate: idpCert,
+		MetadataURL: url.URL{Scheme: "https", Host: "idp.example.com", Path: "/metadata"},
+		SSOURL:      url.URL{Scheme: "https", Host: "idp.example.com", Path: "/sso"},
+	}
+	idpMetadata, err := xml.Marshal(idp.Metadata())
+	if err != nil {
+		t.Fatalf("marshal idp metadata failed: %v", err)
+	}
+
+	spKey, spCert := samlTestKeyPair(t)
+	author := newSAML(Config{
+		Config: model.AuthConfig{
+			SAML: model.AuthConfigSAML{
+				IDPMetadata:       string(idpMetadata),
+				Certificate:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: spCert.Raw})),
+				PrivateKey:        string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(spKey)})),
+				AllowIDPInitiated: allowIDPInitiated,
+				Attribute: model.AuthConfigSAMLAttribute{
+					Name:      "displayName",
+					AdminRole: "koala-admin",
+				},
+			},
+		},
+		CallbackURL: func(ctx context.Context, path string) (string, error) {
+			return samlTestAddress + path, nil
+		},
+	}).(*samlAuth)
+
+	return &samlTestEnv{idp: idp, author: author}
+}
+
+// response 模拟 IdP 签发 SAMLResponse，requestID 为空时视为 IdP 发起的登录
+func (e *samlTestEnv) response(t *testing.T, requestID string, session *saml.Session) string {
+	t.Helper()
+
+	spMetadata, err := e.author.Metadata(context.Background())
+	if err != nil {
+		t.Fatalf("get sp metadata failed: %v", err)
+	}
+
+	var spDesc saml.EntityDescriptor
+	err = xml.Unmarshal(spMetadata, &spDesc)
+	if err != nil {
+		t.Fatalf("unmarshal sp metadata failed: %v", err)
+	}
+
+	req := &saml.IdpAuthnRequest{
+		IDP:         e.idp,
+		HTTPRequest: httptest.NewRequest(http.MethodPost, e.idp.SSOURL.String(), nil),
+		Request: saml.AuthnRequest{
+			ID:     requestID,
+			Issuer: &saml.Issuer{Value: spDesc.EntityID},
+		},
+		ServiceProviderMetadata: &spDesc,
+		SPSSODescriptor:         &spDesc.SPSSODescriptors[0],
+		ACSEndpoint: &saml.IndexedEndpoint{
+			Binding:  saml.HTTPPostBinding,
+			Location: samlTestAddress + samlACSPath,
+		},
+		Now: saml.TimeNow(),
+	}
+
+	err = saml.DefaultAssertionMaker{}.MakeAssertion(req, session)
+	if err != nil {
+		t.Fatalf("make assertion failed: %v", err)
+	}
+
+	err = req.MakeResponse()
+	if err != nil {
+		t.Fatalf("make response failed: %v", err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(req.ResponseEl)
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("write response failed: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func samlTestSession() *saml.Session {
+	return &saml.Session{
+		ID:         "session",
+		CreateTime: time.Now(),
+		ExpireTime: time.Now().Add(time.Hour),
+		Index:      "index",
+		NameID:     "alice@example.com",
+		CustomAttributes: []saml.Attribute{
+			{Name: "displayName", Values: []saml.AttributeValue{{Type: "xs:string", Value: "Alice"}}},
+			{Name: "role", Values: []saml.AttributeValue{{Type: "xs:string", Value: "koala-admin"}}},
+			{Name: "groups", Values: []saml.AttributeValue{
+				{Type: "xs:string", Value: "dev"},
+				{Type: "xs:string", Value: "ops"},
+			}},
+		},
+	}
+}
+
+func TestSAMLAuthURL(t *testing.T) {
+	env := newSAMLTestEnv(t, false)
+
+	authURL, err := env.author.AuthURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatalf("get auth url failed: %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url failed: %v", err)
+	}
+
+	if u.Host != "idp.example.com" {
+		t.Fatalf("unexpected idp host: %s", u.Host)
+	}
+
+	query := u.Query()
+	for _, key := range []string{"SAMLRequest", "SigAlg", "Signature"} {
+		if query.Get(key) == "" {
+			t.Fatalf("auth url missing %s", key)
+		}
+	}
+
+	if query.Get("RelayState") != "state-1" {
+		t.Fatalf("unexpected relay state: %s", query.Get("RelayState"))
+	}
+}
+
+func TestSAMLMetadata(t *testing.T) {
+	env := newSAMLTestEnv(t, false)
+
+	metadata, err := env.author.Metadata(context.Background())
+	if err != nil {
+		t.Fatalf("get metadata failed: %v", err)
+	}
+
+	if !strings.Contains(string(metadata), samlTestAddress+samlACSPath) {
+		t.Fatalf("metadata missing acs url: %s", metadata)
+	}
+}
+
+func TestSAMLUser(t *testing.T) {
+	env := newSAMLTestEnv(t, false)
+	resp := env.response(t, samlRequestID("state-1"), samlTestSession())
+
+	user, err := env.author.User(context.Background(), resp, UserWithState("state-1"))
+	if err != nil {
+		t.Fatalf("get saml user failed: %v", err)
+	}
+
+	if user.ThirdID != "alice@example.com" || user.Email != "alice@example.com" {
+		t.Fatalf("unexpected user identity: %+v", user)
+	}
+	if user.Name != "Alice" {
+		t.Fatalf("unexpected user name: %s", user.Name)
+	}
+	if user.Role != model.UserRoleAdmin {
+		t.Fatalf("unexpected user role: %d", user.Role)
+	}
+	if strings.Join(user.OrgNames, ",") != "dev,ops" {
+		t.Fatalf("unexpected user orgs: %v", user.OrgNames)
+	}
+	if user.Type != model.AuthTypeSAML {
+		t.Fatalf("unexpected user type: %d", user.Type)
+	}
+}
+
+func TestSAMLUserInvalid(t *testing.T) {
+	env := newSAMLTestEnv(t, false)
+	resp := env.response(t, samlRequestID("state-1"), samlTestSession())
+
+	_, err := env.author.User(context.Background(), resp, UserWithState("state-2"))
+	if err == nil {
+		t.Fatal("expect request id mismatch error")
+	}
+
+	_, err = env.author.User(context.Background(), resp)
+	if err == nil {
+		t.Fatal("expect idp initiated disabled error")
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(resp)
+	tampered := strings.Replace(string(raw), "Version=\"2.0\"", "Version=\"2.1\"", 1)
+	_, err = env.author.User(context.Background(), base64.StdEncoding.EncodeToString([]byte(tampered)), UserWithState("state-1"))
+	if err == nil {
+		t.Fatal("expect tampered response error")
+	}
+
+	other := newSAMLTestEnv(t, false)
+	_, err = other.author.User(context.Background(), resp, UserWithState("state-1"))
+	if err == nil {
+		t.Fatal("expect untrusted idp error")
+	}
+}
+
+func TestSAMLUserIDPInitiated(t *testing.T) {
+	env := newSAMLTestEnv(t, true)
+	resp := env.response(t, "", samlTestSession())
+
+	user, err := env.author.User(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("idp initiated login failed: %v", err)
+	}
+
+	if user.ThirdID != "alice@example.com" {
+		t.Fatalf("unexpected third id: %s", user.ThirdID)
+	}
+}
+
+func TestSAMLUserReplay(t *testing.T) {
+	env := newSAMLTestEnv(t, true)
+	resp := env.response(t, "", samlTestSession())
+
+	_, err := env.author.User(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("idp initiated login failed: %v", err)
+	}
+
+	_, err = env.author.User(context.Background(), resp)
+	if err == nil {
+		t.Fatal("expect replayed assertion error")
+	}
+}
@@ -22,6 +22,8 @@ type User struct {
 	Avatar  string
 	Mobile  string
 	Role    model.UserRole
+	// OrgNames 第三方提供的组织名称，用于同步用户所属组织
+	OrgNames []string
 }
 
 func (u *User) HashInt() int {
@@ -66,6 +68,7 @@ const (
 
 type userOpt struct {
 	ThirdIDKey ThirdIDKey
+	State      string
 }
 
 type userOptFunc func(o *userOpt)
@@ -76,6 +79,12 @@ func UserWithThirdIDKey(key ThirdIDKey) userOptFunc {
 	}
 }
 
+func UserWithState(state string) userOptFunc {
+	return func(o *userOpt) {
+		o.State = state
+	}
+}
+
 func getUserOpt(funcs ...userOptFunc) userOpt {
 	var o userOpt
 
@@ -90,3 +99,18 @@ type Author interface {
 	AuthURL(ctx context.Context, state string, optFuncs ...authURLOptFunc) (string, error)
 	User(ctx context.Context, code string, optFuncs ...userOptFunc) (*User, error)
 }
+
+// MetadataProvider 需要向身份提供方暴露元数据的认证方式，例如 SAML
+type MetadataProvider interface {
+	Metadata(ctx context.Context) ([]byte, error)
+}
+
+// PasswordVerifier 使用用户名密码直接认证的方式，例如 LDAP
+type PasswordVerifier interface {
+	Verify(ctx context.Context, username string, password string) (*User, error)
+}
+
+// UserLister 可以列出目录中全部用户的认证方式，用于定时同步
+type UserLister interface {
+	ListUsers(ctx context.Context) ([]*User, error)
+}
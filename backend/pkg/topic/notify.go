@@ -8,6 +8,8 @@ type MsgMessageNotify struct {
 	model.DiscussHeader
 	model.UserReviewHeader
 	model.UserPointHeader
+	model.BadgeHeader
+	model.IssueHeader
 
 	ParentID  uint                `json:"parent_id"`
 	CommentID uint                `json:"comment_id"`
@@ -0,0 +1,7 @@
+package topic
+
+var TopicUserBadge = newTopic("koala.persistence.user.badge", true)
+
+type MsgUserBadge struct {
+	UserID uint `json:"user_id"`
+}
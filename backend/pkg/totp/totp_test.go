@@ -0,0 +1,81 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量，取后 6 位
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for ts, expect := range cases {
+		code, err := Code(rfcSecret, time.Unix(ts, 0))
+		if err != nil {
+			t.Fatalf("generate code failed: %v", err)
+		}
+
+		if code != expect {
+			t.Fatalf("time %d expect %s, got %s", ts, expect, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generate secret failed: %v", err)
+	}
+
+	now := time.Now()
+	prev, _ := Code(secret, now.Add(-Period*time.Second))
+	counter, ok := Validate(secret, prev, now)
+	if !ok || counter != Counter(now)-1 {
+		t.Fatalf("previous period code should be valid, counter %d", counter)
+	}
+
+	old, _ := Code(secret, now.Add(-3*Period*time.Second))
+	if _, ok := Validate(secret, old, now); ok {
+		t.Fatal("expired code should be invalid")
+	}
+
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Fatal("short code should be invalid")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Koala QA", "alice@example.com", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Koala%20QA:alice@example.com?") {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+	if !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Koala+QA") {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := RecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("generate recovery codes failed: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Fatalf("unexpected recovery code: %s", code)
+		}
+		seen[code] = true
+	}
+}
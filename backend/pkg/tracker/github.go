@@ -0,0 +1,139 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chaitin/koalaqa/model"
+)
+
+type github struct {
+	cfg model.TrackerConfig
+}
+
+func newGithub(cfg model.TrackerConfig) (Tracker, error) {
+	owner, repo, ok := strings.Cut(cfg.Project, "/")
+	if !ok || owner == "" || repo == "" {
+		return nil, fmt.Errorf("invalid github project: %s", cfg.Project)
+	}
+
+	return &github{cfg: cfg}, nil
+}
+
+func (g *github) header() http.Header {
+	return http.Header{
+		"Authorization":        []string{"Bearer " + g.cfg.Token},
+		"X-Github-Api-Version": []string{"2022-11-28"},
+	}
+}
+
+func (g *github) CreateIssue(ctx context.Context, title string, body string) (*Issue, error) {
+	var res struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	err := doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/repos/%s/issues", g.cfg.URL, g.cfg.Project), g.header(), map[string]string{
+		"title": title,
+		"body":  body,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Issue{
+		ID:  strconv.Itoa(res.Number),
+		URL: res.HTMLURL,
+	}, nil
+}
+
+func (g *github) CreateComment(ctx context.Context, issueID string, body string) (string, error) {
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	err := doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/repos/%s/issues/%s/comments", g.cfg.URL, g.cfg.Project, issueID), g.header(), map[string]string{
+		"body": body,
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(res.ID, 10), nil
+}
+
+type githubWebhook struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number      int             `json:"number"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	Label struct {
+		Name string `json:"name"`
+	} `json:"label"`
+	Comment struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"comment"`
+}
+
+func (g *github) ParseWebhook(header http.Header, body []byte) (*Event, error) {
+	err := verifySha256(g.cfg.Secret, header.Get("X-Hub-Signature-256"), body)
+	if err != nil {
+		return nil, err
+	}
+
+	var data githubWebhook
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data.Issue.PullRequest) > 0 {
+		return nil, nil
+	}
+
+	issueID := strconv.Itoa(data.Issue.Number)
+	switch header.Get("X-Github-Event") {
+	case "issues":
+		state := StateUnknown
+		switch data.Action {
+		case "closed":
+			state = StateClosed
+		case "reopened":
+			state = StateOpen
+		case "labeled":
+			if inProgressLabel(data.Label.Name) {
+				state = StateInProgress
+			}
+		}
+		if state == StateUnknown {
+			return nil, nil
+		}
+
+		return &Event{
+			Type:    EventTypeState,
+			IssueID: issueID,
+			State:   state,
+		}, nil
+	case "issue_comment":
+		if data.Action != "created" {
+			return nil, nil
+		}
+
+		return &Event{
+			Type:      EventTypeComment,
+			IssueID:   issueID,
+			CommentID: strconv.FormatInt(data.Comment.ID, 10),
+			Author:    data.Comment.User.Login,
+			Body:      data.Comment.Body,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
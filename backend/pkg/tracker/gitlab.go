@@ -0,0 +1,152 @@
+package tracker
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/chaitin/koalaqa/model"
+)
+
+type gitlab struct {
+	cfg model.TrackerConfig
+}
+
+func newGitlab(cfg model.TrackerConfig) (Tracker, error) {
+	return &gitlab{cfg: cfg}, nil
+}
+
+func (g *gitlab) header() http.Header {
+	return http.Header{
+		"Private-Token": []string{g.cfg.Token},
+	}
+}
+
+func (g *gitlab) issueURL() string {
+	return fmt.Sprintf("%s/api/v4/projects/%s/issues", g.cfg.URL, url.PathEscape(g.cfg.Project))
+}
+
+func (g *gitlab) CreateIssue(ctx context.Context, title string, body string) (*Issue, error) {
+	var res struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	err := doJSON(ctx, http.MethodPost, g.issueURL(), g.header(), map[string]string{
+		"title":       title,
+		"description": body,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Issue{
+		ID:  strconv.Itoa(res.IID),
+		URL: res.WebURL,
+	}, nil
+}
+
+func (g *gitlab) CreateComment(ctx context.Context, issueID string, body string) (string, error) {
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	err := doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/%s/notes", g.issueURL(), issueID), g.header(), map[string]string{
+		"body": body,
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(res.ID, 10), nil
+}
+
+type gitlabLabel struct {
+	Title string `json:"title"`
+}
+
+type gitlabWebhook struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	ObjectAttributes struct {
+		ID           int64  `json:"id"`
+		IID          int    `json:"iid"`
+		Action       string `json:"action"`
+		Note         string `json:"note"`
+		NoteableType string `json:"noteable_type"`
+	} `json:"object_attributes"`
+	Issue struct {
+		IID int `json:"iid"`
+	} `json:"issue"`
+	Changes struct {
+		Labels struct {
+			Previous []gitlabLabel `json:"previous"`
+			Current  []gitlabLabel `json:"current"`
+		} `json:"labels"`
+	} `json:"changes"`
+}
+
+func hasInProgressLabel(labels []gitlabLabel) bool {
+	return slices.ContainsFunc(labels, func(l gitlabLabel) bool {
+		return inProgressLabel(l.Title)
+	})
+}
+
+func (g *gitlab) ParseWebhook(header http.Header, body []byte) (*Event, error) {
+	if g.cfg.Secret == "" {
+		return nil, errors.New("empty secret")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(g.cfg.Secret)) != 1 {
+		return nil, errors.New("token mismatch")
+	}
+
+	var data gitlabWebhook
+	err := json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch header.Get("X-Gitlab-Event") {
+	case "Issue Hook":
+		state := StateUnknown
+		switch data.ObjectAttributes.Action {
+		case "close":
+			state = StateClosed
+		case "reopen":
+			state = StateOpen
+		case "update":
+			if !hasInProgressLabel(data.Changes.Labels.Previous) && hasInProgressLabel(data.Changes.Labels.Current) {
+				state = StateInProgress
+			}
+		}
+		if state == StateUnknown {
+			return nil, nil
+		}
+
+		return &Event{
+			Type:    EventTypeState,
+			IssueID: strconv.Itoa(data.ObjectAttributes.IID),
+			State:   state,
+		}, nil
+	case "Note Hook":
+		if data.ObjectAttributes.NoteableType != "Issue" {
+			return nil, nil
+		}
+
+		return &Event{
+			Type:      EventTypeComment,
+			IssueID:   strconv.Itoa(data.Issue.IID),
+			CommentID: strconv.FormatInt(data.ObjectAttributes.ID, 10),
+			Author:    data.User.Username,
+			Body:      data.ObjectAttributes.Note,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,155 @@
+package tracker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/chaitin/koalaqa/model"
+)
+
+type jira struct {
+	cfg model.TrackerConfig
+}
+
+func newJira(cfg model.TrackerConfig) (Tracker, error) {
+	if cfg.IssueType == "" {
+		cfg.IssueType = "Task"
+	}
+
+	return &jira{cfg: cfg}, nil
+}
+
+func (j *jira) header() http.Header {
+	auth := "Bearer " + j.cfg.Token
+	if j.cfg.Username != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(j.cfg.Username+":"+j.cfg.Token))
+	}
+
+	return http.Header{
+		"Authorization": []string{auth},
+	}
+}
+
+func (j *jira) CreateIssue(ctx context.Context, title string, body string) (*Issue, error) {
+	var res struct {
+		Key string `json:"key"`
+	}
+	err := doJSON(ctx, http.MethodPost, j.cfg.URL+"/rest/api/2/issue", j.header(), map[string]any{
+		"fields": map[string]any{
+			"project": map[string]string{
+				"key": j.cfg.Project,
+			},
+			"summary":     title,
+			"description": body,
+			"issuetype": map[string]string{
+				"name": j.cfg.IssueType,
+			},
+		},
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Issue{
+		ID:  res.Key,
+		URL: fmt.Sprintf("%s/browse/%s", j.cfg.URL, res.Key),
+	}, nil
+}
+
+func (j *jira) CreateComment(ctx context.Context, issueID string, body string) (string, error) {
+	var res struct {
+		ID string `json:"id"`
+	}
+	err := doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/rest/api/2/issue/%s/comment", j.cfg.URL, issueID), j.header(), map[string]string{
+		"body": body,
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return res.ID, nil
+}
+
+type jiraWebhook struct {
+	WebhookEvent string `json:"webhookEvent"`
+	Issue        struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Status struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"status"`
+		} `json:"fields"`
+	} `json:"issue"`
+	Changelog struct {
+		Items []struct {
+			Field string `json:"field"`
+		} `json:"items"`
+	} `json:"changelog"`
+	Comment struct {
+		ID     string `json:"id"`
+		Body   string `json:"body"`
+		Author struct {
+			DisplayName string `json:"displayName"`
+		} `json:"author"`
+	} `json:"comment"`
+}
+
+func (j *jira) ParseWebhook(header http.Header, body []byte) (*Event, error) {
+	err := verifySha256(j.cfg.Secret, header.Get("X-Hub-Signature"), body)
+	if err != nil {
+		return nil, err
+	}
+
+	var data jiraWebhook
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch data.WebhookEvent {
+	case "jira:issue_updated":
+		statusChanged := false
+		for _, item := range data.Changelog.Items {
+			if item.Field == "status" {
+				statusChanged = true
+				break
+			}
+		}
+		if !statusChanged {
+			return nil, nil
+		}
+
+		state := StateUnknown
+		switch data.Issue.Fields.Status.StatusCategory.Key {
+		case "new":
+			state = StateOpen
+		case "indeterminate":
+			state = StateInProgress
+		case "done":
+			state = StateClosed
+		default:
+			return nil, nil
+		}
+
+		return &Event{
+			Type:    EventTypeState,
+			IssueID: data.Issue.Key,
+			State:   state,
+		}, nil
+	case "comment_created":
+		return &Event{
+			Type:      EventTypeComment,
+			IssueID:   data.Issue.Key,
+			CommentID: data.Comment.ID,
+			Author:    data.Comment.Author.DisplayName,
+			Body:      data.Comment.Body,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,148 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/util"
+)
+
+type State uint
+
+const (
+	StateUnknown State = iota
+	StateOpen
+	StateInProgress
+	StateClosed
+)
+
+type EventType uint
+
+const (
+	EventTypeState EventType = iota + 1
+	EventTypeComment
+)
+
+type Issue struct {
+	ID  string
+	URL string
+}
+
+// Event 外部系统回调事件
+type Event struct {
+	Type    EventType
+	IssueID string
+	State   State
+
+	CommentID string
+	Author    string
+	Body      string
+}
+
+type Tracker interface {
+	CreateIssue(ctx context.Context, title string, body string) (*Issue, error)
+	CreateComment(ctx context.Context, issueID string, body string) (string, error)
+	// ParseWebhook 校验并解析回调，不关心的事件返回 nil
+	ParseWebhook(header http.Header, body []byte) (*Event, error)
+}
+
+func New(cfg model.TrackerConfig) (Tracker, error) {
+	_, err := util.ParseHTTP(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	switch cfg.Type {
+	case model.TrackerTypeGithub:
+		return newGithub(cfg)
+	case model.TrackerTypeGitlab:
+		return newGitlab(cfg)
+	case model.TrackerTypeJira:
+		return newJira(cfg)
+	default:
+		return nil, fmt.Errorf("tracker type %d not support", cfg.Type)
+	}
+}
+
+func doJSON(ctx context.Context, method string, u string, header http.Header, reqBody any, resBody any) error {
+	var body io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := util.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s status code: %d, body: %s", method, u, resp.StatusCode, util.TruncateString(string(data), 200))
+	}
+
+	if resBody == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, resBody)
+}
+
+// verifySha256 校验 "sha256=<hex>" 格式的 HMAC 签名
+func verifySha256(secret string, signature string, body []byte) error {
+	if secret == "" {
+		return errors.New("empty secret")
+	}
+
+	sign, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return errors.New("invalid signature")
+	}
+
+	expect := hex.EncodeToString(util.HMACSha256(secret, string(body)))
+	if !hmac.Equal([]byte(sign), []byte(expect)) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
+
+// inProgressLabel 带有该标签的 GitHub/GitLab Issue 视为进行中
+func inProgressLabel(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "in progress", "in-progress", "doing":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,255 @@
+package tracker
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/util"
+)
+
+const trackerTestSecret = "test-secret"
+
+type fakeRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+// newFakeServer 记录请求并按路径返回固定响应
+func newFakeServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]fakeRequest) {
+	t.Helper()
+
+	var reqs []fakeRequest
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := fakeRequest{
+			method: r.Method,
+			path:   r.URL.EscapedPath(),
+			header: r.Header.Clone(),
+		}
+		_ = json.Unmarshal(data, &req.body)
+		reqs = append(reqs, req)
+
+		res, ok := responses[r.Method+" "+req.path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(res))
+	}))
+	t.Cleanup(s.Close)
+
+	return s, &reqs
+}
+
+func sign(body string) string {
+	return "sha256=" + hex.EncodeToString(util.HMACSha256(trackerTestSecret, body))
+}
+
+func TestGithub(t *testing.T) {
+	s, reqs := newFakeServer(t, map[string]string{
+		"POST /repos/chaitin/koalaqa/issues":             `{"number": 12, "html_url": "https://github.com/chaitin/koalaqa/issues/12"}`,
+		"POST /repos/chaitin/koalaqa/issues/12/comments": `{"id": 3001}`,
+	})
+
+	tr, err := New(model.TrackerConfig{
+		Type:    model.TrackerTypeGithub,
+		URL:     s.URL + "/",
+		Project: "chaitin/koalaqa",
+		Token:   "gh-token",
+		Secret:  trackerTestSecret,
+	})
+	if err != nil {
+		t.Fatalf("new github tracker failed: %v", err)
+	}
+
+	issue, err := tr.CreateIssue(context.Background(), "login failed", "detail")
+	if err != nil {
+		t.Fatalf("create issue failed: %v", err)
+	}
+	if issue.ID != "12" || issue.URL != "https://github.com/chaitin/koalaqa/issues/12" {
+		t.Fatalf("unexpected issue: %+v", issue)
+	}
+	if (*reqs)[0].header.Get("Authorization") != "Bearer gh-token" || (*reqs)[0].body["title"] != "login failed" {
+		t.Fatalf("unexpected create request: %+v", (*reqs)[0])
+	}
+
+	commentID, err := tr.CreateComment(context.Background(), issue.ID, "hello")
+	if err != nil {
+		t.Fatalf("create comment failed: %v", err)
+	}
+	if commentID != "3001" || (*reqs)[1].body["body"] != "hello" {
+		t.Fatalf("unexpected comment: %s %+v", commentID, (*reqs)[1])
+	}
+
+	body := `{"action": "closed", "issue": {"number": 12}}`
+	header := http.Header{}
+	header.Set("X-Github-Event", "issues")
+	header.Set("X-Hub-Signature-256", sign(body))
+	event, err := tr.ParseWebhook(header, []byte(body))
+	if err != nil {
+		t.Fatalf("parse webhook failed: %v", err)
+	}
+	if event == nil || event.Type != EventTypeState || event.IssueID != "12" || event.State != StateClosed {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	body = `{"action": "created", "issue": {"number": 12}, "comment": {"id": 3002, "body": "fixed", "user": {"login": "dev"}}}`
+	header.Set("X-Github-Event", "issue_comment")
+	header.Set("X-Hub-Signature-256", sign(body))
+	event, err = tr.ParseWebhook(header, []byte(body))
+	if err != nil {
+		t.Fatalf("parse webhook failed: %v", err)
+	}
+	if event == nil || event.Type != EventTypeComment || event.CommentID != "3002" || event.Author != "dev" || event.Body != "fixed" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	header.Set("X-Hub-Signature-256", sign("other"))
+	_, err = tr.ParseWebhook(header, []byte(body))
+	if err == nil {
+		t.Fatal("expect signature mismatch")
+	}
+}
+
+func TestGitlab(t *testing.T) {
+	s, reqs := newFakeServer(t, map[string]string{
+		"POST /api/v4/projects/group%2Fkoalaqa/issues":         `{"iid": 7, "web_url": "https://gitlab.com/group/koalaqa/-/issues/7"}`,
+		"POST /api/v4/projects/group%2Fkoalaqa/issues/7/notes": `{"id": 501}`,
+	})
+
+	tr, err := New(model.TrackerConfig{
+		Type:    model.TrackerTypeGitlab,
+		URL:     s.URL,
+		Project: "group/koalaqa",
+		Token:   "gl-token",
+		Secret:  trackerTestSecret,
+	})
+	if err != nil {
+		t.Fatalf("new gitlab tracker failed: %v", err)
+	}
+
+	issue, err := tr.CreateIssue(context.Background(), "login failed", "detail")
+	if err != nil {
+		t.Fatalf("create issue failed: %v", err)
+	}
+	if issue.ID != "7" || (*reqs)[0].header.Get("Private-Token") != "gl-token" || (*reqs)[0].body["description"] != "detail" {
+		t.Fatalf("unexpected issue: %+v %+v", issue, (*reqs)[0])
+	}
+
+	commentID, err := tr.CreateComment(context.Background(), issue.ID, "hello")
+	if err != nil {
+		t.Fatalf("create comment failed: %v", err)
+	}
+	if commentID != "501" {
+		t.Fatalf("unexpected comment id: %s", commentID)
+	}
+
+	header := http.Header{}
+	header.Set("X-Gitlab-Event", "Issue Hook")
+	header.Set("X-Gitlab-Token", trackerTestSecret)
+	event, err := tr.ParseWebhook(header, []byte(`{"object_attributes": {"iid": 7, "action": "update"}, "changes": {"labels": {"previous": [], "current": [{"title": "In Progress"}]}}}`))
+	if err != nil {
+		t.Fatalf("parse webhook failed: %v", err)
+	}
+	if event == nil || event.State != StateInProgress || event.IssueID != "7" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	header.Set("X-Gitlab-Event", "Note Hook")
+	event, err = tr.ParseWebhook(header, []byte(`{"user": {"username": "dev"}, "object_attributes": {"id": 502, "note": "fixed", "noteable_type": "Issue"}, "issue": {"iid": 7}}`))
+	if err != nil {
+		t.Fatalf("parse webhook failed: %v", err)
+	}
+	if event == nil || event.Type != EventTypeComment || event.CommentID != "502" || event.IssueID != "7" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	header.Set("X-Gitlab-Token", "wrong")
+	_, err = tr.ParseWebhook(header, []byte(`{}`))
+	if err == nil {
+		t.Fatal("expect token mismatch")
+	}
+}
+
+func TestJira(t *testing.T) {
+	s, reqs := newFakeServer(t, map[string]string{
+		"POST /rest/api/2/issue":               `{"key": "KQA-9"}`,
+		"POST /rest/api/2/issue/KQA-9/comment": `{"id": "10001"}`,
+	})
+
+	tr, err := New(model.TrackerConfig{
+		Type:     model.TrackerTypeJira,
+		URL:      s.URL,
+		Project:  "KQA",
+		Username: "dev@example.com",
+		Token:    "jira-token",
+		Secret:   trackerTestSecret,
+	})
+	if err != nil {
+		t.Fatalf("new jira tracker failed: %v", err)
+	}
+
+	issue, err := tr.CreateIssue(context.Background(), "login failed", "detail")
+	if err != nil {
+		t.Fatalf("create issue failed: %v", err)
+	}
+	if issue.ID != "KQA-9" || issue.URL != s.URL+"/browse/KQA-9" {
+		t.Fatalf("unexpected issue: %+v", issue)
+	}
+	fields, _ := (*reqs)[0].body["fields"].(map[string]any)
+	if fields["summary"] != "login failed" || fields["issuetype"].(map[string]any)["name"] != "Task" {
+		t.Fatalf("unexpected create request: %+v", (*reqs)[0].body)
+	}
+	if (*reqs)[0].header.Get("Authorization") != "Basic ZGV2QGV4YW1wbGUuY29tOmppcmEtdG9rZW4=" {
+		t.Fatalf("unexpected auth header: %s", (*reqs)[0].header.Get("Authorization"))
+	}
+
+	commentID, err := tr.CreateComment(context.Background(), issue.ID, "hello")
+	if err != nil {
+		t.Fatalf("create comment failed: %v", err)
+	}
+	if commentID != "10001" {
+		t.Fatalf("unexpected comment id: %s", commentID)
+	}
+
+	body := `{"webhookEvent": "jira:issue_updated", "issue": {"key": "KQA-9", "fields": {"status": {"statusCategory": {"key": "done"}}}}, "changelog": {"items": [{"field": "status"}]}}`
+	header := http.Header{}
+	header.Set("X-Hub-Signature", sign(body))
+	event, err := tr.ParseWebhook(header, []byte(body))
+	if err != nil {
+		t.Fatalf("parse webhook failed: %v", err)
+	}
+	if event == nil || event.State != StateClosed || event.IssueID != "KQA-9" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	body = `{"webhookEvent": "jira:issue_updated", "issue": {"key": "KQA-9"}, "changelog": {"items": [{"field": "summary"}]}}`
+	header.Set("X-Hub-Signature", sign(body))
+	event, err = tr.ParseWebhook(header, []byte(body))
+	if err != nil || event != nil {
+		t.Fatalf("expect ignored event, got %+v, %v", event, err)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(model.TrackerConfig{Type: model.TrackerTypeGithub, URL: "https://api.github.com", Project: "koalaqa"})
+	if err == nil {
+		t.Fatal("expect invalid github project")
+	}
+
+	_, err = New(model.TrackerConfig{Type: 100, URL: "https://example.com"})
+	if err == nil {
+		t.Fatal("expect unsupported type")
+	}
+}
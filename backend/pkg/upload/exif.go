@@ -0,0 +1,172 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+)
+
+// maxPixels 限制解码的图片大小，避免解压炸弹
+const maxPixels = 50_000_000
+
+var ErrImageTooLarge = errors.New("image too large")
+
+// StripMetadata 重新编码 jpeg、png 图片以去除 EXIF 等元数据，jpeg 会先按 EXIF 方向旋转
+// 其他格式原样返回
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return data, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, orient(img, jpegOrientation(data)), &jpeg.Options{Quality: 90})
+	case "image/png":
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// jpegOrientation 读取 EXIF 中的方向，读取失败时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+
+		marker := data[i+1]
+		// SOS 之后是图像数据，不再有 EXIF
+		if marker == 0xDA {
+			return 1
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		i += 2 + size
+	}
+
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := range count {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			v := int(order.Uint16(tiff[entry+8:]))
+			if v < 1 || v > 8 {
+				return 1
+			}
+			return v
+		}
+	}
+
+	return 1
+}
+
+// orient 按 EXIF 方向变换图片
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	var (
+		dst *image.NRGBA
+		src func(x, y int) (int, int)
+	)
+
+	switch orientation {
+	case 2:
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+		src = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+		src = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+		src = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		src = func(x, y int) (int, int) { return y, x }
+	case 6:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		src = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		src = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		src = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return img
+	}
+
+	db := dst.Bounds()
+	for y := 0; y < db.Dy(); y++ {
+		for x := 0; x < db.Dx(); x++ {
+			sx, sy := src(x, y)
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+
+	return dst
+}
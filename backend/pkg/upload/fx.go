@@ -0,0 +1,14 @@
+package upload
+
+import (
+	"github.com/chaitin/koalaqa/pkg/config"
+	"go.uber.org/fx"
+)
+
+func newScanner(cfg config.Config) (Scanner, error) {
+	return NewScanner(cfg.Upload.ClamAV)
+}
+
+var Module = fx.Options(
+	fx.Provide(newScanner),
+)
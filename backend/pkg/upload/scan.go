@@ -0,0 +1,139 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+)
+
+const clamdChunkSize = 64 * 1024
+
+// InfectedError 文件包含恶意代码
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return "file infected: " + e.Signature
+}
+
+// Scanner 扫描上传文件中的恶意代码，发现时返回 *InfectedError
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+type nopScanner struct{}
+
+func (nopScanner) Scan(context.Context, io.Reader) error {
+	return nil
+}
+
+// clamd 通过 INSTREAM 命令将文件发送给 clamd 扫描
+type clamd struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+func (c *clamd) Scan(ctx context.Context, r io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = conn.SetDeadline(deadline)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			_, err = conn.Write(buf[:4+n])
+			if err != nil {
+				// clamd 超过 StreamMaxLength 时会直接返回错误并关闭连接
+				break
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	// 长度为 0 的块表示结束
+	_, _ = conn.Write([]byte{0, 0, 0, 0})
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return err
+	}
+
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00")))
+}
+
+func parseClamdReply(reply string) error {
+	reply = strings.TrimSpace(reply)
+	_, result, _ := strings.Cut(reply, ": ")
+
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &InfectedError{Signature: strings.TrimSuffix(result, " FOUND")}
+	default:
+		return fmt.Errorf("clamd scan failed: %s", reply)
+	}
+}
+
+// NewScanner addr 为空时不扫描
+func NewScanner(cfg config.ClamAV) (Scanner, error) {
+	if cfg.Addr == "" {
+		return nopScanner{}, nil
+	}
+
+	network, addr, ok := strings.Cut(cfg.Addr, "://")
+	if !ok {
+		network, addr = "tcp", cfg.Addr
+	}
+	if network != "tcp" && network != "unix" {
+		return nil, errors.New("unsupported clamd network: " + network)
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+
+	return &clamd{
+		network: network,
+		addr:    addr,
+		timeout: timeout,
+	}, nil
+}
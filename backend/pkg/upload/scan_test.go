@@ -0,0 +1,112 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chaitin/koalaqa/pkg/config"
+)
+
+// fakeClamd 模拟 clamd INSTREAM，内容包含 EICAR 时报毒
+func fakeClamd(t *testing.T) string {
+	sock := filepath.Join(t.TempDir(), "clamd.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+				cmd, err := r.ReadString(0)
+				if err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var data bytes.Buffer
+				for {
+					var size uint32
+					err = binary.Read(r, binary.BigEndian, &size)
+					if err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+
+					_, err = io.CopyN(&data, r, int64(size))
+					if err != nil {
+						return
+					}
+				}
+
+				if strings.Contains(data.String(), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+
+	return "unix://" + sock
+}
+
+func TestClamdScan(t *testing.T) {
+	scanner, err := NewScanner(config.ClamAV{Addr: fakeClamd(t), Timeout: 5})
+	if err != nil {
+		t.Fatalf("new scanner failed: %v", err)
+	}
+
+	err = scanner.Scan(context.Background(), bytes.NewReader(bytes.Repeat([]byte("a"), clamdChunkSize*2+10)))
+	if err != nil {
+		t.Fatalf("clean file should pass: %v", err)
+	}
+
+	err = scanner.Scan(context.Background(), strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+	var infected *InfectedError
+	if !errors.As(err, &infected) || infected.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("expect infected error, got: %v", err)
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	if err := parseClamdReply("stream: OK"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := parseClamdReply("INSTREAM size limit exceeded. ERROR")
+	var infected *InfectedError
+	if err == nil || errors.As(err, &infected) {
+		t.Fatalf("expect scan error, got: %v", err)
+	}
+}
+
+func TestNopScanner(t *testing.T) {
+	scanner, err := NewScanner(config.ClamAV{})
+	if err != nil {
+		t.Fatalf("new scanner failed: %v", err)
+	}
+
+	if err := scanner.Scan(context.Background(), strings.NewReader("EICAR")); err != nil {
+		t.Fatalf("nop scanner should pass: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package upload
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var ErrTypeNotAllowed = errors.New("file type not allowed")
+
+type Scene uint
+
+const (
+	SceneDiscussion Scene = iota + 1
+	SceneKBDocument
+)
+
+var (
+	imageTypes = map[string][]string{
+		"image/png":  {".png"},
+		"image/jpeg": {".jpg", ".jpeg"},
+		"image/gif":  {".gif"},
+		"image/webp": {".webp"},
+		"image/bmp":  {".bmp"},
+	}
+	videoTypes = map[string][]string{
+		"video/mp4":  {".mp4"},
+		"video/webm": {".webm"},
+	}
+)
+
+// allowList 各场景允许的内容类型及对应扩展名，内容类型由文件内容判断
+var allowList = map[Scene]map[string][]string{
+	SceneDiscussion: merge(imageTypes, videoTypes, map[string][]string{
+		"application/pdf": {".pdf"},
+		"text/plain":      {".txt", ".log", ".md"},
+	}),
+	SceneKBDocument: merge(imageTypes, videoTypes, map[string][]string{
+		"application/pdf": {".pdf"},
+		"application/zip": {".docx", ".xlsx", ".pptx", ".zip"},
+		"text/plain":      {".txt", ".md", ".csv"},
+	}),
+}
+
+// publicList 各场景可以公开访问的内容类型，其余文件上传到私有目录，需要签名才能访问
+// 知识库场景的上传用于问答编辑器插入的图片、视频与 PDF，需要直接在前台展示
+var publicList = map[Scene]map[string][]string{
+	SceneDiscussion: allowList[SceneDiscussion],
+	SceneKBDocument: merge(imageTypes, videoTypes, map[string][]string{
+		"application/pdf": {".pdf"},
+	}),
+}
+
+// Public 文件是否可以上传到公开目录
+func Public(scene Scene, contentType string) bool {
+	_, ok := publicList[scene][contentType]
+	return ok
+}
+
+func merge(items ...map[string][]string) map[string][]string {
+	res := make(map[string][]string)
+	for _, item := range items {
+		for k, v := range item {
+			res[k] = v
+		}
+	}
+
+	return res
+}
+
+// Sniff 根据文件头判断内容类型并校验是否允许上传，返回内容类型与应使用的扩展名
+// 图片扩展名与内容不一致时修正扩展名，其余类型扩展名不一致时拒绝
+func Sniff(scene Scene, head []byte, ext string) (string, string, error) {
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "", "", ErrTypeNotAllowed
+	}
+
+	exts, ok := allowList[scene][contentType]
+	if !ok {
+		return "", "", ErrTypeNotAllowed
+	}
+
+	ext = strings.ToLower(ext)
+	if slices.Contains(exts, ext) {
+		return contentType, ext, nil
+	}
+
+	if IsImage(contentType) {
+		return contentType, exts[0], nil
+	}
+
+	return "", "", ErrTypeNotAllowed
+}
+
+func IsImage(contentType string) bool {
+	_, ok := imageTypes[contentType]
+	return ok
+}
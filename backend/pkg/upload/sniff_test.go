@@ -0,0 +1,125 @@
+package upload
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func pngData(t *testing.T) []byte {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 2, 2)))
+	if err != nil {
+		t.Fatalf("encode png failed: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	data := pngData(t)
+
+	contentType, ext, err := Sniff(SceneDiscussion, data, ".PNG")
+	if err != nil || contentType != "image/png" || ext != ".png" {
+		t.Fatalf("unexpected result: %s %s %v", contentType, ext, err)
+	}
+
+	_, ext, err = Sniff(SceneDiscussion, data, ".image")
+	if err != nil || ext != ".png" {
+		t.Fatalf("image ext should be corrected: %s %v", ext, err)
+	}
+
+	for _, c := range []struct {
+		scene Scene
+		data  []byte
+		ext   string
+	}{
+		{SceneDiscussion, []byte("<html><script>alert(1)</script></html>"), ".png"},
+		{SceneDiscussion, []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ".svg"},
+		{SceneDiscussion, []byte("PK\x03\x04"), ".docx"},
+		{SceneKBDocument, []byte("plain text"), ".pdf"},
+	} {
+		_, _, err = Sniff(c.scene, c.data, c.ext)
+		if err != ErrTypeNotAllowed {
+			t.Fatalf("%s should not be allowed, got: %v", c.data, err)
+		}
+	}
+
+	_, ext, err = Sniff(SceneKBDocument, []byte("PK\x03\x04"), ".docx")
+	if err != nil || ext != ".docx" {
+		t.Fatalf("docx should be allowed in kb: %s %v", ext, err)
+	}
+}
+
+// exifJPEG 构造带方向信息的 jpeg
+func exifJPEG(t *testing.T, orientation uint16) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.White)
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+	if err != nil {
+		t.Fatalf("encode jpeg failed: %v", err)
+	}
+
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orientation >> 8), byte(orientation), 0, 0, 0, 0, 0, 0, 0, 0}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := append([]byte{0xFF, 0xE1, byte((len(segment) + 2) >> 8), byte(len(segment) + 2)}, segment...)
+
+	raw := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, app1...), raw[2:]...)
+}
+
+func TestStripMetadata(t *testing.T) {
+	data := exifJPEG(t, 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("read orientation failed")
+	}
+
+	res, err := StripMetadata(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("strip failed: %v", err)
+	}
+
+	if bytes.Contains(res, []byte("Exif")) {
+		t.Fatal("exif not stripped")
+	}
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(res))
+	if err != nil {
+		t.Fatalf("decode result failed: %v", err)
+	}
+	if cfg.Width != 2 || cfg.Height != 4 {
+		t.Fatalf("image not rotated: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	gif := []byte("GIF89a")
+	res, err = StripMetadata(gif, "image/gif")
+	if err != nil || !bytes.Equal(res, gif) {
+		t.Fatalf("gif should be untouched: %v", err)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	for _, c := range []struct {
+		scene       Scene
+		contentType string
+		public      bool
+	}{
+		{SceneDiscussion, "image/png", true},
+		{SceneDiscussion, "application/pdf", true},
+		{SceneKBDocument, "image/jpeg", true},
+		{SceneKBDocument, "application/pdf", true},
+		{SceneKBDocument, "video/mp4", true},
+		{SceneKBDocument, "video/webm", true},
+		{SceneKBDocument, "application/zip", false},
+		{SceneKBDocument, "text/plain", false},
+	} {
+		if Public(c.scene, c.contentType) != c.public {
+			t.Fatalf("unexpected public result: %d %s", c.scene, c.contentType)
+		}
+	}
+}
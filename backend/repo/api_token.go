@@ -21,6 +21,14 @@ func (a *APIToken) GetByToken(ctx context.Context, token string) (*model.APIToke
 	return &res, nil
 }
 
+// ExistScope token 存在且包含 scope
+func (a *APIToken) ExistScope(ctx context.Context, token string, scope string) (bool, error) {
+	return a.Exist(ctx,
+		QueryWithEqual("token", token),
+		QueryWithEqual("? = ANY(scopes)", scope, EqualOPRaw),
+	)
+}
+
 func newAPIToken(db *database.DB) *APIToken {
 	return &APIToken{
 		base: base[*model.APIToken]{
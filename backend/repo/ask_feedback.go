@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm/clause"
+)
+
+type AskFeedback struct {
+	base[*model.AskFeedback]
+}
+
+// Upsert 重复评价时覆盖之前的结果并重新进入待审核状态
+func (a *AskFeedback) Upsert(ctx context.Context, data *model.AskFeedback) error {
+	return a.model(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ask_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"state":       data.State,
+			"reasons":     data.Reasons,
+			"comment":     data.Comment,
+			"status":      model.AskFeedbackStatusPending,
+			"reviewer_id": 0,
+			"updated_at":  time.Now(),
+		}),
+	}).Create(data).Error
+}
+
+func (a *AskFeedback) listQuery(ctx context.Context) *database.DB {
+	return a.model(ctx).
+		Joins("LEFT JOIN ask_sessions AS answers ON answers.id = ask_feedbacks.ask_id").
+		Joins("LEFT JOIN users ON users.id = ask_feedbacks.user_id")
+}
+
+func (a *AskFeedback) ListItem(ctx context.Context, res *[]model.AskFeedbackListItem, queryFuncs ...QueryOptFunc) error {
+	opt := getQueryOpt(queryFuncs...)
+
+	return a.listQuery(ctx).
+		Select(`ask_feedbacks.*, answers.content AS answer, COALESCE(users.name, '匿名游客') AS username,
+(SELECT questions.content FROM ask_sessions AS questions WHERE questions.uuid = ask_feedbacks.session_id AND NOT questions.bot AND questions.id < ask_feedbacks.ask_id ORDER BY questions.id DESC LIMIT 1) AS question`).
+		Scopes(opt.Scopes()...).
+		Find(res).Error
+}
+
+func (a *AskFeedback) CountItem(ctx context.Context, res *int64, queryFuncs ...QueryOptFunc) error {
+	opt := getQueryOpt(queryFuncs...)
+
+	return a.listQuery(ctx).Scopes(opt.Scopes()...).Count(res).Error
+}
+
+func newAskFeedback(db *database.DB) *AskFeedback {
+	return &AskFeedback{
+		base: base[*model.AskFeedback]{
+			db: db, m: &model.AskFeedback{},
+		},
+	}
+}
+
+func init() {
+	register(newAskFeedback)
+}
@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AskFunnel struct {
+	base[*model.AskFunnel]
+}
+
+const askFunnelStatColumns = `COUNT(*) AS sessions,
+COUNT(*) FILTER (WHERE answered_at IS NOT NULL) AS answered,
+COUNT(*) FILTER (WHERE unknown) AS unknown,
+COUNT(*) FILTER (WHERE feedback > 0) AS feedback,
+COUNT(*) FILTER (WHERE feedback = 1) AS "like",
+COUNT(*) FILTER (WHERE escalated_at IS NOT NULL) AS escalated,
+COUNT(*) FILTER (WHERE discussion_id > 0) AS discussions,
+COUNT(*) FILTER (WHERE human_answered_at IS NOT NULL) AS human_answered,
+COUNT(*) FILTER (WHERE accepted_at IS NOT NULL) AS accepted,
+COUNT(*) FILTER (WHERE answered_at IS NOT NULL AND escalated_at IS NULL) AS deflected,
+COALESCE(COUNT(*) FILTER (WHERE answered_at IS NOT NULL AND escalated_at IS NULL)::float / NULLIF(COUNT(*), 0), 0) AS deflection_rate,
+COALESCE(AVG(EXTRACT(EPOCH FROM answered_at - created_at)) FILTER (WHERE answered_at IS NOT NULL AND escalated_at IS NULL), 0)::bigint AS deflected_time,
+COALESCE(AVG(EXTRACT(EPOCH FROM accepted_at - created_at)) FILTER (WHERE accepted_at IS NOT NULL), 0)::bigint AS accepted_time`
+
+// Question 记录会话提问，首次提问时创建漏斗记录
+func (a *AskFunnel) Question(ctx context.Context, uuid string, uid uint, source model.AskSessionSource) error {
+	return a.model(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uuid"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"questions":  gorm.Expr("ask_funnels.questions + 1"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&model.AskFunnel{
+		UUID:      uuid,
+		UserID:    uid,
+		Source:    source,
+		Questions: 1,
+	}).Error
+}
+
+// Answer 记录 AI 回答结果，answered 为 false 表示 AI 无法回答
+func (a *AskFunnel) Answer(ctx context.Context, uuid string, answered bool, groupIDs model.Int64Array) error {
+	updateM := map[string]any{
+		"updated_at": time.Now(),
+	}
+	if answered {
+		updateM["answered_at"] = gorm.Expr("COALESCE(answered_at, ?)", time.Now())
+	} else {
+		updateM["unknown"] = true
+	}
+	if len(groupIDs) > 0 {
+		updateM["group_ids"] = gorm.Expr("array_distinct(COALESCE(group_ids, '{}') || ?::bigint[])", groupIDs)
+	}
+
+	return a.Update(ctx, updateM, QueryWithEqual("uuid", uuid))
+}
+
+// Escalate 记录会话转人工，只保留第一次转人工的时间和原因
+func (a *AskFunnel) Escalate(ctx context.Context, uuid string, reason model.AskEscalateReason, forumID uint) error {
+	updateM := map[string]any{
+		"escalated_at": gorm.Expr("COALESCE(escalated_at, ?)", time.Now()),
+		"updated_at":   time.Now(),
+	}
+	switch reason {
+	case model.AskEscalateReasonNeedHuman:
+		updateM["need_human"] = true
+		updateM["reason"] = gorm.Expr("CASE WHEN escalated_at IS NULL THEN ? ELSE reason END", reason)
+	default:
+		// 主动转人工时根据会话状态推断原因
+		updateM["reason"] = gorm.Expr(`CASE WHEN escalated_at IS NOT NULL THEN reason
+WHEN feedback = ? THEN ?
+WHEN unknown AND answered_at IS NULL THEN ?
+ELSE ? END`, model.AskFunnelFeedbackDislike, model.AskEscalateReasonDislike, model.AskEscalateReasonUnknown, reason)
+	}
+	if forumID > 0 {
+		updateM["forum_id"] = forumID
+	}
+
+	return a.Update(ctx, updateM, QueryWithEqual("uuid", uuid))
+}
+
+func (a *AskFunnel) Feedback(ctx context.Context, uuid string, feedback model.AskFunnelFeedback) error {
+	return a.Update(ctx, map[string]any{
+		"feedback":    feedback,
+		"feedback_at": time.Now(),
+		"updated_at":  time.Now(),
+	}, QueryWithEqual("uuid", uuid))
+}
+
+// LinkDiscussion 关联会话转人工后创建的帖子
+func (a *AskFunnel) LinkDiscussion(ctx context.Context, uuid string, uid uint, disc *model.Discussion) error {
+	return a.Update(ctx, map[string]any{
+		"discussion_id": disc.ID,
+		"forum_id":      disc.ForumID,
+		"group_ids":     gorm.Expr("array_distinct(COALESCE(group_ids, '{}') || ?::bigint[])", disc.GroupIDs),
+		"escalated_at":  gorm.Expr("COALESCE(escalated_at, ?)", time.Now()),
+		"reason":        gorm.Expr("CASE WHEN escalated_at IS NULL THEN ? ELSE reason END", model.AskEscalateReasonManual),
+		"updated_at":    time.Now(),
+	}, QueryWithEqual("uuid", uuid), QueryWithEqual("user_id", uid), QueryWithEqual("discussion_id", 0))
+}
+
+func (a *AskFunnel) HumanAnswered(ctx context.Context, discID uint, answeredAt time.Time) error {
+	return a.model(ctx).
+		Where("discussion_id = ? AND human_answered_at IS NULL", discID).
+		Updates(map[string]any{
+			"human_answered_at": answeredAt,
+			"updated_at":        time.Now(),
+		}).Error
+}
+
+// Accepted acceptedAt 为 nil 表示取消采纳
+func (a *AskFunnel) Accepted(ctx context.Context, discID uint, acceptedAt *time.Time) error {
+	return a.Update(ctx, map[string]any{
+		"accepted_at": acceptedAt,
+		"updated_at":  time.Now(),
+	}, QueryWithEqual("discussion_id", discID))
+}
+
+func (a *AskFunnel) Stat(ctx context.Context, res *model.AskFunnelStat, queryFuncs ...QueryOptFunc) error {
+	opt := getQueryOpt(queryFuncs...)
+
+	return a.model(ctx).Select(askFunnelStatColumns).Scopes(opt.Scopes()...).Scan(res).Error
+}
+
+// Breakdown column 为分组字段，如 forum_id、source、unnest(group_ids)
+func (a *AskFunnel) Breakdown(ctx context.Context, column string, queryFuncs ...QueryOptFunc) ([]model.AskFunnelBreakdown, error) {
+	opt := getQueryOpt(queryFuncs...)
+
+	var res []model.AskFunnelBreakdown
+	err := a.db.WithContext(ctx).Table("(?) AS funnel", a.model(ctx).
+		Select("ask_funnels.*, "+column+" AS dimension_id").
+		Scopes(opt.Scopes()...)).
+		Select("dimension_id AS id, " + askFunnelStatColumns).
+		Group("dimension_id").
+		Order("sessions DESC").
+		Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Trend unit 为 postgres date_trunc 的精度，如 hour、day、week、month
+func (a *AskFunnel) Trend(ctx context.Context, unit string, queryFuncs ...QueryOptFunc) ([]model.AskFunnelTrend, error) {
+	opt := getQueryOpt(queryFuncs...)
+
+	var res []model.AskFunnelTrend
+	err := a.model(ctx).
+		Select("EXTRACT(EPOCH FROM date_trunc(?, created_at))::bigint AS ts, "+askFunnelStatColumns, unit).
+		Scopes(opt.Scopes()...).
+		Group("ts").
+		Order("ts ASC").
+		Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (a *AskFunnel) Reasons(ctx context.Context, res *[]model.Count[model.AskEscalateReason], queryFuncs ...QueryOptFunc) error {
+	opt := getQueryOpt(queryFuncs...)
+
+	return a.model(ctx).Select("reason AS key, COUNT(*) AS count").
+		Where("escalated_at IS NOT NULL").
+		Scopes(opt.Scopes()...).
+		Group("reason").
+		Order("count DESC").
+		Find(res).Error
+}
+
+func newAskFunnel(db *database.DB) *AskFunnel {
+	return &AskFunnel{
+		base: base[*model.AskFunnel]{
+			db: db, m: &model.AskFunnel{},
+		},
+	}
+}
+
+func init() {
+	register(newAskFunnel)
+}
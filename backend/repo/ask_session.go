@@ -35,6 +35,27 @@ func (a *AskSession) Get(ctx context.Context, res any, queryFuncs ...QueryOptFun
 	return a.model(ctx).Scopes(opt.Scopes()...).Find(res).Error
 }
 
+// StatBySite 按挂件站点统计会话数、提问数和转人工数
+func (a *AskSession) StatBySite(ctx context.Context, queryFuncs ...QueryOptFunc) ([]model.StatWidgetSite, error) {
+	opt := getQueryOpt(queryFuncs...)
+
+	var res []model.StatWidgetSite
+	err := a.model(ctx).
+		Select(`site_id, COUNT(DISTINCT uuid) AS sessions,
+			COUNT(*) FILTER (WHERE NOT bot) AS questions,
+			COUNT(DISTINCT uuid) FILTER (WHERE need_human) AS need_human`).
+		Where("site_id > 0").
+		Scopes(opt.Scopes()...).
+		Group("site_id").
+		Order("questions DESC").
+		Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func newAskSession(db *database.DB) *AskSession {
 	return &AskSession{
 		base: base[*model.AskSession]{
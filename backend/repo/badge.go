@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Badge struct {
+	base[*model.Badge]
+}
+
+func (b *Badge) DeleteWithUser(ctx context.Context, id uint) error {
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("badge_id = ?", id).Delete(&model.UserBadge{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", id).Delete(&model.Badge{}).Error
+	})
+}
+
+// Award 授予用户徽章，返回是否为新获得
+func (b *Badge) Award(ctx context.Context, userID uint, badgeID uint) (bool, error) {
+	res := b.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.UserBadge{
+		UserID:  userID,
+		BadgeID: badgeID,
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (b *Badge) ListUser(ctx context.Context, res any, userID uint) error {
+	return b.model(ctx).
+		Select("badges.*, user_badges.created_at AS awarded_at").
+		Joins("JOIN user_badges ON user_badges.badge_id = badges.id").
+		Where("user_badges.user_id = ? AND NOT badges.disabled", userID).
+		Order("user_badges.created_at DESC, badges.id DESC").
+		Find(res).Error
+}
+
+func (b *Badge) UserBadgeIDs(ctx context.Context, userID uint) (res []uint, err error) {
+	err = b.db.WithContext(ctx).Model(&model.UserBadge{}).Where("user_id = ?", userID).Pluck("badge_id", &res).Error
+	return
+}
+
+func newBadge(db *database.DB) *Badge {
+	return &Badge{base: base[*model.Badge]{db: db, m: &model.Badge{}}}
+}
+
+func init() {
+	register(newBadge)
+}
@@ -72,6 +72,18 @@ func (c *Comment) ListFirstHuman(ctx context.Context, discIDs model.Int64Array)
 	return
 }
 
+// ListFirstByDiscussions 批量查询每个帖子最早的 limit 条评论
+func (c *Comment) ListFirstByDiscussions(ctx context.Context, res any, discIDs model.Int64Array, limit int) error {
+	sub := c.model(ctx).
+		Select("comments.*, ROW_NUMBER() OVER (PARTITION BY discussion_id ORDER BY id) AS rn").
+		Where("discussion_id = ANY(?)", discIDs)
+
+	return c.db.WithContext(ctx).Table("(?) AS c", sub).
+		Where("rn <= ?", limit).
+		Order("discussion_id, id").
+		Find(res).Error
+}
+
 func (c *Comment) CountByForumIDs(ctx context.Context, res *int64, forumIDs model.Int64Array, queryFuncs ...QueryOptFunc) error {
 	o := getQueryOpt(queryFuncs...)
 	return c.model(ctx).
@@ -80,11 +92,11 @@ func (c *Comment) CountByForumIDs(ctx context.Context, res *int64, forumIDs mode
 		Count(res).Error
 }
 
-func (c *Comment) CountDiscussion(ctx context.Context, res *int64, begin time.Time, queryFuncs ...QueryOptFunc) error {
+func (c *Comment) CountDiscussion(ctx context.Context, res *int64, begin time.Time, end time.Time, queryFuncs ...QueryOptFunc) error {
 	o := getQueryOpt(queryFuncs...)
 
 	return c.model(ctx).Scopes(o.Scopes()...).
-		Where("discussion_id IN (SELECT id FROM discussions where created_at >= ?)", begin).
+		Where("discussion_id IN (SELECT id FROM discussions where created_at >= ? AND created_at < ?)", begin, end).
 		Count(res).Error
 }
 
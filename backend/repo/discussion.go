@@ -61,6 +61,28 @@ func (d *Discussion) ListType(ctx context.Context, queryFuncs ...QueryOptFunc) (
 	return
 }
 
+// StatBreakdown 按维度聚合帖子，column 为维度字段表达式，数组字段需要 unnest
+func (d *Discussion) StatBreakdown(ctx context.Context, column string, queryFuncs ...QueryOptFunc) ([]model.StatBreakdown, error) {
+	opt := getQueryOpt(queryFuncs...)
+
+	var res []model.StatBreakdown
+	err := d.db.WithContext(ctx).Table("(?) AS disc", d.model(ctx).
+		Select(column+" AS dim_id, id, bot_unknown").
+		Scopes(opt.Scopes()...)).
+		Select(`dim_id AS id, COUNT(*) AS discussions,
+			COUNT(*) FILTER (WHERE bot_unknown) AS bot_unknown,
+			COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM comments WHERE comments.discussion_id = disc.id AND comments.parent_id = 0 AND comments.accepted)) AS accept,
+			COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM comments WHERE comments.discussion_id = disc.id AND comments.parent_id = 0 AND comments.accepted AND comments.bot)) AS bot_accept`).
+		Group("dim_id").
+		Order("discussions DESC").
+		Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (d *Discussion) Detail(ctx context.Context, uid uint, id uint) (*model.DiscussionDetail, error) {
 	var res model.DiscussionDetail
 	res.CurrentUserID = uid
@@ -79,6 +101,13 @@ func (d *Discussion) Detail(ctx context.Context, uid uint, id uint) (*model.Disc
 		}
 	}
 
+	if res.MergedID > 0 {
+		err = d.Get(ctx, &res.Merged, QueryWithEqual("discussions.id", res.MergedID))
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
 	if len(res.GroupIDs) > 0 {
 		err = d.db.WithContext(ctx).
 			Model(&model.GroupItem{}).
@@ -240,7 +269,7 @@ func (d *Discussion) RevokeLikeDiscussion(ctx context.Context, discUUID string,
 	})
 }
 
-func (d *Discussion) ResolveIssue(ctx context.Context, discUUID string, state model.DiscussionState) error {
+func (d *Discussion) ResolveIssue(ctx context.Context, discUUID string, userID uint, state model.DiscussionState) error {
 	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var disc model.Discussion
 		err := tx.Model(d.m).Where("uuid = ?", discUUID).Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&disc).Error
@@ -258,14 +287,110 @@ func (d *Discussion) ResolveIssue(ctx context.Context, discUUID string, state mo
 		}
 
 		now := time.Now()
-		return tx.Model(d.m).Where("uuid = ?", disc.UUID).Updates(map[string]any{
+		err = tx.Model(d.m).Where("uuid = ?", disc.UUID).Updates(map[string]any{
 			"resolved":    state,
 			"resolved_at": now,
 			"updated_at":  now,
 		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.IssueHistory{
+			DiscussionID: disc.ID,
+			UserID:       userID,
+			Field:        model.IssueHistoryFieldState,
+			From:         model.IssueCategoryName(disc.Resolved),
+			To:           model.IssueCategoryName(state),
+		}).Error
 	})
 }
 
+// TransitIssueState 将 Issue 从 from 状态流转到 to 状态，并记录变更
+func (d *Discussion) TransitIssueState(ctx context.Context, discID uint, userID uint, from *model.IssueState, to model.IssueState) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var disc model.Discussion
+		err := tx.Model(d.m).Where("id = ?", discID).Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&disc).Error
+		if err != nil {
+			return err
+		}
+
+		if disc.Type != model.DiscussionTypeIssue {
+			return errors.New("invalid discussion")
+		}
+
+		fromName := model.IssueCategoryName(disc.Resolved)
+		if from != nil {
+			if disc.IssueStateID != from.ID {
+				return errors.New("issue state changed")
+			}
+
+			fromName = from.Name
+		}
+
+		now := time.Now()
+		updateM := map[string]any{
+			"issue_state_id": to.ID,
+			"updated_at":     now,
+		}
+		if disc.Resolved != to.Category {
+			updateM["resolved"] = to.Category
+			updateM["resolved_at"] = now
+		}
+
+		err = tx.Model(d.m).Where("id = ?", disc.ID).Updates(updateM).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.IssueHistory{
+			DiscussionID: disc.ID,
+			UserID:       userID,
+			Field:        model.IssueHistoryFieldState,
+			From:         fromName,
+			To:           to.Name,
+		}).Error
+	})
+}
+
+// UpdateIssue 更新 Issue 的处理人、优先级等字段，并记录变更
+func (d *Discussion) UpdateIssue(ctx context.Context, discID uint, updateM map[string]any, histories []model.IssueHistory) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updateM["updated_at"] = time.Now()
+		err := tx.Model(d.m).Where("id = ?", discID).Updates(updateM).Error
+		if err != nil {
+			return err
+		}
+
+		if len(histories) == 0 {
+			return nil
+		}
+
+		return tx.CreateInBatches(&histories, 100).Error
+	})
+}
+
+// IssueWatcherIDs Issue 的处理人以及关联到该 Issue 的帖子作者
+func (d *Discussion) IssueWatcherIDs(ctx context.Context, issueID uint) ([]uint, error) {
+	var issue model.Discussion
+	err := d.model(ctx).Select("id, assignees").Where("id = ?", issueID).First(&issue).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []uint
+	err = d.model(ctx).Where("associate_id = ?", issueID).Distinct().Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range issue.Assignees {
+		userIDs = append(userIDs, uint(id))
+	}
+
+	return userIDs, nil
+}
+
 func (d *Discussion) UpdateTagsByRagID(ctx context.Context, ragID string, tags []string) error {
 	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		addTags := make(map[string]struct{})
@@ -383,3 +508,214 @@ func (d *Discussion) DeleteByID(ctx context.Context, id uint) error {
 		return tx.Model(&model.DiscussionFollow{}).Where("discussion_id = ?", id).Delete(nil).Error
 	})
 }
+
+// ListTitleSimilar 使用 pg_trgm 按标题相似度查询同一论坛中未被合并的帖子
+func (d *Discussion) ListTitleSimilar(ctx context.Context, res any, forumID uint, title string, typ model.DiscussionType, limit int) error {
+	return d.model(ctx).
+		Joins("left join users on users.id = discussions.user_id").
+		Select("discussions.*, users.name as user_name, users.avatar as user_avatar, similarity(discussions.title, ?) AS similarity", title).
+		Where("discussions.forum_id = ? AND discussions.type = ? AND discussions.merged_id = 0", forumID, typ).
+		Where("discussions.title % ?", title).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "similarity DESC"}}).
+		Limit(limit).
+		Find(res).Error
+}
+
+var errMergeDiscussion = errors.New("discussion can not merge")
+
+// Merge 将 from 的评论、关注、点赞与标签移动到 to，from 关闭并记录合并目标
+func (d *Discussion) Merge(ctx context.Context, fromID uint, toID uint) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var discs []model.Discussion
+		err := tx.Model(d.m).Where("id IN ?", []uint{fromID, toID}).
+			Order("id ASC").
+			Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+			Find(&discs).Error
+		if err != nil {
+			return err
+		}
+
+		if len(discs) != 2 {
+			return database.ErrRecordNotFound
+		}
+
+		from, to := discs[0], discs[1]
+		if from.ID != fromID {
+			from, to = to, from
+		}
+
+		if from.MergedID > 0 || to.MergedID > 0 {
+			return errMergeDiscussion
+		}
+
+		var accepted int64
+		err = tx.Model(&model.Comment{}).Where("discussion_id = ? AND accepted", to.ID).Count(&accepted).Error
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]any{
+			"discussion_id": to.ID,
+			"updated_at":    gorm.Expr("updated_at"),
+		}
+		// 目标帖子已有采纳的回答时，取消被合并帖子中的采纳
+		if accepted > 0 {
+			updates["accepted"] = false
+		}
+		err = tx.Model(&model.Comment{}).Where("discussion_id = ?", from.ID).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`INSERT INTO discussion_follows (created_at, updated_at, discussion_id, user_id)
+			SELECT created_at, updated_at, ?, user_id FROM discussion_follows WHERE discussion_id = ?
+			ON CONFLICT DO NOTHING`, to.ID, from.ID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.DiscussionFollow{}).Where("discussion_id = ?", from.ID).Delete(nil).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`INSERT INTO disc_likes (created_at, updated_at, uuid, user_id)
+			SELECT created_at, updated_at, ?, user_id FROM disc_likes WHERE uuid = ?
+			ON CONFLICT DO NOTHING`, to.UUID, from.UUID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.DiscLike{}).Where("uuid = ?", from.UUID).Delete(nil).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		toUpdates := map[string]any{
+			"like":       gorm.Expr("(SELECT COUNT(*) FROM disc_likes WHERE uuid = ?)", to.UUID),
+			"comment":    gorm.Expr("(SELECT COUNT(*) FROM comments WHERE discussion_id = ?)", to.ID),
+			"tag_ids":    gorm.Expr("array_distinct(COALESCE(tag_ids, '{}') || ?::bigint[])", from.TagIDs),
+			"updated_at": gorm.Expr("updated_at"),
+		}
+		// 目标帖子未解决时，合并过来的采纳回答同样将其标记为已解决
+		if accepted == 0 && to.Resolved == model.DiscussionStateNone {
+			var moved int64
+			err = tx.Model(&model.Comment{}).Where("discussion_id = ? AND accepted", to.ID).Count(&moved).Error
+			if err != nil {
+				return err
+			}
+
+			if moved > 0 {
+				toUpdates["resolved"] = model.DiscussionStateResolved
+				toUpdates["resolved_at"] = now
+			}
+		}
+
+		err = tx.Model(d.m).Where("id = ?", to.ID).Updates(toUpdates).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(d.m).Where("id = ?", from.ID).Updates(map[string]any{
+			"merged_id":   to.ID,
+			"resolved":    model.DiscussionStateClosed,
+			"resolved_at": now,
+			"like":        0,
+			"comment":     0,
+			"tag_ids":     model.Int64Array{},
+			"updated_at":  now,
+		}).Error
+	})
+}
+
+var ErrInsufficientPoint = errors.New("insufficient point")
+
+// CreateWithBounty 创建帖子，设置了悬赏时在同一事务中冻结发帖人的积分
+func (d *Discussion) CreateWithBounty(ctx context.Context, disc *model.Discussion) error {
+	if disc.Bounty == 0 {
+		return d.Create(ctx, disc)
+	}
+
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.User{}).
+			Where("id = ? AND point >= ?", disc.UserID, disc.Bounty).
+			UpdateColumn("point", gorm.Expr("point-?", disc.Bounty))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInsufficientPoint
+		}
+
+		disc.BountyState = model.BountyStateOpen
+		err := tx.Create(disc).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.UserPointRecord{
+			UserPointRecordInfo: model.UserPointRecordInfo{
+				UserID:    disc.UserID,
+				Type:      model.UserPointTypeBountyEscrow,
+				ForeignID: disc.ID,
+				FromID:    disc.UserID,
+			},
+			Point: -int(disc.Bounty),
+		}).Error
+	})
+}
+
+// SettleBounty 结算帖子的悬赏，toUserID 为发帖人时退回悬赏，否则发放给 toUserID
+// 返回结算的积分，悬赏已结算时返回 0
+func (d *Discussion) SettleBounty(ctx context.Context, discID uint, toUserID uint) (uint, error) {
+	var bounty uint
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var disc model.Discussion
+		err := tx.Model(d.m).Where("id = ?", discID).
+			Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+			First(&disc).Error
+		if err != nil {
+			return err
+		}
+
+		if disc.BountyState != model.BountyStateOpen || disc.Bounty == 0 {
+			return nil
+		}
+
+		typ, state := model.UserPointTypeBountyAward, model.BountyStateAwarded
+		if toUserID == disc.UserID {
+			typ, state = model.UserPointTypeBountyRefund, model.BountyStateRefunded
+		}
+
+		err = tx.Model(d.m).Where("id = ?", discID).Updates(map[string]any{
+			"bounty_state": state,
+			"updated_at":   gorm.Expr("updated_at"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&model.UserPointRecord{
+			UserPointRecordInfo: model.UserPointRecordInfo{
+				UserID:    toUserID,
+				Type:      typ,
+				ForeignID: disc.ID,
+				FromID:    disc.UserID,
+			},
+			Point: int(disc.Bounty),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		bounty = disc.Bounty
+		return tx.Model(&model.User{}).Where("id = ?", toUserID).
+			UpdateColumn("point", gorm.Expr("point+?", disc.Bounty)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return bounty, nil
+}
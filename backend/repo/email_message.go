@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm/clause"
+)
+
+type EmailMessage struct {
+	base[*model.EmailMessage]
+}
+
+func (e *EmailMessage) Get(ctx context.Context, res *model.EmailMessage, queryFuncs ...QueryOptFunc) error {
+	o := getQueryOpt(queryFuncs...)
+	return e.model(ctx).Scopes(o.Scopes()...).First(res).Error
+}
+
+// Claim 记录邮件关联，返回 false 表示该帖子或评论已经关联过邮件
+func (e *EmailMessage) Claim(ctx context.Context, msg *model.EmailMessage) (bool, error) {
+	res := e.model(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(msg)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func newEmailMessage(db *database.DB) *EmailMessage {
+	return &EmailMessage{base: base[*model.EmailMessage]{db: db, m: &model.EmailMessage{}}}
+}
+
+func init() {
+	register(newEmailMessage)
+}
@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm/clause"
+)
+
+type ImageDescription struct {
+	base[*model.ImageDescription]
+}
+
+func (i *ImageDescription) Upsert(ctx context.Context, item *model.ImageDescription) error {
+	return i.model(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"model", "content", "updated_at"}),
+	}).Create(item).Error
+}
+
+func newImageDescription(db *database.DB) *ImageDescription {
+	return &ImageDescription{
+		base: base[*model.ImageDescription]{
+			db: db, m: &model.ImageDescription{},
+		},
+	}
+}
+
+func init() {
+	register(newImageDescription)
+}
@@ -0,0 +1,29 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+)
+
+type IssueHistory struct {
+	base[*model.IssueHistory]
+}
+
+func (i *IssueHistory) ListItem(ctx context.Context, res any, discID uint) error {
+	return i.model(ctx).
+		Select("issue_histories.*, users.name AS user_name, users.avatar AS user_avatar").
+		Joins("LEFT JOIN users ON users.id = issue_histories.user_id").
+		Where("issue_histories.discussion_id = ?", discID).
+		Order("issue_histories.created_at ASC, issue_histories.id ASC").
+		Find(res).Error
+}
+
+func newIssueHistory(db *database.DB) *IssueHistory {
+	return &IssueHistory{base: base[*model.IssueHistory]{db: db, m: &model.IssueHistory{}}}
+}
+
+func init() {
+	register(newIssueHistory)
+}
@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm"
+)
+
+type IssueState struct {
+	base[*model.IssueState]
+}
+
+// Save 保存状态，设置为初始状态时取消同一论坛其他状态的初始标记
+func (i *IssueState) Save(ctx context.Context, state *model.IssueState) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if state.Initial {
+			err := tx.Model(i.m).Where("forum_id = ? AND id != ?", state.ForumID, state.ID).Update("initial", false).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Save(state).Error
+	})
+}
+
+// Initial 论坛新建 Issue 的初始状态，没有标记初始状态时使用排序第一的状态，未配置状态时返回 nil
+func (i *IssueState) Initial(ctx context.Context, forumID uint) (*model.IssueState, error) {
+	var states []model.IssueState
+	err := i.model(ctx).Where("forum_id = ?", forumID).Order("initial DESC, index ASC, id ASC").Limit(1).Find(&states).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(states) == 0 {
+		return nil, nil
+	}
+
+	return &states[0], nil
+}
+
+func newIssueState(db *database.DB) *IssueState {
+	return &IssueState{base: base[*model.IssueState]{db: db, m: &model.IssueState{}}}
+}
+
+func init() {
+	register(newIssueState)
+}
@@ -28,6 +28,15 @@ func (l *LLM) GetChatModel(ctx context.Context) (*model.LLM, error) {
 	return &res, nil
 }
 
+// GetActiveByType 获取指定类型且已启用的模型
+func (l *LLM) GetActiveByType(ctx context.Context, typ model.LLMType) (*model.LLM, error) {
+	var res model.LLM
+	if err := l.base.model(ctx).Where("type = ? AND is_active", typ).First(&res).Error; err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
 func (l *LLM) UpdateByRagID(ctx context.Context, ragID string, data map[string]any) error {
 	return l.base.model(ctx).Where("rag_id = ?", ragID).Updates(data).Error
 }
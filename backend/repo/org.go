@@ -84,6 +84,34 @@ func (o *Org) Delete(ctx context.Context, orgID uint) error {
 	return nil
 }
 
+// AddUsers 将用户加入组织，游客不会被加入
+func (o *Org) AddUsers(ctx context.Context, orgID uint, userIDs model.Int64Array) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	return o.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ANY(?) AND role != ?", userIDs, model.UserRoleGuest).
+		Where("NOT (? = ANY(COALESCE(org_ids, '{}')))", orgID).
+		Updates(map[string]any{
+			"org_ids":    gorm.Expr("ARRAY_APPEND(COALESCE(org_ids, '{}'), ?)", orgID),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+func (o *Org) RemoveUsers(ctx context.Context, orgID uint, userIDs model.Int64Array) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	return o.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ANY(?) AND ? = ANY(org_ids)", userIDs, orgID).
+		Updates(map[string]any{
+			"org_ids":    gorm.Expr("ARRAY_REMOVE(org_ids, ?)", orgID),
+			"updated_at": time.Now(),
+		}).Error
+}
+
 func newOrg(db *database.DB) *Org {
 	return &Org{
 		base: base[*model.Org]{
@@ -2,6 +2,8 @@ package repo
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/chaitin/koalaqa/model"
@@ -23,8 +25,20 @@ func (s *Stat) Sum(ctx context.Context, res *int64, queryFuns ...QueryOptFunc) e
 	return s.model(ctx).Select("COALESCE(SUM(count), 0)").Scopes(opt.Scopes()...).Scan(res).Error
 }
 
-func (s *Stat) BotUnknown(ctx context.Context, res *int64, t time.Time) error {
-	return s.model(ctx).Where("type = ? AND ts >= ?", model.StatTypeBotUnknown, t.Unix()).Where("key IN (SELECT uuid FROM discussions WHERE created_at >= ?)", t).Count(res).Error
+// BotUnknown end 为零值时不限制结束时间
+func (s *Stat) BotUnknown(ctx context.Context, res *int64, begin time.Time, end time.Time, queryFuns ...QueryOptFunc) error {
+	opt := getQueryOpt(queryFuns...)
+	if end.IsZero() {
+		return s.model(ctx).Where("type = ? AND ts >= ?", model.StatTypeBotUnknown, begin.Unix()).
+			Where("key IN (SELECT uuid FROM discussions WHERE created_at >= ?)", begin).
+			Scopes(opt.Scopes()...).
+			Count(res).Error
+	}
+
+	return s.model(ctx).Where("type = ? AND ts >= ? AND ts < ?", model.StatTypeBotUnknown, begin.Unix(), end.Unix()).
+		Where("key IN (SELECT uuid FROM discussions WHERE created_at >= ? AND created_at < ?)", begin, end).
+		Scopes(opt.Scopes()...).
+		Count(res).Error
 }
 
 func (s *Stat) Upsert(ctx context.Context, stats ...model.Stat) error {
@@ -61,6 +75,24 @@ func (s *Stat) TrendDay(ctx context.Context, queryFuns ...QueryOptFunc) ([]model
 	return res, nil
 }
 
+// TrendDayItems 按天和类型聚合，周、月粒度由调用方按时区合并
+func (s *Stat) TrendDayItems(ctx context.Context, queryFuns ...QueryOptFunc) ([]model.StatTrendDayItem, error) {
+	opt := getQueryOpt(queryFuns...)
+
+	var res []model.StatTrendDayItem
+	err := s.model(ctx).
+		Select("type, day_ts AS ts, COUNT(*) AS count").
+		Scopes(opt.Scopes()...).
+		Group("type, day_ts").
+		Order("day_ts ASC").
+		Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (s *Stat) Trend(ctx context.Context, queryFuns ...QueryOptFunc) ([]model.StatTrend, error) {
 	opt := getQueryOpt(queryFuns...)
 
@@ -102,6 +134,109 @@ func (s *Stat) InvalidKnowledge(ctx context.Context, queryFuncs ...QueryOptFunc)
 	return res, nil
 }
 
+// AskInvalidKnowledge 统计智能问答中（assocaite_id 为 0）知识文档的命中次数和 [begin, end) 内问答反馈的点踩次数
+func (s *Stat) AskInvalidKnowledge(ctx context.Context, begin time.Time, end time.Time, queryFuncs ...QueryOptFunc) ([]model.StatInvalidKnowledge, error) {
+	var res []model.StatInvalidKnowledge
+
+	askDislike := s.db.WithContext(ctx).Model(&model.AskFeedback{}).
+		Select("unnest(doc_ids) AS doc_id, COUNT(*) AS dislike_count").
+		Where("state = ? AND created_at >= ? AND created_at < ?", model.AskFunnelFeedbackDislike, begin, end).
+		Group("doc_id")
+
+	o := getQueryOpt(queryFuncs...)
+	err := s.model(ctx).Scopes(o.Scopes()...).
+		Joins("JOIN kb_documents ON stats.key::bigint = kb_documents.id").
+		Joins("LEFT JOIN (?) AS ask_dislikes ON ask_dislikes.doc_id = stats.key::bigint", askDislike).
+		Where("stats.type = ? AND stats.assocaite_id = 0", model.StatTypeKnowledgeHit).
+		Where("stats.created_at >= ? AND stats.created_at < ?", begin, end).
+		Group("stats.key").
+		Select("stats.key, MAX(kb_documents.title) AS title, MAX(kb_documents.doc_type) AS type, MAX(kb_documents.updated_at) as updated_at, COALESCE(MAX(ask_dislikes.dislike_count), 0) AS dislike_count, COUNT(DISTINCT stats.id) AS hit_count").
+		Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DiscDimension 按帖子所属板块、分类、标签筛选，分类和标签命中任意一个即可
+type DiscDimension struct {
+	ForumID  uint
+	GroupIDs model.Int64Array
+	TagIDs   model.Int64Array
+}
+
+func (d DiscDimension) Empty() bool {
+	return d.ForumID == 0 && len(d.GroupIDs) == 0 && len(d.TagIDs) == 0
+}
+
+func (d DiscDimension) expr() clause.Expr {
+	var (
+		conds []string
+		args  []any
+	)
+
+	if d.ForumID > 0 {
+		conds = append(conds, "forum_id = ?")
+		args = append(args, d.ForumID)
+	}
+
+	if len(d.GroupIDs) > 0 {
+		conds = append(conds, "group_ids && ?")
+		args = append(args, d.GroupIDs)
+	}
+
+	if len(d.TagIDs) > 0 {
+		conds = append(conds, "tag_ids && ?")
+		args = append(args, d.TagIDs)
+	}
+
+	return gorm.Expr("("+strings.Join(conds, " AND ")+")", args...)
+}
+
+// Query 直接作用于 discussions 表的查询条件
+func (d DiscDimension) Query() QueryOptFunc {
+	return func(qo *queryOpt) {
+		if d.Empty() {
+			return
+		}
+
+		QueryWithEqual("?", d.expr(), EqualOPRaw)(qo)
+	}
+}
+
+// siteStatTypes 没有帖子维度的统计类型
+var siteStatTypes = []model.StatType{model.StatTypeVisit, model.StatTypeSearch}
+
+// QueryWithStatDimension 帖子相关的统计按维度筛选，访问和搜索没有帖子维度，不受筛选影响
+func QueryWithStatDimension(dim DiscDimension) QueryOptFunc {
+	return func(qo *queryOpt) {
+		if dim.Empty() {
+			return
+		}
+
+		expr := dim.expr()
+		QueryWithEqual("?", gorm.Expr(`(type IN (?) OR
+			(type = ? AND assocaite_id IN (SELECT id FROM discussions WHERE ?)) OR
+			(type NOT IN (?) AND key IN (SELECT uuid FROM discussions WHERE ?)))`,
+			siteStatTypes,
+			model.StatTypeKnowledgeHit, expr,
+			append(slices.Clone(siteStatTypes), model.StatTypeKnowledgeHit), expr,
+		), EqualOPRaw)(qo)
+	}
+}
+
+// QueryWithDiscDimension column 为关联帖子的字段，discColumn 为 discussions 表中对应的字段
+func QueryWithDiscDimension(column string, discColumn string, dim DiscDimension) QueryOptFunc {
+	return func(qo *queryOpt) {
+		if dim.Empty() {
+			return
+		}
+
+		QueryWithEqual(column+" IN (SELECT "+discColumn+" FROM discussions WHERE ?)", dim.expr(), EqualOPRaw)(qo)
+	}
+}
+
 func init() {
 	register(newStat)
 }
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Tracker struct {
+	base[*model.Tracker]
+}
+
+func (t *Tracker) Upsert(ctx context.Context, tracker *model.Tracker) error {
+	return t.model(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "forum_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"enabled", "auto_create", "type", "url", "project", "username", "token", "secret", "issue_type", "updated_at",
+		}),
+	}).Create(tracker).Error
+}
+
+// DeleteWithIssue 删除对接配置以及已同步的 Issue、评论记录
+func (t *Tracker) DeleteWithIssue(ctx context.Context, id uint) error {
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("tracker_issue_id IN (?)", tx.Model(&model.TrackerIssue{}).Select("id").Where("tracker_id = ?", id)).
+			Delete(&model.TrackerComment{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("tracker_id = ?", id).Delete(&model.TrackerIssue{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", id).Delete(&model.Tracker{}).Error
+	})
+}
+
+func (t *Tracker) Get(ctx context.Context, res any, queryFuncs ...QueryOptFunc) error {
+	o := getQueryOpt(queryFuncs...)
+	return t.model(ctx).Scopes(o.Scopes()...).First(res).Error
+}
+
+func newTracker(db *database.DB) *Tracker {
+	return &Tracker{base: base[*model.Tracker]{db: db, m: &model.Tracker{}}}
+}
+
+func init() {
+	register(newTracker)
+}
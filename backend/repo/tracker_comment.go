@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm/clause"
+)
+
+type TrackerComment struct {
+	base[*model.TrackerComment]
+}
+
+// Claim 记录已同步的评论，返回 false 表示该外部评论已经同步过
+func (t *TrackerComment) Claim(ctx context.Context, comment *model.TrackerComment) (bool, error) {
+	res := t.model(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(comment)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+// ClaimPending 将同步中的评论关联到外部评论，返回 false 表示没有对应的同步中评论
+func (t *TrackerComment) ClaimPending(ctx context.Context, issueID uint, pendingID string, externalID string) (bool, error) {
+	res := t.model(ctx).
+		Where("tracker_issue_id = ? AND external_id = ?", issueID, pendingID).
+		Update("external_id", externalID)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func newTrackerComment(db *database.DB) *TrackerComment {
+	return &TrackerComment{base: base[*model.TrackerComment]{db: db, m: &model.TrackerComment{}}}
+}
+
+func init() {
+	register(newTrackerComment)
+}
@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+)
+
+type TrackerIssue struct {
+	base[*model.TrackerIssue]
+}
+
+func (t *TrackerIssue) Get(ctx context.Context, res any, queryFuncs ...QueryOptFunc) error {
+	o := getQueryOpt(queryFuncs...)
+	return t.model(ctx).Scopes(o.Scopes()...).First(res).Error
+}
+
+func newTrackerIssue(db *database.DB) *TrackerIssue {
+	return &TrackerIssue{base: base[*model.TrackerIssue]{db: db, m: &model.TrackerIssue{}}}
+}
+
+func init() {
+	register(newTrackerIssue)
+}
@@ -0,0 +1,45 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/util"
+)
+
+type Upload struct {
+	base[*model.Upload]
+}
+
+func (u *Upload) Usage(ctx context.Context, res *model.UploadUsage, uid uint) error {
+	return u.model(ctx).
+		Select("COALESCE(SUM(size), 0) AS size, COUNT(*) AS count, COUNT(*) FILTER (WHERE created_at >= ?) AS today", util.DayTrunc(time.Now())).
+		Where("user_id = ?", uid).
+		Scan(res).Error
+}
+
+// ListOrphan 查询创建时间早于 before 且未被帖子、评论、文档引用的上传文件
+func (u *Upload) ListOrphan(ctx context.Context, res *[]model.Upload, before time.Time, afterID uint, limit int) error {
+	return u.model(ctx).
+		Where("created_at < ? AND id > ?", before, afterID).
+		Where("NOT EXISTS (SELECT 1 FROM discussions WHERE position(uploads.path in discussions.content) > 0)").
+		Where("NOT EXISTS (SELECT 1 FROM comments WHERE position(uploads.path in comments.content) > 0)").
+		Where("NOT EXISTS (SELECT 1 FROM kb_documents WHERE position(convert_to(uploads.path, 'UTF8') in kb_documents.markdown) > 0)").
+		Order("id ASC").
+		Limit(limit).
+		Find(res).Error
+}
+
+func newUpload(db *database.DB) *Upload {
+	return &Upload{
+		base: base[*model.Upload]{
+			db: db, m: &model.Upload{},
+		},
+	}
+}
+
+func init() {
+	register(newUpload)
+}
@@ -73,6 +73,11 @@ func (u *User) UserGroupIDs(ctx context.Context, uid uint) (model.Int64Array, er
 		}
 	}
 
+	return u.ForumGroupIDs(ctx, forumIDs)
+}
+
+// ForumGroupIDs 板块关联的分类组
+func (u *User) ForumGroupIDs(ctx context.Context, forumIDs model.Int64Array) (model.Int64Array, error) {
 	if len(forumIDs) == 0 {
 		return make(model.Int64Array, 0), nil
 	}
@@ -185,6 +190,11 @@ func (u *User) CreateThird(ctx context.Context, orgID uint, user *third_auth.Use
 			return txErr
 		}
 
+		thirdOrgIDs, txErr := u.thirdOrgIDs(tx, user)
+		if txErr != nil {
+			return txErr
+		}
+
 		var userThird model.UserThird
 		txErr = tx.Model(&model.UserThird{}).Where("third_id = ? AND type = ?", user.ThirdID, user.Type).
 			First(&userThird).Error
@@ -216,7 +226,7 @@ func (u *User) CreateThird(ctx context.Context, orgID uint, user *third_auth.Use
 					return txErr
 				}
 			} else {
-				return nil
+				return u.syncThirdOrg(tx, &dbUser, &userThird, thirdOrgIDs)
 			}
 		}
 
@@ -232,6 +242,10 @@ func (u *User) CreateThird(ctx context.Context, orgID uint, user *third_auth.Use
 			}
 		}
 
+		userThird = model.UserThird{
+			ThirdID: user.ThirdID,
+			Type:    user.Type,
+		}
 		if createUser {
 			user.Avatar = u.getAvatarFromURL(ctx, user.Avatar)
 
@@ -249,18 +263,22 @@ func (u *User) CreateThird(ctx context.Context, orgID uint, user *third_auth.Use
 				LastLogin: model.Timestamp(time.Now().Unix()),
 				Key:       uuid.NewString(),
 			}
+			if dbUser.Role != model.UserRoleGuest {
+				dbUser.OrgIDs, userThird.OrgIDs = mergeThirdOrg(dbUser.OrgIDs, nil, thirdOrgIDs)
+			}
+
 			txErr = u.createUser(tx, &dbUser)
 			if txErr != nil {
 				return txErr
 			}
+		} else {
+			txErr = u.syncThirdOrg(tx, &dbUser, &userThird, thirdOrgIDs)
+			if txErr != nil {
+				return txErr
+			}
 		}
 
-		userThird = model.UserThird{
-			UserID:  dbUser.ID,
-			ThirdID: user.ThirdID,
-			Type:    user.Type,
-		}
-
+		userThird.UserID = dbUser.ID
 		txErr = tx.Model(&model.UserThird{}).Create(&userThird).Error
 		if txErr != nil {
 			return txErr
@@ -275,6 +293,232 @@ func (u *User) CreateThird(ctx context.Context, orgID uint, user *third_auth.Use
 	return &dbUser, nil
 }
 
+// thirdOrgIDs 将第三方提供的组织名称映射为组织 id，忽略不存在的组织
+func (u *User) thirdOrgIDs(tx *gorm.DB, user *third_auth.User) (model.Int64Array, error) {
+	if len(user.OrgNames) == 0 {
+		return nil, nil
+	}
+
+	var orgIDs model.Int64Array
+	err := tx.Model(&model.Org{}).Select("id").
+		Where("name = ANY(?) AND type != ?", model.StringArray(user.OrgNames), model.OrgTypeAdmin).
+		Order("id ASC").
+		Scan(&orgIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return orgIDs, nil
+}
+
+// mergeThirdOrg 只增删由第三方同步管理的组织，默认组织和手动分配的组织保持不变，
+// 返回用户的组织以及同步后由第三方管理的组织
+func mergeThirdOrg(current, managed, orgIDs model.Int64Array) (model.Int64Array, model.Int64Array) {
+	userOrgIDs := make(model.Int64Array, 0, len(current)+len(orgIDs))
+	newManaged := make(model.Int64Array, 0, len(orgIDs))
+	for _, id := range current {
+		if slices.Contains(managed, id) {
+			if !slices.Contains(orgIDs, id) {
+				continue
+			}
+
+			newManaged = append(newManaged, id)
+		}
+
+		if !slices.Contains(userOrgIDs, id) {
+			userOrgIDs = append(userOrgIDs, id)
+		}
+	}
+
+	for _, id := range orgIDs {
+		if slices.Contains(userOrgIDs, id) {
+			continue
+		}
+
+		userOrgIDs = append(userOrgIDs, id)
+		newManaged = append(newManaged, id)
+	}
+
+	return userOrgIDs, newManaged
+}
+
+func (u *User) syncThirdOrg(tx *gorm.DB, user *model.User, userThird *model.UserThird, orgIDs model.Int64Array) error {
+	if user.Builtin || user.Role == model.UserRoleGuest {
+		return nil
+	}
+
+	userOrgIDs, managed := mergeThirdOrg(user.OrgIDs, userThird.OrgIDs, orgIDs)
+	if !slices.Equal(userOrgIDs, user.OrgIDs) {
+		err := tx.Model(u.m).Where("id = ?", user.ID).Updates(map[string]any{
+			"org_ids":    userOrgIDs,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		user.OrgIDs = userOrgIDs
+	}
+
+	if userThird.ID > 0 && !slices.Equal(managed, userThird.OrgIDs) {
+		err := tx.Model(&model.UserThird{}).Where("id = ?", userThird.ID).Update("org_ids", managed).Error
+		if err != nil {
+			return err
+		}
+	}
+	userThird.OrgIDs = managed
+
+	return nil
+}
+
+// ErrSyncTooManyMissing 目录中缺失的用户过多，多半是目录服务异常或搜索条件过窄，本次同步不禁用用户
+var ErrSyncTooManyMissing = errors.New("too many users missing from directory, skip disabling")
+
+// SyncThird 根据第三方目录中的用户同步组织，目录中已不存在的用户会被禁用并退出登录，
+// 重新出现在目录中的用户会解除由同步设置的禁用
+func (u *User) SyncThird(ctx context.Context, typ model.AuthType, users []*third_auth.User) (synced int, disabled int, err error) {
+	thirdUsers := make(map[string]*third_auth.User, len(users))
+	for _, user := range users {
+		thirdUsers[user.ThirdID] = user
+	}
+
+	var userThirds []model.UserThird
+	err = u.db.WithContext(ctx).Model(&model.UserThird{}).Where("type = ?", typ).Find(&userThirds).Error
+	if err != nil {
+		return
+	}
+
+	missing := 0
+	for _, userThird := range userThirds {
+		if _, ok := thirdUsers[userThird.ThirdID]; !ok {
+			missing++
+		}
+	}
+	allowBlock := len(users) > 0 && missing*2 <= len(userThirds)
+
+	for i := range userThirds {
+		userThird := &userThirds[i]
+		err = u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var dbUser model.User
+			txErr := tx.Model(u.m).Where("id = ?", userThird.UserID).First(&dbUser).Error
+			if txErr != nil {
+				if errors.Is(txErr, database.ErrRecordNotFound) {
+					return nil
+				}
+				return txErr
+			}
+
+			thirdUser, ok := thirdUsers[userThird.ThirdID]
+			if !ok {
+				if !allowBlock || dbUser.Builtin || dbUser.BlockUntil < 0 {
+					return nil
+				}
+
+				disabled++
+				txErr = tx.Model(u.m).Where("id = ?", dbUser.ID).Updates(map[string]any{
+					"block_until": -1,
+					"key":         uuid.NewString(),
+					"updated_at":  time.Now(),
+				}).Error
+				if txErr != nil {
+					return txErr
+				}
+
+				return tx.Model(&model.UserThird{}).Where("id = ?", userThird.ID).Update("sync_blocked", true).Error
+			}
+
+			// 只解除同步自己设置的禁用，管理员手动设置的禁用保持不变
+			if userThird.SyncBlocked {
+				if dbUser.BlockUntil == -1 {
+					txErr = tx.Model(u.m).Where("id = ?", dbUser.ID).Updates(map[string]any{
+						"block_until": 0,
+						"updated_at":  time.Now(),
+					}).Error
+					if txErr != nil {
+						return txErr
+					}
+				}
+
+				txErr = tx.Model(&model.UserThird{}).Where("id = ?", userThird.ID).Update("sync_blocked", false).Error
+				if txErr != nil {
+					return txErr
+				}
+			}
+
+			orgIDs, txErr := u.thirdOrgIDs(tx, thirdUser)
+			if txErr != nil {
+				return txErr
+			}
+
+			synced++
+			return u.syncThirdOrg(tx, &dbUser, userThird, orgIDs)
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if !allowBlock && missing > 0 {
+		err = ErrSyncTooManyMissing
+	}
+
+	return
+}
+
+func (u *User) withThird(ctx context.Context, typ model.AuthType) *gorm.DB {
+	return u.model(ctx).Joins("JOIN user_thirds ON user_thirds.user_id = users.id AND user_thirds.type = ?", typ)
+}
+
+// ListWithThird 只列出关联了指定第三方类型的用户，附带 third_id 和 external_id
+func (u *User) ListWithThird(ctx context.Context, typ model.AuthType, res any, offset int, limit int, queryFuncs ...QueryOptFunc) error {
+	queryOpt := getQueryOpt(queryFuncs...)
+	return u.withThird(ctx, typ).
+		Select("users.*, user_thirds.third_id, user_thirds.external_id").
+		Scopes(queryOpt.Scopes()...).
+		Order("users.id ASC").
+		Offset(offset).Limit(limit).
+		Find(res).Error
+}
+
+func (u *User) CountWithThird(ctx context.Context, typ model.AuthType, res *int64, queryFuncs ...QueryOptFunc) error {
+	queryOpt := getQueryOpt(queryFuncs...)
+	return u.withThird(ctx, typ).Scopes(queryOpt.Scopes()...).Count(res).Error
+}
+
+// CreateWithThird user.ID 为 0 时创建用户，然后关联第三方 id
+func (u *User) CreateWithThird(ctx context.Context, user *model.User, third *model.UserThird) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if user.ID == 0 {
+			err := u.createUser(tx, user)
+			if err != nil {
+				return err
+			}
+		}
+
+		third.UserID = user.ID
+		return tx.Model(&model.UserThird{}).Create(third).Error
+	})
+}
+
+func (u *User) UpdateWithThird(ctx context.Context, userID uint, typ model.AuthType, userM map[string]any, thirdM map[string]any) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(userM) > 0 {
+			err := tx.Model(u.m).Where("id = ?", userID).Updates(userM).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(thirdM) > 0 {
+			err := tx.Model(&model.UserThird{}).Where("user_id = ? AND type = ?", userID, typ).Updates(thirdM).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 func (u *User) createUser(tx *gorm.DB, user *model.User) error {
 	user.Point = 0
 
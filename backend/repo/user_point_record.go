@@ -15,10 +15,41 @@ type UserPointRecord struct {
 	base[*model.UserPointRecord]
 }
 
-func (u *UserPointRecord) updateUserPoint(tx *gorm.DB, record *model.UserPointRecord, todayAddPoint, addPoint *int) error {
+// pointCap 积分上限，均为 0 表示不限制
+type pointCap struct {
+	daily int // 每人每天获得的积分上限
+	typ   int // 每人每天通过该类型行为获得的积分上限
+}
+
+// updateUserPoint 先对用户加事务锁，避免并发时每日上限的统计与积分扣减基于过期数据
+func (u *UserPointRecord) updateUserPoint(tx *gorm.DB, record *model.UserPointRecord, limit pointCap, todayAddPoint, addPoint *int) error {
+	err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, record.UserID).Error
+	if err != nil {
+		return err
+	}
+
 	if record.Point > 0 && record.RevokeID == 0 {
+		if limit.typ > 0 {
+			var typePoints int
+			err := tx.Model(&model.UserPointRecord{}).Select("COALESCE(SUM(point),0)").
+				Where("user_id = ? AND type = ? AND point > 0 AND revoke_id = 0 AND created_at >= ?", record.UserID, record.Type, util.DayTrunc(time.Now())).
+				Scan(&typePoints).Error
+			if err != nil {
+				return err
+			}
+
+			if limit.typ-typePoints <= 0 {
+				return nil
+			}
+
+			record.Point = min(record.Point, limit.typ-typePoints)
+		}
+
 		var todayPoints int
-		err := tx.Model(&model.UserPointRecord{}).Select("COALESCE(SUM(point),0)").Where("user_id = ? AND point > 0 AND revoke_id = 0 AND created_at >= ?", record.UserID, util.DayTrunc(time.Now())).Scan(&todayPoints).Error
+		err := tx.Model(&model.UserPointRecord{}).Select("COALESCE(SUM(point),0)").Where("user_id = ? AND point > 0 AND revoke_id = 0 AND created_at >= ?", record.UserID, util.DayTrunc(time.Now())).
+			// 悬赏积分来自其他用户，不计入每日上限
+			Where("type NOT IN ?", []model.UserPointType{model.UserPointTypeBountyAward, model.UserPointTypeBountyRefund}).
+			Scan(&todayPoints).Error
 		if err != nil {
 			return err
 		}
@@ -27,12 +58,13 @@ func (u *UserPointRecord) updateUserPoint(tx *gorm.DB, record *model.UserPointRe
 			*todayAddPoint = todayPoints
 		}
 
-		if 100-todayPoints <= 0 {
-			// 当前最多只能 +100 积分
-			return nil
-		}
+		if limit.daily > 0 {
+			if limit.daily-todayPoints <= 0 {
+				return nil
+			}
 
-		record.Point = min(record.Point, 100-todayPoints)
+			record.Point = min(record.Point, limit.daily-todayPoints)
+		}
 
 		if addPoint != nil {
 			*addPoint = record.Point
@@ -52,7 +84,7 @@ func (u *UserPointRecord) updateUserPoint(tx *gorm.DB, record *model.UserPointRe
 		return nil
 	}
 
-	err := tx.Create(record).Error
+	err = tx.Create(record).Error
 	if err != nil {
 		return err
 	}
@@ -65,11 +97,54 @@ func (u *UserPointRecord) updateUserPoint(tx *gorm.DB, record *model.UserPointRe
 	return nil
 }
 
-func (u *UserPointRecord) skipCreate(ctx context.Context, record model.UserPointRecordInfo) (bool, error) {
-	if _, ok := model.UserPointTypePointM[record.Type]; !ok {
-		return true, nil
+// pointRule 查询记录所在论坛的积分规则，论坛规则优先于全局规则，都没有时使用默认积分
+// 类型没有积分或规则被禁用时返回 nil
+func (u *UserPointRecord) pointRule(tx *gorm.DB, record model.UserPointRecordInfo) (*model.UserPointRule, error) {
+	var (
+		forumID uint
+		err     error
+	)
+	switch {
+	case record.Type.ForeignComment():
+		err = tx.Model(&model.Comment{}).
+			Joins("JOIN discussions ON discussions.id = comments.discussion_id").
+			Where("comments.id = ?", record.ForeignID).
+			Select("discussions.forum_id").
+			Scan(&forumID).Error
+	case record.Type.ForeignDiscussion():
+		err = tx.Model(&model.Discussion{}).Where("id = ?", record.ForeignID).Select("forum_id").Scan(&forumID).Error
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []model.UserPointRule
+	err = tx.Model(&model.UserPointRule{}).
+		Where("type = ? AND forum_id IN ?", record.Type, []uint{0, forumID}).
+		Order("forum_id DESC").
+		Limit(1).
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) > 0 {
+		if rules[0].Disabled || rules[0].Point == 0 {
+			return nil, nil
+		}
+
+		return &rules[0], nil
+	}
+
+	point, ok := model.UserPointTypePointM[record.Type]
+	if !ok {
+		return nil, nil
+	}
+
+	return &model.UserPointRule{Type: record.Type, Point: point}, nil
+}
 
+func (u *UserPointRecord) skipCreate(ctx context.Context, record model.UserPointRecordInfo) (bool, error) {
 	switch record.Type {
 	case model.UserPointTypeUserAvatar, model.UserPointTypeUserIntro, model.UserPointTypeUserRole:
 		exist, err := u.Exist(ctx, QueryWithEqual("type", record.Type), QueryWithEqual("user_id", record.UserID))
@@ -89,7 +164,8 @@ func (u *UserPointRecord) skipCreate(ctx context.Context, record model.UserPoint
 	return record.FromID == record.UserID, nil
 }
 
-func (u *UserPointRecord) CreateRecord(ctx context.Context, record model.UserPointRecordInfo, revoke bool, todayAddPoint, addPoint *int) error {
+// CreateRecord 按积分规则记录积分，dailyCap 为每人每天获得积分的上限，0 表示不限制
+func (u *UserPointRecord) CreateRecord(ctx context.Context, record model.UserPointRecordInfo, revoke bool, dailyCap int, todayAddPoint, addPoint *int) error {
 	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if revoke {
 			var lastRecord model.UserPointRecord
@@ -109,7 +185,15 @@ func (u *UserPointRecord) CreateRecord(ctx context.Context, record model.UserPoi
 				UserPointRecordInfo: record,
 				Point:               -lastRecord.Point,
 				RevokeID:            lastRecord.ID,
-			}, todayAddPoint, addPoint)
+			}, pointCap{}, todayAddPoint, addPoint)
+		}
+
+		rule, err := u.pointRule(tx, record)
+		if err != nil {
+			return err
+		}
+		if rule == nil {
+			return nil
 		}
 
 		skip, err := u.skipCreate(ctx, record)
@@ -122,9 +206,9 @@ func (u *UserPointRecord) CreateRecord(ctx context.Context, record model.UserPoi
 
 		return u.updateUserPoint(tx, &model.UserPointRecord{
 			UserPointRecordInfo: record,
-			Point:               model.UserPointTypePointM[record.Type],
+			Point:               rule.Point,
 			RevokeID:            0,
-		}, todayAddPoint, addPoint)
+		}, pointCap{daily: dailyCap, typ: rule.DailyCap}, todayAddPoint, addPoint)
 	})
 }
 
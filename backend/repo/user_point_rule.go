@@ -0,0 +1,28 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm/clause"
+)
+
+type UserPointRule struct {
+	base[*model.UserPointRule]
+}
+
+func (u *UserPointRule) Upsert(ctx context.Context, rule *model.UserPointRule) error {
+	return u.model(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "forum_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"point", "daily_cap", "disabled", "updated_at"}),
+	}).Create(rule).Error
+}
+
+func newUserPointRule(db *database.DB) *UserPointRule {
+	return &UserPointRule{base: base[*model.UserPointRule]{db: db, m: &model.UserPointRule{}}}
+}
+
+func init() {
+	register(newUserPointRule)
+}
@@ -0,0 +1,38 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+)
+
+type UserSession struct {
+	base[*model.UserSession]
+}
+
+func (u *UserSession) GetByRefreshHash(ctx context.Context, res *model.UserSession, hash string) error {
+	return u.model(ctx).Where("refresh_hash = ? OR prev_refresh_hash = ?", hash, hash).First(res).Error
+}
+
+// Rotate 仅当 refresh token 仍为 hash 时更新，返回是否更新成功，并发刷新时只有一个请求能成功
+func (u *UserSession) Rotate(ctx context.Context, id uint, hash string, updateM map[string]any) (bool, error) {
+	res := u.model(ctx).Where("id = ? AND refresh_hash = ?", id, hash).Updates(updateM)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func newUserSession(db *database.DB) *UserSession {
+	return &UserSession{
+		base: base[*model.UserSession]{
+			db: db, m: &model.UserSession{},
+		},
+	}
+}
+
+func init() {
+	register(newUserSession)
+}
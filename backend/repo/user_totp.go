@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"gorm.io/gorm"
+)
+
+type UserTOTP struct {
+	base[*model.UserTOTP]
+}
+
+func (u *UserTOTP) GetByUserID(ctx context.Context, res *model.UserTOTP, userID uint) error {
+	return u.model(ctx).Where("user_id = ?", userID).First(res).Error
+}
+
+// Upsert 重新生成密钥，未启用前不影响登录
+func (u *UserTOTP) Upsert(ctx context.Context, userID uint, secret string) error {
+	var exist model.UserTOTP
+	err := u.GetByUserID(ctx, &exist, userID)
+	if err != nil {
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			return err
+		}
+
+		return u.Create(ctx, &model.UserTOTP{
+			UserID: userID,
+			Secret: secret,
+		})
+	}
+
+	return u.Update(ctx, map[string]any{
+		"secret":         secret,
+		"enabled":        false,
+		"last_counter":   0,
+		"recovery_codes": model.StringArray{},
+		"updated_at":     time.Now(),
+	}, QueryWithEqual("id", exist.ID))
+}
+
+// UseCounter 仅当 counter 大于已使用的计数器时更新，返回是否更新成功，并发使用同一验证码时只有一个请求能成功
+func (u *UserTOTP) UseCounter(ctx context.Context, id uint, counter int64) (bool, error) {
+	res := u.model(ctx).Where("id = ? AND last_counter < ?", id, counter).Updates(map[string]any{
+		"last_counter": counter,
+		"updated_at":   time.Now(),
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode 删除未使用的恢复码，返回是否删除成功，并发使用同一恢复码时只有一个请求能成功
+func (u *UserTOTP) UseRecoveryCode(ctx context.Context, id uint, hash string) (bool, error) {
+	res := u.model(ctx).Where("id = ? AND ? = ANY(recovery_codes)", id, hash).Updates(map[string]any{
+		"recovery_codes": gorm.Expr("array_remove(recovery_codes, ?)", hash),
+		"updated_at":     time.Now(),
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func newUserTOTP(db *database.DB) *UserTOTP {
+	return &UserTOTP{
+		base: base[*model.UserTOTP]{
+			db: db, m: &model.UserTOTP{},
+		},
+	}
+}
+
+func init() {
+	register(newUserTOTP)
+}
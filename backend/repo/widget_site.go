@@ -0,0 +1,22 @@
+package repo
+
+import (
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+)
+
+type WidgetSite struct {
+	base[*model.WidgetSite]
+}
+
+func newWidgetSite(db *database.DB) *WidgetSite {
+	return &WidgetSite{
+		base: base[*model.WidgetSite]{
+			db: db, m: &model.WidgetSite{},
+		},
+	}
+}
+
+func init() {
+	register(newWidgetSite)
+}
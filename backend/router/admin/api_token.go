@@ -60,7 +60,7 @@ func (a *apiToken) Create(ctx *context.Context) {
 		return
 	}
 
-	res, err := a.apiToken.Create(ctx, req)
+	res, err := a.apiToken.Create(ctx, ctx.GetUser().UID, req)
 	if err != nil {
 		ctx.InternalError(err, "create token failed")
 		return
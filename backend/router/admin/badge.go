@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type badge struct {
+	svcBadge *svc.Badge
+}
+
+// List
+// @Summary list badge
+// @Tags badge
+// @Produce json
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.Badge}}
+// @Router /admin/badge [get]
+func (b *badge) List(ctx *context.Context) {
+	res, err := b.svcBadge.List(ctx)
+	if err != nil {
+		ctx.InternalError(err, "list badge failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Create
+// @Summary create badge
+// @Tags badge
+// @Accept json
+// @Param req body svc.BadgeCreateReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=uint}
+// @Router /admin/badge [post]
+func (b *badge) Create(ctx *context.Context) {
+	var req svc.BadgeCreateReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := b.svcBadge.Create(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "create badge failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Update
+// @Summary update badge
+// @Tags badge
+// @Accept json
+// @Param badge_id path uint true "badge id"
+// @Param req body svc.BadgeCreateReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/badge/{badge_id} [put]
+func (b *badge) Update(ctx *context.Context) {
+	badgeID, err := ctx.ParamUint("badge_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	var req svc.BadgeCreateReq
+	err = ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = b.svcBadge.Update(ctx, badgeID, req)
+	if err != nil {
+		ctx.InternalError(err, "update badge failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// Delete
+// @Summary delete badge
+// @Tags badge
+// @Param badge_id path uint true "badge id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/badge/{badge_id} [delete]
+func (b *badge) Delete(ctx *context.Context) {
+	badgeID, err := ctx.ParamUint("badge_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = b.svcBadge.Delete(ctx, badgeID)
+	if err != nil {
+		ctx.InternalError(err, "delete badge failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (b *badge) Route(h server.Handler) {
+	g := h.Group("/badge")
+	g.GET("", b.List)
+	g.POST("", b.Create)
+
+	{
+		detailG := g.Group("/:badge_id")
+		detailG.PUT("", b.Update)
+		detailG.DELETE("", b.Delete)
+	}
+}
+
+func newBadge(b *svc.Badge) server.Router {
+	return &badge{svcBadge: b}
+}
+
+func init() {
+	registerAdminAPIRouter(newBadge)
+}
@@ -7,7 +7,8 @@ import (
 )
 
 type discussion struct {
-	disc *svc.Discussion
+	disc        *svc.Discussion
+	askFeedback *svc.AskFeedback
 }
 
 func (d *discussion) Route(h server.Handler) {
@@ -16,10 +17,13 @@ func (d *discussion) Route(h server.Handler) {
 	g.POST("/reindex", d.Reindex)
 	g.GET("/ask", d.ListAsks)
 	g.GET("/ask/session", d.AskSession)
+	g.GET("/ask/feedback", d.ListAskFeedback)
+	g.PUT("/ask/feedback/:feedback_id/ignore", d.IgnoreAskFeedback)
+	g.POST("/ask/feedback/:feedback_id/qa", d.AskFeedbackQA)
 }
 
-func newDiscussion(disc *svc.Discussion) server.Router {
-	return &discussion{disc: disc}
+func newDiscussion(disc *svc.Discussion, askFeedback *svc.AskFeedback) server.Router {
+	return &discussion{disc: disc, askFeedback: askFeedback}
 }
 
 func init() {
@@ -117,3 +121,85 @@ func (d *discussion) AskSession(ctx *context.Context) {
 
 	ctx.Success(res)
 }
+
+// ListAskFeedback
+// @Summary backend list ask answer feedback
+// @Description backend list ask answer feedback
+// @Tags discussion
+// @Produce json
+// @Param req query svc.AskFeedbackListReq false "req params"
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.AskFeedbackListItem}}
+// @Router /admin/discussion/ask/feedback [get]
+func (d *discussion) ListAskFeedback(ctx *context.Context) {
+	var req svc.AskFeedbackListReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := d.askFeedback.List(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "list ask feedback failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// IgnoreAskFeedback
+// @Summary backend ignore ask answer feedback
+// @Description backend ignore ask answer feedback
+// @Tags discussion
+// @Produce json
+// @Param feedback_id path uint true "feedback_id"
+// @Success 200 {object} context.Response
+// @Router /admin/discussion/ask/feedback/{feedback_id}/ignore [put]
+func (d *discussion) IgnoreAskFeedback(ctx *context.Context) {
+	feedbackID, err := ctx.ParamUint("feedback_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = d.askFeedback.Ignore(ctx, ctx.GetUser().UID, feedbackID)
+	if err != nil {
+		ctx.InternalError(err, "ignore ask feedback failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// AskFeedbackQA
+// @Summary backend create qa from ask answer feedback
+// @Description backend create corrected qa from ask answer feedback
+// @Tags discussion
+// @Produce json
+// @Accept json
+// @Param feedback_id path uint true "feedback_id"
+// @Param req body svc.AskFeedbackQAReq true "req params"
+// @Success 200 {object} context.Response{data=uint}
+// @Router /admin/discussion/ask/feedback/{feedback_id}/qa [post]
+func (d *discussion) AskFeedbackQA(ctx *context.Context) {
+	feedbackID, err := ctx.ParamUint("feedback_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	var req svc.AskFeedbackQAReq
+	err = ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := d.askFeedback.CreateQA(ctx, ctx.GetUser().UID, feedbackID, req)
+	if err != nil {
+		ctx.InternalError(err, "create qa from ask feedback failed")
+		return
+	}
+
+	ctx.Success(res)
+}
@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type email struct {
+	svcEmail *svc.Email
+}
+
+// Get
+// @Summary system email gateway detail
+// @Tags email
+// @Produce json
+// @Success 200 {object} context.Response{data=model.SystemEmail}
+// @Router /admin/system/email [get]
+func (e *email) Get(ctx *context.Context) {
+	res, err := e.svcEmail.Get(ctx)
+	if err != nil {
+		ctx.InternalError(err, "get system email failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Put
+// @Summary update system email gateway config
+// @Tags email
+// @Accept json
+// @Param req body model.SystemEmail true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/system/email [put]
+func (e *email) Put(ctx *context.Context) {
+	var req model.SystemEmail
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = e.svcEmail.Update(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "update system email failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (e *email) Route(h server.Handler) {
+	g := h.Group("/system/email")
+	g.GET("", e.Get)
+	g.PUT("", e.Put)
+}
+
+func newEmail(e *svc.Email) server.Router {
+	return &email{svcEmail: e}
+}
+
+func init() {
+	registerAdminAPIRouter(newEmail)
+}
@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type issueState struct {
+	svcIssue *svc.Issue
+}
+
+// List
+// @Summary list issue state
+// @Tags issue_state
+// @Param req query svc.IssueStateListReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.IssueState}}
+// @Router /admin/issue_state [get]
+func (i *issueState) List(ctx *context.Context) {
+	var req svc.IssueStateListReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := i.svcIssue.ListState(ctx, ctx.GetUser().UID, req)
+	if err != nil {
+		ctx.InternalError(err, "list issue state failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Create
+// @Summary create issue state
+// @Tags issue_state
+// @Accept json
+// @Param req body svc.IssueStateReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=uint}
+// @Router /admin/issue_state [post]
+func (i *issueState) Create(ctx *context.Context) {
+	var req svc.IssueStateReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := i.svcIssue.CreateState(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "create issue state failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Update
+// @Summary update issue state
+// @Tags issue_state
+// @Accept json
+// @Param state_id path uint true "state id"
+// @Param req body svc.IssueStateReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/issue_state/{state_id} [put]
+func (i *issueState) Update(ctx *context.Context) {
+	stateID, err := ctx.ParamUint("state_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	var req svc.IssueStateReq
+	err = ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = i.svcIssue.UpdateState(ctx, stateID, req)
+	if err != nil {
+		ctx.InternalError(err, "update issue state failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// Delete
+// @Summary delete issue state
+// @Tags issue_state
+// @Param state_id path uint true "state id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/issue_state/{state_id} [delete]
+func (i *issueState) Delete(ctx *context.Context) {
+	stateID, err := ctx.ParamUint("state_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = i.svcIssue.DeleteState(ctx, stateID)
+	if err != nil {
+		ctx.InternalError(err, "delete issue state failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (i *issueState) Route(h server.Handler) {
+	g := h.Group("/issue_state")
+	g.GET("", i.List)
+	g.POST("", i.Create)
+
+	{
+		detailG := g.Group("/:state_id")
+		detailG.PUT("", i.Update)
+		detailG.DELETE("", i.Delete)
+	}
+}
+
+func newIssueState(issue *svc.Issue) server.Router {
+	return &issueState{svcIssue: issue}
+}
+
+func init() {
+	registerAdminAPIRouter(newIssueState)
+}
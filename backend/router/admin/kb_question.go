@@ -195,7 +195,7 @@ func (q *kbQuestion) UploadFile(ctx *context.Context) {
 		ctx.BadRequest(err)
 		return
 	}
-	path, err := q.svc.UploadFile(ctx, kbID, req)
+	path, err := q.svc.UploadFile(ctx, ctx.GetUser().UID, kbID, req)
 	if err != nil {
 		ctx.InternalError(err, "upload file failed")
 		return
@@ -1,13 +1,44 @@
 package admin
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
 	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/export"
 	"github.com/chaitin/koalaqa/server"
 	"github.com/chaitin/koalaqa/svc"
 )
 
 type stat struct {
-	svcStat *svc.Stat
+	svcStat      *svc.Stat
+	svcAskFunnel *svc.AskFunnel
+}
+
+type statExportReq struct {
+	Format export.Format `form:"format" binding:"required,oneof=csv xlsx"`
+}
+
+func (s *stat) export(ctx *context.Context, name string, tables []export.Table) {
+	var req statExportReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = export.Write(&buf, req.Format, tables...)
+	if err != nil {
+		ctx.InternalError(err, "export stat failed")
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102150405"), req.Format)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Data(http.StatusOK, req.Format.ContentType(), buf.Bytes())
 }
 
 // Visit
@@ -34,12 +65,44 @@ func (s *stat) Visit(ctx *context.Context) {
 	ctx.Success(res)
 }
 
+// VisitExport
+// @Summary export stat visit
+// @Tags stat
+// @Param req query svc.StatReq false "req params"
+// @Param format query string true "export format" Enums(csv, xlsx)
+// @Produce octet-stream
+// @Router /admin/stat/visit/export [get]
+func (s *stat) VisitExport(ctx *context.Context) {
+	var req svc.StatReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.Visit(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat visit failed")
+		return
+	}
+
+	s.export(ctx, "visit", res.Tables())
+}
+
+// statSearchRes data 保持为搜索次数以兼容旧版本，compare 为 true 时附带上一周期的搜索次数
+type statSearchRes struct {
+	context.Response
+
+	Previous *int64 `json:"previous,omitempty"`
+}
+
 // SearchCoount
 // @Summary stat search count
 // @Tags stat
+// @Description compare 为 true 时 previous 返回上一周期的搜索次数
 // @Param req query svc.StatReq false "req params"
 // @Produce json
-// @Success 200 {object} context.Response{data=uint}
+// @Success 200 {object} statSearchRes{data=int64}
 // @Router /admin/stat/search [get]
 func (s *stat) SearchCoount(ctx *context.Context) {
 	var req svc.StatReq
@@ -49,13 +112,40 @@ func (s *stat) SearchCoount(ctx *context.Context) {
 		return
 	}
 
-	res, err := s.svcStat.SearchCount(ctx, req)
+	res, err := s.svcStat.Search(ctx, req)
 	if err != nil {
 		ctx.InternalError(err, "stat search count failed")
 		return
 	}
 
-	ctx.Success(res)
+	ctx.JSON(http.StatusOK, statSearchRes{
+		Response: ctx.SuccessResponse(res.Count),
+		Previous: res.Previous,
+	})
+}
+
+// SearchExport
+// @Summary export stat search count
+// @Tags stat
+// @Param req query svc.StatReq false "req params"
+// @Param format query string true "export format" Enums(csv, xlsx)
+// @Produce octet-stream
+// @Router /admin/stat/search/export [get]
+func (s *stat) SearchExport(ctx *context.Context) {
+	var req svc.StatReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.Search(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat search count failed")
+		return
+	}
+
+	s.export(ctx, "search", res.Tables())
 }
 
 // Discussion
@@ -82,12 +172,84 @@ func (s *stat) Discussion(ctx *context.Context) {
 	ctx.Success(res)
 }
 
+// DiscussionExport
+// @Summary export stat discussion
+// @Tags stat
+// @Param req query svc.StatReq false "req params"
+// @Param format query string true "export format" Enums(csv, xlsx)
+// @Produce octet-stream
+// @Router /admin/stat/discussion/export [get]
+func (s *stat) DiscussionExport(ctx *context.Context) {
+	var req svc.StatReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.Discussion(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat discussion failed")
+		return
+	}
+
+	s.export(ctx, "discussion", res.Tables())
+}
+
+// Breakdown
+// @Summary stat discussion by forum, group or tag
+// @Tags stat
+// @Param req query svc.StatBreakdownReq false "req params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.StatBreakdownRes}
+// @Router /admin/stat/breakdown [get]
+func (s *stat) Breakdown(ctx *context.Context) {
+	var req svc.StatBreakdownReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.Breakdown(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat breakdown failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// BreakdownExport
+// @Summary export stat breakdown
+// @Tags stat
+// @Param req query svc.StatBreakdownReq false "req params"
+// @Param format query string true "export format" Enums(csv, xlsx)
+// @Produce octet-stream
+// @Router /admin/stat/breakdown/export [get]
+func (s *stat) BreakdownExport(ctx *context.Context) {
+	var req svc.StatBreakdownReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.Breakdown(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat breakdown failed")
+		return
+	}
+
+	s.export(ctx, "breakdown", res.Tables())
+}
+
 // Trend
 // @Summary stat trend
 // @Tags stat
 // @Param req query svc.StatTrendReq false "req params"
 // @Produce json
-// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.StatTrend{items=[]model.StatTrendItem}}}
+// @Success 200 {object} context.Response{data=svc.StatTrendRes{items=[]model.StatTrend{items=[]model.StatTrendItem}}}
 // @Router /admin/stat/trend [get]
 func (s *stat) Trend(ctx *context.Context) {
 	var req svc.StatTrendReq
@@ -106,16 +268,171 @@ func (s *stat) Trend(ctx *context.Context) {
 	ctx.Success(res)
 }
 
+// TrendExport
+// @Summary export stat trend
+// @Tags stat
+// @Param req query svc.StatTrendReq false "req params"
+// @Param format query string true "export format" Enums(csv, xlsx)
+// @Produce octet-stream
+// @Router /admin/stat/trend/export [get]
+func (s *stat) TrendExport(ctx *context.Context) {
+	var req svc.StatTrendReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.Trend(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "get trend failed")
+		return
+	}
+
+	s.export(ctx, "trend", res.Tables(req.StatTypes))
+}
+
+// AskFunnel
+// @Summary stat ask session funnel
+// @Tags stat
+// @Param req query svc.AskFunnelReq false "req params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.AskFunnelRes}
+// @Router /admin/stat/ask_funnel [get]
+func (s *stat) AskFunnel(ctx *context.Context) {
+	var req svc.AskFunnelReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcAskFunnel.Stat(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat ask funnel failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// AskFunnelTrend
+// @Summary stat ask session funnel trend
+// @Tags stat
+// @Param req query svc.AskFunnelTrendReq false "req params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.AskFunnelTrendRes}
+// @Router /admin/stat/ask_funnel/trend [get]
+func (s *stat) AskFunnelTrend(ctx *context.Context) {
+	var req svc.AskFunnelTrendReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcAskFunnel.Trend(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat ask funnel trend failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// AskFunnelBreakdown
+// @Summary stat ask session funnel by forum, group or source
+// @Tags stat
+// @Param req query svc.AskFunnelBreakdownReq false "req params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.AskFunnelBreakdownRes}
+// @Router /admin/stat/ask_funnel/breakdown [get]
+func (s *stat) AskFunnelBreakdown(ctx *context.Context) {
+	var req svc.AskFunnelBreakdownReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcAskFunnel.Breakdown(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat ask funnel breakdown failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// WidgetSite
+// @Summary stat ask by widget site
+// @Tags stat
+// @Param req query svc.StatReq false "req params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.StatWidgetSiteRes}
+// @Router /admin/stat/widget_site [get]
+func (s *stat) WidgetSite(ctx *context.Context) {
+	var req svc.StatReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.WidgetSite(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat widget site failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// WidgetSiteExport
+// @Summary export stat ask by widget site
+// @Tags stat
+// @Param req query svc.StatReq false "req params"
+// @Param format query string true "export format" Enums(csv, xlsx)
+// @Produce octet-stream
+// @Router /admin/stat/widget_site/export [get]
+func (s *stat) WidgetSiteExport(ctx *context.Context) {
+	var req svc.StatReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := s.svcStat.WidgetSite(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "stat widget site failed")
+		return
+	}
+
+	s.export(ctx, "widget_site", res.Tables())
+}
+
 func (s *stat) Route(h server.Handler) {
 	g := h.Group("/stat")
 	g.GET("/visit", s.Visit)
+	g.GET("/visit/export", s.VisitExport)
 	g.GET("/search", s.SearchCoount)
+	g.GET("/search/export", s.SearchExport)
 	g.GET("/discussion", s.Discussion)
+	g.GET("/discussion/export", s.DiscussionExport)
+	g.GET("/breakdown", s.Breakdown)
+	g.GET("/breakdown/export", s.BreakdownExport)
 	g.GET("/trend", s.Trend)
+	g.GET("/trend/export", s.TrendExport)
+	g.GET("/ask_funnel", s.AskFunnel)
+	g.GET("/ask_funnel/trend", s.AskFunnelTrend)
+	g.GET("/ask_funnel/breakdown", s.AskFunnelBreakdown)
+	g.GET("/widget_site", s.WidgetSite)
+	g.GET("/widget_site/export", s.WidgetSiteExport)
 }
 
-func newStat(s *svc.Stat) server.Router {
-	return &stat{svcStat: s}
+func newStat(s *svc.Stat, askFunnel *svc.AskFunnel) server.Router {
+	return &stat{svcStat: s, svcAskFunnel: askFunnel}
 }
 
 func init() {
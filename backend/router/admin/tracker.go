@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type tracker struct {
+	svcTracker *svc.Tracker
+}
+
+// Get
+// @Summary forum tracker detail
+// @Tags tracker
+// @Param req query svc.TrackerGetReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=model.Tracker}
+// @Router /admin/tracker [get]
+func (t *tracker) Get(ctx *context.Context) {
+	var req svc.TrackerGetReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := t.svcTracker.Get(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "get tracker failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Put
+// @Summary create or update forum tracker
+// @Tags tracker
+// @Accept json
+// @Param req body svc.TrackerUpsertReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/tracker [put]
+func (t *tracker) Put(ctx *context.Context) {
+	var req svc.TrackerUpsertReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = t.svcTracker.Upsert(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "update tracker failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// Delete
+// @Summary delete forum tracker
+// @Tags tracker
+// @Param tracker_id path uint true "tracker id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/tracker/{tracker_id} [delete]
+func (t *tracker) Delete(ctx *context.Context) {
+	trackerID, err := ctx.ParamUint("tracker_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = t.svcTracker.Delete(ctx, trackerID)
+	if err != nil {
+		ctx.InternalError(err, "delete tracker failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (t *tracker) Route(h server.Handler) {
+	g := h.Group("/tracker")
+	g.GET("", t.Get)
+	g.PUT("", t.Put)
+	g.DELETE("/:tracker_id", t.Delete)
+}
+
+func newTracker(tr *svc.Tracker) server.Router {
+	return &tracker{svcTracker: tr}
+}
+
+func init() {
+	registerAdminAPIRouter(newTracker)
+}
@@ -143,6 +143,52 @@ func (u *user) Block(ctx *context.Context) {
 	ctx.Success(nil)
 }
 
+// Logout
+// @Summary force user logout on all devices
+// @Tags user
+// @Param user_id path uint true "user id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/user/{user_id}/logout [post]
+func (u *user) Logout(ctx *context.Context) {
+	userID, err := ctx.ParamUint("user_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = u.svcUser.ForceLogout(ctx, userID)
+	if err != nil {
+		ctx.InternalError(err, "force user logout failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// ResetTOTP
+// @Summary reset user totp
+// @Tags user
+// @Param user_id path uint true "user id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/user/{user_id}/totp [delete]
+func (u *user) ResetTOTP(ctx *context.Context) {
+	userID, err := ctx.ParamUint("user_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = u.svcUser.ResetTOTP(ctx, userID)
+	if err != nil {
+		ctx.InternalError(err, "reset user totp failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
 // JoinOrg
 // @Summary user join org
 // @Tags user
@@ -203,6 +249,8 @@ func (u *user) Route(h server.Handler) {
 			userG.PUT("", u.Update)
 			userG.DELETE("", u.Delete)
 			userG.PUT("/block", u.Block)
+			userG.POST("/logout", u.Logout)
+			userG.DELETE("/totp", u.ResetTOTP)
 		}
 	}
 }
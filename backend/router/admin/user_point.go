@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type userPoint struct {
+	svcRule *svc.UserPointRule
+}
+
+// ListRule
+// @Summary list user point rule
+// @Tags user_point
+// @Param req query svc.UserPointRuleListReq false "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.UserPointRule}}
+// @Router /admin/user_point/rule [get]
+func (u *userPoint) ListRule(ctx *context.Context) {
+	var req svc.UserPointRuleListReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := u.svcRule.List(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "list user point rule failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// DefaultRule
+// @Summary list default user point
+// @Tags user_point
+// @Produce json
+// @Success 200 {object} context.Response{data=[]svc.UserPointRuleDefault}
+// @Router /admin/user_point/rule/default [get]
+func (u *userPoint) DefaultRule(ctx *context.Context) {
+	ctx.Success(u.svcRule.Default())
+}
+
+// UpsertRule
+// @Summary create or update user point rule
+// @Tags user_point
+// @Accept json
+// @Param req body svc.UserPointRuleUpsertReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/user_point/rule [put]
+func (u *userPoint) UpsertRule(ctx *context.Context) {
+	var req svc.UserPointRuleUpsertReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = u.svcRule.Upsert(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "upsert user point rule failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// DeleteRule
+// @Summary delete user point rule
+// @Tags user_point
+// @Param rule_id path uint true "rule id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/user_point/rule/{rule_id} [delete]
+func (u *userPoint) DeleteRule(ctx *context.Context) {
+	ruleID, err := ctx.ParamUint("rule_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = u.svcRule.Delete(ctx, ruleID)
+	if err != nil {
+		ctx.InternalError(err, "delete user point rule failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// GetSystem
+// @Summary system user point detail
+// @Tags user_point
+// @Produce json
+// @Success 200 {object} context.Response{data=model.SystemUserPoint}
+// @Router /admin/system/user_point [get]
+func (u *userPoint) GetSystem(ctx *context.Context) {
+	res, err := u.svcRule.GetSystem(ctx)
+	if err != nil {
+		ctx.InternalError(err, "get system user point failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// PutSystem
+// @Summary update system user point config
+// @Tags user_point
+// @Accept json
+// @Param req body model.SystemUserPoint true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/system/user_point [put]
+func (u *userPoint) PutSystem(ctx *context.Context) {
+	var req model.SystemUserPoint
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = u.svcRule.UpdateSystem(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "update system user point failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (u *userPoint) Route(h server.Handler) {
+	g := h.Group("/user_point/rule")
+	g.GET("", u.ListRule)
+	g.GET("/default", u.DefaultRule)
+	g.PUT("", u.UpsertRule)
+	g.DELETE("/:rule_id", u.DeleteRule)
+
+	sysG := h.Group("/system/user_point")
+	sysG.GET("", u.GetSystem)
+	sysG.PUT("", u.PutSystem)
+}
+
+func newUserPoint(rule *svc.UserPointRule) server.Router {
+	return &userPoint{svcRule: rule}
+}
+
+func init() {
+	registerAdminAPIRouter(newUserPoint)
+}
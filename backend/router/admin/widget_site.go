@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type widgetSite struct {
+	svcSite *svc.WidgetSite
+}
+
+func (w *widgetSite) Route(h server.Handler) {
+	g := h.Group("/widget_site")
+	g.GET("", w.List)
+	g.POST("", w.Create)
+
+	{
+		detailG := g.Group("/:site_id")
+		detailG.PUT("", w.Update)
+		detailG.DELETE("", w.Delete)
+		detailG.PUT("/key", w.ResetKey)
+	}
+}
+
+func newWidgetSite(site *svc.WidgetSite) server.Router {
+	return &widgetSite{svcSite: site}
+}
+
+// List
+// @Summary list widget sites
+// @Tags widget_site
+// @Produce json
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.WidgetSite}}
+// @Router /admin/widget_site [get]
+func (w *widgetSite) List(ctx *context.Context) {
+	res, err := w.svcSite.List(ctx)
+	if err != nil {
+		ctx.InternalError(err, "list widget site failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Create
+// @Summary create widget site
+// @Tags widget_site
+// @Accept json
+// @Param req body svc.WidgetSiteReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=uint}
+// @Router /admin/widget_site [post]
+func (w *widgetSite) Create(ctx *context.Context) {
+	var req svc.WidgetSiteReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := w.svcSite.Create(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "create widget site failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Update
+// @Summary update widget site
+// @Tags widget_site
+// @Accept json
+// @Param site_id path uint true "site id"
+// @Param req body svc.WidgetSiteReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/widget_site/{site_id} [put]
+func (w *widgetSite) Update(ctx *context.Context) {
+	id, err := ctx.ParamUint("site_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	var req svc.WidgetSiteReq
+	err = ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = w.svcSite.Update(ctx, id, req)
+	if err != nil {
+		ctx.InternalError(err, "update widget site failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// ResetKey
+// @Summary reset widget site key
+// @Tags widget_site
+// @Param site_id path uint true "site id"
+// @Produce json
+// @Success 200 {object} context.Response{data=string}
+// @Router /admin/widget_site/{site_id}/key [put]
+func (w *widgetSite) ResetKey(ctx *context.Context) {
+	id, err := ctx.ParamUint("site_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := w.svcSite.ResetKey(ctx, id)
+	if err != nil {
+		ctx.InternalError(err, "reset widget site key failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Delete
+// @Summary delete widget site
+// @Tags widget_site
+// @Param site_id path uint true "site id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /admin/widget_site/{site_id} [delete]
+func (w *widgetSite) Delete(ctx *context.Context) {
+	id, err := ctx.ParamUint("site_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = w.svcSite.Delete(ctx, id)
+	if err != nil {
+		ctx.InternalError(err, "delete widget site failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func init() {
+	registerAdminAPIRouter(newWidgetSite)
+}
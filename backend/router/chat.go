@@ -89,6 +89,81 @@ func (c *chat) WecomServiceChat(ctx *context.Context) {
 	c.wecomChat(ctx, chatPkg.TypeWecomService)
 }
 
+func (c *chat) FeishuChat(ctx *context.Context) {
+	content, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.InternalError(err, "read body failed")
+		return
+	}
+
+	res, err := c.svcChat.StreamText(ctx, chatPkg.TypeFeishu, chatPkg.VerifyReq{
+		VerifySign: chatPkg.VerifySign{
+			MsgSignature: ctx.GetHeader("X-Lark-Signature"),
+			Timestamp:    ctx.GetHeader("X-Lark-Request-Timestamp"),
+			Nonce:        ctx.GetHeader("X-Lark-Request-Nonce"),
+		},
+		Content: string(content),
+	})
+	if err != nil {
+		ctx.InternalError(err, "stream chat failed")
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", []byte(res))
+}
+
+func (c *chat) slackChat(ctx *context.Context, command bool) {
+	content, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.InternalError(err, "read body failed")
+		return
+	}
+
+	res, err := c.svcChat.StreamText(ctx, chatPkg.TypeSlack, chatPkg.VerifyReq{
+		VerifySign: chatPkg.VerifySign{
+			MsgSignature: ctx.GetHeader("X-Slack-Signature"),
+			Timestamp:    ctx.GetHeader("X-Slack-Request-Timestamp"),
+		},
+		Content: string(content),
+		Command: command,
+	})
+	if err != nil {
+		ctx.InternalError(err, "stream chat failed")
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", []byte(res))
+}
+
+func (c *chat) SlackChat(ctx *context.Context) {
+	c.slackChat(ctx, false)
+}
+
+func (c *chat) SlackCommand(ctx *context.Context) {
+	c.slackChat(ctx, true)
+}
+
+func (c *chat) TeamsChat(ctx *context.Context) {
+	content, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.InternalError(err, "read body failed")
+		return
+	}
+
+	_, err = c.svcChat.StreamText(ctx, chatPkg.TypeTeams, chatPkg.VerifyReq{
+		VerifySign: chatPkg.VerifySign{
+			MsgSignature: ctx.GetHeader("Authorization"),
+		},
+		Content: string(content),
+	})
+	if err != nil {
+		ctx.InternalError(err, "stream chat failed")
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
 func (c *chat) Route(h server.Handler) {
 	g := h.Group("/chat")
 
@@ -100,6 +175,10 @@ func (c *chat) Route(h server.Handler) {
 		// botG.POST("/wecom_intelligent", c.WecomIntelligentChat)
 		botG.GET("/wecom_service", c.WecomServiceVerify)
 		botG.POST("/wecom_service", c.WecomServiceChat)
+		botG.POST("/feishu", c.FeishuChat)
+		botG.POST("/slack", c.SlackChat)
+		botG.POST("/slack/command", c.SlackCommand)
+		botG.POST("/teams", c.TeamsChat)
 	}
 }
 
@@ -2,20 +2,30 @@ package router
 
 import (
 	"fmt"
+	"html/template"
 	"io"
+	"net/http"
 
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/config"
 	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/llm"
+	"github.com/chaitin/koalaqa/pkg/util"
 	"github.com/chaitin/koalaqa/server"
 	"github.com/chaitin/koalaqa/svc"
 )
 
 type discussion struct {
-	disc       *svc.Discussion
-	discFollow *svc.DiscussionFollow
+	disc        *svc.Discussion
+	discFollow  *svc.DiscussionFollow
+	askFeedback *svc.AskFeedback
+	issue       *svc.Issue
+	tracker     *svc.Tracker
+	cfg         config.Config
 }
 
-func newDiscussion(svc *svc.Discussion, discFollow *svc.DiscussionFollow) server.Router {
-	return &discussion{disc: svc, discFollow: discFollow}
+func newDiscussion(svc *svc.Discussion, discFollow *svc.DiscussionFollow, askFeedback *svc.AskFeedback, issue *svc.Issue, tracker *svc.Tracker, cfg config.Config) server.Router {
+	return &discussion{disc: svc, discFollow: discFollow, askFeedback: askFeedback, issue: issue, tracker: tracker, cfg: cfg}
 }
 
 func init() {
@@ -30,9 +40,15 @@ func (d *discussion) Route(h server.Handler) {
 	g.GET("/:disc_id/associate", d.ListAssociate)
 	g.GET("/:disc_id/similarity", d.ListSimilarity)
 	g.GET("/:disc_id/follow", d.FollowInfo)
+	g.GET("/:disc_id/issue/history", d.IssueHistory)
+	g.GET("/:disc_id/tracker", d.TrackerIssue)
+	g.GET("/issue/state", d.IssueState)
 	g.POST("/ask", d.Ask)
 	g.GET("/ask/:ask_session_id", d.AskHistory)
 	g.POST("/ask/stop", d.StopAskSession)
+	g.POST("/ask/feedback", d.AskFeedback)
+	g.GET("/ask/feedback/bot", d.AskBotFeedbackPage)
+	g.POST("/ask/feedback/bot", d.AskBotFeedback)
 	g.GET("/ask/session", d.CreateOrLastSession)
 	g.POST("/summary/content", d.SummaryByContent)
 }
@@ -60,6 +76,67 @@ func (d *discussion) List(ctx *context.Context) {
 	ctx.Success(res)
 }
 
+// IssueState
+// @Summary list forum issue state
+// @Description list forum issue state
+// @Tags discussion
+// @Param req query svc.IssueStateListReq true "req params"
+// @Produce json
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.IssueState}}
+// @Router /discussion/issue/state [get]
+func (d *discussion) IssueState(ctx *context.Context) {
+	var req svc.IssueStateListReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := d.issue.ListState(ctx, ctx.GetUser().UID, req)
+	if err != nil {
+		ctx.InternalError(err, "list issue state failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// IssueHistory
+// @Summary list issue history
+// @Description list issue history
+// @Tags discussion
+// @Param disc_id path string true "disc_id"
+// @Produce json
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.IssueHistoryItem}}
+// @Router /discussion/{disc_id}/issue/history [get]
+func (d *discussion) IssueHistory(ctx *context.Context) {
+	res, err := d.issue.ListHistory(ctx, ctx.GetUser().UID, ctx.Param("disc_id"))
+	if err != nil {
+		ctx.InternalError(err, "list issue history failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// TrackerIssue
+// @Summary external tracker issue of discussion
+// @Description external tracker issue of discussion
+// @Tags discussion
+// @Param disc_id path string true "disc_id"
+// @Produce json
+// @Success 200 {object} context.Response{data=model.TrackerIssue}
+// @Router /discussion/{disc_id}/tracker [get]
+func (d *discussion) TrackerIssue(ctx *context.Context) {
+	res, err := d.tracker.GetIssue(ctx, ctx.GetUser().UID, ctx.Param("disc_id"))
+	if err != nil {
+		ctx.InternalError(err, "get tracker issue failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
 // ListAssociate
 // @Summary list associate discussion
 // @Description list associate discussion
@@ -195,6 +272,11 @@ func (d *discussion) Ask(ctx *context.Context) {
 		ctx.InternalError(err, "get ask stream failed")
 		return
 	}
+
+	renderAskStream(ctx, stream)
+}
+
+func renderAskStream(ctx *context.Context, stream *llm.Stream[llm.AskSessionStreamItem]) {
 	defer stream.Close()
 
 	ctx.Header("X-Accel-Buffering", "no")
@@ -287,6 +369,121 @@ func (d *discussion) StopAskSession(ctx *context.Context) {
 	ctx.Success(nil)
 }
 
+// AskFeedback
+// @Summary feedback ask answer
+// @Description like or dislike ai answer in ask session
+// @Tags discussion
+// @Produce json
+// @Accept json
+// @Param req body svc.AskFeedbackReq true "req params"
+// @Success 200 {object} context.Response
+// @Router /discussion/ask/feedback [post]
+func (d *discussion) AskFeedback(ctx *context.Context) {
+	var req svc.AskFeedbackReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = d.askFeedback.Feedback(ctx, ctx.GetUser().UID, req)
+	if err != nil {
+		ctx.InternalError(err, "feedback ask answer failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// askBotFeedbackTmpl 机器人回答中的评价链接会被聊天软件预览抓取，GET 仅展示确认页，用户确认后再 POST 提交
+var askBotFeedbackTmpl = template.Must(template.New("ask_bot_feedback").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>回答反馈</title>
+</head>
+<body style="font-family: sans-serif; text-align: center; padding-top: 80px;">
+<p id="msg">{{.Title}}</p>
+<button id="btn" onclick="submitFeedback()">确认</button>
+<script>
+function submitFeedback() {
+  var btn = document.getElementById("btn");
+  var msg = document.getElementById("msg");
+  btn.disabled = true;
+  fetch(location.href, {method: "POST", headers: {"X-CSRF-TOKEN": {{.CSRF}}}})
+    .then(function (res) { return res.json(); })
+    .then(function (res) { msg.textContent = res.success ? "感谢您的反馈" : "提交失败，请稍后重试"; })
+    .catch(function () { msg.textContent = "提交失败，请稍后重试"; })
+    .finally(function () { btn.remove(); });
+}
+</script>
+</body>
+</html>`))
+
+// AskBotFeedbackPage
+// @Summary bot answer feedback page
+// @Description confirm page for feedback link in bot answer
+// @Tags discussion
+// @Produce html
+// @Param req query svc.AskBotFeedbackReq true "req params"
+// @Success 200 {string} string
+// @Router /discussion/ask/feedback/bot [get]
+func (d *discussion) AskBotFeedbackPage(ctx *context.Context) {
+	var req svc.AskBotFeedbackReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	title := "确认这条回答有帮助？"
+	if req.State == model.AskFunnelFeedbackDislike {
+		title = "确认这条回答没有帮助？"
+	}
+
+	csrfToken := ""
+	if !d.cfg.API.FreeCSRF && ctx.GetUser().Salt != "" {
+		csrfToken = util.Sha1(d.cfg.API.CSRFSecret + "-" + ctx.GetUser().Salt)
+	}
+
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	err = askBotFeedbackTmpl.Execute(ctx.Writer, map[string]string{
+		"Title": title,
+		"CSRF":  csrfToken,
+	})
+	if err != nil {
+		ctx.InternalError(err, "render feedback page failed")
+		return
+	}
+}
+
+// AskBotFeedback
+// @Summary feedback bot answer
+// @Description like or dislike ai answer from dingtalk, wecom or other bots
+// @Tags discussion
+// @Produce json
+// @Param req query svc.AskBotFeedbackReq true "req params"
+// @Success 200 {object} context.Response
+// @Router /discussion/ask/feedback/bot [post]
+func (d *discussion) AskBotFeedback(ctx *context.Context) {
+	var req svc.AskBotFeedbackReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = d.askFeedback.BotFeedback(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "feedback bot answer failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
 // SummaryByContent
 // @Summary content summary
 // @Description content summary
@@ -10,10 +10,12 @@ type discussionAuth struct {
 	disc       *svc.Discussion
 	discFollow *svc.DiscussionFollow
 	kbDoc      *svc.KBDocument
+	issue      *svc.Issue
+	tracker    *svc.Tracker
 }
 
-func newDiscussionAuth(svc *svc.Discussion, discFollow *svc.DiscussionFollow, kbDoc *svc.KBDocument) server.Router {
-	return &discussionAuth{disc: svc, discFollow: discFollow, kbDoc: kbDoc}
+func newDiscussionAuth(svc *svc.Discussion, discFollow *svc.DiscussionFollow, kbDoc *svc.KBDocument, issue *svc.Issue, tracker *svc.Tracker) server.Router {
+	return &discussionAuth{disc: svc, discFollow: discFollow, kbDoc: kbDoc, issue: issue, tracker: tracker}
 }
 
 func init() {
@@ -27,6 +29,7 @@ func (d *discussionAuth) Route(h server.Handler) {
 	g.GET("/follow", d.ListFollow)
 	g.POST("/complete", d.Complete)
 	g.POST("/upload", d.UploadFile)
+	g.POST("/duplicate", d.CheckDuplicate)
 
 	{
 		detailG := g.Group("/:disc_id")
@@ -37,8 +40,12 @@ func (d *discussionAuth) Route(h server.Handler) {
 		detailG.POST("/resolve", d.ResolveFeedback)
 		detailG.PUT("/close", d.CloseDiscussion)
 		detailG.POST("/resolve_issue", d.ResolveIssue)
+		detailG.PUT("/issue", d.UpdateIssue)
+		detailG.POST("/issue/state", d.TransitIssue)
+		detailG.POST("/tracker", d.CreateTrackerIssue)
 		detailG.POST("/requirement", d.Requirement)
 		detailG.POST("/associate", d.Associate)
+		detailG.POST("/merge", d.Merge)
 		detailG.POST("/follow", d.Follow)
 		detailG.DELETE("/follow", d.Unfollow)
 		detailG.POST("/ai_learn", d.AILearn)
@@ -417,6 +424,78 @@ func (d *discussionAuth) ResolveIssue(ctx *context.Context) {
 	ctx.Success(nil)
 }
 
+// UpdateIssue
+// @Summary update issue assignee, priority, severity and due date
+// @Description update issue assignee, priority, severity and due date
+// @Tags discussion
+// @Accept json
+// @Param req body svc.IssueUpdateReq true "req params"
+// @Produce json
+// @Param disc_id path string true "disc_id"
+// @Success 200 {object} context.Response
+// @Router /discussion/{disc_id}/issue [put]
+func (d *discussionAuth) UpdateIssue(ctx *context.Context) {
+	var req svc.IssueUpdateReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = d.issue.Update(ctx, ctx.GetUser(), ctx.Param("disc_id"), req)
+	if err != nil {
+		ctx.InternalError(err, "update issue failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// TransitIssue
+// @Summary change issue state
+// @Description change issue state
+// @Tags discussion
+// @Accept json
+// @Param req body svc.IssueTransitReq true "req params"
+// @Produce json
+// @Param disc_id path string true "disc_id"
+// @Success 200 {object} context.Response
+// @Router /discussion/{disc_id}/issue/state [post]
+func (d *discussionAuth) TransitIssue(ctx *context.Context) {
+	var req svc.IssueTransitReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = d.issue.Transit(ctx, ctx.GetUser(), ctx.Param("disc_id"), req)
+	if err != nil {
+		ctx.InternalError(err, "change issue state failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// CreateTrackerIssue
+// @Summary create external tracker issue
+// @Description create external tracker issue
+// @Tags discussion
+// @Produce json
+// @Param disc_id path string true "disc_id"
+// @Success 200 {object} context.Response{data=model.TrackerIssue}
+// @Router /discussion/{disc_id}/tracker [post]
+func (d *discussionAuth) CreateTrackerIssue(ctx *context.Context) {
+	res, err := d.tracker.CreateIssue(ctx, ctx.GetUser(), ctx.Param("disc_id"))
+	if err != nil {
+		ctx.InternalError(err, "create tracker issue failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
 // UploadFile
 // @Summary discussion upload file
 // @Description discussion upload file
@@ -435,7 +514,7 @@ func (d *discussionAuth) UploadFile(ctx *context.Context) {
 		return
 	}
 
-	path, err := d.disc.UploadFile(ctx, req)
+	path, err := d.disc.UploadFile(ctx, ctx.GetUser().UID, req)
 	if err != nil {
 		ctx.InternalError(err, "upload file failed")
 		return
@@ -495,6 +574,59 @@ func (d *discussionAuth) Associate(ctx *context.Context) {
 	ctx.Success(nil)
 }
 
+// CheckDuplicate
+// @Summary check duplicate discussion
+// @Description check duplicate discussion before create
+// @Tags discussion
+// @Accept json
+// @Produce json
+// @Param req body svc.DiscussionDuplicateReq true "request params"
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]svc.DiscussionDuplicateItem}}
+// @Router /discussion/duplicate [post]
+func (d *discussionAuth) CheckDuplicate(ctx *context.Context) {
+	var req svc.DiscussionDuplicateReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := d.disc.CheckDuplicate(ctx, ctx.GetUser().UID, req)
+	if err != nil {
+		ctx.InternalError(err, "check duplicate discussion failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Merge
+// @Summary merge discussion
+// @Description merge duplicate discussion into target discussion
+// @Tags discussion
+// @Accept json
+// @Produce json
+// @Param disc_id path string true "disc_id"
+// @Param req body svc.DiscussionMergeReq true "request params"
+// @Success 200 {object} context.Response
+// @Router /discussion/{disc_id}/merge [post]
+func (d *discussionAuth) Merge(ctx *context.Context) {
+	var req svc.DiscussionMergeReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = d.disc.Merge(ctx, ctx.GetUser(), ctx.Param("disc_id"), req)
+	if err != nil {
+		ctx.InternalError(err, "merge discussion failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
 // Requirement
 // @Summary discussion requirement
 // @Description discussion requirement
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"io"
+
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type email struct {
+	svcEmail *svc.Email
+}
+
+type emailInboundReq struct {
+	Secret string `form:"secret" binding:"required"`
+}
+
+// Inbound
+// @Summary inbound email webhook
+// @Description receive raw RFC 5322 email piped from MTA, new emails become questions and replies become comments
+// @Tags email
+// @Accept plain
+// @Param secret query string true "inbound secret"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /email/inbound [post]
+func (e *email) Inbound(ctx *context.Context) {
+	var req emailInboundReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, 32<<20))
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = e.svcEmail.Inbound(ctx, req.Secret, body)
+	if err != nil {
+		ctx.InternalError(err, "handle inbound email failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (e *email) Route(h server.Handler) {
+	g := h.Group("/email")
+	g.POST("/inbound", e.Inbound)
+}
+
+func newEmail(e *svc.Email) server.Router {
+	return &email{svcEmail: e}
+}
+
+func init() {
+	registerApiNoAuthRouter(newEmail)
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/gql"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type graphQL struct {
+	svc *svc.GraphQL
+}
+
+func newGraphQL(svc *svc.GraphQL) server.Router {
+	return &graphQL{svc: svc}
+}
+
+func init() {
+	registerApiNoAuthRouter(newGraphQL)
+}
+
+func (g *graphQL) Route(h server.Handler) {
+	h.GET("/graphql", g.Get)
+	h.POST("/graphql", g.Post)
+}
+
+// Get
+// @Summary graphql query by get, used for persisted queries
+// @Tags graphql
+// @Produce json
+// @Param query query string false "graphql query"
+// @Param operationName query string false "operation name"
+// @Param variables query string false "json encoded variables"
+// @Param extensions query string false "json encoded extensions"
+// @Success 200 {object} object
+// @Router /graphql [get]
+func (g *graphQL) Get(ctx *context.Context) {
+	var req gql.Request
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	if variables := ctx.Query("variables"); variables != "" {
+		err = json.Unmarshal([]byte(variables), &req.Variables)
+		if err != nil {
+			ctx.BadRequest(err)
+			return
+		}
+	}
+
+	if extensions := ctx.Query("extensions"); extensions != "" {
+		err = json.Unmarshal([]byte(extensions), &req.Extensions)
+		if err != nil {
+			ctx.BadRequest(err)
+			return
+		}
+	}
+
+	g.execute(ctx, req)
+}
+
+// Post
+// @Summary graphql query
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param req body gql.Request true "request params"
+// @Success 200 {object} object
+// @Router /graphql [post]
+func (g *graphQL) Post(ctx *context.Context) {
+	var req gql.Request
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	g.execute(ctx, req)
+}
+
+func (g *graphQL) execute(ctx *context.Context, req gql.Request) {
+	ctx.JSON(http.StatusOK, g.svc.Execute(ctx, ctx.GetUser(), req))
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/chaitin/koalaqa/intercept"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"go.uber.org/fx"
+)
+
+type mcpRouter struct {
+	streamable   http.Handler
+	sse          *mcpserver.SSEServer
+	interceptors []intercept.Interceptor
+}
+
+type mcpIn struct {
+	fx.In
+
+	SvcMCP       *svc.MCP
+	Interceptors []intercept.Interceptor `group:"mcp_interceptors"`
+}
+
+func newMCP(in mcpIn) server.Router {
+	return &mcpRouter{
+		streamable:   mcpserver.NewStreamableHTTPServer(in.SvcMCP.Server()),
+		sse:          mcpserver.NewSSEServer(in.SvcMCP.Server(), mcpserver.WithStaticBasePath("/mcp")),
+		interceptors: in.Interceptors,
+	}
+}
+
+func init() {
+	registerGlobalRouter(newMCP)
+}
+
+// Route streamable http 使用 /mcp，旧版 HTTP+SSE 客户端使用 /mcp/sse 建立连接并向 /mcp/message 发送消息
+func (m *mcpRouter) Route(h server.Handler) {
+	g := h.GroupInterceptors("/mcp", m.interceptors...)
+	g.GET("", m.Streamable)
+	g.POST("", m.Streamable)
+	g.DELETE("", m.Streamable)
+	g.GET("/sse", m.SSE)
+	g.POST("/message", m.Message)
+}
+
+// Streamable
+// @Summary mcp streamable http transport
+// @Tags mcp
+// @Accept json
+// @Produce json,text/event-stream
+// @Router /mcp [post]
+func (m *mcpRouter) Streamable(ctx *context.Context) {
+	m.streamable.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// SSE
+// @Summary mcp sse transport
+// @Tags mcp
+// @Produce text/event-stream
+// @Router /mcp/sse [get]
+func (m *mcpRouter) SSE(ctx *context.Context) {
+	m.sse.SSEHandler().ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// Message
+// @Summary mcp sse transport message endpoint
+// @Tags mcp
+// @Accept json
+// @Produce json
+// @Param sessionId query string true "sse session id"
+// @Router /mcp/message [post]
+func (m *mcpRouter) Message(ctx *context.Context) {
+	m.sse.MessageHandler().ServeHTTP(ctx.Writer, ctx.Request)
+}
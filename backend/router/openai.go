@@ -0,0 +1,195 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/chaitin/koalaqa/intercept"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/openai"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+	"go.uber.org/fx"
+)
+
+type openAIRouter struct {
+	logger       *glog.Logger
+	svcChat      *svc.ChatCompletion
+	interceptors []intercept.Interceptor
+}
+
+type openAIIn struct {
+	fx.In
+
+	SvcChat      *svc.ChatCompletion
+	Interceptors []intercept.Interceptor `group:"openai_interceptors"`
+}
+
+func newOpenAI(in openAIIn) server.Router {
+	return &openAIRouter{
+		logger:       glog.Module("router", "openai"),
+		svcChat:      in.SvcChat,
+		interceptors: in.Interceptors,
+	}
+}
+
+func init() {
+	registerGlobalRouter(newOpenAI)
+}
+
+func (o *openAIRouter) Route(h server.Handler) {
+	g := h.GroupInterceptors("/v1", o.interceptors...)
+	g.GET("/models", o.Models)
+	g.POST("/chat/completions", o.ChatCompletions)
+}
+
+func (o *openAIRouter) error(ctx *context.Context, err error) {
+	var oaiErr *openai.Error
+	if !errors.As(err, &oaiErr) {
+		o.logger.WithContext(ctx).WithErr(err).Error("chat completion failed")
+		oaiErr = openai.ServerError("internal error")
+	}
+
+	ctx.JSON(oaiErr.StatusCode(), oaiErr)
+}
+
+// Models
+// @Summary openai compatible model list
+// @Tags openai
+// @Produce json
+// @Success 200 {object} openai.ModelList
+// @Router /v1/models [get]
+func (o *openAIRouter) Models(ctx *context.Context) {
+	ctx.JSON(http.StatusOK, o.svcChat.Models())
+}
+
+// ChatCompletions
+// @Summary openai compatible chat completions backed by the knowledge base
+// @Description stream 为 true 时以 SSE 返回 chat.completion.chunk，引用文档在 koala 扩展字段中返回
+// @Tags openai
+// @Accept json
+// @Produce json,text/event-stream
+// @Param req body svc.ChatCompletionReq true "request params"
+// @Success 200 {object} openai.Response
+// @Router /v1/chat/completions [post]
+func (o *openAIRouter) ChatCompletions(ctx *context.Context) {
+	var req svc.ChatCompletionReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		o.error(ctx, openai.InvalidRequest(err.Error()))
+		return
+	}
+
+	answer, err := o.svcChat.Create(ctx, req)
+	if err != nil {
+		o.error(ctx, err)
+		return
+	}
+	defer answer.Close()
+
+	if req.Stream {
+		o.stream(ctx, answer)
+		return
+	}
+
+	var content strings.Builder
+	for {
+		text, ok := answer.Next(ctx)
+		if !ok {
+			break
+		}
+
+		content.WriteString(text)
+	}
+
+	err = answer.Err()
+	if err != nil {
+		o.error(ctx, err)
+		return
+	}
+
+	ext, err := answer.Extension(ctx)
+	if err != nil {
+		o.error(ctx, err)
+		return
+	}
+
+	finish := openai.FinishReasonStop
+	ctx.JSON(http.StatusOK, openai.Response{
+		ID:      answer.ID,
+		Object:  openai.ObjectCompletion,
+		Created: answer.Created,
+		Model:   answer.Model,
+		Choices: []openai.Choice{{
+			Message:      &openai.Message{Role: openai.RoleAssistant, Content: openai.Content(content.String())},
+			FinishReason: &finish,
+		}},
+		Koala: ext,
+	})
+}
+
+func (o *openAIRouter) stream(ctx *context.Context, answer *svc.ChatAnswer) {
+	chunk := func(delta openai.Delta, finish *string, ext *openai.Extension) []byte {
+		data, _ := json.Marshal(openai.Response{
+			ID:      answer.ID,
+			Object:  openai.ObjectChunk,
+			Created: answer.Created,
+			Model:   answer.Model,
+			Choices: []openai.Choice{{Delta: &delta, FinishReason: finish}},
+			Koala:   ext,
+		})
+		return data
+	}
+
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Header("Content-Type", "text/event-stream;charset=utf-8")
+	ctx.Header("Cache-Control", "no-cache")
+
+	started := false
+	finished := false
+	ctx.Stream(func(w io.Writer) bool {
+		if finished {
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return false
+		}
+
+		if !started {
+			started = true
+			fmt.Fprintf(w, "data: %s\n\n", chunk(openai.Delta{Role: openai.RoleAssistant}, nil, nil))
+			return true
+		}
+
+		text, ok := answer.Next(ctx)
+		if ok {
+			fmt.Fprintf(w, "data: %s\n\n", chunk(openai.Delta{Content: text}, nil, nil))
+			return true
+		}
+
+		if ctx.Err() != nil {
+			return false
+		}
+
+		// 已经开始输出时无法修改状态码，按 OpenAI 的方式以 error 事件结束
+		err := answer.Err()
+		if err != nil {
+			data, _ := json.Marshal(err)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			return false
+		}
+
+		ext, err := answer.Extension(ctx)
+		if err != nil {
+			o.logger.WithContext(ctx).WithErr(err).Warn("get chat completion citations failed")
+		}
+
+		finished = true
+		finish := openai.FinishReasonStop
+		fmt.Fprintf(w, "data: %s\n\n", chunk(openai.Delta{}, &finish, ext))
+		return true
+	})
+}
@@ -0,0 +1,38 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/oss"
+	"github.com/chaitin/koalaqa/server"
+)
+
+// ossObject 本地磁盘、S3 等存储无法像 minio 一样由 nginx 直接代理，通过 /{bucket}/{path} 访问对象
+type ossObject struct {
+	handler oss.Handler
+}
+
+func (o *ossObject) Route(h server.Handler) {
+	if o.handler == nil {
+		return
+	}
+
+	for _, bucket := range o.handler.Buckets() {
+		serve := func(ctx *context.Context) {
+			o.handler.ServeObject(ctx.Writer, ctx.Request, bucket, ctx.Param("object"))
+		}
+
+		h.GET("/"+bucket+"/*object", serve)
+		h.Handle(http.MethodHead, "/"+bucket+"/*object", serve)
+	}
+}
+
+func newOSSObject(oc oss.Client) server.Router {
+	handler, _ := oc.(oss.Handler)
+	return &ossObject{handler: handler}
+}
+
+func init() {
+	registerGlobalRouter(newOSSObject)
+}
@@ -0,0 +1,339 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/chaitin/koalaqa/intercept"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/restapi"
+	"github.com/chaitin/koalaqa/pkg/trace"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+	"go.uber.org/fx"
+)
+
+const restAPIPrefix = "/api/v1"
+
+var restPathParamReg = regexp.MustCompile(`\{(\w+)\}`)
+
+type restAPIRouter struct {
+	logger       *glog.Logger
+	svcRest      *svc.RestAPI
+	interceptors []intercept.Interceptor
+	spec         *restapi.Spec
+}
+
+type restAPIIn struct {
+	fx.In
+
+	SvcRest      *svc.RestAPI
+	Interceptors []intercept.Interceptor `group:"rest_api_interceptors"`
+}
+
+// render 响应带上 ETag，命中 If-None-Match 时返回 304
+func (r *restAPIRouter) render(ctx *context.Context, obj any) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	etag := restapi.ETag(data)
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", "no-cache")
+	if restapi.Match(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// error 对外 api 使用固定的错误格式，不能使用 context.Response
+func (r *restAPIRouter) error(ctx *context.Context, err error) {
+	var restErr *restapi.Error
+	if !errors.As(err, &restErr) {
+		r.logger.WithContext(ctx).WithErr(err).Error("rest api request failed")
+		restErr = restapi.Internal("internal server error")
+	}
+
+	data, _ := json.Marshal(restErr.WithTraceID(trace.TraceIDString(ctx)))
+	ctx.Data(restErr.StatusCode(), "application/json; charset=utf-8", data)
+}
+
+func (r *restAPIRouter) bindQuery(ctx *context.Context, obj any) bool {
+	err := ctx.ShouldBindQuery(obj)
+	if err != nil {
+		r.error(ctx, restapi.BadRequest(err.Error()))
+		return false
+	}
+
+	return true
+}
+
+func (r *restAPIRouter) paramID(ctx *context.Context, key string) (uint, bool) {
+	id, err := ctx.ParamUint(key)
+	if err != nil {
+		r.error(ctx, restapi.BadRequest(err.Error()))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// OpenAPI
+// @Summary rest api v1 openapi document
+// @Tags rest_api
+// @Produce json
+// @Success 200 {object} object
+// @Router /api/v1/openapi.json [get]
+func (r *restAPIRouter) OpenAPI(ctx *context.Context) {
+	r.render(ctx, r.spec.Build())
+}
+
+// ListForums
+// @Summary rest api list forums
+// @Tags rest_api
+// @Param req query restapi.Page false "request params"
+// @Produce json
+// @Success 200 {object} restapi.List[restapi.Forum]
+// @Router /api/v1/forums [get]
+func (r *restAPIRouter) ListForums(ctx *context.Context) {
+	var req restapi.Page
+	if !r.bindQuery(ctx, &req) {
+		return
+	}
+
+	res, err := r.svcRest.ListForums(ctx, ctx.GetUser(), req)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// GetForum
+// @Summary rest api get forum
+// @Tags rest_api
+// @Param forum_id path uint true "forum id"
+// @Produce json
+// @Success 200 {object} restapi.Forum
+// @Router /api/v1/forums/{forum_id} [get]
+func (r *restAPIRouter) GetForum(ctx *context.Context) {
+	id, ok := r.paramID(ctx, "forum_id")
+	if !ok {
+		return
+	}
+
+	res, err := r.svcRest.GetForum(ctx, ctx.GetUser(), id)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// ListDiscussions
+// @Summary rest api list discussions
+// @Tags rest_api
+// @Param req query svc.RestDiscussionListReq false "request params"
+// @Produce json
+// @Success 200 {object} restapi.List[restapi.Discussion]
+// @Router /api/v1/discussions [get]
+func (r *restAPIRouter) ListDiscussions(ctx *context.Context) {
+	var req svc.RestDiscussionListReq
+	if !r.bindQuery(ctx, &req) {
+		return
+	}
+
+	res, err := r.svcRest.ListDiscussions(ctx, ctx.GetUser(), req)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// GetDiscussion
+// @Summary rest api get discussion
+// @Tags rest_api
+// @Param discussion_id path uint true "discussion id"
+// @Produce json
+// @Success 200 {object} restapi.Discussion
+// @Router /api/v1/discussions/{discussion_id} [get]
+func (r *restAPIRouter) GetDiscussion(ctx *context.Context) {
+	id, ok := r.paramID(ctx, "discussion_id")
+	if !ok {
+		return
+	}
+
+	res, err := r.svcRest.GetDiscussion(ctx, ctx.GetUser(), id)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// ListComments
+// @Summary rest api list discussion comments
+// @Tags rest_api
+// @Param discussion_id path uint true "discussion id"
+// @Param req query restapi.Page false "request params"
+// @Produce json
+// @Success 200 {object} restapi.List[restapi.Comment]
+// @Router /api/v1/discussions/{discussion_id}/comments [get]
+func (r *restAPIRouter) ListComments(ctx *context.Context) {
+	id, ok := r.paramID(ctx, "discussion_id")
+	if !ok {
+		return
+	}
+
+	var req restapi.Page
+	if !r.bindQuery(ctx, &req) {
+		return
+	}
+
+	res, err := r.svcRest.ListComments(ctx, ctx.GetUser(), id, req)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// GetUser
+// @Summary rest api get user
+// @Tags rest_api
+// @Param user_id path uint true "user id"
+// @Produce json
+// @Success 200 {object} restapi.User
+// @Router /api/v1/users/{user_id} [get]
+func (r *restAPIRouter) GetUser(ctx *context.Context) {
+	id, ok := r.paramID(ctx, "user_id")
+	if !ok {
+		return
+	}
+
+	res, err := r.svcRest.GetUser(ctx, ctx.GetUser(), id)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// ListDocuments
+// @Summary rest api list kb documents
+// @Tags rest_api
+// @Param req query svc.RestDocumentListReq false "request params"
+// @Produce json
+// @Success 200 {object} restapi.List[restapi.Document]
+// @Router /api/v1/documents [get]
+func (r *restAPIRouter) ListDocuments(ctx *context.Context) {
+	var req svc.RestDocumentListReq
+	if !r.bindQuery(ctx, &req) {
+		return
+	}
+
+	res, err := r.svcRest.ListDocuments(ctx, ctx.GetUser(), req)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// GetDocument
+// @Summary rest api get kb document
+// @Tags rest_api
+// @Param document_id path uint true "document id"
+// @Produce json
+// @Success 200 {object} restapi.Document
+// @Router /api/v1/documents/{document_id} [get]
+func (r *restAPIRouter) GetDocument(ctx *context.Context) {
+	id, ok := r.paramID(ctx, "document_id")
+	if !ok {
+		return
+	}
+
+	res, err := r.svcRest.GetDocument(ctx, ctx.GetUser(), id)
+	if err != nil {
+		r.error(ctx, err)
+		return
+	}
+
+	r.render(ctx, res)
+}
+
+// handle 注册路由的同时记录到 openapi 文档
+func (r *restAPIRouter) handle(g server.Handler, op restapi.Operation, h server.HandlerFunc) {
+	for _, match := range restPathParamReg.FindAllStringSubmatch(op.Path, -1) {
+		op.Params = append(op.Params, restapi.Param{Name: match[1], In: "path"})
+	}
+
+	r.spec.Add(op)
+	g.Handle(op.Method, restPathParamReg.ReplaceAllString(op.Path, ":$1"), h)
+}
+
+func (r *restAPIRouter) Route(h server.Handler) {
+	g := h.GroupInterceptors(restAPIPrefix, r.interceptors...)
+	g.GET("/openapi.json", r.OpenAPI)
+
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/forums", Summary: "list forums", Tag: "forum",
+		Query: restapi.Page{}, Response: restapi.Forum{}, List: true, Public: true,
+	}, r.ListForums)
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/forums/{forum_id}", Summary: "get forum", Tag: "forum",
+		Response: restapi.Forum{}, Public: true,
+	}, r.GetForum)
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/discussions", Summary: "list discussions", Tag: "discussion",
+		Query: svc.RestDiscussionListReq{}, Response: restapi.Discussion{}, List: true, Public: true,
+	}, r.ListDiscussions)
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/discussions/{discussion_id}", Summary: "get discussion", Tag: "discussion",
+		Response: restapi.Discussion{}, Public: true,
+	}, r.GetDiscussion)
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/discussions/{discussion_id}/comments", Summary: "list discussion comments", Tag: "comment",
+		Query: restapi.Page{}, Response: restapi.Comment{}, List: true, Public: true,
+	}, r.ListComments)
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/users/{user_id}", Summary: "get user", Tag: "user",
+		Response: restapi.User{}, Public: true,
+	}, r.GetUser)
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/documents", Summary: "list kb documents", Tag: "document",
+		Query: svc.RestDocumentListReq{}, Response: restapi.Document{}, List: true,
+	}, r.ListDocuments)
+	r.handle(g, restapi.Operation{
+		Method: http.MethodGet, Path: "/documents/{document_id}", Summary: "get kb document", Tag: "document",
+		Response: restapi.Document{},
+	}, r.GetDocument)
+}
+
+func newRestAPI(in restAPIIn) server.Router {
+	return &restAPIRouter{
+		logger:       glog.Module("router", "rest_api"),
+		svcRest:      in.SvcRest,
+		interceptors: in.Interceptors,
+		spec:         restapi.NewSpec("KoalaQA API", "1.0.0", restAPIPrefix),
+	}
+}
+
+func init() {
+	registerGlobalRouter(newRestAPI)
+}
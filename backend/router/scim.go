@@ -0,0 +1,371 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/chaitin/koalaqa/intercept"
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/scim"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+	"go.uber.org/fx"
+)
+
+type scimRouter struct {
+	logger       *glog.Logger
+	svcSCIM      *svc.SCIM
+	interceptors []intercept.Interceptor
+}
+
+type scimIn struct {
+	fx.In
+
+	SvcSCIM      *svc.SCIM
+	Interceptors []intercept.Interceptor `group:"scim_interceptors"`
+}
+
+func (s *scimRouter) render(ctx *context.Context, status int, obj any) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	ctx.Data(status, scim.ContentType, data)
+}
+
+// error SCIM 客户端只识别协议规定的错误格式，不能使用 context.Response
+func (s *scimRouter) error(ctx *context.Context, err error) {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		s.logger.WithContext(ctx).WithErr(err).Error("scim request failed")
+		scimErr = scim.NewError(http.StatusInternalServerError, "", "internal error")
+	}
+
+	s.render(ctx, scimErr.StatusCode(), scimErr)
+}
+
+func (s *scimRouter) bindJSON(ctx *context.Context, obj any) bool {
+	err := ctx.ShouldBindJSON(obj)
+	if err != nil {
+		s.error(ctx, scim.BadRequest(scim.ErrorTypeInvalidSyntax, err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// ServiceProviderConfig
+// @Summary scim service provider config
+// @Tags scim
+// @Produce json
+// @Success 200 {object} object
+// @Router /scim/v2/ServiceProviderConfig [get]
+func (s *scimRouter) ServiceProviderConfig(ctx *context.Context) {
+	s.render(ctx, http.StatusOK, s.svcSCIM.ServiceProviderConfig())
+}
+
+// ResourceTypes
+// @Summary scim resource types
+// @Tags scim
+// @Produce json
+// @Success 200 {object} object
+// @Router /scim/v2/ResourceTypes [get]
+func (s *scimRouter) ResourceTypes(ctx *context.Context) {
+	s.render(ctx, http.StatusOK, s.svcSCIM.ResourceTypes())
+}
+
+// ListUsers
+// @Summary scim list users
+// @Tags scim
+// @Param req query svc.SCIMListReq false "request params"
+// @Produce json
+// @Success 200 {object} scim.ListResponse[scim.User]
+// @Router /scim/v2/Users [get]
+func (s *scimRouter) ListUsers(ctx *context.Context) {
+	var req svc.SCIMListReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		s.error(ctx, scim.BadRequest(scim.ErrorTypeInvalidValue, err.Error()))
+		return
+	}
+
+	res, err := s.svcSCIM.ListUsers(ctx, req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// GetUser
+// @Summary scim get user
+// @Tags scim
+// @Param id path string true "user id"
+// @Produce json
+// @Success 200 {object} scim.User
+// @Router /scim/v2/Users/{id} [get]
+func (s *scimRouter) GetUser(ctx *context.Context) {
+	res, err := s.svcSCIM.GetUser(ctx, ctx.Param("id"))
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// CreateUser
+// @Summary scim create user
+// @Tags scim
+// @Accept json
+// @Param req body scim.User true "request params"
+// @Produce json
+// @Success 201 {object} scim.User
+// @Router /scim/v2/Users [post]
+func (s *scimRouter) CreateUser(ctx *context.Context) {
+	var req scim.User
+	if !s.bindJSON(ctx, &req) {
+		return
+	}
+
+	res, err := s.svcSCIM.CreateUser(ctx, req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusCreated, res)
+}
+
+// ReplaceUser
+// @Summary scim replace user
+// @Tags scim
+// @Accept json
+// @Param id path string true "user id"
+// @Param req body scim.User true "request params"
+// @Produce json
+// @Success 200 {object} scim.User
+// @Router /scim/v2/Users/{id} [put]
+func (s *scimRouter) ReplaceUser(ctx *context.Context) {
+	var req scim.User
+	if !s.bindJSON(ctx, &req) {
+		return
+	}
+
+	res, err := s.svcSCIM.ReplaceUser(ctx, ctx.Param("id"), req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// PatchUser
+// @Summary scim patch user
+// @Tags scim
+// @Accept json
+// @Param id path string true "user id"
+// @Param req body scim.PatchRequest true "request params"
+// @Produce json
+// @Success 200 {object} scim.User
+// @Router /scim/v2/Users/{id} [patch]
+func (s *scimRouter) PatchUser(ctx *context.Context) {
+	var req scim.PatchRequest
+	if !s.bindJSON(ctx, &req) {
+		return
+	}
+
+	res, err := s.svcSCIM.PatchUser(ctx, ctx.Param("id"), req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// DeleteUser
+// @Summary scim delete user
+// @Tags scim
+// @Param id path string true "user id"
+// @Success 204
+// @Router /scim/v2/Users/{id} [delete]
+func (s *scimRouter) DeleteUser(ctx *context.Context) {
+	err := s.svcSCIM.DeleteUser(ctx, ctx.Param("id"))
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// ListGroups
+// @Summary scim list groups
+// @Tags scim
+// @Param req query svc.SCIMListReq false "request params"
+// @Produce json
+// @Success 200 {object} scim.ListResponse[scim.Group]
+// @Router /scim/v2/Groups [get]
+func (s *scimRouter) ListGroups(ctx *context.Context) {
+	var req svc.SCIMListReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		s.error(ctx, scim.BadRequest(scim.ErrorTypeInvalidValue, err.Error()))
+		return
+	}
+
+	res, err := s.svcSCIM.ListGroups(ctx, req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// GetGroup
+// @Summary scim get group
+// @Tags scim
+// @Param id path string true "group id"
+// @Produce json
+// @Success 200 {object} scim.Group
+// @Router /scim/v2/Groups/{id} [get]
+func (s *scimRouter) GetGroup(ctx *context.Context) {
+	res, err := s.svcSCIM.GetGroup(ctx, ctx.Param("id"))
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// CreateGroup
+// @Summary scim create group
+// @Tags scim
+// @Accept json
+// @Param req body scim.Group true "request params"
+// @Produce json
+// @Success 201 {object} scim.Group
+// @Router /scim/v2/Groups [post]
+func (s *scimRouter) CreateGroup(ctx *context.Context) {
+	var req scim.Group
+	if !s.bindJSON(ctx, &req) {
+		return
+	}
+
+	res, err := s.svcSCIM.CreateGroup(ctx, req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusCreated, res)
+}
+
+// ReplaceGroup
+// @Summary scim replace group
+// @Tags scim
+// @Accept json
+// @Param id path string true "group id"
+// @Param req body scim.Group true "request params"
+// @Produce json
+// @Success 200 {object} scim.Group
+// @Router /scim/v2/Groups/{id} [put]
+func (s *scimRouter) ReplaceGroup(ctx *context.Context) {
+	var req scim.Group
+	if !s.bindJSON(ctx, &req) {
+		return
+	}
+
+	res, err := s.svcSCIM.ReplaceGroup(ctx, ctx.Param("id"), req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// PatchGroup
+// @Summary scim patch group
+// @Tags scim
+// @Accept json
+// @Param id path string true "group id"
+// @Param req body scim.PatchRequest true "request params"
+// @Produce json
+// @Success 200 {object} scim.Group
+// @Router /scim/v2/Groups/{id} [patch]
+func (s *scimRouter) PatchGroup(ctx *context.Context) {
+	var req scim.PatchRequest
+	if !s.bindJSON(ctx, &req) {
+		return
+	}
+
+	res, err := s.svcSCIM.PatchGroup(ctx, ctx.Param("id"), req)
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	s.render(ctx, http.StatusOK, res)
+}
+
+// DeleteGroup
+// @Summary scim delete group
+// @Tags scim
+// @Param id path string true "group id"
+// @Success 204
+// @Router /scim/v2/Groups/{id} [delete]
+func (s *scimRouter) DeleteGroup(ctx *context.Context) {
+	err := s.svcSCIM.DeleteGroup(ctx, ctx.Param("id"))
+	if err != nil {
+		s.error(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *scimRouter) Route(h server.Handler) {
+	g := h.GroupInterceptors("/api/scim/v2", s.interceptors...)
+	g.GET("/ServiceProviderConfig", s.ServiceProviderConfig)
+	g.GET("/ResourceTypes", s.ResourceTypes)
+	{
+		userG := g.Group("/Users")
+		userG.GET("", s.ListUsers)
+		userG.POST("", s.CreateUser)
+		userG.GET("/:id", s.GetUser)
+		userG.PUT("/:id", s.ReplaceUser)
+		userG.Handle(http.MethodPatch, "/:id", s.PatchUser)
+		userG.DELETE("/:id", s.DeleteUser)
+	}
+	{
+		groupG := g.Group("/Groups")
+		groupG.GET("", s.ListGroups)
+		groupG.POST("", s.CreateGroup)
+		groupG.GET("/:id", s.GetGroup)
+		groupG.PUT("/:id", s.ReplaceGroup)
+		groupG.Handle(http.MethodPatch, "/:id", s.PatchGroup)
+		groupG.DELETE("/:id", s.DeleteGroup)
+	}
+}
+
+func newSCIM(in scimIn) server.Router {
+	return &scimRouter{
+		logger:       glog.Module("router", "scim"),
+		svcSCIM:      in.SvcSCIM,
+		interceptors: in.Interceptors,
+	}
+}
+
+func init() {
+	registerGlobalRouter(newSCIM)
+}
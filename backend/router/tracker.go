@@ -0,0 +1,56 @@
+package router
+
+import (
+	"io"
+
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type tracker struct {
+	svcTracker *svc.Tracker
+}
+
+// Webhook
+// @Summary external tracker webhook
+// @Description receive GitHub/GitLab/Jira issue and comment events
+// @Tags tracker
+// @Param tracker_id path uint true "tracker id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /tracker/{tracker_id}/webhook [post]
+func (t *tracker) Webhook(ctx *context.Context) {
+	trackerID, err := ctx.ParamUint("tracker_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, 1<<20))
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = t.svcTracker.Webhook(ctx, trackerID, ctx.Request.Header, body)
+	if err != nil {
+		ctx.InternalError(err, "handle tracker webhook failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (t *tracker) Route(h server.Handler) {
+	g := h.Group("/tracker")
+	g.POST("/:tracker_id/webhook", t.Webhook)
+}
+
+func newTracker(tr *svc.Tracker) server.Router {
+	return &tracker{svcTracker: tr}
+}
+
+func init() {
+	registerApiNoAuthRouter(newTracker)
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/chaitin/koalaqa/model"
+	koalaCache "github.com/chaitin/koalaqa/pkg/cache"
 	koalaCfg "github.com/chaitin/koalaqa/pkg/config"
 	"github.com/chaitin/koalaqa/pkg/context"
 	"github.com/chaitin/koalaqa/server"
@@ -20,9 +23,10 @@ import (
 )
 
 type user struct {
-	expire   int
-	svcU     *svc.User
-	svcTrend *svc.Trend
+	expire     int
+	svcU       *svc.User
+	svcTrend   *svc.Trend
+	samlStates koalaCache.Cache[stateCache]
 }
 
 // Register
@@ -72,7 +76,7 @@ func (u *user) LoginMethod(ctx *context.Context) {
 // @Accept json
 // @Param req body svc.UserLoginReq true "request params"
 // @Produce json
-// @Success 200 {object} context.Response{data=string}
+// @Success 200 {object} context.Response{data=svc.UserLoginRes}
 // @Router /user/login [post]
 func (u *user) Login(ctx *context.Context) {
 	var req svc.UserLoginReq
@@ -82,23 +86,39 @@ func (u *user) Login(ctx *context.Context) {
 		return
 	}
 
-	token, err := u.svcU.Login(ctx, req, false)
+	res, err := u.svcU.Login(ctx, req, false)
 	if err != nil {
 		ctx.InternalError(err, "user login failed")
 		return
 	}
 
-	ctx.SetCookie("auth_token", token, u.expire, "/", "", false, true)
+	if res.TOTPToken != "" {
+		ctx.Success(res)
+		return
+	}
+
+	ctx.SetAuthCookie(res.Token.AccessToken, res.Token.RefreshToken, u.expire)
 	ctx.Success(nil)
 }
 
+// loginCorsRes data 保持为 access token 字符串以兼容旧版本，其余信息放在同级字段
+type loginCorsRes struct {
+	context.Response
+
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	// TOTPToken 不为空时 data 为空，需要调用 /user/login/totp 完成二次验证
+	TOTPToken  string `json:"totp_token,omitempty"`
+	TOTPEnroll bool   `json:"totp_enroll,omitempty"`
+}
+
 // LoginCors
 // @Summary user cors login
 // @Tags user
 // @Accept json
 // @Param req body svc.UserLoginReq true "request params"
 // @Produce json
-// @Success 200 {object} context.Response{data=string}
+// @Success 200 {object} loginCorsRes{data=string}
 // @Router /user/login/cors [post]
 func (u *user) LoginCors(ctx *context.Context) {
 	var req svc.UserLoginReq
@@ -108,15 +128,153 @@ func (u *user) LoginCors(ctx *context.Context) {
 		return
 	}
 
-	token, err := u.svcU.Login(ctx, req, true)
+	res, err := u.svcU.Login(ctx, req, true)
 	if err != nil {
 		ctx.InternalError(err, "user login cors failed")
 		return
 	}
 
+	data := loginCorsRes{
+		TOTPToken:  res.TOTPToken,
+		TOTPEnroll: res.TOTPEnroll,
+	}
+	if res.Token != nil {
+		data.Response = ctx.SuccessResponse(res.Token.AccessToken)
+		data.RefreshToken = res.Token.RefreshToken
+		data.ExpiresIn = res.Token.ExpiresIn
+	} else {
+		data.Response = ctx.SuccessResponse("")
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+// Refresh
+// @Summary refresh user token
+// @Tags user
+// @Accept json
+// @Param req body svc.UserRefreshReq false "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.LoginToken}
+// @Router /user/refresh [post]
+func (u *user) Refresh(ctx *context.Context) {
+	var req svc.UserRefreshReq
+	if ctx.Request.ContentLength > 0 {
+		err := ctx.ShouldBindJSON(&req)
+		if err != nil {
+			ctx.BadRequest(err)
+			return
+		}
+	}
+
+	cookie := req.RefreshToken == ""
+	if cookie {
+		req.RefreshToken, _ = ctx.Cookie(context.RefreshTokenCookie)
+		if req.RefreshToken == "" {
+			ctx.Unauthorized("refresh token is empty")
+			return
+		}
+	}
+
+	token, err := u.svcU.RefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		if cookie {
+			ctx.ClearAuthCookie()
+		}
+		ctx.Unauthorized(err.Error())
+		return
+	}
+
+	if cookie {
+		ctx.SetAuthCookie(token.AccessToken, token.RefreshToken, u.expire)
+		ctx.Success(nil)
+		return
+	}
+
 	ctx.Success(token)
 }
 
+// LoginTOTP
+// @Summary user login second step with totp
+// @Tags user
+// @Accept json
+// @Param req body svc.UserLoginTOTPReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.UserLoginTOTPRes}
+// @Router /user/login/totp [post]
+func (u *user) LoginTOTP(ctx *context.Context) {
+	var req svc.UserLoginTOTPReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := u.svcU.LoginTOTP(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "user totp login failed")
+		return
+	}
+
+	if !res.Cors() {
+		ctx.SetAuthCookie(res.Token.AccessToken, res.Token.RefreshToken, u.expire)
+		res.Token = nil
+	}
+
+	ctx.Success(res)
+}
+
+// LoginTOTPEnroll
+// @Summary user bind totp during login
+// @Tags user
+// @Accept json
+// @Param req body svc.UserLoginTOTPEnrollReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.UserTOTPSetupRes}
+// @Router /user/login/totp/enroll [post]
+func (u *user) LoginTOTPEnroll(ctx *context.Context) {
+	var req svc.UserLoginTOTPEnrollReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := u.svcU.LoginTOTPEnroll(ctx, req)
+	if err != nil {
+		ctx.InternalError(err, "user totp enroll failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// LoginLDAP
+// @Summary user ldap login
+// @Tags user
+// @Accept json
+// @Param req body svc.UserLoginLDAPReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /user/login/ldap [post]
+func (u *user) LoginLDAP(ctx *context.Context) {
+	var req svc.UserLoginLDAPReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	token, err := u.svcU.LoginLDAP(ctx, req, false)
+	if err != nil {
+		ctx.InternalError(err, "user ldap login failed")
+		return
+	}
+
+	ctx.SetAuthCookie(token.AccessToken, token.RefreshToken, u.expire)
+	ctx.Success(nil)
+}
+
 const stateKey = "third_login_state"
 
 type stateCache struct {
@@ -170,6 +328,15 @@ func (u *user) LoginThirdURL(ctx *context.Context) {
 	})
 	session.Save()
 
+	if req.Type == model.AuthTypeSAML {
+		// IdP 以跨站 POST 回调，浏览器不会携带 session cookie，需要在服务端保存 state
+		u.samlStates.Set(state, stateCache{
+			Cors:     cors,
+			Value:    state,
+			Redirect: req.Redirect,
+		})
+	}
+
 	ctx.Success(authURL)
 }
 
@@ -197,6 +364,10 @@ func (u *user) loginThirdCallback(ctx *context.Context, typ model.AuthType) {
 		return
 	}
 
+	u.loginRedirect(ctx, state, token)
+}
+
+func (u *user) loginRedirect(ctx *context.Context, state stateCache, token *svc.LoginToken) {
 	if state.Redirect == "" {
 		state.Redirect = "/"
 	}
@@ -209,11 +380,12 @@ func (u *user) loginThirdCallback(ctx *context.Context, typ model.AuthType) {
 		}
 
 		query := parseRedirect.Query()
-		query.Set("koala_cors_token", token)
+		query.Set("koala_cors_token", token.AccessToken)
+		query.Set("koala_cors_refresh_token", token.RefreshToken)
 		parseRedirect.RawQuery = query.Encode()
 		state.Redirect = parseRedirect.String()
 	} else {
-		ctx.SetCookie("auth_token", token, u.expire, "/", "", false, true)
+		ctx.SetAuthCookie(token.AccessToken, token.RefreshToken, u.expire)
 	}
 
 	ctx.Redirect(http.StatusFound, state.Redirect)
@@ -231,6 +403,51 @@ func (u *user) LoginWechatCallback(ctx *context.Context) {
 	u.loginThirdCallback(ctx, model.AuthTypeWechat)
 }
 
+func (u *user) LoginSAMLCallback(ctx *context.Context) {
+	var req svc.LoginSAMLCallbackReq
+	err := ctx.ShouldBind(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	state, ok := u.samlStates.Get(req.RelayState)
+	if ok {
+		u.samlStates.Delete(req.RelayState)
+	} else {
+		// IdP 发起的登录，RelayState 只允许作为站内跳转地址
+		state = stateCache{}
+		if strings.HasPrefix(req.RelayState, "/") && !strings.HasPrefix(req.RelayState, "//") &&
+			!strings.HasPrefix(req.RelayState, "/\\") {
+			state.Redirect = req.RelayState
+		}
+	}
+
+	token, err := u.svcU.LoginSAMLCallback(ctx, req, state.Value, state.Cors)
+	if err != nil {
+		ctx.InternalError(err, "saml callback failed")
+		return
+	}
+
+	u.loginRedirect(ctx, state, token)
+}
+
+// SAMLMetadata
+// @Summary saml service provider metadata
+// @Tags user
+// @Produce xml
+// @Success 200 {string} string
+// @Router /user/login/third/saml/metadata [get]
+func (u *user) SAMLMetadata(ctx *context.Context) {
+	data, err := u.svcU.SAMLMetadata(ctx)
+	if err != nil {
+		ctx.InternalError(err, "get saml metadata failed")
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/samlmetadata+xml", data)
+}
+
 func (u *user) VerifyWechatOfficialAccount(ctx *context.Context) {
 	notifySub, err := u.svcU.GetNotifySubByType(ctx, model.MessageNotifySubTypeWechatOfficialAccount)
 	if err != nil {
@@ -292,15 +509,21 @@ func (u *user) Route(h server.Handler) {
 	g := h.Group("/api/user")
 	g.POST("/register", u.Register)
 	g.POST("/login", u.Login)
+	g.POST("/login/ldap", u.LoginLDAP)
+	g.POST("/refresh", u.Refresh)
+	g.POST("/login/totp", u.LoginTOTP)
+	g.POST("/login/totp/enroll", u.LoginTOTPEnroll)
 	g.GET("/login_method", u.LoginMethod)
 	{
 		thirdG := g.Group("/login/third")
 		thirdG.GET("", u.LoginThirdURL)
+		thirdG.GET("/saml/metadata", u.SAMLMetadata)
 		{
 			thirdCallbackG := thirdG.Group("/callback")
 			thirdCallbackG.GET("/oidc", u.LoginOIDCCallback)
 			thirdCallbackG.GET("/we_com", u.LoginWeComCallback)
 			thirdCallbackG.GET("/wechat", u.LoginWechatCallback)
+			thirdCallbackG.POST("/saml", u.LoginSAMLCallback)
 		}
 
 	}
@@ -312,11 +535,12 @@ func (u *user) Route(h server.Handler) {
 	}
 }
 
-func newUser(cfg koalaCfg.Config, u *svc.User, trend *svc.Trend) server.Router {
+func newUser(cfg koalaCfg.Config, u *svc.User, trend *svc.Trend, cacheBackend koalaCache.Backend) server.Router {
 	return &user{
-		expire:   int(cfg.JWT.Expire),
-		svcU:     u,
-		svcTrend: trend,
+		expire:     int(cfg.JWT.Expire),
+		svcU:       u,
+		svcTrend:   trend,
+		samlStates: koalaCache.NewShared[stateCache](cacheBackend, "saml_state", time.Minute*10),
 	}
 }
 
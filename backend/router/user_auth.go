@@ -33,13 +33,13 @@ type userAuth struct {
 // @Success 200 {object} context.Response
 // @Router /user/logout [post]
 func (u *userAuth) Logout(ctx *context.Context) {
-	err := u.in.SvcU.Logout(ctx, ctx.GetUser().UID)
+	err := u.in.SvcU.Logout(ctx, ctx.GetUser().UserCore)
 	if err != nil {
 		ctx.InternalError(err, "user logout failed")
 		return
 	}
 
-	ctx.SetCookie("auth_token", "", -1, "/", "", false, true)
+	ctx.ClearAuthCookie()
 
 	ctx.Success(nil)
 }
@@ -115,6 +115,22 @@ func (u *userAuth) ListPoint(ctx *context.Context) {
 	ctx.Success(res)
 }
 
+// UploadUsage
+// @Summary user upload usage
+// @Tags user
+// @Produce json
+// @Success 200 {object} context.Response{data=model.UploadUsage}
+// @Router /user/upload/usage [get]
+func (u *userAuth) UploadUsage(ctx *context.Context) {
+	res, err := u.in.SvcUpload.Usage(ctx, ctx.GetUser().UID)
+	if err != nil {
+		ctx.InternalError(err, "get upload usage failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
 type notifyType uint
 
 const (
@@ -620,12 +636,176 @@ func (u *userAuth) NotifySubWechatOfficialAccount(ctx *context.Context) {
 	ctx.Data(http.StatusOK, "image/jpg", img)
 }
 
+// ListSession
+// @Summary list user login sessions
+// @Tags user
+// @Produce json
+// @Success 200 {object} context.Response{data=[]svc.UserSessionListItem}
+// @Router /user/session [get]
+func (u *userAuth) ListSession(ctx *context.Context) {
+	res, err := u.in.SvcSession.List(ctx, ctx.GetUser().UserCore)
+	if err != nil {
+		ctx.InternalError(err, "list user session failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// RevokeSession
+// @Summary revoke user login session
+// @Tags user
+// @Param session_id path uint true "session id"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /user/session/{session_id} [delete]
+func (u *userAuth) RevokeSession(ctx *context.Context) {
+	sessionID, err := ctx.ParamUint("session_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = u.in.SvcSession.Revoke(ctx, ctx.GetUser().UID, sessionID)
+	if err != nil {
+		ctx.InternalError(err, "revoke user session failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// RevokeOtherSession
+// @Summary revoke all login sessions except current
+// @Tags user
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /user/session [delete]
+func (u *userAuth) RevokeOtherSession(ctx *context.Context) {
+	user := ctx.GetUser()
+	err := u.in.SvcSession.RevokeAll(ctx, user.UID, user.SessionID)
+	if err != nil {
+		ctx.InternalError(err, "revoke user sessions failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// TOTPStatus
+// @Summary user totp status
+// @Tags user
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.UserTOTPStatusRes}
+// @Router /user/totp [get]
+func (u *userAuth) TOTPStatus(ctx *context.Context) {
+	res, err := u.in.SvcU.TOTPStatus(ctx, ctx.GetUser())
+	if err != nil {
+		ctx.InternalError(err, "get totp status failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// TOTPSetup
+// @Summary generate totp secret
+// @Tags user
+// @Produce json
+// @Success 200 {object} context.Response{data=svc.UserTOTPSetupRes}
+// @Router /user/totp/setup [post]
+func (u *userAuth) TOTPSetup(ctx *context.Context) {
+	res, err := u.in.SvcU.TOTPSetup(ctx, ctx.GetUser().UID)
+	if err != nil {
+		ctx.InternalError(err, "setup totp failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// TOTPEnable
+// @Summary enable totp
+// @Tags user
+// @Accept json
+// @Param req body svc.UserTOTPCodeReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=[]string}
+// @Router /user/totp/enable [post]
+func (u *userAuth) TOTPEnable(ctx *context.Context) {
+	var req svc.UserTOTPCodeReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := u.in.SvcU.TOTPEnable(ctx, ctx.GetUser().UID, req)
+	if err != nil {
+		ctx.InternalError(err, "enable totp failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// TOTPDisable
+// @Summary disable totp
+// @Tags user
+// @Accept json
+// @Param req body svc.UserTOTPCodeReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response
+// @Router /user/totp/disable [post]
+func (u *userAuth) TOTPDisable(ctx *context.Context) {
+	var req svc.UserTOTPCodeReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = u.in.SvcU.TOTPDisable(ctx, ctx.GetUser(), req)
+	if err != nil {
+		ctx.InternalError(err, "disable totp failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+// TOTPRecoveryCodes
+// @Summary regenerate totp recovery codes
+// @Tags user
+// @Accept json
+// @Param req body svc.UserTOTPCodeReq true "request params"
+// @Produce json
+// @Success 200 {object} context.Response{data=[]string}
+// @Router /user/totp/recovery_codes [post]
+func (u *userAuth) TOTPRecoveryCodes(ctx *context.Context) {
+	var req svc.UserTOTPCodeReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := u.in.SvcU.TOTPRecoveryCodes(ctx, ctx.GetUser().UID, req)
+	if err != nil {
+		ctx.InternalError(err, "regenerate recovery codes failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
 func (u *userAuth) Route(h server.Handler) {
 	g := h.Group("/user")
 	g.POST("/logout", u.Logout)
 	g.GET("", u.Detail)
 	g.PUT("", u.Update)
 	g.GET("/point", u.ListPoint)
+	g.GET("/upload/usage", u.UploadUsage)
 
 	{
 		notifyG := g.Group("/notify")
@@ -649,6 +829,22 @@ func (u *userAuth) Route(h server.Handler) {
 		}
 	}
 
+	{
+		sessionG := g.Group("/session")
+		sessionG.GET("", u.ListSession)
+		sessionG.DELETE("", u.RevokeOtherSession)
+		sessionG.DELETE("/:session_id", u.RevokeSession)
+	}
+
+	{
+		totpG := g.Group("/totp")
+		totpG.GET("", u.TOTPStatus)
+		totpG.POST("/setup", u.TOTPSetup)
+		totpG.POST("/enable", u.TOTPEnable)
+		totpG.POST("/disable", u.TOTPDisable)
+		totpG.POST("/recovery_codes", u.TOTPRecoveryCodes)
+	}
+
 	{
 		qrG := g.Group("/quick_reply")
 		qrG.GET("", u.QuickReplyList)
@@ -665,10 +861,12 @@ func (u *userAuth) Route(h server.Handler) {
 type userAuthIn struct {
 	fx.In
 
-	SvcUserQR *svc.UserQuickReply
-	SvcU      *svc.User
-	SvcNotify *svc.MessageNotify
-	Sub       mq.SubscriberWithHandler `name:"memory_mq"`
+	SvcUserQR  *svc.UserQuickReply
+	SvcU       *svc.User
+	SvcSession *svc.UserSession
+	SvcNotify  *svc.MessageNotify
+	SvcUpload  *svc.Upload
+	Sub        mq.SubscriberWithHandler `name:"memory_mq"`
 }
 
 func newUserAuth(in userAuthIn) server.Router {
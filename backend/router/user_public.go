@@ -9,6 +9,7 @@ import (
 type userPublic struct {
 	svcU     *svc.User
 	svcTrend *svc.Trend
+	svcBadge *svc.Badge
 }
 
 // Statistics
@@ -58,16 +59,41 @@ func (u *userPublic) TrendList(ctx *context.Context) {
 	ctx.Success(res)
 }
 
+// BadgeList
+// @Summary list user badge
+// @Tags user
+// @Param user_id path uint true "user id"
+// @Produce json
+// @Success 200 {object} context.Response{data=model.ListRes{items=[]model.UserBadgeItem}}
+// @Router /user/{user_id}/badge [get]
+func (u *userPublic) BadgeList(ctx *context.Context) {
+	userID, err := ctx.ParamUint("user_id")
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := u.svcBadge.ListUser(ctx, userID)
+	if err != nil {
+		ctx.InternalError(err, "list user badge failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
 func (u *userPublic) Route(h server.Handler) {
 	g := h.Group("/user")
 	g.GET("/:user_id", u.Statistics)
+	g.GET("/:user_id/badge", u.BadgeList)
 	g.GET("/trend", u.TrendList)
 }
 
-func newUserPublic(u *svc.User, trend *svc.Trend) server.Router {
+func newUserPublic(u *svc.User, trend *svc.Trend, badge *svc.Badge) server.Router {
 	return &userPublic{
 		svcU:     u,
 		svcTrend: trend,
+		svcBadge: badge,
 	}
 }
 
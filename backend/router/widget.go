@@ -0,0 +1,122 @@
+package router
+
+import (
+	"github.com/chaitin/koalaqa/pkg/context"
+	"github.com/chaitin/koalaqa/server"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type widget struct {
+	svcSite *svc.WidgetSite
+}
+
+func newWidget(site *svc.WidgetSite) server.Router {
+	return &widget{svcSite: site}
+}
+
+func init() {
+	registerGlobalRouter(newWidget)
+}
+
+// 挂件接口使用 site key 访问，不依赖社区登录和公开访问设置，
+// 跨域来源在 cors 拦截器中按站点配置校验
+func (w *widget) Route(h server.Handler) {
+	g := h.Group("/api/widget/:site_key")
+	g.GET("", w.Config)
+	g.GET("/session", w.Session)
+	g.POST("/ask", w.Ask)
+	g.POST("/ask/stop", w.StopAsk)
+}
+
+// Config
+// @Summary widget site config
+// @Tags widget
+// @Produce json
+// @Param site_key path string true "site key"
+// @Success 200 {object} context.Response{data=svc.WidgetSiteConfig}
+// @Router /widget/{site_key} [get]
+func (w *widget) Config(ctx *context.Context) {
+	res, err := w.svcSite.Config(ctx, ctx.Param("site_key"))
+	if err != nil {
+		ctx.InternalError(err, "get widget config failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Session
+// @Summary create or get last anonymous widget session
+// @Tags widget
+// @Produce json
+// @Param site_key path string true "site key"
+// @Param req query svc.WidgetSessionReq false "req params"
+// @Success 200 {object} context.Response{data=string}
+// @Router /widget/{site_key}/session [get]
+func (w *widget) Session(ctx *context.Context) {
+	var req svc.WidgetSessionReq
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	res, err := w.svcSite.Session(ctx, ctx.Param("site_key"), req)
+	if err != nil {
+		ctx.InternalError(err, "get widget session failed")
+		return
+	}
+
+	ctx.Success(res)
+}
+
+// Ask
+// @Summary widget ask
+// @Tags widget
+// @Accept json
+// @Produce text/event-stream
+// @Param site_key path string true "site key"
+// @Param req body svc.WidgetAskReq true "req params"
+// @Router /widget/{site_key}/ask [post]
+func (w *widget) Ask(ctx *context.Context) {
+	var req svc.WidgetAskReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	stream, err := w.svcSite.Ask(ctx, ctx.Param("site_key"), req)
+	if err != nil {
+		ctx.InternalError(err, "get widget ask stream failed")
+		return
+	}
+
+	renderAskStream(ctx, stream)
+}
+
+// StopAsk
+// @Summary stop widget ask
+// @Tags widget
+// @Accept json
+// @Produce json
+// @Param site_key path string true "site key"
+// @Param req body svc.StopAskSessionReq true "req params"
+// @Success 200 {object} context.Response
+// @Router /widget/{site_key}/ask/stop [post]
+func (w *widget) StopAsk(ctx *context.Context) {
+	var req svc.StopAskSessionReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.BadRequest(err)
+		return
+	}
+
+	err = w.svcSite.StopAsk(ctx, ctx.Param("site_key"), req)
+	if err != nil {
+		ctx.InternalError(err, "stop widget ask failed")
+		return
+	}
+
+	ctx.Success(nil)
+}
@@ -0,0 +1,62 @@
+package sub
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/mq"
+	"github.com/chaitin/koalaqa/pkg/topic"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+// emailComment 邮件发布的帖子有新回答时回信给提问者
+type emailComment struct {
+	logger *glog.Logger
+	email  *svc.Email
+}
+
+func newEmailComment(email *svc.Email) *emailComment {
+	return &emailComment{
+		logger: glog.Module("sub", "email_comment"),
+		email:  email,
+	}
+}
+
+func (e *emailComment) MsgType() mq.Message {
+	return topic.MsgCommentChange{}
+}
+
+func (e *emailComment) Topic() mq.Topic {
+	return topic.TopicCommentChange
+}
+
+func (e *emailComment) Group() string {
+	return "koala_comment_change_email"
+}
+
+func (e *emailComment) AckWait() time.Duration {
+	return time.Minute * 2
+}
+
+func (e *emailComment) Concurrent() uint {
+	return 2
+}
+
+func (e *emailComment) Handle(ctx context.Context, msg mq.Message) error {
+	data := msg.(topic.MsgCommentChange)
+	if data.OP != topic.OPInsert {
+		return nil
+	}
+
+	logger := e.logger.WithContext(ctx).With("msg", data)
+	logger.Debug("receive comment insert msg")
+
+	err := e.email.NotifyComment(ctx, data.CommID)
+	if err != nil {
+		logger.WithErr(err).Warn("notify comment email failed")
+		return err
+	}
+
+	return nil
+}
@@ -22,6 +22,10 @@ var Module = fx.Options(
 	fx.Provide(mq.AsSubscriber(newUserReview)),
 	fx.Provide(mq.AsSubscriber(NewCommentSummary)),
 	fx.Provide(mq.AsSubscriber(newUserPoint)),
+	fx.Provide(mq.AsSubscriber(newUserBadge)),
+	fx.Provide(mq.AsSubscriber(newTrackerIssue)),
+	fx.Provide(mq.AsSubscriber(newTrackerComment)),
+	fx.Provide(mq.AsSubscriber(newEmailComment)),
 	fx.Provide(mq.AsSubscriber(newDiscUserPoint)),
 	fx.Provide(mq.AsSubscriber(NewDiscReindex)),
 	fx.Provide(mq.AsSubscriber(newRagDoc)),
@@ -21,6 +21,7 @@ type messageNotifyIn struct {
 
 	Bot        *svc.Bot
 	Disc       *svc.Discussion
+	DiscRepo   *repo.Discussion
 	NotifySub  *svc.MessageNotifySub
 	DiscFollow *repo.DiscussionFollow
 	User       *repo.User
@@ -33,6 +34,7 @@ type messageNotify struct {
 	logger     *glog.Logger
 	bot        *svc.Bot
 	disc       *svc.Discussion
+	discRepo   *repo.Discussion
 	notifySub  *svc.MessageNotifySub
 	discFollow *repo.DiscussionFollow
 	user       *repo.User
@@ -50,6 +52,7 @@ func newMessageNotify(in messageNotifyIn) *messageNotify {
 		comment:    in.Comment,
 		mn:         in.Mn,
 		disc:       in.Disc,
+		discRepo:   in.DiscRepo,
 		pub:        in.Pub,
 		natsPub:    in.NatsPub,
 		discFollow: in.DiscFollow,
@@ -87,7 +90,7 @@ func (mn *messageNotify) Handle(ctx context.Context, msg mq.Message) error {
 		return nil
 	}
 
-	if data.FromID == data.ToID || (data.FromID == 0 && data.Type != model.MsgNotifyTypeUserPoint) ||
+	if data.FromID == data.ToID || (data.FromID == 0 && data.Type != model.MsgNotifyTypeUserPoint && data.Type != model.MsgNotifyTypeBadge) ||
 		(data.ToID == bot.UserID && !slices.Contains([]model.MsgNotifyType{model.MsgNotifyTypeDislikeComment, model.MsgNotifyTypeBotUnknown}, data.Type)) {
 		logger.With("msg", data).With("bot_user_id", bot.UserID).Debug("ignore message notify")
 		return nil
@@ -131,6 +134,8 @@ func (mn *messageNotify) Handle(ctx context.Context, msg mq.Message) error {
 			ParentComment: util.TruncateString(parentComment, 50),
 		},
 		UserPointHeader: data.UserPointHeader,
+		BadgeHeader:     data.BadgeHeader,
+		IssueHeader:     data.IssueHeader,
 		Type:            data.Type,
 		FromID:          data.FromID,
 		FromName:        fromUser.Name,
@@ -164,8 +169,8 @@ func (mn *messageNotify) Handle(ctx context.Context, msg mq.Message) error {
 			}
 
 			return nil
-		case model.MsgNotifyTypeUserPoint:
-			// 用户获得积分，机器人不需要通知到管理员
+		case model.MsgNotifyTypeUserPoint, model.MsgNotifyTypeBadge:
+			// 用户获得积分或徽章，机器人不需要通知到管理员
 			return nil
 		}
 
@@ -191,14 +196,25 @@ func (mn *messageNotify) Handle(ctx context.Context, msg mq.Message) error {
 		}
 	} else if data.ToID == 0 {
 		switch data.Type {
-		case model.MsgNotifyTypeIssueInProgress, model.MsgNotifyTypeIssueResolved:
+		case model.MsgNotifyTypeIssueInProgress, model.MsgNotifyTypeIssueResolved, model.MsgNotifyTypeIssueStateChange:
 			userIDs, err := mn.discFollow.ListUserID(ctx, data.DiscussID)
 			if err != nil {
 				logger.WithErr(err).Warn("get disc follow user id failed")
 				return nil
 			}
 
+			// Issue 处理人与关联帖子的作者也需要通知
+			watcherIDs, err := mn.discRepo.IssueWatcherIDs(ctx, data.DiscussID)
+			if err != nil {
+				logger.WithErr(err).Warn("get issue watcher id failed")
+			}
+			userIDs = append(userIDs, watcherIDs...)
+
 			for _, userID := range userIDs {
+				if _, ok := topics[userID]; ok || userID == data.FromID || userID == bot.UserID {
+					continue
+				}
+
 				dbMessageNotify = append(dbMessageNotify, model.MessageNotify{
 					UserID:              userID,
 					MessageNotifyCommon: common,
@@ -0,0 +1,114 @@
+package sub
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/mq"
+	"github.com/chaitin/koalaqa/pkg/topic"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+// trackerIssue 新建 Issue 时同步到外部系统
+type trackerIssue struct {
+	logger  *glog.Logger
+	tracker *svc.Tracker
+}
+
+func newTrackerIssue(tracker *svc.Tracker) *trackerIssue {
+	return &trackerIssue{
+		logger:  glog.Module("sub", "tracker_issue"),
+		tracker: tracker,
+	}
+}
+
+func (t *trackerIssue) MsgType() mq.Message {
+	return topic.MsgDiscChange{}
+}
+
+func (t *trackerIssue) Topic() mq.Topic {
+	return topic.TopicDiscChange
+}
+
+func (t *trackerIssue) Group() string {
+	return "koala_discussion_change_tracker"
+}
+
+func (t *trackerIssue) AckWait() time.Duration {
+	return time.Minute * 2
+}
+
+func (t *trackerIssue) Concurrent() uint {
+	return 2
+}
+
+func (t *trackerIssue) Handle(ctx context.Context, msg mq.Message) error {
+	data := msg.(topic.MsgDiscChange)
+	if data.OP != topic.OPInsert || data.Type != model.DiscussionTypeIssue {
+		return nil
+	}
+
+	logger := t.logger.WithContext(ctx).With("msg", data)
+	logger.Debug("receive issue insert msg")
+
+	err := t.tracker.AutoCreateIssue(ctx, data.DiscID)
+	if err != nil {
+		logger.WithErr(err).Warn("auto create tracker issue failed")
+		return err
+	}
+
+	return nil
+}
+
+// trackerComment 新评论同步到外部 Issue
+type trackerComment struct {
+	logger  *glog.Logger
+	tracker *svc.Tracker
+}
+
+func newTrackerComment(tracker *svc.Tracker) *trackerComment {
+	return &trackerComment{
+		logger:  glog.Module("sub", "tracker_comment"),
+		tracker: tracker,
+	}
+}
+
+func (t *trackerComment) MsgType() mq.Message {
+	return topic.MsgCommentChange{}
+}
+
+func (t *trackerComment) Topic() mq.Topic {
+	return topic.TopicCommentChange
+}
+
+func (t *trackerComment) Group() string {
+	return "koala_comment_change_tracker"
+}
+
+func (t *trackerComment) AckWait() time.Duration {
+	return time.Minute * 2
+}
+
+func (t *trackerComment) Concurrent() uint {
+	return 2
+}
+
+func (t *trackerComment) Handle(ctx context.Context, msg mq.Message) error {
+	data := msg.(topic.MsgCommentChange)
+	if data.OP != topic.OPInsert {
+		return nil
+	}
+
+	logger := t.logger.WithContext(ctx).With("msg", data)
+	logger.Debug("receive comment insert msg")
+
+	err := t.tracker.SyncComment(ctx, data.CommID)
+	if err != nil {
+		logger.WithErr(err).Warn("sync tracker comment failed")
+		return err
+	}
+
+	return nil
+}
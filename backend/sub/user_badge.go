@@ -0,0 +1,59 @@
+package sub
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/mq"
+	"github.com/chaitin/koalaqa/pkg/topic"
+	"github.com/chaitin/koalaqa/svc"
+)
+
+type userBadge struct {
+	logger *glog.Logger
+
+	badge *svc.Badge
+}
+
+func newUserBadge(badge *svc.Badge) *userBadge {
+	return &userBadge{
+		logger: glog.Module("sub", "user_badge"),
+		badge:  badge,
+	}
+}
+
+func (u *userBadge) MsgType() mq.Message {
+	return topic.MsgUserBadge{}
+}
+
+func (u *userBadge) Topic() mq.Topic {
+	return topic.TopicUserBadge
+}
+
+func (u *userBadge) Group() string {
+	return "koala_user_badge"
+}
+
+func (u *userBadge) AckWait() time.Duration {
+	return time.Minute * 1
+}
+
+func (u *userBadge) Concurrent() uint {
+	return 1
+}
+
+func (u *userBadge) Handle(ctx context.Context, msg mq.Message) error {
+	data := msg.(topic.MsgUserBadge)
+
+	logger := u.logger.WithContext(ctx).With("msg", data)
+	logger.Debug("receive user badge msg")
+
+	err := u.badge.Evaluate(ctx, data.UserID)
+	if err != nil {
+		logger.WithErr(err).Warn("evaluate user badge failed")
+		return err
+	}
+
+	return nil
+}
@@ -9,19 +9,22 @@ import (
 	"github.com/chaitin/koalaqa/pkg/mq"
 	"github.com/chaitin/koalaqa/pkg/topic"
 	"github.com/chaitin/koalaqa/repo"
+	"github.com/chaitin/koalaqa/svc"
 )
 
 type userPoint struct {
 	logger *glog.Logger
 
 	userPoint *repo.UserPointRecord
+	pointRule *svc.UserPointRule
 	pub       mq.Publisher
 }
 
-func newUserPoint(up *repo.UserPointRecord, pub mq.Publisher) *userPoint {
+func newUserPoint(up *repo.UserPointRecord, rule *svc.UserPointRule, pub mq.Publisher) *userPoint {
 	return &userPoint{
 		logger:    glog.Module("sub", "user_point"),
 		userPoint: up,
+		pointRule: rule,
 		pub:       pub,
 	}
 }
@@ -53,16 +56,24 @@ func (u *userPoint) Handle(ctx context.Context, msg mq.Message) error {
 
 	logger.Debug("receive user point msg")
 
+	sysPoint, err := u.pointRule.GetSystem(ctx)
+	if err != nil {
+		logger.WithErr(err).Error("get system user point failed")
+		return err
+	}
+
 	var (
 		todayAddPoint int
 		addPoint      int
 	)
-	err := u.userPoint.CreateRecord(ctx, data.UserPointRecordInfo, data.Revoke, &todayAddPoint, &addPoint)
+	err = u.userPoint.CreateRecord(ctx, data.UserPointRecordInfo, data.Revoke, sysPoint.DailyCap, &todayAddPoint, &addPoint)
 	if err != nil {
 		logger.WithErr(err).Error("create user point record failed")
 		return err
 	}
 
+	u.pub.Publish(ctx, topic.TopicUserBadge, topic.MsgUserBadge{UserID: data.UserID})
+
 	if addPoint > 0 {
 		point := 0
 		switch {
@@ -2,6 +2,7 @@ package svc
 
 import (
 	"context"
+	"errors"
 
 	"github.com/chaitin/koalaqa/model"
 	"github.com/chaitin/koalaqa/pkg/util"
@@ -10,11 +11,13 @@ import (
 
 type APIToken struct {
 	apiToken *repo.APIToken
+	user     *repo.User
 }
 
-func newAPIToken(apiToken *repo.APIToken) *APIToken {
+func newAPIToken(apiToken *repo.APIToken, user *repo.User) *APIToken {
 	return &APIToken{
 		apiToken: apiToken,
+		user:     user,
 	}
 }
 
@@ -30,12 +33,34 @@ func (a *APIToken) List(ctx context.Context) (*model.ListRes[model.APIToken], er
 
 type APITokenCreateReq struct {
 	Name string `json:"name" binding:"required"`
+	// Scopes 为空时只能访问管理接口
+	Scopes model.StringArray `json:"scopes" binding:"dive,oneof=admin rest_api scim openai mcp:read mcp:write"`
+	// UserID token 代表的用户，为空时为创建者
+	UserID uint `json:"user_id"`
 }
 
-func (a *APIToken) Create(ctx context.Context, req APITokenCreateReq) (uint, error) {
+func (a *APIToken) Create(ctx context.Context, creator uint, req APITokenCreateReq) (uint, error) {
+	if len(req.Scopes) == 0 {
+		req.Scopes = model.StringArray{model.APITokenScopeAdmin}
+	}
+
+	if req.UserID == 0 {
+		req.UserID = creator
+	} else {
+		exist, err := a.user.ExistByID(ctx, req.UserID)
+		if err != nil {
+			return 0, err
+		}
+		if !exist {
+			return 0, errors.New("user not exist")
+		}
+	}
+
 	apiToken := model.APIToken{
-		Name:  req.Name,
-		Token: util.RandomString(32),
+		Name:   req.Name,
+		Token:  util.RandomString(32),
+		Scopes: req.Scopes,
+		UserID: req.UserID,
 	}
 	err := a.apiToken.Create(ctx, &apiToken)
 	if err != nil {
@@ -0,0 +1,187 @@
+package svc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+type AskFeedback struct {
+	repoFeedback *repo.AskFeedback
+	repoSession  *repo.AskSession
+	repoFunnel   *repo.AskFunnel
+	svcDoc       *KBDocument
+	logger       *glog.Logger
+}
+
+type AskFeedbackReq struct {
+	SessionID string `json:"session_id" binding:"required,uuid"`
+	// AskID 被评价的 AI 回答，为空时评价会话中最后一条 AI 回答
+	AskID   uint                    `json:"ask_id"`
+	State   model.AskFunnelFeedback `json:"state" binding:"required,oneof=1 2"`
+	Reasons model.Int64Array        `json:"reasons" binding:"omitempty,dive,min=1,max=4"`
+	Comment string                  `json:"comment" binding:"max=1000"`
+}
+
+func (a *AskFeedback) Feedback(ctx context.Context, uid uint, req AskFeedbackReq) error {
+	query := []repo.QueryOptFunc{
+		repo.QueryWithEqual("uuid", req.SessionID),
+		repo.QueryWithEqual("user_id", uid),
+		repo.QueryWithEqual("bot", true),
+		repo.QueryWithOrderBy("created_at DESC, id DESC"),
+	}
+	if req.AskID > 0 {
+		query = append(query, repo.QueryWithEqual("id", req.AskID))
+	}
+
+	var answer model.AskSession
+	err := a.repoSession.Get(ctx, &answer, query...)
+	if err != nil {
+		return err
+	}
+	if answer.ID == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	if req.State == model.AskFunnelFeedbackLike {
+		req.Reasons = nil
+	}
+
+	err = a.repoFeedback.Upsert(ctx, &model.AskFeedback{
+		AskID:     answer.ID,
+		SessionID: answer.UUID,
+		UserID:    uid,
+		State:     req.State,
+		Reasons:   req.Reasons,
+		Comment:   req.Comment,
+		DocIDs:    answer.DocIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = a.repoFunnel.Feedback(ctx, answer.UUID, req.State)
+	if err != nil {
+		a.logger.WithContext(ctx).WithErr(err).With("session_id", answer.UUID).Warn("update ask funnel feedback failed")
+	}
+
+	return nil
+}
+
+type AskBotFeedbackReq struct {
+	SessionID string                  `form:"session_id" binding:"required,uuid"`
+	State     model.AskFunnelFeedback `form:"state" binding:"required,oneof=1 2"`
+}
+
+// BotFeedback 钉钉、企业微信等机器人回答的评价，机器人会话没有登录用户，仅允许评价机器人来源的会话
+func (a *AskFeedback) BotFeedback(ctx context.Context, req AskBotFeedbackReq) error {
+	exist, err := a.repoSession.Exist(ctx,
+		repo.QueryWithEqual("uuid", req.SessionID),
+		repo.QueryWithEqual("user_id", 0),
+		repo.QueryWithEqual("source", model.AskSessionSourceBot),
+		repo.QueryWithEqual("bot", true),
+	)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return database.ErrRecordNotFound
+	}
+
+	return a.Feedback(ctx, 0, AskFeedbackReq{
+		SessionID: req.SessionID,
+		State:     req.State,
+	})
+}
+
+type AskFeedbackListReq struct {
+	*model.Pagination
+
+	State  *model.AskFunnelFeedback `form:"state"`
+	Status *model.AskFeedbackStatus `form:"status"`
+	Reason *model.AskFeedbackReason `form:"reason"`
+}
+
+func (a *AskFeedback) List(ctx context.Context, req AskFeedbackListReq) (*model.ListRes[model.AskFeedbackListItem], error) {
+	query := []repo.QueryOptFunc{
+		repo.QueryWithEqual("ask_feedbacks.state", req.State),
+		repo.QueryWithEqual("ask_feedbacks.status", req.Status),
+		repo.QueryWithEqual("ask_feedbacks.reasons", req.Reason, repo.EqualOPValIn),
+	}
+
+	var res model.ListRes[model.AskFeedbackListItem]
+	err := a.repoFeedback.ListItem(ctx, &res.Items, append(query,
+		repo.QueryWithOrderBy("ask_feedbacks.updated_at DESC, ask_feedbacks.id DESC"),
+		repo.QueryWithPagination(req.Pagination),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.repoFeedback.CountItem(ctx, &res.Total, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (a *AskFeedback) Ignore(ctx context.Context, reviewerID uint, id uint) error {
+	return a.repoFeedback.Update(ctx, map[string]any{
+		"status":      model.AskFeedbackStatusIgnored,
+		"reviewer_id": reviewerID,
+	}, repo.QueryWithEqual("id", id))
+}
+
+type AskFeedbackQAReq struct {
+	DocCreateQAReq
+
+	KBID uint `json:"kb_id" binding:"required"`
+}
+
+// CreateQA 根据差评回答创建修正后的问答对
+func (a *AskFeedback) CreateQA(ctx context.Context, reviewerID uint, id uint, req AskFeedbackQAReq) (uint, error) {
+	var feedback model.AskFeedback
+	err := a.repoFeedback.GetByID(ctx, &feedback, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if feedback.Status == model.AskFeedbackStatusCorrected {
+		return 0, errors.New("feedback already corrected")
+	}
+
+	qaID, err := a.svcDoc.CreateQA(ctx, req.KBID, req.DocCreateQAReq)
+	if err != nil {
+		return 0, err
+	}
+
+	err = a.repoFeedback.Update(ctx, map[string]any{
+		"status":      model.AskFeedbackStatusCorrected,
+		"reviewer_id": reviewerID,
+		"qa_id":       qaID,
+	}, repo.QueryWithEqual("id", id))
+	if err != nil {
+		return 0, err
+	}
+
+	return qaID, nil
+}
+
+func newAskFeedback(feedback *repo.AskFeedback, session *repo.AskSession, funnel *repo.AskFunnel, doc *KBDocument) *AskFeedback {
+	return &AskFeedback{
+		repoFeedback: feedback,
+		repoSession:  session,
+		repoFunnel:   funnel,
+		svcDoc:       doc,
+		logger:       glog.Module("svc", "ask_feedback"),
+	}
+}
+
+func init() {
+	registerSvc(newAskFeedback)
+}
@@ -0,0 +1,275 @@
+package svc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+type AskFunnel struct {
+	repoFunnel    *repo.AskFunnel
+	repoForum     *repo.Forum
+	repoGroupItem *repo.GroupItem
+}
+
+// AskFunnelReq 按首次提问时间统计，不支持标签筛选
+type AskFunnelReq struct {
+	StatReq
+
+	Source *model.AskSessionSource `form:"source" binding:"omitempty,max=4"`
+}
+
+func (r AskFunnelReq) query() []repo.QueryOptFunc {
+	query := []repo.QueryOptFunc{
+		repo.QueryWithEqual("created_at", r.beginTime(), repo.EqualOPGTE),
+		repo.QueryWithEqual("created_at", r.endTime(), repo.EqualOPLT),
+		repo.QueryWithEqual("source", r.Source),
+	}
+	if r.ForumID > 0 {
+		query = append(query, repo.QueryWithEqual("forum_id", r.ForumID))
+	}
+	if len(r.GroupIDs) > 0 {
+		query = append(query, repo.QueryWithEqual("group_ids", r.GroupIDs, repo.EqualOPContainAny))
+	}
+
+	return query
+}
+
+func (r AskFunnelReq) previous() AskFunnelReq {
+	r.StatReq = r.StatReq.previous()
+	return r
+}
+
+type AskFunnelReason struct {
+	Reason model.AskEscalateReason `json:"reason"`
+	Name   string                  `json:"name"`
+	Count  int64                   `json:"count"`
+}
+
+type AskFunnelRes struct {
+	model.AskFunnelStat
+
+	Reasons  []AskFunnelReason `json:"reasons"`
+	Previous *AskFunnelRes     `json:"previous,omitempty"`
+}
+
+// Stat 智能问答漏斗：提问 -> AI 回答 -> 用户反馈 -> 转人工 -> 人工回答 -> 采纳
+func (a *AskFunnel) Stat(ctx context.Context, req AskFunnelReq) (*AskFunnelRes, error) {
+	var res AskFunnelRes
+	err := a.repoFunnel.Stat(ctx, &res.AskFunnelStat, req.query()...)
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []model.Count[model.AskEscalateReason]
+	err = a.repoFunnel.Reasons(ctx, &reasons, req.query()...)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Reasons = make([]AskFunnelReason, len(reasons))
+	for i, reason := range reasons {
+		res.Reasons[i] = AskFunnelReason{
+			Reason: reason.Key,
+			Name:   reason.Key.Name(),
+			Count:  reason.Count,
+		}
+	}
+
+	if req.Compare {
+		res.Previous, err = a.Stat(ctx, req.previous())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
+}
+
+type AskFunnelTrendReq struct {
+	AskFunnelReq
+
+	StatGroup StateTrendGroup `form:"stat_group" binding:"required,min=1,max=4"`
+}
+
+type AskFunnelTrendRes struct {
+	model.ListRes[model.AskFunnelTrend]
+
+	Previous []model.AskFunnelTrend `json:"previous,omitempty"`
+}
+
+func (a *AskFunnel) trend(ctx context.Context, req AskFunnelTrendReq) ([]model.AskFunnelTrend, error) {
+	var unit string
+	switch req.StatGroup {
+	case StateTrendGroupHour:
+		unit = "hour"
+	case StateTrendGroupDay:
+		unit = "day"
+	case StateTrendGroupWeek:
+		unit = "week"
+	case StateTrendGroupMonth:
+		unit = "month"
+	default:
+		return nil, errors.New("unsupported stat group")
+	}
+
+	return a.repoFunnel.Trend(ctx, unit, req.query()...)
+}
+
+func (a *AskFunnel) Trend(ctx context.Context, req AskFunnelTrendReq) (*AskFunnelTrendRes, error) {
+	var (
+		res AskFunnelTrendRes
+		err error
+	)
+
+	res.Items, err = a.trend(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	res.Total = int64(len(res.Items))
+
+	if req.Compare {
+		prevReq := req
+		prevReq.AskFunnelReq = req.previous()
+		res.Previous, err = a.trend(ctx, prevReq)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
+}
+
+type AskFunnelDimension uint
+
+const (
+	AskFunnelDimensionForum AskFunnelDimension = iota + 1
+	AskFunnelDimensionGroup
+	AskFunnelDimensionSource
+)
+
+type AskFunnelBreakdownReq struct {
+	AskFunnelReq
+
+	Dimension AskFunnelDimension `form:"dimension" binding:"required,min=1,max=3"`
+}
+
+type AskFunnelBreakdownRes struct {
+	Items    []model.AskFunnelBreakdown `json:"items"`
+	Previous []model.AskFunnelBreakdown `json:"previous,omitempty"`
+}
+
+func (a *AskFunnel) breakdownNames(ctx context.Context, dim AskFunnelDimension, items []model.AskFunnelBreakdown) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	names := make(map[uint]string)
+	ids := make(model.Int64Array, len(items))
+	for i := range items {
+		ids[i] = int64(items[i].ID)
+	}
+	query := repo.QueryWithEqual("id", ids, repo.EqualOPEqAny)
+
+	switch dim {
+	case AskFunnelDimensionForum:
+		var forums []model.Forum
+		err := a.repoForum.List(ctx, &forums, query)
+		if err != nil {
+			return err
+		}
+
+		for _, forum := range forums {
+			names[forum.ID] = forum.Name
+		}
+	case AskFunnelDimensionGroup:
+		var groupItems []model.GroupItem
+		err := a.repoGroupItem.List(ctx, &groupItems, query)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range groupItems {
+			names[item.ID] = item.Name
+		}
+	case AskFunnelDimensionSource:
+		for _, item := range items {
+			names[item.ID] = model.AskSessionSource(item.ID).Name()
+		}
+	}
+
+	for i := range items {
+		items[i].Name = names[items[i].ID]
+	}
+
+	return nil
+}
+
+func (a *AskFunnel) breakdown(ctx context.Context, req AskFunnelBreakdownReq) ([]model.AskFunnelBreakdown, error) {
+	var column string
+	switch req.Dimension {
+	case AskFunnelDimensionForum:
+		// 未转人工的会话没有板块，id 为 0
+		column = "forum_id"
+	case AskFunnelDimensionGroup:
+		column = "unnest(group_ids)"
+	case AskFunnelDimensionSource:
+		column = "source"
+	default:
+		return nil, errors.New("unsupported ask funnel dimension")
+	}
+
+	items, err := a.repoFunnel.Breakdown(ctx, column, req.query()...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.breakdownNames(ctx, req.Dimension, items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Breakdown 按板块、分类或会话来源拆分漏斗
+func (a *AskFunnel) Breakdown(ctx context.Context, req AskFunnelBreakdownReq) (*AskFunnelBreakdownRes, error) {
+	var (
+		res AskFunnelBreakdownRes
+		err error
+	)
+
+	res.Items, err = a.breakdown(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Compare {
+		prevReq := req
+		prevReq.AskFunnelReq = req.previous()
+		res.Previous, err = a.breakdown(ctx, prevReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Previous == nil {
+			res.Previous = []model.AskFunnelBreakdown{}
+		}
+	}
+
+	return &res, nil
+}
+
+func newAskFunnel(funnel *repo.AskFunnel, forum *repo.Forum, groupItem *repo.GroupItem) *AskFunnel {
+	return &AskFunnel{
+		repoFunnel:    funnel,
+		repoForum:     forum,
+		repoGroupItem: groupItem,
+	}
+}
+
+func init() {
+	registerSvc(newAskFunnel)
+}
@@ -2,8 +2,10 @@ package svc
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/cache"
 	"github.com/chaitin/koalaqa/pkg/glog"
 	"github.com/chaitin/koalaqa/pkg/third_auth"
 	"github.com/chaitin/koalaqa/repo"
@@ -14,19 +16,22 @@ type Auth struct {
 	repoSys       *repo.System
 	svcPublicAddr *PublicAddress
 	authMgmt      *third_auth.Manager
+	broadcaster   cache.Broadcaster
 	logger        *glog.Logger
 
-	cacheAuth *model.Auth
+	cacheAuth atomic.Pointer[model.Auth]
 }
 
+const cacheTopicAuth = "auth"
+
 type AuthInfo struct {
 	Type model.AuthType `json:"type"`
 	URL  string         `json:"url"`
 }
 
 func (l *Auth) Get(ctx context.Context) (*model.Auth, error) {
-	if l.cacheAuth != nil {
-		return l.cacheAuth, nil
+	if auth := l.cacheAuth.Load(); auth != nil {
+		return auth, nil
 	}
 
 	var data model.Auth
@@ -35,7 +40,7 @@ func (l *Auth) Get(ctx context.Context) (*model.Auth, error) {
 		return nil, err
 	}
 
-	l.cacheAuth = &data
+	l.cacheAuth.Store(&data)
 
 	return &data, nil
 }
@@ -116,17 +121,40 @@ func (l *Auth) Update(ctx context.Context, req model.Auth) error {
 		return err
 	}
 
-	l.cacheAuth = &req
+	l.cacheAuth.Store(&req)
+
+	err = l.broadcaster.Broadcast(ctx, cacheTopicAuth)
+	if err != nil {
+		l.logger.WithContext(ctx).WithErr(err).Warn("broadcast auth cache invalidate failed")
+	}
 	return nil
 }
 
-func newAuth(lc fx.Lifecycle, sys *repo.System, authMgmt *third_auth.Manager, publicAddr *PublicAddress) *Auth {
+// reload 其他副本修改了登录配置，重新加载并更新第三方登录
+func (l *Auth) reload(ctx context.Context) {
+	l.cacheAuth.Store(nil)
+
+	data, err := l.Get(ctx)
+	if err != nil {
+		l.logger.WithContext(ctx).WithErr(err).Warn("reload auth failed")
+		return
+	}
+
+	err = l.updateAuthMgmt(ctx, *data, false)
+	if err != nil {
+		l.logger.WithContext(ctx).WithErr(err).Warn("reload auth mgmt failed")
+	}
+}
+
+func newAuth(lc fx.Lifecycle, sys *repo.System, authMgmt *third_auth.Manager, publicAddr *PublicAddress, broadcaster cache.Broadcaster) *Auth {
 	auth := &Auth{
 		repoSys:       sys,
 		svcPublicAddr: publicAddr,
 		authMgmt:      authMgmt,
+		broadcaster:   broadcaster,
 		logger:        glog.Module("svc", "auth"),
 	}
+	broadcaster.Subscribe(cacheTopicAuth, auth.reload)
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			dbAuth, err := auth.Get(ctx)
@@ -0,0 +1,228 @@
+package svc
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/mq"
+	"github.com/chaitin/koalaqa/pkg/topic"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+type Badge struct {
+	logger *glog.Logger
+
+	repoBadge   *repo.Badge
+	repoComment *repo.Comment
+	repoUser    *repo.User
+	repoRank    *repo.Rank
+	svcBot      *Bot
+	pub         mq.Publisher
+}
+
+func newBadge(badge *repo.Badge, comment *repo.Comment, user *repo.User, rank *repo.Rank, bot *Bot, pub mq.Publisher) *Badge {
+	return &Badge{
+		logger:      glog.Module("svc", "badge"),
+		repoBadge:   badge,
+		repoComment: comment,
+		repoUser:    user,
+		repoRank:    rank,
+		svcBot:      bot,
+		pub:         pub,
+	}
+}
+
+func (b *Badge) List(ctx context.Context) (*model.ListRes[model.Badge], error) {
+	var res model.ListRes[model.Badge]
+	err := b.repoBadge.List(ctx, &res.Items, repo.QueryWithOrderBy("id ASC"))
+	if err != nil {
+		return nil, err
+	}
+
+	res.Total = int64(len(res.Items))
+	return &res, nil
+}
+
+type BadgeCreateReq struct {
+	Name        string          `json:"name" binding:"required"`
+	Description string          `json:"description"`
+	Icon        string          `json:"icon"`
+	Kind        model.BadgeKind `json:"kind" binding:"required,oneof=answer_accepted answer point contribute_rank"`
+	Threshold   int             `json:"threshold" binding:"min=1"`
+	Disabled    bool            `json:"disabled"`
+}
+
+func (b *Badge) Create(ctx context.Context, req BadgeCreateReq) (uint, error) {
+	badge := model.Badge{
+		Name:        req.Name,
+		Description: req.Description,
+		Icon:        req.Icon,
+		Kind:        req.Kind,
+		Threshold:   req.Threshold,
+		Disabled:    req.Disabled,
+	}
+	err := b.repoBadge.Create(ctx, &badge)
+	if err != nil {
+		return 0, err
+	}
+
+	return badge.ID, nil
+}
+
+func (b *Badge) Update(ctx context.Context, id uint, req BadgeCreateReq) error {
+	return b.repoBadge.Update(ctx, map[string]any{
+		"name":        req.Name,
+		"description": req.Description,
+		"icon":        req.Icon,
+		"kind":        req.Kind,
+		"threshold":   req.Threshold,
+		"disabled":    req.Disabled,
+		"updated_at":  time.Now(),
+	}, repo.QueryWithEqual("id", id))
+}
+
+func (b *Badge) Delete(ctx context.Context, id uint) error {
+	return b.repoBadge.DeleteWithUser(ctx, id)
+}
+
+func (b *Badge) ListUser(ctx context.Context, userID uint) (*model.ListRes[model.UserBadgeItem], error) {
+	var res model.ListRes[model.UserBadgeItem]
+	err := b.repoBadge.ListUser(ctx, &res.Items, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Total = int64(len(res.Items))
+	return &res, nil
+}
+
+// badgeProgress 计算用户在某类徽章上的进度，达到 Badge.Threshold 即获得徽章
+// 周贡献榜的进度为排名，未上榜时返回 0
+func (b *Badge) badgeProgress(ctx context.Context, userID uint, kind model.BadgeKind) (int64, error) {
+	var res int64
+	switch kind {
+	case model.BadgeKindAnswerAccepted:
+		err := b.repoComment.Count(ctx, &res,
+			repo.QueryWithEqual("user_id", userID),
+			repo.QueryWithEqual("accepted", true),
+		)
+		if err != nil {
+			return 0, err
+		}
+	case model.BadgeKindAnswer:
+		err := b.repoComment.Count(ctx, &res,
+			repo.QueryWithEqual("user_id", userID),
+			repo.QueryWithEqual("parent_id", 0),
+		)
+		if err != nil {
+			return 0, err
+		}
+	case model.BadgeKindPoint:
+		var user model.User
+		err := b.repoUser.GetByID(ctx, &user, userID)
+		if err != nil {
+			return 0, err
+		}
+
+		res = int64(user.Point)
+	case model.BadgeKindContributeRank:
+		var ranks []model.Rank
+		err := b.repoRank.List(ctx, &ranks,
+			repo.QueryWithEqual("type", model.RankTypeContribute),
+			repo.QueryWithOrderBy("score DESC, id ASC"),
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		scoreID := strconv.FormatUint(uint64(userID), 10)
+		for i, rank := range ranks {
+			if rank.ScoreID == scoreID {
+				res = int64(i + 1)
+				break
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Evaluate 检查用户是否满足徽章条件，授予新徽章并发送通知
+func (b *Badge) Evaluate(ctx context.Context, userID uint) error {
+	if userID == 0 {
+		return nil
+	}
+
+	logger := b.logger.WithContext(ctx).With("user_id", userID)
+
+	bot, err := b.svcBot.Get(ctx)
+	if err != nil {
+		return err
+	}
+	if bot.UserID == userID {
+		return nil
+	}
+
+	var badges []model.Badge
+	err = b.repoBadge.List(ctx, &badges, repo.QueryWithEqual("disabled", false))
+	if err != nil {
+		return err
+	}
+
+	ownIDs, err := b.repoBadge.UserBadgeIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	progressM := make(map[model.BadgeKind]int64)
+	for _, badge := range badges {
+		if slices.Contains(ownIDs, badge.ID) {
+			continue
+		}
+
+		progress, ok := progressM[badge.Kind]
+		if !ok {
+			progress, err = b.badgeProgress(ctx, userID, badge.Kind)
+			if err != nil {
+				return err
+			}
+
+			progressM[badge.Kind] = progress
+		}
+
+		if badge.Kind == model.BadgeKindContributeRank {
+			if progress == 0 || progress > int64(badge.Threshold) {
+				continue
+			}
+		} else if progress < int64(badge.Threshold) {
+			continue
+		}
+
+		awarded, err := b.repoBadge.Award(ctx, userID, badge.ID)
+		if err != nil {
+			return err
+		}
+		if !awarded {
+			continue
+		}
+
+		logger.With("badge_id", badge.ID).Info("user awarded badge")
+		b.pub.Publish(ctx, topic.TopicMessageNotify, topic.MsgMessageNotify{
+			BadgeHeader: model.BadgeHeader{
+				BadgeName: badge.Name,
+			},
+			Type: model.MsgNotifyTypeBadge,
+			ToID: userID,
+		})
+	}
+
+	return nil
+}
+
+func init() {
+	registerSvc(newBadge)
+}
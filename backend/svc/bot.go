@@ -7,9 +7,12 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/cache"
 	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
 	"github.com/chaitin/koalaqa/pkg/keyword"
 	"github.com/chaitin/koalaqa/pkg/oss"
 	"github.com/chaitin/koalaqa/pkg/util"
@@ -17,13 +20,17 @@ import (
 )
 
 type Bot struct {
-	botCache *BotGetRes
+	botCache atomic.Pointer[BotGetRes]
 
-	oc       oss.Client
-	repoBot  *repo.Bot
-	repoUser *repo.User
+	oc          oss.Client
+	repoBot     *repo.Bot
+	repoUser    *repo.User
+	broadcaster cache.Broadcaster
+	logger      *glog.Logger
 }
 
+const cacheTopicBot = "bot"
+
 type BotSetReq struct {
 	Avatar *multipart.FileHeader `form:"avatar" swaggerignore:"true"`
 
@@ -95,8 +102,15 @@ func (b *Bot) Set(ctx context.Context, req BotSetReq) error {
 		bot.Name = dbBot.Name
 	}
 
-	b.botCache.BotInfo = bot.BotInfo
-	b.botCache.keywordMatcher = nil
+	b.botCache.Store(&BotGetRes{
+		BotInfo: bot.BotInfo,
+		UserID:  dbBot.UserID,
+	})
+
+	err = b.broadcaster.Broadcast(ctx, cacheTopicBot)
+	if err != nil {
+		b.logger.WithContext(ctx).WithErr(err).Warn("broadcast bot cache invalidate failed")
+	}
 	return nil
 }
 
@@ -110,10 +124,6 @@ type BotGetRes struct {
 var lock sync.Mutex
 
 func (b *BotGetRes) MatcherCursor() *keyword.Cursor {
-	if b.keywordMatcher != nil {
-		return b.keywordMatcher.NewCursor()
-	}
-
 	if !b.KeywordsEnable || len(b.Keywords) <= 1000 {
 		return nil
 	}
@@ -135,22 +145,20 @@ func (b *BotGetRes) MatcherCursor() *keyword.Cursor {
 }
 
 func (b *Bot) Get(ctx context.Context) (*BotGetRes, error) {
-	if b.botCache != nil {
-		return b.botCache, nil
+	if bot := b.botCache.Load(); bot != nil {
+		return bot, nil
 	}
 
 	var botInfo BotGetRes
 	err := b.repoBot.GetByKey(ctx, &botInfo, model.BotKeyDisscution)
 	if err != nil {
-		if errors.Is(err, database.ErrRecordNotFound) {
-			b.botCache = &BotGetRes{}
-			return b.botCache, nil
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			return nil, err
 		}
-		return nil, err
 	}
 
-	b.botCache = &botInfo
-	return b.botCache, nil
+	b.botCache.Store(&botInfo)
+	return &botInfo, nil
 }
 
 func splitKeywords(raw string) []string {
@@ -168,8 +176,20 @@ func splitKeywords(raw string) []string {
 	return res
 }
 
-func newBot(bot *repo.Bot, user *repo.User, oc oss.Client) *Bot {
-	return &Bot{repoBot: bot, repoUser: user, oc: oc}
+func newBot(bot *repo.Bot, user *repo.User, oc oss.Client, broadcaster cache.Broadcaster) *Bot {
+	b := &Bot{
+		repoBot:     bot,
+		repoUser:    user,
+		oc:          oc,
+		broadcaster: broadcaster,
+		logger:      glog.Module("svc", "bot"),
+	}
+
+	// 其他副本修改了机器人配置，下次使用时重新从数据库加载
+	broadcaster.Subscribe(cacheTopicBot, func(context.Context) {
+		b.botCache.Store(nil)
+	})
+	return b
 }
 
 func init() {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -116,7 +117,10 @@ func (c *Chat) botCallback(ctx context.Context, req chat.BotReq) (*llm.Stream[st
 		}
 
 		if ret {
-			wrapStream.RecvOne(fmt.Sprintf("\n\n---\n\n[%s](%s)", retText, publicAddr.FullURL("/")), true)
+			feedbackURL := publicAddr.FullURL("/api/discussion/ask/feedback/bot") + "?session_id=" + url.QueryEscape(sessionID)
+			wrapStream.RecvOne(fmt.Sprintf("\n\n---\n\n[%s](%s)\n\n回答是否有帮助？[有帮助](%s&state=%d) | [没帮助](%s&state=%d)",
+				retText, publicAddr.FullURL("/"),
+				feedbackURL, model.AskFunnelFeedbackLike, feedbackURL, model.AskFunnelFeedbackDislike), true)
 			return
 		}
 
@@ -267,6 +271,10 @@ func (c *Chat) checkConfig(typ chat.Type, cfg model.SystemChatConfig) error {
 		if cfg.TemplateID == "" {
 			return errors.New("empty template_id")
 		}
+	case chat.TypeFeishu, chat.TypeSlack:
+		if cfg.Token == "" {
+			return errors.New("empty token")
+		}
 	case chat.TypeWecom, chat.TypeWecomService:
 		if cfg.CorpID == "" {
 			return errors.New("empty corp_id")
@@ -375,6 +383,9 @@ func newChat(lc fx.Lifecycle, sys *repo.System, disc *Discussion, publicAddr *Pu
 			chat.TypeDingtalk: model.SystemKeyChatDingtalk,
 			// chat.TypeWecom:        model.SystemKeyChatWecom,
 			chat.TypeWecomService: model.SystemKeyChatWecomService,
+			chat.TypeFeishu:       model.SystemKeyChatFeishu,
+			chat.TypeSlack:        model.SystemKeyChatSlack,
+			chat.TypeTeams:        model.SystemKeyChatTeams,
 		},
 		logger: glog.Module("svc", "chat"),
 	}
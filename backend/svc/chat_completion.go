@@ -0,0 +1,325 @@
+package svc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/llm"
+	"github.com/chaitin/koalaqa/pkg/openai"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+)
+
+const chatCompletionModel = "koalaqa"
+
+// ChatCompletion OpenAI 兼容的问答接口，检索与回答逻辑与社区智能问答一致
+type ChatCompletion struct {
+	in     chatCompletionIn
+	logger *glog.Logger
+}
+
+type chatCompletionIn struct {
+	fx.In
+
+	LLM        *LLM
+	User       *repo.User
+	GroupItem  *repo.GroupItem
+	AskSession *repo.AskSession
+	KBDoc      *repo.KBDocument
+}
+
+func newChatCompletion(in chatCompletionIn) *ChatCompletion {
+	return &ChatCompletion{
+		in:     in,
+		logger: glog.Module("svc", "chat_completion"),
+	}
+}
+
+func init() {
+	registerSvc(newChatCompletion)
+}
+
+func (c *ChatCompletion) Models() openai.ModelList {
+	return openai.ModelList{
+		Object: "list",
+		Data: []openai.Model{{
+			ID:      chatCompletionModel,
+			Object:  "model",
+			OwnedBy: chatCompletionModel,
+		}},
+	}
+}
+
+type ChatCompletionReq struct {
+	openai.Request
+
+	// ForumIDs 只检索指定板块关联的分类，为空时不限制
+	ForumIDs model.Int64Array `json:"forum_ids"`
+	// KBIDs 只检索指定知识库，为空时不限制
+	KBIDs model.Int64Array `json:"kb_ids"`
+}
+
+// scopeGroups 指定板块关联的分类，板块没有关联分类时返回错误，不能退化为全量检索
+func (c *ChatCompletion) scopeGroups(ctx context.Context, forumIDs model.Int64Array) ([]model.GroupItemInfo, error) {
+	if len(forumIDs) == 0 {
+		return nil, nil
+	}
+
+	groupIDs, err := c.in.User.ForumGroupIDs(ctx, forumIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(groupIDs) == 0 {
+		return nil, openai.InvalidRequest("forum_ids have no groups")
+	}
+
+	var groups []model.GroupItemInfo
+	err = c.in.GroupItem.List(ctx, &groups, repo.QueryWithEqual("group_id", groupIDs, repo.EqualOPEqAny))
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, openai.InvalidRequest("forum_ids have no groups")
+	}
+
+	return groups, nil
+}
+
+// Create 发起一次回答，调用方通过 Next 读取回答内容，结束后必须调用 Close 记录问答
+// 问题和回答在 Close 时一并记录，生成失败的问答不会留下记录
+func (c *ChatCompletion) Create(ctx context.Context, req ChatCompletionReq) (*ChatAnswer, error) {
+	question, messages, err := req.Split()
+	if err != nil {
+		return nil, openai.InvalidRequest(err.Error())
+	}
+
+	history := make([]GenerateContextItem, 0, len(messages))
+	for _, msg := range messages {
+		history = append(history, GenerateContextItem{
+			Bot:     msg.Role == openai.RoleAssistant,
+			Content: string(msg.Content),
+		})
+	}
+
+	groups, err := c.scopeGroups(ctx, req.ForumIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultAnswer := "无法回答问题"
+	stream, docIDs, err := c.in.LLM.StreamAnswer(ctx, llm.SystemStreamChatPrompt, GenerateReq{
+		Context:       history,
+		Question:      question,
+		Groups:        groups,
+		Prompt:        question,
+		DefaultAnswer: defaultAnswer,
+		KBIDs:         req.KBIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	modelName := req.Model
+	if modelName == "" {
+		modelName = chatCompletionModel
+	}
+
+	return &ChatAnswer{
+		ID:        openai.NewID(),
+		Model:     modelName,
+		Created:   time.Now().Unix(),
+		SessionID: uuid.NewString(),
+
+		svc:           c,
+		question:      question,
+		stream:        stream,
+		docIDs:        docIDs,
+		defaultAnswer: defaultAnswer,
+	}, nil
+}
+
+// ChatAnswer 回答的第一个字符为 1/2/3 标识（能回答/无法回答/转人工），读取时去掉标识
+type ChatAnswer struct {
+	ID        string
+	Model     string
+	Created   int64
+	SessionID string
+
+	svc           *ChatCompletion
+	question      string
+	stream        *llm.Stream[string]
+	docIDs        []string
+	defaultAnswer string
+
+	parsed    bool
+	done      bool
+	closed    bool
+	canceled  bool
+	answered  bool
+	needHuman bool
+	err       error
+	content   strings.Builder
+}
+
+func (a *ChatAnswer) emit(text string) string {
+	a.content.WriteString(text)
+	return text
+}
+
+// Next 返回下一段回答内容，没有更多内容时返回 false
+func (a *ChatAnswer) Next(ctx context.Context) (string, bool) {
+	for !a.done {
+		text, canceled, ok := a.stream.Text(ctx)
+		if !ok {
+			a.done = true
+			a.canceled = canceled
+			if err := a.stream.Err(); err != nil {
+				a.svc.logger.WithContext(ctx).WithErr(err).With("session_id", a.SessionID).Error("generate chat completion failed")
+				a.err = openai.ServerError("generate answer failed")
+				return "", false
+			}
+
+			if !a.parsed && !canceled {
+				return a.emit(a.defaultAnswer), true
+			}
+
+			return "", false
+		}
+
+		if !a.parsed {
+			text = strings.TrimLeft(text, " \t\n\r")
+			if text == "" {
+				continue
+			}
+
+			a.parsed = true
+			switch text[0] {
+			case '1':
+				a.answered = true
+				text = text[1:]
+			case '2':
+				a.done = true
+				text = a.defaultAnswer
+			case '3':
+				a.done = true
+				a.needHuman = true
+				text = llm.HumanAssistanceResponse
+			default:
+				a.answered = true
+			}
+		}
+
+		if text == "" {
+			continue
+		}
+
+		return a.emit(text), true
+	}
+
+	return "", false
+}
+
+// Err 回答结束后返回生成过程中的错误，此时已输出的内容不完整
+func (a *ChatAnswer) Err() error {
+	return a.err
+}
+
+func (a *ChatAnswer) refDocIDs() model.Int64Array {
+	if !a.answered || a.canceled {
+		return nil
+	}
+
+	ids := make(model.Int64Array, 0, len(a.docIDs))
+	for _, docID := range a.docIDs {
+		id, err := strconv.ParseInt(docID, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// Extension 回答结束后的引用文档，无法回答时引用为空
+func (a *ChatAnswer) Extension(ctx context.Context) (*openai.Extension, error) {
+	res := openai.Extension{
+		SessionID: a.SessionID,
+		Citations: make([]openai.Citation, 0),
+	}
+
+	ids := a.refDocIDs()
+	if len(ids) == 0 {
+		return &res, nil
+	}
+
+	var docs []model.KBDocument
+	err := a.svc.in.KBDoc.List(ctx, &docs,
+		repo.QueryWithSelectColumn("id", "kb_id", "doc_type", "title"),
+		repo.QueryWithEqual("id", ids, repo.EqualOPEqAny),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	docs = util.SortByKeys(docs, a.docIDs, func(doc model.KBDocument) string {
+		return strconv.FormatUint(uint64(doc.ID), 10)
+	})
+	for _, doc := range docs {
+		res.Citations = append(res.Citations, openai.Citation{
+			ID:    doc.ID,
+			KBID:  doc.KBID,
+			Type:  restDocTypes[doc.DocType],
+			Title: doc.Title,
+		})
+	}
+
+	return &res, nil
+}
+
+// Close 关闭回答并记录到问答记录，未读取完的回答记为已取消，生成失败时不记录
+func (a *ChatAnswer) Close() {
+	if a.closed {
+		return
+	}
+	a.closed = true
+	a.stream.Close()
+
+	logger := a.svc.logger.With("session_id", a.SessionID)
+	if a.err != nil {
+		logger.Info("chat completion failed, skip ask session")
+		return
+	}
+
+	ctx := context.Background()
+	err := a.svc.in.AskSession.Create(ctx, &model.AskSession{
+		UUID:    a.SessionID,
+		Bot:     false,
+		Content: a.question,
+		Source:  model.AskSessionSourceOpenAI,
+	})
+	if err != nil {
+		logger.WithErr(err).Warn("create chat completion question failed")
+		return
+	}
+
+	err = a.svc.in.AskSession.Create(ctx, &model.AskSession{
+		UUID:      a.SessionID,
+		Source:    model.AskSessionSourceOpenAI,
+		Bot:       true,
+		Canceled:  a.canceled || !a.done,
+		NeedHuman: a.needHuman,
+		DocIDs:    a.refDocIDs(),
+		Content:   a.content.String(),
+	})
+	if err != nil {
+		logger.WithErr(err).Warn("create chat completion answer failed")
+	}
+}
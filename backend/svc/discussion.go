@@ -2,13 +2,14 @@ package svc
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"github.com/chaitin/koalaqa/pkg/rag"
 	"github.com/chaitin/koalaqa/pkg/ratelimit"
 	"github.com/chaitin/koalaqa/pkg/topic"
+	"github.com/chaitin/koalaqa/pkg/upload"
 	"github.com/chaitin/koalaqa/pkg/util"
 	"github.com/chaitin/koalaqa/pkg/webhook/message"
 	"github.com/chaitin/koalaqa/repo"
@@ -49,6 +51,7 @@ type discussionIn struct {
 	GroupRepo      *repo.Group
 	OrgRepo        *repo.Org
 	AskSessionRepo *repo.AskSession
+	AskFunnelRepo  *repo.AskFunnel
 	BotSvc         *Bot
 	TrendSvc       *Trend
 	Pub            mq.Publisher
@@ -62,6 +65,9 @@ type discussionIn struct {
 	UserPoint      *UserPoint
 	PublicAddr     *PublicAddress
 	WebPlugin      *WebPlugin
+	Upload         *Upload
+	Vision         *Vision
+	Issue          *Issue
 	Cfg            config.Config
 }
 
@@ -94,13 +100,17 @@ func init() {
 }
 
 type DiscussionCreateReq struct {
-	Title     string               `json:"title" binding:"required"`
-	Summary   string               `json:"summary"`
-	Content   string               `json:"content"`
-	Type      model.DiscussionType `json:"type"`
-	GroupIDs  model.Int64Array     `json:"group_ids"`
-	ForumID   uint                 `json:"forum_id"`
-	skipLimit bool                 `json:"-"`
+	Title    string               `json:"title" binding:"required"`
+	Summary  string               `json:"summary"`
+	Content  string               `json:"content"`
+	Type     model.DiscussionType `json:"type"`
+	GroupIDs model.Int64Array     `json:"group_ids"`
+	ForumID  uint                 `json:"forum_id"`
+	// SessionID 从智能问答转人工发帖时传入，用于统计问答漏斗
+	SessionID string `json:"session_id"`
+	// Bounty 悬赏积分，仅问题可设置，发帖时从发帖人积分中冻结
+	Bounty    uint `json:"bounty" binding:"max=1000"`
+	skipLimit bool `json:"-"`
 }
 
 func (d *Discussion) generateUUID() string {
@@ -129,6 +139,7 @@ var (
 	errPermission       = errors.New("permission denied")
 	errAskSessionClosed = errors.New("session closed")
 	errStreaming        = errors.New("streaming")
+	errAskOutOfScope    = errors.New("no group in site scope")
 )
 
 func (d *Discussion) Create(ctx context.Context, user model.UserInfo, req DiscussionCreateReq) (string, error) {
@@ -153,6 +164,10 @@ func (d *Discussion) Create(ctx context.Context, user model.UserInfo, req Discus
 		req.Summary = ""
 	}
 
+	if req.Bounty > 0 && req.Type != model.DiscussionTypeQA {
+		return "", errors.New("bounty only allowed for qa")
+	}
+
 	if req.ForumID == 0 {
 		forumID, err := d.in.ForumRepo.GetFirstID(ctx)
 		if err != nil {
@@ -179,25 +194,43 @@ func (d *Discussion) Create(ctx context.Context, user model.UserInfo, req Discus
 		break
 	}
 
+	var issueStateID uint
+	resolved := model.DiscussionStateNone
+	if req.Type == model.DiscussionTypeIssue {
+		issueStateID, resolved, err = d.in.Issue.InitialStateID(ctx, req.ForumID)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	disc := model.Discussion{
-		Title:      req.Title,
-		Summary:    req.Summary,
-		Content:    req.Content,
-		GroupIDs:   req.GroupIDs,
-		UUID:       d.generateUUID(),
-		UserID:     user.UID,
-		Type:       req.Type,
-		ForumID:    req.ForumID,
-		Members:    model.Int64Array{int64(user.UID)},
-		Hot:        2000,
-		BotUnknown: true,
-		Resolved:   model.DiscussionStateNone,
-	}
-	err = d.in.DiscRepo.Create(ctx, &disc)
+		Title:        req.Title,
+		Summary:      req.Summary,
+		Content:      req.Content,
+		GroupIDs:     req.GroupIDs,
+		UUID:         d.generateUUID(),
+		UserID:       user.UID,
+		Type:         req.Type,
+		ForumID:      req.ForumID,
+		Members:      model.Int64Array{int64(user.UID)},
+		Hot:          2000,
+		BotUnknown:   true,
+		Resolved:     resolved,
+		Bounty:       req.Bounty,
+		IssueStateID: issueStateID,
+	}
+	err = d.in.DiscRepo.CreateWithBounty(ctx, &disc)
 	if err != nil {
 		return "", err
 	}
 
+	if req.SessionID != "" {
+		err = d.in.AskFunnelRepo.LinkDiscussion(ctx, req.SessionID, user.UID, &disc)
+		if err != nil {
+			d.logger.WithContext(ctx).WithErr(err).With("session_id", req.SessionID).Warn("link ask funnel discussion failed")
+		}
+	}
+
 	switch disc.Type {
 	case model.DiscussionTypeQA:
 		d.in.Pub.Publish(ctx, topic.TopicAIInsight, topic.MsgAIInsight{
@@ -339,6 +372,8 @@ func (d *Discussion) Delete(ctx context.Context, user model.UserInfo, uuid strin
 		return errors.New("resolved qa can not delete")
 	}
 
+	d.settleBounty(ctx, disc, disc.UserID)
+
 	if err := d.in.DiscRepo.DeleteByID(ctx, disc.ID); err != nil {
 		return err
 	}
@@ -377,8 +412,8 @@ func (d *Discussion) ListSimilarity(ctx context.Context, discUUID string) (*mode
 	}
 
 	for _, searchDisc := range discs {
-		// 过滤关联 issue 或者帖子本身
-		if searchDisc.ID == disc.ID || searchDisc.ID == disc.AssociateID {
+		// 过滤关联 issue、已合并的帖子或者帖子本身
+		if searchDisc.ID == disc.ID || searchDisc.ID == disc.AssociateID || searchDisc.MergedID > 0 {
 			continue
 		}
 
@@ -391,6 +426,210 @@ func (d *Discussion) ListSimilarity(ctx context.Context, discUUID string) (*mode
 	return &res, nil
 }
 
+type DiscussionDuplicateReq struct {
+	ForumID uint                 `json:"forum_id" binding:"required"`
+	Type    model.DiscussionType `json:"type" binding:"omitempty,oneof=qa feedback issue"`
+	Title   string               `json:"title" binding:"required"`
+	Content string               `json:"content"`
+}
+
+type DiscussionDuplicateItem struct {
+	model.DiscussionListItem
+
+	// Answer 已采纳的回答
+	Answer string `json:"answer"`
+}
+
+// CheckDuplicate 发帖前查询可能重复的帖子，结合 RAG 相似度与标题 trigram 相似度
+func (d *Discussion) CheckDuplicate(ctx context.Context, uid uint, req DiscussionDuplicateReq) (*model.ListRes[*DiscussionDuplicateItem], error) {
+	ok, err := d.in.UserRepo.HasForumPermission(ctx, uid, req.ForumID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errPermission
+	}
+
+	if req.Type == "" {
+		req.Type = model.DiscussionTypeQA
+	}
+
+	keyword := req.Title
+	if req.Content != "" {
+		keyword += "\n" + util.TruncateString(req.Content, 500)
+	}
+
+	ragDiscs, err := d.Search(ctx, DiscussionSearchReq{
+		Keyword:             keyword,
+		ForumID:             req.ForumID,
+		SimilarityThreshold: 0.5,
+		MaxChunksPerDoc:     1,
+		Metadata:            model.DiscMetadata{DiscussType: req.Type},
+	})
+	if err != nil {
+		d.logger.WithContext(ctx).WithErr(err).Warn("search duplicate discussion failed")
+	}
+
+	var titleDiscs []*model.DiscussionListItem
+	err = d.in.DiscRepo.ListTitleSimilar(ctx, &titleDiscs, req.ForumID, req.Title, req.Type, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	discM := make(map[uint]*model.DiscussionListItem)
+	for _, disc := range append(ragDiscs, titleDiscs...) {
+		if disc.MergedID > 0 {
+			continue
+		}
+
+		exist, ok := discM[disc.ID]
+		if ok && exist.Similarity >= disc.Similarity {
+			continue
+		}
+		discM[disc.ID] = disc
+	}
+
+	var res model.ListRes[*DiscussionDuplicateItem]
+	if len(discM) == 0 {
+		return &res, nil
+	}
+
+	discIDs := make(model.Int64Array, 0, len(discM))
+	for _, disc := range discM {
+		res.Items = append(res.Items, &DiscussionDuplicateItem{DiscussionListItem: *disc})
+		discIDs = append(discIDs, int64(disc.ID))
+	}
+	slices.SortFunc(res.Items, func(a, b *DiscussionDuplicateItem) int {
+		return cmp.Compare(b.Similarity, a.Similarity)
+	})
+	if len(res.Items) > 5 {
+		res.Items = res.Items[:5]
+	}
+
+	var comments []model.Comment
+	err = d.in.CommRepo.List(ctx, &comments,
+		repo.QueryWithEqual("comments.discussion_id", discIDs, repo.EqualOPEqAny),
+		repo.QueryWithEqual("comments.accepted", true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	answerM := make(map[uint]string, len(comments))
+	for _, comment := range comments {
+		answerM[comment.DiscussionID] = util.TruncateString(comment.Content, 300)
+	}
+	for _, item := range res.Items {
+		item.Answer = answerM[item.ID]
+	}
+
+	res.Total = int64(len(res.Items))
+	return &res, nil
+}
+
+type DiscussionMergeReq struct {
+	TargetUUID string `json:"target_uuid" binding:"required"`
+}
+
+// Merge 将重复的帖子合并到目标帖子，评论、关注、点赞与标签移动到目标帖子，原帖子关闭并跳转到目标帖子
+func (d *Discussion) Merge(ctx context.Context, user model.UserInfo, discUUID string, req DiscussionMergeReq) error {
+	if user.Role != model.UserRoleAdmin && user.Role != model.UserRoleOperator {
+		return errPermission
+	}
+
+	disc, err := d.in.DiscRepo.GetByUUID(ctx, discUUID)
+	if err != nil {
+		return err
+	}
+
+	target, err := d.in.DiscRepo.GetByUUID(ctx, req.TargetUUID)
+	if err != nil {
+		return err
+	}
+
+	if disc.ID == target.ID || disc.ForumID != target.ForumID || disc.Type != target.Type ||
+		disc.Type == model.DiscussionTypeBlog {
+		return errors.New("discussion can not merge")
+	}
+
+	ok, err := d.in.UserRepo.HasForumPermission(ctx, user.UID, disc.ForumID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errPermission
+	}
+
+	var forum model.Forum
+	err = d.in.ForumRepo.GetByID(ctx, &forum, disc.ForumID)
+	if err != nil {
+		return err
+	}
+
+	err = d.in.DiscRepo.Merge(ctx, disc.ID, target.ID)
+	if err != nil {
+		return err
+	}
+
+	d.settleBounty(ctx, disc, disc.UserID)
+
+	logger := d.logger.WithContext(ctx).With("disc_id", disc.ID).With("target_id", target.ID)
+
+	// 两个帖子都有的标签，合并后只计一次
+	var dupTagIDs model.Int64Array
+	for _, tagID := range disc.TagIDs {
+		if slices.Contains(target.TagIDs, tagID) {
+			dupTagIDs = append(dupTagIDs, tagID)
+		}
+	}
+	if len(dupTagIDs) > 0 {
+		err = d.in.DiscTagRepo.Update(ctx, map[string]any{
+			"count": gorm.Expr("GREATEST(0, count-1)"),
+		}, repo.QueryWithEqual("forum_id", disc.ForumID), repo.QueryWithEqual("id", dupTagIDs, repo.EqualOPEqAny))
+		if err != nil {
+			logger.WithErr(err).Warn("desc tag count failed")
+		}
+	}
+
+	if disc.RagID != "" {
+		err = d.in.Rag.DeleteRecords(ctx, forum.DatasetID, []string{disc.RagID})
+		if err != nil {
+			logger.WithErr(err).Warn("delete merged discussion rag failed")
+		}
+
+		err = d.in.DiscRepo.Update(ctx, map[string]any{"rag_id": ""}, repo.QueryWithEqual("id", disc.ID))
+		if err != nil {
+			logger.WithErr(err).Warn("clear merged discussion rag_id failed")
+		}
+	}
+
+	err = d.in.Pub.Publish(ctx, topic.TopicDiscReindex, topic.MsgDiscReindex{
+		ForumID: target.ForumID,
+		DiscID:  target.ID,
+		RagID:   target.RagID,
+	})
+	if err != nil {
+		logger.WithErr(err).Warn("pub reindex target discussion failed")
+	}
+
+	err = d.in.DiscFollowRepo.Upsert(ctx, &model.DiscussionFollow{
+		DiscussionID: target.ID,
+		UserID:       disc.UserID,
+	})
+	if err != nil {
+		logger.WithErr(err).Warn("follow target discussion failed")
+	}
+
+	d.in.Pub.Publish(ctx, topic.TopicMessageNotify, topic.MsgMessageNotify{
+		DiscussHeader: target.Header(),
+		Type:          model.MsgNotifyTypeMergeDiscussion,
+		FromID:        user.UID,
+		ToID:          disc.UserID,
+	})
+
+	return nil
+}
+
 type DiscussionListBackendReq struct {
 	*model.Pagination
 
@@ -431,6 +670,7 @@ const (
 	DiscussionListFilterHot     DiscussionListFilter = "hot"
 	DiscussionListFilterNew     DiscussionListFilter = "new"
 	DiscussionListFilterPublish DiscussionListFilter = "publish"
+	DiscussionListFilterBounty  DiscussionListFilter = "bounty"
 )
 
 type DiscussionListReq struct {
@@ -446,6 +686,13 @@ type DiscussionListReq struct {
 	DiscussionIDs *model.Int64Array      `json:"discussion_ids" form:"discussion_ids"`
 	Stat          bool                   `json:"stat" form:"stat"`
 	TagIDs        model.Int64Array       `json:"tag_ids" form:"tag_ids"`
+	// Bounty 只查询悬赏未结算的问题
+	Bounty bool `json:"bounty" form:"bounty"`
+	// 以下筛选仅对 Issue 生效
+	Assignee     *uint                `json:"assignee" form:"assignee"`
+	Priority     *model.IssuePriority `json:"priority" form:"priority"`
+	Severity     *model.IssueSeverity `json:"severity" form:"severity"`
+	IssueStateID *uint                `json:"issue_state_id" form:"issue_state_id"`
 }
 
 func (d *Discussion) List(ctx context.Context, sessionUUID string, userInfo model.UserInfo, req DiscussionListReq) (*model.ListRes[*model.DiscussionListItem], error) {
@@ -518,13 +765,22 @@ func (d *Discussion) List(ctx context.Context, sessionUUID string, userInfo mode
 		repo.QueryWithEqual("resolved", req.Resolved),
 		repo.QueryWithEqual("discussions.id", req.DiscussionIDs, repo.EqualOPEqAny),
 		repo.QueryWithEqual("discussions.tag_ids", req.TagIDs, repo.EqualOPContainAny),
+		repo.QueryWithEqual("priority", req.Priority),
+		repo.QueryWithEqual("severity", req.Severity),
+		repo.QueryWithEqual("issue_state_id", req.IssueStateID),
 	)
+	if req.Assignee != nil {
+		query = append(query, repo.QueryWithEqual("assignees", *req.Assignee, repo.EqualOPValIn))
+	}
 	if req.OnlyMine {
 		query = append(query, repo.QueryWithEqual("members", userInfo.UID, repo.EqualOPValIn))
 	}
 	if req.Resolved != nil {
 		query = append(query, repo.QueryWithEqual("type", model.DiscussionTypeBlog, repo.EqualOPNE))
 	}
+	if req.Bounty {
+		query = append(query, repo.QueryWithEqual("bounty_state", model.BountyStateOpen))
+	}
 
 	if len(groupM) > 0 {
 		for _, groupIDs := range groupM {
@@ -540,6 +796,8 @@ func (d *Discussion) List(ctx context.Context, sessionUUID string, userInfo mode
 		pageFuncs = append(pageFuncs, repo.QueryWithOrderBy("updated_at DESC"))
 	case DiscussionListFilterPublish:
 		pageFuncs = append(pageFuncs, repo.QueryWithOrderBy("created_at DESC"))
+	case DiscussionListFilterBounty:
+		pageFuncs = append(pageFuncs, repo.QueryWithOrderBy("(bounty_state = 1) DESC, bounty DESC, created_at DESC"))
 	}
 	err = d.in.DiscRepo.List(ctx, &res.Items, append(query, pageFuncs...)...)
 	if err != nil {
@@ -885,8 +1143,9 @@ func (d *Discussion) LikeDiscussion(ctx context.Context, discUUID string, user m
 	return nil
 }
 
-func (d *Discussion) CheckPerm(ctx context.Context, uid uint, disc *model.Discussion) error {
-	ok, err := d.in.UserRepo.HasForumPermission(ctx, uid, disc.ForumID)
+// CheckViewPerm 只校验论坛权限，已关闭的帖子依然可以查看
+func (d *Discussion) CheckViewPerm(ctx context.Context, uid uint, forumID uint) error {
+	ok, err := d.in.UserRepo.HasForumPermission(ctx, uid, forumID)
 	if err != nil {
 		return err
 	}
@@ -895,6 +1154,15 @@ func (d *Discussion) CheckPerm(ctx context.Context, uid uint, disc *model.Discus
 		return errPermission
 	}
 
+	return nil
+}
+
+func (d *Discussion) CheckPerm(ctx context.Context, uid uint, disc *model.Discussion) error {
+	err := d.CheckViewPerm(ctx, uid, disc.ForumID)
+	if err != nil {
+		return err
+	}
+
 	if disc.Closed() {
 		return errDiscussionClosed
 	}
@@ -1048,6 +1316,8 @@ func (d *Discussion) Close(ctx context.Context, user model.UserInfo, discUUID st
 		d.logger.WithContext(ctx).WithErr(err).With("disc_id", disc.ID).Warn("update rag metadata failed")
 	}
 
+	d.settleBounty(ctx, disc, disc.UserID)
+
 	d.in.Pub.Publish(ctx, topic.TopicMessageNotify, topic.MsgMessageNotify{
 		DiscussHeader: disc.Header(),
 		Type:          model.MsgNotifyTypeCloseDiscussion,
@@ -1058,6 +1328,50 @@ func (d *Discussion) Close(ctx context.Context, user model.UserInfo, discUUID st
 	return nil
 }
 
+// settleBounty 结算悬赏，toUserID 为发帖人时退回，结算失败只记录日志
+func (d *Discussion) settleBounty(ctx context.Context, disc *model.Discussion, toUserID uint) {
+	if disc.BountyState != model.BountyStateOpen {
+		return
+	}
+
+	bounty, err := d.in.DiscRepo.SettleBounty(ctx, disc.ID, toUserID)
+	if err != nil {
+		d.logger.WithContext(ctx).WithErr(err).With("disc_id", disc.ID).With("to_user_id", toUserID).Error("settle bounty failed")
+		return
+	}
+
+	if bounty == 0 {
+		return
+	}
+
+	d.in.Pub.Publish(ctx, topic.TopicMessageNotify, topic.MsgMessageNotify{
+		DiscussHeader: disc.Header(),
+		UserPointHeader: model.UserPointHeader{
+			UserPoint: int(bounty),
+		},
+		Type: model.MsgNotifyTypeUserPoint,
+		ToID: toUserID,
+	})
+}
+
+// RefundClosedBounty 退回已关闭问题中未结算的悬赏
+func (d *Discussion) RefundClosedBounty(ctx context.Context) (int, error) {
+	var discs []model.Discussion
+	err := d.in.DiscRepo.List(ctx, &discs,
+		repo.QueryWithEqual("resolved", model.DiscussionStateClosed),
+		repo.QueryWithEqual("bounty_state", model.BountyStateOpen),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range discs {
+		d.settleBounty(ctx, &discs[i], discs[i].UserID)
+	}
+
+	return len(discs), nil
+}
+
 func (d *Discussion) GetByID(ctx context.Context, id uint) (*model.Discussion, error) {
 	var discussion model.Discussion
 	err := d.in.DiscRepo.GetByID(ctx, &discussion, id)
@@ -1123,6 +1437,13 @@ func (d *Discussion) CreateComment(ctx context.Context, uid uint, discUUID strin
 			if err != nil {
 				d.logger.WithContext(ctx).WithErr(err).With("comment_id", comment.ID).Warn("create user trend failed")
 			}
+
+			if uid != disc.UserID {
+				err = d.in.AskFunnelRepo.HumanAnswered(ctx, disc.ID, comment.CreatedAt.Time())
+				if err != nil {
+					d.logger.WithContext(ctx).WithErr(err).With("disc_id", disc.ID).Warn("update ask funnel human answered failed")
+				}
+			}
 		}
 
 		if disc.Type == model.DiscussionTypeQA {
@@ -1315,19 +1636,14 @@ type DiscussUploadFileReq struct {
 	File *multipart.FileHeader `form:"file" swaggerignore:"true"`
 }
 
-func (d *Discussion) UploadFile(ctx context.Context, req DiscussUploadFileReq) (string, error) {
-	f, err := req.File.Open()
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	return d.in.OC.Upload(ctx, d.ossDir(req.UUID), f,
-		oss.WithExt(filepath.Ext(req.File.Filename)),
-		oss.WithFileSize(int(req.File.Size)),
-		oss.WithLimitSize(),
-		oss.WithPublic(),
-	)
+func (d *Discussion) UploadFile(ctx context.Context, uid uint, req DiscussUploadFileReq) (string, error) {
+	return d.in.Upload.upload(ctx, uploadReq{
+		UserID: uid,
+		Scene:  upload.SceneDiscussion,
+		Dir:    d.ossDir(req.UUID),
+		File:   req.File,
+		Quota:  true,
+	})
 }
 
 func (d *Discussion) AcceptComment(ctx context.Context, user model.UserInfo, discUUID string, commentID uint) error {
@@ -1396,6 +1712,11 @@ func (d *Discussion) AcceptComment(ctx context.Context, user model.UserInfo, dis
 			return err
 		}
 
+		err = d.in.AskFunnelRepo.Accepted(ctx, disc.ID, nil)
+		if err != nil {
+			d.logger.WithContext(ctx).WithErr(err).With("disc_id", disc.ID).Warn("update ask funnel accepted failed")
+		}
+
 		err = d.in.Pub.Publish(ctx, topic.TopicUserPoint, topic.MsgUserPoint{
 			UserPointRecordInfo: model.UserPointRecordInfo{
 				UserID:    disc.UserID,
@@ -1467,6 +1788,13 @@ func (d *Discussion) AcceptComment(ctx context.Context, user model.UserInfo, dis
 		return err
 	}
 
+	// 悬赏发放后不随取消采纳收回，采纳机器人或自己的回答时退回悬赏
+	bountyTo := comment.UserID
+	if comment.Bot || comment.UserID == disc.UserID {
+		bountyTo = disc.UserID
+	}
+	d.settleBounty(ctx, disc, bountyTo)
+
 	if comment.Bot {
 		d.in.Batcher.Send(model.StatInfo{
 			Type: model.StatTypeBotAccept,
@@ -1475,6 +1803,11 @@ func (d *Discussion) AcceptComment(ctx context.Context, user model.UserInfo, dis
 		})
 	}
 
+	err = d.in.AskFunnelRepo.Accepted(ctx, disc.ID, &now)
+	if err != nil {
+		d.logger.WithContext(ctx).WithErr(err).With("disc_id", disc.ID).Warn("update ask funnel accepted failed")
+	}
+
 	if disc.Resolved == model.DiscussionStateNone {
 		if err := d.in.DiscRepo.Update(ctx, map[string]any{
 			"resolved":    model.DiscussionStateResolved,
@@ -1869,16 +2202,24 @@ func (d *Discussion) ResolveIssue(ctx context.Context, user model.UserInfo, disc
 		return errPermission
 	}
 
-	err := d.in.DiscRepo.ResolveIssue(ctx, discUUID, req.Resolve)
+	disc, err := d.in.DiscRepo.GetByUUID(ctx, discUUID)
 	if err != nil {
 		return err
 	}
 
-	disc, err := d.in.DiscRepo.GetByUUID(ctx, discUUID)
+	// 论坛配置了自定义状态时按状态流转处理
+	handled, err := d.in.Issue.TransitCategory(ctx, user, disc, req.Resolve)
+	if handled || err != nil {
+		return err
+	}
+
+	err = d.in.DiscRepo.ResolveIssue(ctx, discUUID, user.UID, req.Resolve)
 	if err != nil {
 		return err
 	}
 
+	disc.Resolved = req.Resolve
+
 	var forum model.Forum
 	err = d.in.ForumRepo.GetByID(ctx, &forum, disc.ForumID)
 	if err != nil {
@@ -2002,6 +2343,8 @@ func (d *Discussion) AssociateDiscussion(ctx context.Context, user model.UserInf
 		d.logger.WithContext(ctx).WithErr(err).With("disc_id", disc.ID).Warn("update disc rag metadata failed")
 	}
 
+	d.settleBounty(ctx, disc, disc.UserID)
+
 	err = d.in.DiscFollowRepo.Upsert(ctx, &model.DiscussionFollow{
 		DiscussionID: issue.ID,
 		UserID:       disc.UserID,
@@ -2091,6 +2434,8 @@ func (d *Discussion) Reindex(ctx context.Context, req ReindexReq) error {
 type CreateOrLastSessionReq struct {
 	SessionID   *string `form:"session_id"`
 	ForceCreate bool    `form:"force_create"`
+	// SiteID 网页挂件站点，不同站点的会话互不复用
+	SiteID uint `form:"-" swaggerignore:"true"`
 }
 
 func (d *Discussion) CreateOrLastSession(ctx context.Context, uid uint, req CreateOrLastSessionReq, useReqSessionID bool) (string, error) {
@@ -2104,6 +2449,7 @@ func (d *Discussion) CreateOrLastSession(ctx context.Context, uid uint, req Crea
 		err = d.in.AskSessionRepo.Get(ctx, &lastSession,
 			repo.QueryWithEqual("user_id", uid),
 			repo.QueryWithEqual("uuid", req.SessionID),
+			repo.QueryWithEqual("site_id", req.SiteID),
 			repo.QueryWithEqual("created_at", time.Now().Add(-time.Hour), repo.EqualOPGT),
 			repo.QueryWithOrderBy("created_at DESC"),
 		)
@@ -2128,6 +2474,7 @@ func (d *Discussion) CreateOrLastSession(ctx context.Context, uid uint, req Crea
 		UserID:  uid,
 		Bot:     true,
 		Summary: false,
+		SiteID:  req.SiteID,
 		Content: fmt.Sprintf("您好！我是%s，很高兴为您服务。有什么问题可以帮您？", bot.Name),
 	})
 	if err != nil {
@@ -2141,6 +2488,8 @@ type DiscussionAskReq struct {
 	Question  string                 `json:"question" binding:"required"`
 	GroupIDs  model.Int64Array       `json:"group_ids"`
 	Source    model.AskSessionSource `json:"source" binding:"oneof=0 1 3"`
+	// Site 通过网页挂件站点提问时，检索范围限制在站点配置内
+	Site *model.WidgetSite `json:"-" swaggerignore:"true"`
 }
 
 func (d *Discussion) AskSessionClosed(ctx context.Context, uid uint, sessionID string) (bool, error) {
@@ -2176,7 +2525,8 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 		return nil, err
 	}
 
-	if !webPlugin.Enabled && !webPlugin.Plugin {
+	// 挂件站点由站点自身的开关控制
+	if req.Site == nil && !webPlugin.Enabled && !webPlugin.Plugin {
 		return nil, errors.New("disabled")
 	}
 
@@ -2198,7 +2548,7 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 
 	var groups []model.GroupItemInfo
 	if len(req.GroupIDs) > 0 {
-		groupIDs, err := d.in.UserRepo.UserGroupIDs(ctx, uid)
+		groupIDs, err := d.askGroupIDs(ctx, uid, req.Site)
 		if err != nil {
 			return nil, err
 		}
@@ -2228,11 +2578,17 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 		Bot:     false,
 		Content: req.Question,
 		Source:  req.Source,
+		SiteID:  req.Site.GetID(),
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	err = d.in.AskFunnelRepo.Question(ctx, req.SessionID, uid, req.Source)
+	if err != nil {
+		d.logger.WithContext(ctx).WithErr(err).Warn("create ask funnel failed")
+	}
+
 	cancelCtx, cancel := context.WithCancel(ctx)
 
 	_, loaded := d.streamStop.LoadOrStore(req.SessionID, cancel)
@@ -2254,6 +2610,8 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 			aiResBuilder strings.Builder
 			canceled     bool
 			needHuman    bool
+			answered     bool
+			unknown      bool
 			cancelText   = "已取消生成"
 		)
 
@@ -2266,7 +2624,7 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 				d.logger.WithContext(ctx).WithErr(err).Warn("wrap stream thinking recv failed")
 				return
 			}
-			autoGroups, err := d.detectAskGroups(ctx, uid, req.Question)
+			autoGroups, err := d.detectAskGroups(ctx, uid, req.Site, req.Question)
 			if err == nil {
 				logger.With("groups", autoGroups).Info("detect ask groups")
 				groups = autoGroups
@@ -2281,17 +2639,38 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 			}
 		}
 
-		stream, err := d.in.LLM.StreamAnswer(cancelCtx, llm.SystemStreamChatPrompt, GenerateReq{
-			Context:       askHistories,
-			Question:      req.Question,
-			Groups:        groups,
-			Prompt:        req.Question,
-			DefaultAnswer: defaultAnswer,
-			NewCommentID:  0,
-			Debug:         d.in.Cfg.RAG.DEBUG,
-		})
+		var (
+			stream *llm.Stream[string]
+			docIDs []string
+		)
+		// 挂件站点限定了检索范围，范围内没有可用分类时不能退化为全量检索
+		if len(groups) == 0 && req.Site.Scoped() {
+			err = errAskOutOfScope
+		} else {
+			question := d.in.Vision.Describe(cancelCtx, req.Question)
+			stream, docIDs, err = d.in.LLM.StreamAnswer(cancelCtx, llm.SystemStreamChatPrompt, GenerateReq{
+				Context:       askHistories,
+				Question:      question,
+				Groups:        groups,
+				Prompt:        question,
+				DefaultAnswer: defaultAnswer,
+				NewCommentID:  0,
+				Debug:         d.in.Cfg.RAG.DEBUG,
+			})
+		}
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
+			if errors.Is(err, errAskOutOfScope) {
+				logger.With("site_id", req.Site.GetID()).Info("no group in site scope, skip answer")
+				unknown = true
+				aiResBuilder.WriteString(defaultAnswer)
+				err = wrapSteam.RecvOne(llm.AskSessionStreamItem{
+					Type:    "text",
+					Content: defaultAnswer,
+				}, true)
+				if err != nil {
+					d.logger.WithContext(ctx).WithErr(err).Warn("wrap stream read failed")
+				}
+			} else if errors.Is(err, context.Canceled) {
 				canceled = true
 				aiResBuilder.WriteString(cancelText)
 				err = wrapSteam.RecvOne(llm.AskSessionStreamItem{
@@ -2331,6 +2710,7 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 								Content: text,
 							}, nil
 						}
+						unknown = true
 						aiResBuilder.WriteString(defaultAnswer)
 						return llm.AskSessionStreamItem{
 							Type:    "text",
@@ -2373,9 +2753,11 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 					case "1":
 						// 能够回答，去掉 "1" 后继续输出
 						text = text[min(length, len(text)):]
+						answered = true
 					case "2":
 						// 无法回答
 						text = defaultAnswer
+						unknown = true
 						if !d.in.Cfg.RAG.DEBUG {
 							ret = true
 						} else {
@@ -2399,6 +2781,7 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 					default:
 						// 没有识别标识，把已读取的内容作为答案的一部分
 						text = answerText.String() + text[min(length, len(text)):]
+						answered = true
 					}
 
 					parsed = true
@@ -2413,6 +2796,24 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 			})
 		}
 
+		var refDocIDs model.Int64Array
+		if answered && !canceled {
+			nowUnix := time.Now().Unix()
+			for _, docID := range docIDs {
+				id, err := strconv.ParseInt(docID, 10, 64)
+				if err != nil {
+					continue
+				}
+
+				refDocIDs = append(refDocIDs, id)
+				d.in.Batcher.Send(model.StatInfo{
+					Type: model.StatTypeKnowledgeHit,
+					Ts:   nowUnix,
+					Key:  docID,
+				})
+			}
+		}
+
 		err = d.in.AskSessionRepo.Create(context.Background(), &model.AskSession{
 			UUID:      req.SessionID,
 			UserID:    uid,
@@ -2420,23 +2821,39 @@ func (d *Discussion) Ask(ctx context.Context, uid uint, req DiscussionAskReq) (*
 			Bot:       true,
 			Canceled:  canceled,
 			NeedHuman: needHuman,
+			DocIDs:    refDocIDs,
 			Content:   aiResBuilder.String(),
+			SiteID:    req.Site.GetID(),
 		})
 		if err != nil {
 			d.logger.WithContext(ctx).Warn("create bot ask session failed")
 			return
 		}
+
+		switch {
+		case needHuman:
+			err = d.in.AskFunnelRepo.Escalate(context.Background(), req.SessionID, model.AskEscalateReasonNeedHuman, 0)
+		case answered, unknown:
+			groupIDs := make(model.Int64Array, len(groups))
+			for i := range groups {
+				groupIDs[i] = int64(groups[i].ID)
+			}
+			err = d.in.AskFunnelRepo.Answer(context.Background(), req.SessionID, answered, groupIDs)
+		}
+		if err != nil {
+			d.logger.WithContext(ctx).WithErr(err).Warn("update ask funnel failed")
+		}
 	}()
 
 	return wrapSteam, nil
 }
 
-func (d *Discussion) detectAskGroups(ctx context.Context, uid uint, question string) ([]model.GroupItemInfo, error) {
+func (d *Discussion) detectAskGroups(ctx context.Context, uid uint, site *model.WidgetSite, question string) ([]model.GroupItemInfo, error) {
 	if strings.TrimSpace(question) == "" {
 		return nil, nil
 	}
 
-	options, infoMap, err := d.buildAskGroupRouteOptions(ctx, uid)
+	options, infoMap, err := d.buildAskGroupRouteOptions(ctx, uid, site)
 	if err != nil || len(options) == 0 {
 		return nil, err
 	}
@@ -2445,9 +2862,6 @@ func (d *Discussion) detectAskGroups(ctx context.Context, uid uint, question str
 	if err != nil {
 		return nil, err
 	}
-	if len(ids) == 0 {
-		return nil, nil
-	}
 
 	var groups []model.GroupItemInfo
 	seen := make(map[uint]struct{}, len(ids))
@@ -2462,11 +2876,27 @@ func (d *Discussion) detectAskGroups(ctx context.Context, uid uint, question str
 		}
 	}
 
+	// 挂件站点没有匹配到分类时，使用站点范围内的全部分类检索
+	if len(groups) == 0 && site.Scoped() {
+		for _, option := range options {
+			groups = append(groups, infoMap[option.ID])
+		}
+	}
+
 	return groups, nil
 }
 
-func (d *Discussion) buildAskGroupRouteOptions(ctx context.Context, uid uint) ([]GroupRouteOption, map[uint]model.GroupItemInfo, error) {
-	groupIDs, err := d.in.UserRepo.UserGroupIDs(ctx, uid)
+// askGroupIDs 提问可以使用的分类组，挂件站点配置了板块时使用站点的板块
+func (d *Discussion) askGroupIDs(ctx context.Context, uid uint, site *model.WidgetSite) (model.Int64Array, error) {
+	if site == nil || len(site.ForumIDs) == 0 {
+		return d.in.UserRepo.UserGroupIDs(ctx, uid)
+	}
+
+	return d.in.UserRepo.ForumGroupIDs(ctx, site.ForumIDs)
+}
+
+func (d *Discussion) buildAskGroupRouteOptions(ctx context.Context, uid uint, site *model.WidgetSite) ([]GroupRouteOption, map[uint]model.GroupItemInfo, error) {
+	groupIDs, err := d.askGroupIDs(ctx, uid, site)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -2474,11 +2904,16 @@ func (d *Discussion) buildAskGroupRouteOptions(ctx context.Context, uid uint) ([
 		return nil, nil, nil
 	}
 
-	var groupItems []model.GroupItem
-	err = d.in.GroupItemRepo.List(ctx, &groupItems,
+	query := []repo.QueryOptFunc{
 		repo.QueryWithEqual("group_id", groupIDs, repo.EqualOPEqAny),
 		repo.QueryWithOrderBy("group_id ASC, \"index\" ASC"),
-	)
+	}
+	if site != nil && len(site.GroupIDs) > 0 {
+		query = append(query, repo.QueryWithEqual("id", site.GroupIDs, repo.EqualOPEqAny))
+	}
+
+	var groupItems []model.GroupItem
+	err = d.in.GroupItemRepo.List(ctx, &groupItems, query...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -2712,6 +3147,11 @@ func (d *Discussion) SummaryByContent(ctx context.Context, uid uint, req Summary
 
 	lastContent := histories[len(histories)-1]
 
+	err = d.in.AskFunnelRepo.Escalate(ctx, req.SessionID, model.AskEscalateReasonManual, req.ForumID)
+	if err != nil {
+		d.logger.WithContext(ctx).WithErr(err).Warn("escalate ask funnel failed")
+	}
+
 	wrapSteam := llm.NewStream[llm.AskSessionStreamItem]()
 
 	cancelCtx, cancel := context.WithCancel(ctx)
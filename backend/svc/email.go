@@ -0,0 +1,584 @@
+package svc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/email"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/ratelimit"
+	"github.com/chaitin/koalaqa/pkg/upload"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+)
+
+var errEmailDisabled = errors.New("email gateway disabled")
+
+type emailIn struct {
+	fx.In
+
+	SysRepo    *repo.System
+	EmailRepo  *repo.EmailMessage
+	UserRepo   *repo.User
+	OrgRepo    *repo.Org
+	ForumRepo  *repo.Forum
+	DiscRepo   *repo.Discussion
+	CommRepo   *repo.Comment
+	Auth       *Auth
+	Disc       *Discussion
+	Review     *UserReview
+	Upload     *Upload
+	PublicAddr *PublicAddress
+	Limiter    ratelimit.Limiter
+}
+
+type Email struct {
+	in     emailIn
+	logger *glog.Logger
+}
+
+func newEmail(in emailIn) *Email {
+	return &Email{
+		in:     in,
+		logger: glog.Module("svc", "email"),
+	}
+}
+
+func (e *Email) Get(ctx context.Context) (*model.SystemEmail, error) {
+	var res model.SystemEmail
+	err := e.in.SysRepo.GetValueByKey(ctx, &res, model.SystemKeyEmail)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return &res, nil
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (e *Email) Update(ctx context.Context, req model.SystemEmail) error {
+	if req.Enabled {
+		if req.ForumID == 0 {
+			return errors.New("empty forum_id")
+		}
+
+		if req.Secret == "" {
+			return errors.New("empty secret")
+		}
+
+		if req.AuthServID == "" {
+			return errors.New("empty authserv_id")
+		}
+
+		exist, err := e.in.ForumRepo.ExistByID(ctx, req.ForumID)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return errors.New("forum not exist")
+		}
+	}
+
+	return e.in.SysRepo.Upsert(ctx, &model.System[any]{
+		Key:   model.SystemKeyEmail,
+		Value: model.NewJSONBAny(req),
+	})
+}
+
+// user 按发件地址查找用户，不存在时创建，开启注册审核时创建为访客
+func (e *Email) user(ctx context.Context, from mail.Address) (*model.User, error) {
+	var user model.User
+	err := e.in.UserRepo.GetByEmail(ctx, &user, from.Address)
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	auth, err := e.in.Auth.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := e.in.OrgRepo.GetDefaultOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := from.Name
+	if name == "" {
+		name, _, _ = strings.Cut(from.Address, "@")
+	}
+
+	user = model.User{
+		UserBasic: model.UserBasic{
+			Name:      name,
+			Email:     from.Address,
+			Role:      model.UserRoleUser,
+			OrgIDs:    model.Int64Array{int64(org.ID)},
+			WebNotify: true,
+		},
+		Key: uuid.NewString(),
+	}
+	if auth.NeedReview {
+		user.Role = model.UserRoleGuest
+	}
+
+	err = e.in.UserRepo.Create(ctx, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// replySign 回复令牌签名，令牌绑定帖子和收件用户
+func replySign(secret string, discID uint, uid uint) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "email-reply:%d:%d", discID, uid)
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// replyAddress 生成携带回复令牌的 Reply-To 地址，需要 MTA 将 "+" 子地址投递到发件地址
+func replyAddress(cfg *model.SystemEmail, discID uint, uid uint) string {
+	i := strings.LastIndex(cfg.SMTP.From, "@")
+	if i <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s+r%d.%d.%s%s", cfg.SMTP.From[:i], discID, uid, replySign(cfg.Secret, discID, uid), cfg.SMTP.From[i:])
+}
+
+// replyToken 从收件地址中解析并校验回复令牌，返回帖子 id 和用户 id
+func replyToken(cfg *model.SystemEmail, recipients []string) (uint, uint, bool) {
+	i := strings.LastIndex(cfg.SMTP.From, "@")
+	if i <= 0 {
+		return 0, 0, false
+	}
+
+	prefix := strings.ToLower(cfg.SMTP.From[:i]) + "+r"
+	suffix := strings.ToLower(cfg.SMTP.From[i:])
+	for _, addr := range recipients {
+		if len(addr) <= len(prefix)+len(suffix) || !strings.HasPrefix(addr, prefix) || !strings.HasSuffix(addr, suffix) {
+			continue
+		}
+
+		parts := strings.Split(addr[len(prefix):len(addr)-len(suffix)], ".")
+		if len(parts) != 3 {
+			continue
+		}
+
+		discID, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		uid, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if hmac.Equal([]byte(parts[2]), []byte(replySign(cfg.Secret, uint(discID), uint(uid)))) {
+			return uint(discID), uint(uid), true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// thread 根据 In-Reply-To/References 查找邮件所属帖子
+func (e *Email) thread(ctx context.Context, ids []string) (*model.EmailMessage, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var res model.EmailMessage
+	err := e.in.EmailRepo.Get(ctx, &res, repo.QueryWithEqual("message_id", ids, repo.EqualOPIn),
+		repo.QueryWithOrderBy("id DESC"))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (e *Email) attachments(ctx context.Context, uid uint, m *email.Mail) string {
+	var builder strings.Builder
+	for _, item := range m.Attachments {
+		path, err := e.in.Upload.upload(ctx, uploadReq{
+			UserID:   uid,
+			Scene:    upload.SceneDiscussion,
+			Dir:      e.in.Disc.ossDir(""),
+			Data:     item.Data,
+			Filename: item.Filename,
+			Quota:    true,
+		})
+		if err != nil {
+			e.logger.WithContext(ctx).WithErr(err).With("filename", item.Filename).Warn("upload email attachment failed")
+			continue
+		}
+
+		if upload.IsImage(item.ContentType) {
+			builder.WriteString(fmt.Sprintf("\n\n![%s](%s)", item.Filename, path))
+		} else {
+			builder.WriteString(fmt.Sprintf("\n\n[%s](%s)", item.Filename, path))
+		}
+	}
+
+	return builder.String()
+}
+
+func emailTitle(m *email.Mail, content string) string {
+	title := m.Subject
+	if title == "" {
+		title, _, _ = strings.Cut(content, "\n")
+	}
+
+	if utf8.RuneCountInString(title) > 100 {
+		title = string([]rune(title)[:100])
+	}
+
+	return strings.TrimSpace(title)
+}
+
+// Inbound 处理 MTA 投递的原始邮件，新邮件发布为问题，回复邮件归并为评论
+func (e *Email) Inbound(ctx context.Context, secret string, raw []byte) error {
+	cfg, err := e.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Enabled {
+		return errEmailDisabled
+	}
+
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(cfg.Secret)) != 1 {
+		return errPermission
+	}
+
+	m, err := email.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	logger := e.logger.WithContext(ctx).With("message_id", m.MessageID).With("from", m.From.Address)
+	if m.AutoReply || strings.EqualFold(m.From.Address, cfg.SMTP.From) {
+		logger.Info("ignore auto reply email")
+		return nil
+	}
+
+	if m.MessageID == "" {
+		m.MessageID = email.NewMessageID(m.From.Address)
+	} else {
+		exist, err := e.in.EmailRepo.Exist(ctx, repo.QueryWithEqual("message_id", m.MessageID))
+		if err != nil {
+			return err
+		}
+		if exist {
+			logger.Info("email already handled")
+			return nil
+		}
+	}
+
+	var (
+		user   *model.User
+		discID uint
+	)
+	if tokenDiscID, uid, ok := replyToken(cfg, m.Recipients); ok {
+		var tokenUser model.User
+		err = e.in.UserRepo.GetByID(ctx, &tokenUser, uid)
+		if err != nil {
+			if errors.Is(err, database.ErrRecordNotFound) {
+				logger.With("uid", uid).Info("reply token user not found, skip")
+				return nil
+			}
+
+			return err
+		}
+
+		user = &tokenUser
+		discID = tokenDiscID
+	} else {
+		// 没有回复令牌时只信任通过 SPF/DKIM 校验的发件人，避免冒用他人邮箱
+		if !m.Authenticated(cfg.AuthServID) {
+			logger.Info("sender not authenticated, skip")
+			return nil
+		}
+
+		user, err = e.user(ctx, m.From)
+		if err != nil {
+			return err
+		}
+
+		thread, err := e.thread(ctx, m.ThreadIDs())
+		if err != nil {
+			return err
+		}
+		if thread != nil {
+			discID = thread.DiscussionID
+		}
+	}
+
+	// 邮件无法提供与登录同等的身份保证，不映射到管理员和运营账号
+	if user.Role == model.UserRoleAdmin || user.Role == model.UserRoleOperator {
+		logger.With("uid", user.ID).Warn("email from privileged account, skip")
+		return nil
+	}
+
+	if user.Blocked(time.Now()) {
+		logger.Info("sender blocked, skip")
+		return nil
+	}
+
+	userInfo := model.UserInfo{
+		UserCore: model.UserCore{
+			UID: user.ID,
+			Key: user.Key,
+		},
+		UserBasic: user.UserBasic,
+	}
+
+	if user.Role == model.UserRoleGuest {
+		_, domain, _ := strings.Cut(m.From.Address, "@")
+		if !e.in.Limiter.Allow("email_guest:sender:"+m.From.Address, time.Hour, 1) ||
+			!e.in.Limiter.Allow("email_guest:domain:"+domain, time.Hour, 20) {
+			logger.Info("guest email ratelimit, skip")
+			return nil
+		}
+
+		err = e.in.Review.GuestCreate(ctx, userInfo, UserReviewGuestCreateReq{
+			Reason: "邮件提问：" + m.Subject,
+		})
+		if err != nil {
+			logger.WithErr(err).Debug("create guest review failed")
+		}
+
+		// 只回信给通过校验的发件人，避免伪造发件地址造成退信骚扰
+		if m.Authenticated(cfg.AuthServID) {
+			e.send(ctx, cfg, m.From, m.MessageID, "Re: "+m.Subject, "您的账号正在审核中，审核通过后再发送邮件即可提问。")
+		}
+		return nil
+	}
+
+	if discID != 0 {
+		return e.reply(ctx, user, discID, m)
+	}
+
+	content := m.Text + e.attachments(ctx, user.ID, m)
+	if strings.TrimSpace(content) == "" {
+		logger.Info("empty email content, skip")
+		return nil
+	}
+
+	discUUID, err := e.in.Disc.Create(ctx, userInfo, DiscussionCreateReq{
+		Title:     emailTitle(m, content),
+		Content:   content,
+		Type:      model.DiscussionTypeQA,
+		ForumID:   cfg.ForumID,
+		skipLimit: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	disc, err := e.in.DiscRepo.GetByUUID(ctx, discUUID)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.in.EmailRepo.Claim(ctx, &model.EmailMessage{
+		MessageID:    m.MessageID,
+		DiscussionID: disc.ID,
+		UserID:       user.ID,
+		Inbound:      true,
+	})
+	return err
+}
+
+func (e *Email) reply(ctx context.Context, user *model.User, discID uint, m *email.Mail) error {
+	logger := e.logger.WithContext(ctx).With("message_id", m.MessageID).With("disc_id", discID).With("uid", user.ID)
+
+	var disc model.Discussion
+	err := e.in.DiscRepo.GetByID(ctx, &disc, discID)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	// 与 HTTP 评论接口一致，校验用户对帖子所在板块的访问和评论权限
+	err = e.in.Disc.CheckPerm(ctx, user.ID, &disc)
+	if err != nil {
+		logger.WithErr(err).Info("no permission to reply, skip")
+		return nil
+	}
+
+	content := email.TrimQuote(m.Text) + e.attachments(ctx, user.ID, m)
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+
+	commentID, err := e.in.Disc.CreateComment(ctx, user.ID, disc.UUID, CommentCreateReq{
+		Content: content,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = e.in.EmailRepo.Claim(ctx, &model.EmailMessage{
+		MessageID:    m.MessageID,
+		DiscussionID: disc.ID,
+		CommentID:    commentID,
+		UserID:       user.ID,
+		Inbound:      true,
+	})
+	return err
+}
+
+func (e *Email) send(ctx context.Context, cfg *model.SystemEmail, to mail.Address, inReplyTo string, subject string, text string) {
+	err := email.Send(cfg.SMTP, cfg.Name, email.OutMail{
+		MessageID:  email.NewMessageID(cfg.SMTP.From),
+		To:         to,
+		Subject:    subject,
+		Text:       text,
+		InReplyTo:  inReplyTo,
+		References: []string{inReplyTo},
+	})
+	if err != nil {
+		e.logger.WithContext(ctx).WithErr(err).With("to", to.Address).Warn("send email failed")
+	}
+}
+
+// NotifyComment 将通过邮件发布的帖子下的新回答（包括 AI 回答）发送给提问者
+func (e *Email) NotifyComment(ctx context.Context, commentID uint) error {
+	cfg, err := e.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Enabled || cfg.SMTP.Host == "" {
+		return nil
+	}
+
+	var comment model.Comment
+	err = e.in.CommRepo.GetByID(ctx, &comment, commentID)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	var root model.EmailMessage
+	err = e.in.EmailRepo.Get(ctx, &root, repo.QueryWithEqual("discussion_id", comment.DiscussionID),
+		repo.QueryWithEqual("comment_id", 0))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if comment.UserID == root.UserID {
+		return nil
+	}
+
+	var disc model.Discussion
+	err = e.in.DiscRepo.GetByID(ctx, &disc, comment.DiscussionID)
+	if err != nil {
+		return err
+	}
+
+	var author model.User
+	err = e.in.UserRepo.GetByID(ctx, &author, root.UserID)
+	if err != nil {
+		return err
+	}
+
+	if author.Email == "" {
+		return nil
+	}
+
+	var commenter model.User
+	err = e.in.UserRepo.GetByID(ctx, &commenter, comment.UserID)
+	if err != nil {
+		return err
+	}
+
+	var forum model.Forum
+	err = e.in.ForumRepo.GetByID(ctx, &forum, disc.ForumID)
+	if err != nil {
+		return err
+	}
+
+	publicAddr, err := e.in.PublicAddr.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	out := model.EmailMessage{
+		MessageID:    email.NewMessageID(cfg.SMTP.From),
+		DiscussionID: disc.ID,
+		CommentID:    comment.ID,
+		UserID:       comment.UserID,
+	}
+	// 评论由邮件回复创建或已经发送过时跳过
+	ok, err := e.in.EmailRepo.Claim(ctx, &out)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	text := fmt.Sprintf("%s 回复：\n\n%s\n\n---\n直接回复此邮件即可继续追问，查看详情：%s",
+		commenter.Name, comment.Content, publicAddr.FullURL("/"+forum.RouteName+"/"+disc.UUID))
+
+	err = email.Send(cfg.SMTP, cfg.Name, email.OutMail{
+		MessageID:  out.MessageID,
+		To:         mail.Address{Name: author.Name, Address: author.Email},
+		ReplyTo:    replyAddress(cfg, disc.ID, author.ID),
+		Subject:    "Re: " + disc.Title,
+		Text:       text,
+		InReplyTo:  root.MessageID,
+		References: []string{root.MessageID},
+	})
+	if err != nil {
+		e.logger.WithContext(ctx).WithErr(err).With("comment_id", comment.ID).Warn("send comment email failed")
+		if e := e.in.EmailRepo.DeleteByID(ctx, out.ID); e != nil {
+			return e
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func init() {
+	registerSvc(newEmail)
+}
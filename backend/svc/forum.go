@@ -38,23 +38,28 @@ type ForumRes struct {
 	Tags  []ForumTag  `json:"tags" gorm:"-"`
 }
 
+// VisibleIDs 用户可以访问的论坛，未登录用户使用默认组织的权限
+func (f *Forum) VisibleIDs(ctx context.Context, user model.UserInfo) (model.Int64Array, error) {
+	if user.UID == 0 {
+		org, err := f.repoOrg.GetDefaultOrg(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return org.ForumIDs, nil
+	}
+
+	return f.repoOrg.ListForumIDs(ctx, user.OrgIDs...)
+}
+
 func (f *Forum) List(ctx context.Context, user model.UserInfo, permissionCheck bool) ([]*ForumRes, error) {
 	var forumIDs model.Int64Array
 
 	if permissionCheck {
 		var err error
-		if user.UID == 0 {
-			org, err := f.repoOrg.GetDefaultOrg(ctx)
-			if err != nil {
-				return nil, err
-			}
-
-			forumIDs = org.ForumIDs
-		} else {
-			forumIDs, err = f.repoOrg.ListForumIDs(ctx, user.OrgIDs...)
-			if err != nil {
-				return nil, err
-			}
+		forumIDs, err = f.VisibleIDs(ctx, user)
+		if err != nil {
+			return nil, err
 		}
 
 		if len(forumIDs) == 0 {
@@ -0,0 +1,521 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	koalaCache "github.com/chaitin/koalaqa/pkg/cache"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/gql"
+	"github.com/chaitin/koalaqa/pkg/restapi"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"go.uber.org/fx"
+)
+
+const (
+	graphQLMaxDepth      = 8
+	graphQLMaxComplexity = 5000
+	graphQLPersistedTTL  = time.Hour * 24 * 7
+)
+
+// GraphQL 门户页面使用的只读 graphql 接口，权限与页面接口一致
+type GraphQL struct {
+	in        graphQLIn
+	schema    graphql.Schema
+	persisted *gql.PersistedStore
+	limit     gql.Limit
+}
+
+type graphQLIn struct {
+	fx.In
+
+	SvcDisc  *Discussion
+	SvcForum *Forum
+	Disc     *repo.Discussion
+	Forum    *repo.Forum
+	Comment  *repo.Comment
+	User     *repo.User
+	KB       *repo.KnowledgeBase
+	KBDoc    *repo.KBDocument
+	Cache    koalaCache.Backend
+}
+
+func newGraphQL(in graphQLIn) (*GraphQL, error) {
+	g := &GraphQL{
+		in:        in,
+		persisted: gql.NewPersistedStore(in.Cache, graphQLPersistedTTL),
+		limit: gql.Limit{
+			MaxDepth:      graphQLMaxDepth,
+			MaxComplexity: graphQLMaxComplexity,
+		},
+	}
+
+	schema, err := g.buildSchema()
+	if err != nil {
+		return nil, err
+	}
+	g.schema = schema
+
+	return g, nil
+}
+
+func init() {
+	registerSvc(newGraphQL)
+}
+
+type gqlStateKey struct{}
+
+// gqlState 单次请求内共享的用户信息与批量加载器
+type gqlState struct {
+	ctx      context.Context
+	user     model.UserInfo
+	forumIDs model.Int64Array
+
+	users     *gql.Loader[uint, *restapi.User]
+	forums    *gql.Loader[uint, *restapi.Forum]
+	contents  *gql.Loader[uint, string]
+	markdowns *gql.Loader[uint, string]
+	comments  map[int]*gql.Loader[uint, []restapi.Comment]
+}
+
+func gqlStateFrom(ctx context.Context) *gqlState {
+	return ctx.Value(gqlStateKey{}).(*gqlState)
+}
+
+func (s *gqlState) canView(forumID uint) bool {
+	return slices.Contains(s.forumIDs, int64(forumID))
+}
+
+func (s *gqlState) canManage() bool {
+	return s.user.Role == model.UserRoleAdmin || s.user.Role == model.UserRoleOperator
+}
+
+type gqlConnection struct {
+	Nodes      any
+	NextCursor *string
+}
+
+func newGQLConnection[T any](l *restapi.List[T]) *gqlConnection {
+	nodes := make([]*T, 0, len(l.Data))
+	for i := range l.Data {
+		nodes = append(nodes, &l.Data[i])
+	}
+
+	res := &gqlConnection{Nodes: nodes}
+	if l.NextCursor != "" {
+		res.NextCursor = &l.NextCursor
+	}
+
+	return res
+}
+
+type gqlKnowledgeBase struct {
+	ID   uint
+	Name string
+	Desc string
+}
+
+func gqlErrorResult(err error) *graphql.Result {
+	formatted := gqlerrors.FormatError(err)
+	if errors.Is(err, gql.ErrPersistedQueryNotFound) {
+		formatted.Extensions = map[string]any{"code": "PERSISTED_QUERY_NOT_FOUND"}
+	}
+
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}}
+}
+
+func (g *GraphQL) newState(ctx context.Context, user model.UserInfo) (*gqlState, error) {
+	forumIDs, err := g.in.SvcForum.VisibleIDs(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gqlState{
+		ctx:       ctx,
+		user:      user,
+		forumIDs:  forumIDs,
+		users:     gql.NewLoader(ctx, g.loadUsers),
+		forums:    gql.NewLoader(ctx, g.loadForums),
+		contents:  gql.NewLoader(ctx, g.loadContents),
+		markdowns: gql.NewLoader(ctx, g.loadMarkdowns),
+		comments:  make(map[int]*gql.Loader[uint, []restapi.Comment]),
+	}, nil
+}
+
+func (g *GraphQL) Execute(ctx context.Context, user model.UserInfo, req gql.Request) *graphql.Result {
+	err := g.persisted.Resolve(&req)
+	if err != nil {
+		return gqlErrorResult(err)
+	}
+
+	doc, err := gql.Parse(req.Query)
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(&g.schema, doc, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	err = g.limit.Check(&g.schema, doc, req.OperationName, req.Variables)
+	if err != nil {
+		return gqlErrorResult(err)
+	}
+
+	state, err := g.newState(ctx, user)
+	if err != nil {
+		return gqlErrorResult(err)
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        g.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(ctx, gqlStateKey{}, state),
+	})
+}
+
+func (g *GraphQL) loadUsers(ctx context.Context, ids []uint) (map[uint]*restapi.User, error) {
+	var users []model.User
+	err := g.in.User.List(ctx, &users, repo.QueryWithEqual("id", model.Int64Array(gqlIDs(ids)), repo.EqualOPEqAny))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[uint]*restapi.User, len(users))
+	for _, u := range users {
+		res[u.ID] = &restapi.User{
+			ID:        u.ID,
+			Name:      u.Name,
+			Avatar:    u.Avatar,
+			Intro:     u.Intro,
+			Role:      restUserRoles[u.Role],
+			Point:     u.Point,
+			CreatedAt: int64(u.CreatedAt),
+		}
+	}
+
+	return res, nil
+}
+
+func (g *GraphQL) loadForums(ctx context.Context, ids []uint) (map[uint]*restapi.Forum, error) {
+	var forums []model.Forum
+	err := g.in.Forum.List(ctx, &forums, repo.QueryWithEqual("id", model.Int64Array(gqlIDs(ids)), repo.EqualOPEqAny))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[uint]*restapi.Forum, len(forums))
+	for _, f := range forums {
+		forum := restForum(f)
+		res[f.ID] = &forum
+	}
+
+	return res, nil
+}
+
+func (g *GraphQL) loadContents(ctx context.Context, ids []uint) (map[uint]string, error) {
+	var discs []model.Discussion
+	err := g.in.Disc.List(ctx, &discs,
+		repo.QueryWithEqual("discussions.id", model.Int64Array(gqlIDs(ids)), repo.EqualOPEqAny),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[uint]string, len(discs))
+	for _, d := range discs {
+		res[d.ID] = d.Content
+	}
+
+	return res, nil
+}
+
+func (g *GraphQL) loadMarkdowns(ctx context.Context, ids []uint) (map[uint]string, error) {
+	var docs []model.KBDocument
+	err := g.in.KBDoc.List(ctx, &docs,
+		repo.QueryWithSelectColumn("id", "markdown"),
+		repo.QueryWithEqual("id", model.Int64Array(gqlIDs(ids)), repo.EqualOPEqAny),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[uint]string, len(docs))
+	for _, d := range docs {
+		res[d.ID] = string(d.Markdown)
+	}
+
+	return res, nil
+}
+
+func (g *GraphQL) commentLoader(state *gqlState, limit int) *gql.Loader[uint, []restapi.Comment] {
+	loader, ok := state.comments[limit]
+	if ok {
+		return loader
+	}
+
+	loader = gql.NewLoader(state.ctx, func(ctx context.Context, ids []uint) (map[uint][]restapi.Comment, error) {
+		var comments []model.Comment
+		// 多查询一条用于判断是否有下一页
+		err := g.in.Comment.ListFirstByDiscussions(ctx, &comments, model.Int64Array(gqlIDs(ids)), limit+1)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make(map[uint][]restapi.Comment)
+		for _, c := range comments {
+			res[c.DiscussionID] = append(res[c.DiscussionID], gqlComment(c))
+		}
+
+		return res, nil
+	})
+	state.comments[limit] = loader
+
+	return loader
+}
+
+func gqlIDs(ids []uint) []int64 {
+	res := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, int64(id))
+	}
+
+	return res
+}
+
+func gqlComment(c model.Comment) restapi.Comment {
+	return restapi.Comment{
+		ID:           c.ID,
+		DiscussionID: c.DiscussionID,
+		ParentID:     c.ParentID,
+		Content:      c.Content,
+		Accepted:     c.Accepted,
+		Bot:          c.Bot,
+		Author:       restapi.UserRef{ID: c.UserID},
+		Like:         c.Like,
+		Dislike:      c.Dislike,
+		CreatedAt:    int64(c.CreatedAt),
+		UpdatedAt:    int64(c.UpdatedAt),
+	}
+}
+
+func gqlPage(args map[string]any) restapi.Page {
+	var page restapi.Page
+	page.Limit, _ = args[gql.ListArg].(int)
+	page.Cursor, _ = args["after"].(string)
+
+	return page
+}
+
+func gqlArgID(args map[string]any, key string) uint {
+	id, _ := args[key].(int)
+	if id < 0 {
+		return 0
+	}
+
+	return uint(id)
+}
+
+func (g *GraphQL) listDiscussions(ctx context.Context, forumID uint, typ string, page restapi.Page) (*gqlConnection, error) {
+	state := gqlStateFrom(ctx)
+
+	query, limit, err := restPageQuery(page, "discussions.id", false)
+	if err != nil {
+		return nil, err
+	}
+
+	if forumID > 0 {
+		if !state.canView(forumID) {
+			return nil, errPermission
+		}
+		query = append(query, repo.QueryWithEqual("discussions.forum_id", forumID))
+	} else {
+		if len(state.forumIDs) == 0 {
+			return newGQLConnection(&restapi.List[restapi.Discussion]{}), nil
+		}
+		query = append(query, repo.QueryWithEqual("discussions.forum_id", state.forumIDs, repo.EqualOPEqAny))
+	}
+
+	if typ != "" {
+		query = append(query, repo.QueryWithEqual("discussions.type", typ))
+	}
+
+	var discs []model.DiscussionListItem
+	err = g.in.Disc.List(ctx, &discs, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]restapi.Discussion, 0, len(discs))
+	for _, d := range discs {
+		items = append(items, restDiscussion(d))
+	}
+
+	return newGQLConnection(restapi.NewList(items, limit, func(d restapi.Discussion) uint { return d.ID })), nil
+}
+
+func (g *GraphQL) getDiscussion(ctx context.Context, id uint, uuid string) (*restapi.Discussion, error) {
+	state := gqlStateFrom(ctx)
+
+	var query repo.QueryOptFunc
+	switch {
+	case id > 0:
+		query = repo.QueryWithEqual("discussions.id", id)
+	case uuid != "":
+		query = repo.QueryWithEqual("discussions.uuid", uuid)
+	default:
+		return nil, errors.New("id or uuid is required")
+	}
+
+	var disc model.DiscussionListItem
+	err := g.in.Disc.Get(ctx, &disc, query)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	err = g.in.SvcDisc.CheckViewPerm(ctx, state.user.UID, disc.ForumID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := restDiscussion(disc)
+	res.Content = disc.Content
+	return &res, nil
+}
+
+func (g *GraphQL) listComments(ctx context.Context, disc *restapi.Discussion, page restapi.Page) (any, error) {
+	state := gqlStateFrom(ctx)
+
+	if page.Cursor == "" {
+		limit := gql.ListLimit(page.Limit)
+		thunk := g.commentLoader(state, limit).Load(disc.ID)
+		return func() (any, error) {
+			comments, err := thunk()
+			if err != nil {
+				return nil, err
+			}
+
+			return newGQLConnection(restapi.NewList(comments, limit, func(c restapi.Comment) uint { return c.ID })), nil
+		}, nil
+	}
+
+	query, limit, err := restPageQuery(page, "comments.id", true)
+	if err != nil {
+		return nil, err
+	}
+	query = append(query, repo.QueryWithEqual("comments.discussion_id", disc.ID))
+
+	var comments []model.CommentDetail
+	err = g.in.Comment.List(ctx, &comments, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]restapi.Comment, 0, len(comments))
+	for _, c := range comments {
+		items = append(items, gqlComment(c.Comment))
+	}
+
+	return newGQLConnection(restapi.NewList(items, limit, func(c restapi.Comment) uint { return c.ID })), nil
+}
+
+func (g *GraphQL) listForums(ctx context.Context) ([]*restapi.Forum, error) {
+	state := gqlStateFrom(ctx)
+	res := make([]*restapi.Forum, 0)
+	if len(state.forumIDs) == 0 {
+		return res, nil
+	}
+
+	var forums []model.Forum
+	err := g.in.Forum.List(ctx, &forums,
+		repo.QueryWithEqual("id", state.forumIDs, repo.EqualOPEqAny),
+		repo.QueryWithOrderBy("index ASC"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range forums {
+		forum := restForum(f)
+		res = append(res, &forum)
+	}
+
+	return res, nil
+}
+
+func (g *GraphQL) listKnowledgeBases(ctx context.Context) ([]*gqlKnowledgeBase, error) {
+	if !gqlStateFrom(ctx).canManage() {
+		return nil, errPermission
+	}
+
+	var kbs []model.KnowledgeBase
+	err := g.in.KB.List(ctx, &kbs, repo.QueryWithOrderBy("id ASC"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*gqlKnowledgeBase, 0, len(kbs))
+	for _, kb := range kbs {
+		res = append(res, &gqlKnowledgeBase{ID: kb.ID, Name: kb.Name, Desc: kb.Desc})
+	}
+
+	return res, nil
+}
+
+func (g *GraphQL) listDocuments(ctx context.Context, kbID uint, page restapi.Page) (*gqlConnection, error) {
+	query, limit, err := restPageQuery(page, "id", false)
+	if err != nil {
+		return nil, err
+	}
+	query = append(query,
+		repo.QueryWithSelectColumn("id", "kb_id", "parent_id", "doc_type", "title", "desc", "created_at", "updated_at"),
+		repo.QueryWithEqual("kb_id", kbID),
+		repo.QueryWithEqual("status", model.DocStatusApplySuccess),
+	)
+
+	var docs []model.KBDocument
+	err = g.in.KBDoc.List(ctx, &docs, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]restapi.Document, 0, len(docs))
+	for _, d := range docs {
+		items = append(items, restDocument(d))
+	}
+
+	return newGQLConnection(restapi.NewList(items, limit, func(d restapi.Document) uint { return d.ID })), nil
+}
+
+func (g *GraphQL) getDocument(ctx context.Context, id uint) (*restapi.Document, error) {
+	if !gqlStateFrom(ctx).canManage() {
+		return nil, errPermission
+	}
+
+	var docs []model.KBDocument
+	err := g.in.KBDoc.List(ctx, &docs,
+		repo.QueryWithEqual("id", id),
+		repo.QueryWithEqual("status", model.DocStatusApplySuccess),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	res := restDocument(docs[0])
+	res.Markdown = string(docs[0].Markdown)
+	return &res, nil
+}
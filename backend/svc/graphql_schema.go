@@ -0,0 +1,307 @@
+package svc
+
+import (
+	"time"
+
+	"github.com/chaitin/koalaqa/pkg/gql"
+	"github.com/chaitin/koalaqa/pkg/restapi"
+	"github.com/graphql-go/graphql"
+)
+
+func gqlListArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		gql.ListArg: &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: gql.DefaultListLimit,
+			Description:  "每页数量，最大 100",
+		},
+		"after": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "上一页返回的 nextCursor",
+		},
+	}
+	for k, v := range extra {
+		args[k] = v
+	}
+
+	return args
+}
+
+func gqlConnectionType(node *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: node.Name() + "Connection",
+		Fields: graphql.Fields{
+			"nodes":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(node)))},
+			"nextCursor": &graphql.Field{Type: graphql.String},
+		},
+	})
+}
+
+func gqlTime(get func(source any) int64) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.DateTime,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return time.Unix(get(p.Source), 0), nil
+		},
+	}
+}
+
+func (g *GraphQL) buildSchema() (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"avatar":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"intro":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"role":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"point":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"createdAt": gqlTime(func(s any) int64 { return s.(*restapi.User).CreatedAt }),
+		},
+	})
+
+	loadUser := func(p graphql.ResolveParams, id uint) (any, error) {
+		if id == 0 {
+			return nil, nil
+		}
+
+		thunk := gqlStateFrom(p.Context).users.Load(id)
+		return func() (any, error) {
+			return thunk()
+		}, nil
+	}
+
+	commentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Comment",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"discussionId": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"parentId":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "为 0 表示回答，否则为对回答的评论"},
+			"content":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"accepted":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"bot":          &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"like":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"dislike":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"createdAt":    gqlTime(func(s any) int64 { return s.(*restapi.Comment).CreatedAt }),
+			"updatedAt":    gqlTime(func(s any) int64 { return s.(*restapi.Comment).UpdatedAt }),
+			"author": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadUser(p, p.Source.(*restapi.Comment).Author.ID)
+				},
+			},
+		},
+	})
+	commentConnType := gqlConnectionType(commentType)
+
+	forumType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Forum",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"routeName": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"index":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	discussionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Discussion",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"uuid":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"forumId":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"type":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"title":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"summary":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"tagIds":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.Int)))},
+			"resolved":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"closed":      &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"like":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"view":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"commentsNum": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"createdAt":   gqlTime(func(s any) int64 { return s.(*restapi.Discussion).CreatedAt }),
+			"updatedAt":   gqlTime(func(s any) int64 { return s.(*restapi.Discussion).UpdatedAt }),
+			"content": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					disc := p.Source.(*restapi.Discussion)
+					if disc.Content != "" {
+						return disc.Content, nil
+					}
+
+					thunk := gqlStateFrom(p.Context).contents.Load(disc.ID)
+					return func() (any, error) {
+						return thunk()
+					}, nil
+				},
+			},
+			"forum": &graphql.Field{
+				Type: forumType,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					thunk := gqlStateFrom(p.Context).forums.Load(p.Source.(*restapi.Discussion).ForumID)
+					return func() (any, error) {
+						return thunk()
+					}, nil
+				},
+			},
+			"author": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadUser(p, p.Source.(*restapi.Discussion).Author.ID)
+				},
+			},
+			"comments": &graphql.Field{
+				Type: graphql.NewNonNull(commentConnType),
+				Args: gqlListArgs(nil),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return g.listComments(p.Context, p.Source.(*restapi.Discussion), gqlPage(p.Args))
+				},
+			},
+		},
+	})
+	discussionConnType := gqlConnectionType(discussionType)
+
+	discussionArgs := gqlListArgs(graphql.FieldConfigArgument{
+		"type": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "qa / feedback / blog / issue",
+		},
+	})
+
+	forumType.AddFieldConfig("discussions", &graphql.Field{
+		Type: graphql.NewNonNull(discussionConnType),
+		Args: discussionArgs,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			typ, _ := p.Args["type"].(string)
+			return g.listDiscussions(p.Context, p.Source.(*restapi.Forum).ID, typ, gqlPage(p.Args))
+		},
+	})
+
+	documentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Document",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"kbId":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"parentId":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"type":      &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "question / document / space / web"},
+			"title":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"desc":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": gqlTime(func(s any) int64 { return s.(*restapi.Document).CreatedAt }),
+			"updatedAt": gqlTime(func(s any) int64 { return s.(*restapi.Document).UpdatedAt }),
+			"markdown": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					doc := p.Source.(*restapi.Document)
+					if doc.Markdown != "" {
+						return doc.Markdown, nil
+					}
+
+					thunk := gqlStateFrom(p.Context).markdowns.Load(doc.ID)
+					return func() (any, error) {
+						return thunk()
+					}, nil
+				},
+			},
+		},
+	})
+
+	kbType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "KnowledgeBase",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"desc": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"documents": &graphql.Field{
+				Type: graphql.NewNonNull(gqlConnectionType(documentType)),
+				Args: gqlListArgs(nil),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return g.listDocuments(p.Context, p.Source.(*gqlKnowledgeBase).ID, gqlPage(p.Args))
+				},
+			},
+		},
+	})
+
+	idArg := graphql.FieldConfigArgument{
+		"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "当前登录用户，未登录时为 null",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadUser(p, gqlStateFrom(p.Context).user.UID)
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadUser(p, gqlArgID(p.Args, "id"))
+				},
+			},
+			"forums": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(forumType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return g.listForums(p.Context)
+				},
+			},
+			"forum": &graphql.Field{
+				Type: forumType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := gqlArgID(p.Args, "id")
+					if !gqlStateFrom(p.Context).canView(id) {
+						return nil, nil
+					}
+
+					thunk := gqlStateFrom(p.Context).forums.Load(id)
+					return func() (any, error) {
+						return thunk()
+					}, nil
+				},
+			},
+			"discussions": &graphql.Field{
+				Type: graphql.NewNonNull(discussionConnType),
+				Args: gqlListArgs(graphql.FieldConfigArgument{
+					"forumId": &graphql.ArgumentConfig{Type: graphql.Int},
+					"type":    discussionArgs["type"],
+				}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					typ, _ := p.Args["type"].(string)
+					return g.listDiscussions(p.Context, gqlArgID(p.Args, "forumId"), typ, gqlPage(p.Args))
+				},
+			},
+			"discussion": &graphql.Field{
+				Type: discussionType,
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"uuid": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					uuid, _ := p.Args["uuid"].(string)
+					return g.getDiscussion(p.Context, gqlArgID(p.Args, "id"), uuid)
+				},
+			},
+			"knowledgeBases": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(kbType))),
+				Description: "仅管理员与运营可见",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return g.listKnowledgeBases(p.Context)
+				},
+			},
+			"document": &graphql.Field{
+				Type:        documentType,
+				Description: "仅管理员与运营可见",
+				Args:        idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return g.getDocument(p.Context, gqlArgID(p.Args, "id"))
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
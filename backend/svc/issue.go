@@ -0,0 +1,432 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/mq"
+	"github.com/chaitin/koalaqa/pkg/rag"
+	"github.com/chaitin/koalaqa/pkg/topic"
+	"github.com/chaitin/koalaqa/repo"
+	"go.uber.org/fx"
+)
+
+type issueIn struct {
+	fx.In
+
+	DiscRepo    *repo.Discussion
+	StateRepo   *repo.IssueState
+	HistoryRepo *repo.IssueHistory
+	UserRepo    *repo.User
+	ForumRepo   *repo.Forum
+	Rag         rag.Service
+	Pub         mq.Publisher
+}
+
+type Issue struct {
+	in     issueIn
+	logger *glog.Logger
+}
+
+func newIssue(in issueIn) *Issue {
+	return &Issue{
+		in:     in,
+		logger: glog.Module("svc", "issue"),
+	}
+}
+
+type IssueStateListReq struct {
+	ForumID uint `form:"forum_id" binding:"required"`
+}
+
+func (i *Issue) ListState(ctx context.Context, userID uint, req IssueStateListReq) (*model.ListRes[model.IssueState], error) {
+	ok, err := i.in.UserRepo.HasForumPermission(ctx, userID, req.ForumID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errPermission
+	}
+
+	var res model.ListRes[model.IssueState]
+	err = i.in.StateRepo.List(ctx, &res.Items,
+		repo.QueryWithEqual("forum_id", req.ForumID),
+		repo.QueryWithOrderBy("index ASC, id ASC"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Total = int64(len(res.Items))
+	return &res, nil
+}
+
+type IssueStateReq struct {
+	ForumID  uint                  `json:"forum_id" binding:"required"`
+	Name     string                `json:"name" binding:"required"`
+	Index    uint                  `json:"index"`
+	Category model.DiscussionState `json:"category" binding:"oneof=1 2 4"`
+	Initial  bool                  `json:"initial"`
+	NextIDs  model.Int64Array      `json:"next_ids"`
+}
+
+func (i *Issue) saveState(ctx context.Context, state *model.IssueState, req IssueStateReq) error {
+	if len(req.NextIDs) > 0 {
+		var count int64
+		err := i.in.StateRepo.Count(ctx, &count,
+			repo.QueryWithEqual("id", req.NextIDs, repo.EqualOPEqAny),
+			repo.QueryWithEqual("forum_id", req.ForumID),
+		)
+		if err != nil {
+			return err
+		}
+
+		if int(count) != len(req.NextIDs) {
+			return errors.New("invalid next state")
+		}
+	}
+
+	state.ForumID = req.ForumID
+	state.Name = req.Name
+	state.Index = req.Index
+	state.Category = req.Category
+	state.Initial = req.Initial
+	state.NextIDs = req.NextIDs
+
+	return i.in.StateRepo.Save(ctx, state)
+}
+
+func (i *Issue) CreateState(ctx context.Context, req IssueStateReq) (uint, error) {
+	exist, err := i.in.ForumRepo.ExistByID(ctx, req.ForumID)
+	if err != nil {
+		return 0, err
+	}
+	if !exist {
+		return 0, errors.New("forum not found")
+	}
+
+	var state model.IssueState
+	err = i.saveState(ctx, &state, req)
+	if err != nil {
+		return 0, err
+	}
+
+	return state.ID, nil
+}
+
+func (i *Issue) UpdateState(ctx context.Context, id uint, req IssueStateReq) error {
+	var state model.IssueState
+	err := i.in.StateRepo.GetByID(ctx, &state, id)
+	if err != nil {
+		return err
+	}
+
+	if state.ForumID != req.ForumID || slices.Contains(req.NextIDs, int64(id)) {
+		return errors.New("invalid request")
+	}
+
+	return i.saveState(ctx, &state, req)
+}
+
+func (i *Issue) DeleteState(ctx context.Context, id uint) error {
+	exist, err := i.in.DiscRepo.Exist(ctx, repo.QueryWithEqual("issue_state_id", id))
+	if err != nil {
+		return err
+	}
+	if exist {
+		return errors.New("state in use")
+	}
+
+	return i.in.StateRepo.DeleteByID(ctx, id)
+}
+
+// InitialStateID 论坛新建 Issue 的初始状态，未配置状态时返回 0
+func (i *Issue) InitialStateID(ctx context.Context, forumID uint) (uint, model.DiscussionState, error) {
+	state, err := i.in.StateRepo.Initial(ctx, forumID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if state == nil {
+		return 0, model.DiscussionStateNone, nil
+	}
+
+	return state.ID, state.Category, nil
+}
+
+func (i *Issue) getIssue(ctx context.Context, user model.UserInfo, discUUID string) (*model.Discussion, error) {
+	if !user.CanOperator(0) {
+		return nil, errPermission
+	}
+
+	disc, err := i.in.DiscRepo.GetByUUID(ctx, discUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if disc.Type != model.DiscussionTypeIssue {
+		return nil, errors.New("invalid discussion")
+	}
+
+	return disc, nil
+}
+
+type IssueUpdateReq struct {
+	Assignees *model.Int64Array    `json:"assignees"`
+	Priority  *model.IssuePriority `json:"priority" binding:"omitempty,max=4"`
+	Severity  *model.IssueSeverity `json:"severity" binding:"omitempty,max=4"`
+	// DueAt 截止时间，0 表示清除
+	DueAt *model.Timestamp `json:"due_at"`
+}
+
+func formatDueAt(t model.Timestamp) string {
+	if t <= 0 {
+		return ""
+	}
+
+	return t.Time().Format(time.DateOnly)
+}
+
+func (i *Issue) Update(ctx context.Context, user model.UserInfo, discUUID string, req IssueUpdateReq) error {
+	disc, err := i.getIssue(ctx, user, discUUID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		updateM     = make(map[string]any)
+		histories   []model.IssueHistory
+		newAssignee []int64
+	)
+	addHistory := func(field model.IssueHistoryField, from, to string) {
+		histories = append(histories, model.IssueHistory{
+			DiscussionID: disc.ID,
+			UserID:       user.UID,
+			Field:        field,
+			From:         from,
+			To:           to,
+		})
+	}
+
+	if req.Assignees != nil {
+		assignees := slices.Compact(slices.Sorted(slices.Values(*req.Assignees)))
+		if len(assignees) > 0 {
+			var count int64
+			err = i.in.UserRepo.Count(ctx, &count,
+				repo.QueryWithEqual("id", model.Int64Array(assignees), repo.EqualOPEqAny),
+				repo.QueryWithEqual("role", []model.UserRole{model.UserRoleAdmin, model.UserRoleOperator}, repo.EqualOPIn),
+			)
+			if err != nil {
+				return err
+			}
+
+			if int(count) != len(assignees) {
+				return errors.New("assignee must be operator")
+			}
+		}
+
+		if !slices.Equal(assignees, slices.Sorted(slices.Values(disc.Assignees))) {
+			updateM["assignees"] = model.Int64Array(assignees)
+			addHistory(model.IssueHistoryFieldAssignee, i.userNames(ctx, disc.Assignees), i.userNames(ctx, assignees))
+
+			for _, id := range assignees {
+				if !slices.Contains(disc.Assignees, id) {
+					newAssignee = append(newAssignee, id)
+				}
+			}
+		}
+	}
+
+	if req.Priority != nil && *req.Priority != disc.Priority {
+		updateM["priority"] = *req.Priority
+		addHistory(model.IssueHistoryFieldPriority, strconv.Itoa(int(disc.Priority)), strconv.Itoa(int(*req.Priority)))
+	}
+
+	if req.Severity != nil && *req.Severity != disc.Severity {
+		updateM["severity"] = *req.Severity
+		addHistory(model.IssueHistoryFieldSeverity, strconv.Itoa(int(disc.Severity)), strconv.Itoa(int(*req.Severity)))
+	}
+
+	if req.DueAt != nil && formatDueAt(*req.DueAt) != formatDueAt(disc.DueAt) {
+		if *req.DueAt > 0 {
+			updateM["due_at"] = req.DueAt.Time()
+		} else {
+			updateM["due_at"] = nil
+		}
+		addHistory(model.IssueHistoryFieldDueAt, formatDueAt(disc.DueAt), formatDueAt(*req.DueAt))
+	}
+
+	if len(updateM) == 0 {
+		return nil
+	}
+
+	err = i.in.DiscRepo.UpdateIssue(ctx, disc.ID, updateM, histories)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range newAssignee {
+		i.in.Pub.Publish(ctx, topic.TopicMessageNotify, topic.MsgMessageNotify{
+			DiscussHeader: disc.Header(),
+			Type:          model.MsgNotifyTypeIssueAssigned,
+			FromID:        user.UID,
+			ToID:          uint(id),
+		})
+	}
+
+	return nil
+}
+
+func (i *Issue) userNames(ctx context.Context, ids []int64) string {
+	if len(ids) == 0 {
+		return ""
+	}
+
+	var users []model.User
+	err := i.in.UserRepo.List(ctx, &users, repo.QueryWithEqual("id", model.Int64Array(ids), repo.EqualOPEqAny))
+	if err != nil {
+		i.logger.WithContext(ctx).WithErr(err).Warn("list assignee failed")
+		return fmt.Sprint(ids)
+	}
+
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+
+	return fmt.Sprint(names)
+}
+
+type IssueTransitReq struct {
+	StateID uint `json:"state_id" binding:"required"`
+}
+
+func (i *Issue) Transit(ctx context.Context, user model.UserInfo, discUUID string, req IssueTransitReq) error {
+	disc, err := i.getIssue(ctx, user, discUUID)
+	if err != nil {
+		return err
+	}
+
+	var to model.IssueState
+	err = i.in.StateRepo.GetByID(ctx, &to, req.StateID)
+	if err != nil {
+		return err
+	}
+
+	if to.ForumID != disc.ForumID {
+		return errors.New("invalid state")
+	}
+
+	var from *model.IssueState
+	if disc.IssueStateID > 0 {
+		from = &model.IssueState{}
+		err = i.in.StateRepo.GetByID(ctx, from, disc.IssueStateID)
+		if err != nil {
+			return err
+		}
+
+		if !from.CanTransit(to.ID) {
+			return errors.New("state transition not allowed")
+		}
+	}
+
+	err = i.in.DiscRepo.TransitIssueState(ctx, disc.ID, user.UID, from, to)
+	if err != nil {
+		return err
+	}
+
+	if disc.Resolved != to.Category {
+		disc.Resolved = to.Category
+
+		var forum model.Forum
+		err = i.in.ForumRepo.GetByID(ctx, &forum, disc.ForumID)
+		if err != nil {
+			return err
+		}
+
+		err = i.in.Rag.UpdateDocumentMetadata(ctx, forum.DatasetID, disc.RagID, disc.Metadata())
+		if err != nil {
+			return err
+		}
+	}
+
+	i.in.Pub.Publish(ctx, topic.TopicMessageNotify, topic.MsgMessageNotify{
+		DiscussHeader: disc.Header(),
+		IssueHeader: model.IssueHeader{
+			IssueState: to.Name,
+		},
+		Type:   model.MsgNotifyTypeIssueStateChange,
+		FromID: user.UID,
+	})
+
+	return nil
+}
+
+// TransitCategory 兼容旧的 Issue 状态接口，流转到排序第一个可达且 Category 匹配的状态
+// 论坛未配置状态时返回 false
+func (i *Issue) TransitCategory(ctx context.Context, user model.UserInfo, disc *model.Discussion, category model.DiscussionState) (bool, error) {
+	var states []model.IssueState
+	err := i.in.StateRepo.List(ctx, &states,
+		repo.QueryWithEqual("forum_id", disc.ForumID),
+		repo.QueryWithOrderBy("index ASC, id ASC"),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if len(states) == 0 {
+		return false, nil
+	}
+
+	var from *model.IssueState
+	for idx := range states {
+		if states[idx].ID == disc.IssueStateID {
+			from = &states[idx]
+			break
+		}
+	}
+
+	for _, state := range states {
+		if state.Category != category || (from != nil && !from.CanTransit(state.ID)) {
+			continue
+		}
+
+		return true, i.Transit(ctx, user, disc.UUID, IssueTransitReq{StateID: state.ID})
+	}
+
+	return true, errors.New("state transition not allowed")
+}
+
+func (i *Issue) ListHistory(ctx context.Context, userID uint, discUUID string) (*model.ListRes[model.IssueHistoryItem], error) {
+	disc, err := i.in.DiscRepo.GetByUUID(ctx, discUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := i.in.UserRepo.HasForumPermission(ctx, userID, disc.ForumID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errPermission
+	}
+
+	var res model.ListRes[model.IssueHistoryItem]
+	err = i.in.HistoryRepo.ListItem(ctx, &res.Items, disc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Total = int64(len(res.Items))
+	return &res, nil
+}
+
+func init() {
+	registerSvc(newIssue)
+}
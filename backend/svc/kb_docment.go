@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"mime/multipart"
 	"path"
-	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -21,6 +20,7 @@ import (
 	"github.com/chaitin/koalaqa/pkg/rag"
 	"github.com/chaitin/koalaqa/pkg/topic"
 	"github.com/chaitin/koalaqa/pkg/tree"
+	"github.com/chaitin/koalaqa/pkg/upload"
 	"github.com/chaitin/koalaqa/pkg/util"
 	"github.com/chaitin/koalaqa/repo"
 	"github.com/google/uuid"
@@ -55,6 +55,7 @@ type KBDocument struct {
 	oc            oss.Client
 	rag           rag.Service
 	repoDataset   *repo.Dataset
+	svcUpload     *Upload
 	logger        *glog.Logger
 }
 
@@ -608,19 +609,13 @@ type UploadFileReq struct {
 	File *multipart.FileHeader `form:"file" binding:"required"`
 }
 
-func (d *KBDocument) UploadFile(ctx context.Context, kbID uint, req UploadFileReq) (string, error) {
-	f, err := req.File.Open()
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	return d.oc.Upload(ctx, d.ossDir(kbID), f,
-		oss.WithExt(filepath.Ext(req.File.Filename)),
-		oss.WithFileSize(int(req.File.Size)),
-		oss.WithLimitSize(),
-		oss.WithPublic(),
-	)
+func (d *KBDocument) UploadFile(ctx context.Context, uid uint, kbID uint, req UploadFileReq) (string, error) {
+	return d.svcUpload.upload(ctx, uploadReq{
+		UserID: uid,
+		Scene:  upload.SceneKBDocument,
+		Dir:    d.ossDir(kbID),
+		File:   req.File,
+	})
 }
 
 type ListSpaceItem struct {
@@ -1443,7 +1438,7 @@ func (d *KBDocument) DocReindex(ctx context.Context, req DocReindexReq) error {
 }
 
 func newDocument(repoDoc *repo.KBDocument, rank *repo.Rank, disc *repo.Discussion, groupItem *repo.GroupItem, rag rag.Service,
-	doc anydoc.Anydoc, pub mq.Publisher, oc oss.Client, pa *PublicAddress, kb *repo.KnowledgeBase, dataset *repo.Dataset) *KBDocument {
+	doc anydoc.Anydoc, pub mq.Publisher, oc oss.Client, pa *PublicAddress, kb *repo.KnowledgeBase, dataset *repo.Dataset, svcUpload *Upload) *KBDocument {
 	return &KBDocument{
 		repoRank:      rank,
 		repoKB:        kb,
@@ -1456,6 +1451,7 @@ func newDocument(repoDoc *repo.KBDocument, rank *repo.Rank, disc *repo.Discussio
 		oc:            oc,
 		svcPublicAddr: pa,
 		rag:           rag,
+		svcUpload:     svcUpload,
 		logger:        glog.Module("svc", "kb_document"),
 	}
 }
@@ -3,7 +3,9 @@ package svc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"slices"
 	"strconv"
 	"strings"
@@ -31,10 +33,11 @@ type LLM struct {
 	comm    *repo.Comment
 	bot     *Bot
 	repoLLM *repo.LLM
+	vision  *Vision
 }
 
 func newLLM(rag rag.Service, dataset *repo.Dataset, doc *repo.KBDocument, kit *ModelKit, bot *Bot,
-	cfg config.Config, disc *repo.Discussion, comm *repo.Comment, repoLLM *repo.LLM) *LLM {
+	cfg config.Config, disc *repo.Discussion, comm *repo.Comment, repoLLM *repo.LLM, vision *Vision) *LLM {
 	return &LLM{
 		rag:     rag,
 		dataset: dataset,
@@ -46,6 +49,7 @@ func newLLM(rag rag.Service, dataset *repo.Dataset, doc *repo.KBDocument, kit *M
 		comm:    comm,
 		bot:     bot,
 		repoLLM: repoLLM,
+		vision:  vision,
 	}
 }
 
@@ -66,6 +70,8 @@ type GenerateReq struct {
 	DefaultAnswer string                `json:"default_answer"`
 	NewCommentID  uint                  `json:"new_comment_id"`
 	Debug         bool                  `json:"debug"`
+	// KBIDs 只使用指定知识库中的文档，为空时不限制
+	KBIDs model.Int64Array `json:"kb_ids"`
 }
 
 type GroupRouteOption struct {
@@ -132,7 +138,8 @@ func (l *LLM) RouteGroups(ctx context.Context, question string, options []GroupR
 	return ids, nil
 }
 
-func (l *LLM) StreamAnswer(ctx context.Context, sysPrompt string, req GenerateReq) (*llm.Stream[string], error) {
+// StreamAnswer 同时返回检索到的知识库文档 id
+func (l *LLM) StreamAnswer(ctx context.Context, sysPrompt string, req GenerateReq) (*llm.Stream[string], []string, error) {
 	query := req.Question
 
 	groupIDs, groupNames := req.GroupInfo()
@@ -152,14 +159,14 @@ func (l *LLM) StreamAnswer(ctx context.Context, sysPrompt string, req GenerateRe
 
 	rewrittenQuery, knowledgeDocuments, err := l.queryKnowledgeDocuments(ctx, query, model.KBDocMetadata{
 		GroupIDs: groupIDs,
-	}, chatHistoies...)
+	}, req.KBIDs, chatHistoies...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	botInfo, err := l.bot.Get(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	blockKeywords := ""
@@ -181,7 +188,7 @@ func (l *LLM) StreamAnswer(ctx context.Context, sysPrompt string, req GenerateRe
 		"GeneralKnowledge":   botInfo.GeneralKnowledge,
 	}, req.Histories()...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	go func() {
@@ -196,6 +203,7 @@ func (l *LLM) StreamAnswer(ctx context.Context, sysPrompt string, req GenerateRe
 					runes = runes[:0]
 					return data, nil
 				}
+				filterStream.SetErr(stream.Err())
 				return "", errStreaming
 			}
 
@@ -225,7 +233,12 @@ func (l *LLM) StreamAnswer(ctx context.Context, sysPrompt string, req GenerateRe
 
 	}()
 
-	return filterStream, nil
+	docIDs := make([]string, len(knowledgeDocuments))
+	for i, doc := range knowledgeDocuments {
+		docIDs[i] = doc.Source
+	}
+
+	return filterStream, docIDs, nil
 }
 
 func (l *LLM) answer(ctx context.Context, sysPrompt string, req GenerateReq) (string, bool, []string, error) {
@@ -244,7 +257,7 @@ func (l *LLM) answer(ctx context.Context, sysPrompt string, req GenerateReq) (st
 
 	rewrittenQuery, knowledgeDocuments, err := l.queryKnowledgeDocuments(ctx, query, model.KBDocMetadata{
 		GroupIDs: groupIDs,
-	})
+	}, req.KBIDs)
 	if err != nil {
 		return "", false, nil, err
 	}
@@ -467,6 +480,9 @@ func (l *LLM) StreamChat(ctx context.Context, sMsg string, uMsg string, params m
 		s.Recv(func() (string, error) {
 			msg, err := reader.Recv()
 			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					s.SetErr(err)
+				}
 				return "", err
 			}
 
@@ -484,6 +500,8 @@ type discussionTemplateOpts struct {
 	CommID uint
 	// LoadComments 是否加载该讨论的全部评论列表
 	LoadComments bool
+	// Images 是否使用视觉模型识别帖子及新评论中的图片
+	Images bool
 }
 
 // buildDiscussionTemplate 统一构建讨论提示词模板的内部方法
@@ -512,6 +530,13 @@ func (l *LLM) buildDiscussionTemplate(ctx context.Context, discID uint, opts dis
 		}
 	}
 
+	if opts.Images {
+		discussion.Content = l.vision.Describe(ctx, discussion.Content)
+		if newComment != nil {
+			newComment.Content = l.vision.Describe(ctx, newComment.Content)
+		}
+	}
+
 	return llm.NewDiscussionPromptTemplate(discussion, allComments, newComment), nil
 }
 
@@ -576,10 +601,10 @@ func (l *LLM) GeneratePrompt(ctx context.Context, opts GeneratePromptOpts) (Prom
 	discID := opts.DiscIDs[0]
 
 	templateOptsMap := map[PromptMode]discussionTemplateOpts{
-		PromptModeAnswer:              {CommID: opts.CommID, LoadComments: true},
-		PromptModeAnswerNoComments:    {},
-		PromptModeRetrieval:           {LoadComments: true},
-		PromptModeRetrievalNoComments: {},
+		PromptModeAnswer:              {CommID: opts.CommID, LoadComments: true, Images: true},
+		PromptModeAnswerNoComments:    {Images: true},
+		PromptModeRetrieval:           {LoadComments: true, Images: l.cfg.RAG.ImageIndex},
+		PromptModeRetrievalNoComments: {Images: l.cfg.RAG.ImageIndex},
 	}
 	t, err := l.buildDiscussionTemplate(ctx, discID, templateOptsMap[opts.Mode])
 	if err != nil {
@@ -608,7 +633,7 @@ func (l *LLM) GeneratePrompt(ctx context.Context, opts GeneratePromptOpts) (Prom
 }
 
 // queryKnowledgeDocuments 查询相关知识文档
-func (l *LLM) queryKnowledgeDocuments(ctx context.Context, query string, metadata rag.Metadata, histories ...string) (string, []llm.KnowledgeDocument, error) {
+func (l *LLM) queryKnowledgeDocuments(ctx context.Context, query string, metadata rag.Metadata, kbIDs model.Int64Array, histories ...string) (string, []llm.KnowledgeDocument, error) {
 	logger := l.logger.WithContext(ctx)
 
 	logger.With("query", query).Debug("query knowledge documents")
@@ -659,6 +684,10 @@ func (l *LLM) queryKnowledgeDocuments(ctx context.Context, query string, metadat
 
 	knowledgeDocs := make([]llm.KnowledgeDocument, 0, len(docs))
 	for _, doc := range docs {
+		if len(kbIDs) > 0 && !slices.Contains(kbIDs, int64(doc.KBID)) {
+			continue
+		}
+
 		content := docContent[doc.RagID]
 		if doc.DocType == model.DocTypeQuestion {
 			content = string(doc.Markdown)
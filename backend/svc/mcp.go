@@ -0,0 +1,415 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/rag"
+	"github.com/chaitin/koalaqa/pkg/restapi"
+	"github.com/chaitin/koalaqa/pkg/version"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"go.uber.org/fx"
+)
+
+const (
+	mcpToolListForums       = "list_forums"
+	mcpToolSearchDiscussion = "search_discussions"
+	mcpToolGetDiscussion    = "get_discussion"
+	mcpToolSearchKB         = "search_kb"
+	mcpToolListHotQuestions = "list_hot_questions"
+	mcpToolCreateDiscussion = "create_discussion"
+
+	mcpDefaultLimit = 10
+	mcpMaxLimit     = 20
+)
+
+// mcpToolScopes 工具需要的 api token 范围
+var mcpToolScopes = map[string]string{
+	mcpToolListForums:       model.APITokenScopeMCPRead,
+	mcpToolSearchDiscussion: model.APITokenScopeMCPRead,
+	mcpToolGetDiscussion:    model.APITokenScopeMCPRead,
+	mcpToolSearchKB:         model.APITokenScopeMCPRead,
+	mcpToolListHotQuestions: model.APITokenScopeMCPRead,
+	mcpToolCreateDiscussion: model.APITokenScopeMCPWrite,
+}
+
+var errMCPScope = errors.New("api token scope not allowed")
+
+type (
+	mcpTokenKey struct{}
+	mcpUserKey  struct{}
+)
+
+// MCPContext 记录发起请求的 api token 及其代表的用户，工具调用时据此校验范围和板块权限
+func MCPContext(ctx context.Context, token *model.APIToken, user model.UserInfo) context.Context {
+	return context.WithValue(context.WithValue(ctx, mcpTokenKey{}, token), mcpUserKey{}, user)
+}
+
+func mcpAllowTool(ctx context.Context, name string) bool {
+	token, ok := ctx.Value(mcpTokenKey{}).(*model.APIToken)
+	if !ok {
+		return false
+	}
+
+	scope, ok := mcpToolScopes[name]
+	return ok && token.HasScope(scope)
+}
+
+// MCP 对外提供 Model Context Protocol 工具，http 与 stdio 传输共用同一个 server
+type MCP struct {
+	in     mcpIn
+	logger *glog.Logger
+	server *mcpserver.MCPServer
+}
+
+type mcpIn struct {
+	fx.In
+
+	Disc    *Discussion
+	RestAPI *RestAPI
+	Rank    *Rank
+	Rag     rag.Service
+	Dataset *repo.Dataset
+	KBDoc   *repo.KBDocument
+}
+
+func newMCP(in mcpIn) *MCP {
+	m := &MCP{
+		in:     in,
+		logger: glog.Module("svc", "mcp"),
+	}
+
+	m.server = mcpserver.NewMCPServer("koalaqa", version.Version,
+		mcpserver.WithToolCapabilities(false),
+		mcpserver.WithRecovery(),
+		mcpserver.WithInstructions("KoalaQA 社区与知识库工具：先用 search_kb 和 search_discussions 检索已有答案，找不到时再用 create_discussion 发帖提问。"),
+		mcpserver.WithToolFilter(func(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+			return slices.DeleteFunc(tools, func(tool mcp.Tool) bool {
+				return !mcpAllowTool(ctx, tool.Name)
+			})
+		}),
+		mcpserver.WithToolHandlerMiddleware(func(next mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+			return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				if !mcpAllowTool(ctx, req.Params.Name) {
+					return mcp.NewToolResultError(errMCPScope.Error()), nil
+				}
+
+				return next(ctx, req)
+			}
+		}),
+	)
+	m.addTools()
+
+	return m
+}
+
+func init() {
+	registerSvc(newMCP)
+}
+
+func (m *MCP) Server() *mcpserver.MCPServer {
+	return m.server
+}
+
+func (m *MCP) addTools() {
+	m.server.AddTool(mcp.NewTool(mcpToolListForums,
+		mcp.WithDescription("列出社区的所有板块，返回的 id 可用于 search_discussions 和 create_discussion 的 forum_id"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), m.listForums)
+
+	m.server.AddTool(mcp.NewTool(mcpToolSearchDiscussion,
+		mcp.WithDescription("按语义检索社区帖子，返回相似度从高到低的帖子列表"),
+		mcp.WithString("query", mcp.Required(), mcp.Description("检索内容")),
+		mcp.WithNumber("forum_id", mcp.Required(), mcp.Description("检索的板块，通过 list_forums 获取")),
+		mcp.WithNumber("limit", mcp.Description("返回数量，默认 10，最大 20")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), m.searchDiscussions)
+
+	m.server.AddTool(mcp.NewTool(mcpToolGetDiscussion,
+		mcp.WithDescription("获取帖子详情以及回答与评论"),
+		mcp.WithNumber("id", mcp.Required(), mcp.Description("帖子 id")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), m.getDiscussion)
+
+	m.server.AddTool(mcp.NewTool(mcpToolSearchKB,
+		mcp.WithDescription("检索知识库中的文档、问答对和网页，返回命中的文档内容片段"),
+		mcp.WithString("query", mcp.Required(), mcp.Description("检索内容")),
+		mcp.WithNumber("kb_id", mcp.Description("只检索指定知识库，不传时检索所有知识库")),
+		mcp.WithNumber("limit", mcp.Description("返回文档数量，默认 10，最大 20")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), m.searchKB)
+
+	m.server.AddTool(mcp.NewTool(mcpToolListHotQuestions,
+		mcp.WithDescription("获取最近智能问答中的热门问题"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), m.listHotQuestions)
+
+	m.server.AddTool(mcp.NewTool(mcpToolCreateDiscussion,
+		mcp.WithDescription("在社区发帖，帖子以 api token 代表的用户身份发布"),
+		mcp.WithString("title", mcp.Required(), mcp.Description("标题")),
+		mcp.WithString("content", mcp.Required(), mcp.Description("markdown 格式的内容")),
+		mcp.WithString("type", mcp.Enum(string(model.DiscussionTypeQA), string(model.DiscussionTypeFeedback), string(model.DiscussionTypeBlog)), mcp.Description("帖子类型，默认 qa")),
+		mcp.WithNumber("forum_id", mcp.Required(), mcp.Description("发布的板块，通过 list_forums 获取")),
+		mcp.WithDestructiveHintAnnotation(false),
+	), m.createDiscussion)
+}
+
+func (m *MCP) result(ctx context.Context, name string, data any, err error) (*mcp.CallToolResult, error) {
+	if err != nil {
+		var restErr *restapi.Error
+		if !errors.As(err, &restErr) && !errors.Is(err, errPermission) && !errors.Is(err, errRatelimit) {
+			m.logger.WithContext(ctx).WithErr(err).With("tool", name).Warn("call mcp tool failed")
+		}
+
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return mcp.NewToolResultJSON(data)
+}
+
+func mcpLimit(req mcp.CallToolRequest) int {
+	limit := req.GetInt("limit", mcpDefaultLimit)
+	if limit <= 0 {
+		return mcpDefaultLimit
+	}
+
+	return min(limit, mcpMaxLimit)
+}
+
+// mcpList 结构化结果必须是对象，列表统一包一层
+func mcpList[T any](items []T) model.ListRes[T] {
+	return model.ListRes[T]{
+		Items: items,
+		Total: int64(len(items)),
+	}
+}
+
+// mcpUser api token 代表的用户，读写都按该用户的板块权限处理
+func mcpUser(ctx context.Context) model.UserInfo {
+	user, _ := ctx.Value(mcpUserKey{}).(model.UserInfo)
+	return user
+}
+
+func (m *MCP) listForums(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	res, err := m.in.RestAPI.ListForums(ctx, mcpUser(ctx), restapi.Page{Limit: restapi.MaxLimit})
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	return m.result(ctx, req.Params.Name, mcpList(res.Data), nil)
+}
+
+type MCPDiscussion struct {
+	restapi.Discussion
+
+	Similarity float64 `json:"similarity"`
+}
+
+func (m *MCP) searchDiscussions(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// 每个板块对应一次向量检索，只检索指定的板块
+	forumID, err := req.RequireInt("forum_id")
+	if err != nil || forumID <= 0 {
+		return mcp.NewToolResultError("forum_id must be a positive integer"), nil
+	}
+
+	err = m.in.RestAPI.checkForum(ctx, mcpUser(ctx), uint(forumID))
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	discs, err := m.in.Disc.Search(ctx, DiscussionSearchReq{
+		Keyword: query,
+		ForumID: uint(forumID),
+	})
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	res := make([]MCPDiscussion, 0, len(discs))
+	for _, disc := range discs {
+		res = append(res, MCPDiscussion{
+			Discussion: restDiscussion(*disc),
+			Similarity: disc.Similarity,
+		})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Similarity > res[j].Similarity
+	})
+
+	return m.result(ctx, req.Params.Name, mcpList(res[:min(len(res), mcpLimit(req))]), nil)
+}
+
+type MCPDiscussionDetail struct {
+	restapi.Discussion
+
+	Comments []restapi.Comment `json:"comments"`
+}
+
+func (m *MCP) getDiscussion(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireInt("id")
+	if err != nil || id <= 0 {
+		return mcp.NewToolResultError("id must be a positive integer"), nil
+	}
+
+	disc, err := m.in.RestAPI.GetDiscussion(ctx, mcpUser(ctx), uint(id))
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	comments, err := m.in.RestAPI.ListComments(ctx, mcpUser(ctx), uint(id), restapi.Page{Limit: restapi.MaxLimit})
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	return m.result(ctx, req.Params.Name, MCPDiscussionDetail{
+		Discussion: *disc,
+		Comments:   comments.Data,
+	}, nil)
+}
+
+type MCPKBDocument struct {
+	ID         uint    `json:"id"`
+	KBID       uint    `json:"kb_id"`
+	Type       string  `json:"type"`
+	Title      string  `json:"title"`
+	Similarity float64 `json:"similarity"`
+	Content    string  `json:"content"`
+}
+
+func (m *MCP) searchKB(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	_, records, err := m.in.Rag.QueryRecords(ctx, rag.QueryRecordsReq{
+		DatasetID: m.in.Dataset.GetBackendID(ctx),
+		Query:     query,
+	})
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	ragIDs := make([]string, 0, len(records))
+	for _, record := range records {
+		ragIDs = append(ragIDs, record.DocID)
+	}
+
+	docs, err := m.in.KBDoc.GetByRagIDs(ctx, ragIDs)
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	kbID := req.GetInt("kb_id", 0)
+	docM := make(map[string]model.KBDocument, len(docs))
+	for _, doc := range docs {
+		if kbID > 0 && doc.KBID != uint(kbID) {
+			continue
+		}
+
+		docM[doc.RagID] = doc
+	}
+
+	// 同一文档命中多个分片时合并内容，按第一次命中的顺序返回
+	var (
+		res   = make([]MCPKBDocument, 0)
+		index = make(map[string]int)
+	)
+	for _, record := range records {
+		doc, ok := docM[record.DocID]
+		if !ok {
+			continue
+		}
+
+		if i, ok := index[record.DocID]; ok {
+			if doc.DocType != model.DocTypeQuestion {
+				res[i].Content += "\n" + record.Content
+			}
+			continue
+		}
+
+		content := record.Content
+		if doc.DocType == model.DocTypeQuestion {
+			content = string(doc.Markdown)
+		}
+
+		index[record.DocID] = len(res)
+		res = append(res, MCPKBDocument{
+			ID:         doc.ID,
+			KBID:       doc.KBID,
+			Type:       restDocTypes[doc.DocType],
+			Title:      doc.Title,
+			Similarity: record.Similarity,
+			Content:    strings.TrimSpace(content),
+		})
+	}
+
+	return m.result(ctx, req.Params.Name, mcpList(res[:min(len(res), mcpLimit(req))]), nil)
+}
+
+func (m *MCP) listHotQuestions(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	hot, err := m.in.Rank.LastHotQuestions(ctx)
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	res := make([]string, 0, len(hot.Items))
+	for _, item := range hot.Items {
+		res = append(res, item.Content)
+	}
+
+	return m.result(ctx, req.Params.Name, mcpList(res), nil)
+}
+
+type MCPCreateDiscussionRes struct {
+	UUID string `json:"uuid"`
+}
+
+func (m *MCP) createDiscussion(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	title, err := req.RequireString("title")
+	if err != nil || strings.TrimSpace(title) == "" {
+		return mcp.NewToolResultError("title is required"), nil
+	}
+
+	content, err := req.RequireString("content")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	discType := model.DiscussionType(req.GetString("type", string(model.DiscussionTypeQA)))
+	switch discType {
+	case model.DiscussionTypeQA, model.DiscussionTypeFeedback, model.DiscussionTypeBlog:
+	default:
+		return mcp.NewToolResultError("invalid discussion type"), nil
+	}
+
+	forumID, err := req.RequireInt("forum_id")
+	if err != nil || forumID <= 0 {
+		return mcp.NewToolResultError("forum_id must be a positive integer"), nil
+	}
+
+	discUUID, err := m.in.Disc.Create(ctx, mcpUser(ctx), DiscussionCreateReq{
+		Title:   title,
+		Content: content,
+		Type:    discType,
+		ForumID: uint(forumID),
+	})
+	if err != nil {
+		return m.result(ctx, req.Params.Name, nil, err)
+	}
+
+	return m.result(ctx, req.Params.Name, MCPCreateDiscussionRes{UUID: discUUID}, nil)
+}
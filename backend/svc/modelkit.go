@@ -239,24 +239,41 @@ func (m *ModelKit) GetChatModel(ctx context.Context) (einoModel.BaseChatModel, e
 	if err != nil {
 		return nil, err
 	}
+	return m.getModel(ctx, chat)
+}
+
+// GetVisionModel 获取已启用的 analysis-vl 模型，未配置时返回 database.ErrRecordNotFound
+func (m *ModelKit) GetVisionModel(ctx context.Context) (einoModel.BaseChatModel, *model.LLM, error) {
+	vl, err := m.repo.GetActiveByType(ctx, model.LLMTypeAnalysisVL)
+	if err != nil {
+		return nil, nil, err
+	}
+	cm, err := m.getModel(ctx, vl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cm, vl, nil
+}
+
+func (m *ModelKit) getModel(ctx context.Context, llm *model.LLM) (einoModel.BaseChatModel, error) {
 	res, err := m.modelkit.GetChatModel(ctx, &domain.ModelMetadata{
-		Provider:   consts.ParseModelProvider(string(chat.Provider)),
-		ModelName:  chat.Model,
-		APIKey:     chat.APIKey,
-		BaseURL:    chat.BaseURL,
-		APIVersion: chat.APIVersion,
-		APIHeader:  chat.APIHeader,
-		ModelType:  consts.ParseModelType(string(chat.Type)),
+		Provider:   consts.ParseModelProvider(string(llm.Provider)),
+		ModelName:  llm.Model,
+		APIKey:     llm.APIKey,
+		BaseURL:    llm.BaseURL,
+		APIVersion: llm.APIVersion,
+		APIHeader:  llm.APIHeader,
+		ModelType:  consts.ParseModelType(string(llm.Type)),
 	})
 	if err != nil {
 		return nil, err
 	}
 	m.logger.WithContext(ctx).
-		With("provider", chat.Provider).
-		With("base_url", chat.BaseURL).
-		With("api_version", chat.APIVersion).
-		With("model_type", chat.Type).
-		With("model", chat.Model).
+		With("provider", llm.Provider).
+		With("base_url", llm.BaseURL).
+		With("api_version", llm.APIVersion).
+		With("model_type", llm.Type).
+		With("model", llm.Model).
 		Debug("get chat model success")
 	return res, nil
 }
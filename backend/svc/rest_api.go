@@ -0,0 +1,462 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/restapi"
+	"github.com/chaitin/koalaqa/repo"
+	"go.uber.org/fx"
+)
+
+// RestAPI 对外 v1 只读 api，响应结构见 pkg/restapi
+type RestAPI struct {
+	in restAPIIn
+}
+
+type restAPIIn struct {
+	fx.In
+
+	Forum   *repo.Forum
+	Disc    *repo.Discussion
+	Comment *repo.Comment
+	User    *repo.User
+	Org     *repo.Org
+	KBDoc   *repo.KBDocument
+}
+
+func newRestAPI(in restAPIIn) *RestAPI {
+	return &RestAPI{in: in}
+}
+
+func init() {
+	registerSvc(newRestAPI)
+}
+
+var restUserRoles = map[model.UserRole]string{
+	model.UserRoleAdmin:    "admin",
+	model.UserRoleOperator: "operator",
+	model.UserRoleUser:     "user",
+	model.UserRoleGuest:    "guest",
+}
+
+var restDocTypes = map[model.DocType]string{
+	model.DocTypeQuestion: "question",
+	model.DocTypeDocument: "document",
+	model.DocTypeSpace:    "space",
+	model.DocTypeWeb:      "web",
+}
+
+// restPageQuery 按 column 做游标分页，多查询一条用于判断是否有下一页
+func restPageQuery(page restapi.Page, column string, asc bool) ([]repo.QueryOptFunc, int, error) {
+	after, limit, err := page.Parse()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order, op := column+" DESC", repo.EqualOPLT
+	if asc {
+		order, op = column+" ASC", repo.EqualOPGT
+	}
+
+	query := []repo.QueryOptFunc{
+		repo.QueryWithPagination(&model.Pagination{Page: 1, Size: limit + 1}),
+		repo.QueryWithOrderBy(order),
+	}
+	if after > 0 {
+		query = append(query, repo.QueryWithEqual(column, after, op))
+	}
+
+	return query, limit, nil
+}
+
+func restNotFound(err error, msg string) error {
+	if errors.Is(err, database.ErrRecordNotFound) {
+		return restapi.NotFound(msg)
+	}
+
+	return err
+}
+
+func restOnlyToken(user model.UserInfo) error {
+	if user.AuthType != model.AuthTypeAPIToken {
+		return restapi.Unauthorized("api token required")
+	}
+
+	return nil
+}
+
+// forumIDs 返回可访问的论坛，api token 不限制时返回 nil，代表用户时使用用户所在组织的论坛权限，匿名访问使用默认组织的论坛权限
+func (r *RestAPI) forumIDs(ctx context.Context, user model.UserInfo) (model.Int64Array, error) {
+	if user.UID > 0 {
+		forumIDs, err := r.in.Org.ListForumIDs(ctx, user.OrgIDs...)
+		if err != nil {
+			return nil, err
+		}
+		if forumIDs == nil {
+			return model.Int64Array{}, nil
+		}
+
+		return forumIDs, nil
+	}
+
+	if user.AuthType == model.AuthTypeAPIToken {
+		return nil, nil
+	}
+
+	org, err := r.in.Org.GetDefaultOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if org.ForumIDs == nil {
+		return model.Int64Array{}, nil
+	}
+
+	return org.ForumIDs, nil
+}
+
+func (r *RestAPI) checkForum(ctx context.Context, user model.UserInfo, forumID uint) error {
+	forumIDs, err := r.forumIDs(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	if forumIDs != nil && !slices.Contains(forumIDs, int64(forumID)) {
+		return restapi.NotFound("forum not found")
+	}
+
+	return nil
+}
+
+func (r *RestAPI) userRefs(ctx context.Context, ids model.Int64Array) (map[uint]restapi.UserRef, error) {
+	res := make(map[uint]restapi.UserRef)
+	if len(ids) == 0 {
+		return res, nil
+	}
+
+	var users []model.User
+	err := r.in.User.List(ctx, &users,
+		repo.QueryWithEqual("id", ids, repo.EqualOPEqAny),
+		repo.QueryWithSelectColumn("id", "name", "avatar"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range users {
+		res[u.ID] = restapi.UserRef{
+			ID:     u.ID,
+			Name:   u.Name,
+			Avatar: u.Avatar,
+		}
+	}
+
+	return res, nil
+}
+
+func restForum(f model.Forum) restapi.Forum {
+	return restapi.Forum{
+		ID:        f.ID,
+		Name:      f.Name,
+		RouteName: f.RouteName,
+		Index:     f.Index,
+	}
+}
+
+func (r *RestAPI) ListForums(ctx context.Context, user model.UserInfo, page restapi.Page) (*restapi.List[restapi.Forum], error) {
+	query, limit, err := restPageQuery(page, "id", true)
+	if err != nil {
+		return nil, err
+	}
+
+	forumIDs, err := r.forumIDs(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	query = append(query, repo.QueryWithEqual("id", forumIDs, repo.EqualOPEqAny))
+
+	var forums []model.Forum
+	err = r.in.Forum.List(ctx, &forums, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]restapi.Forum, 0, len(forums))
+	for _, f := range forums {
+		items = append(items, restForum(f))
+	}
+
+	return restapi.NewList(items, limit, func(f restapi.Forum) uint { return f.ID }), nil
+}
+
+func (r *RestAPI) GetForum(ctx context.Context, user model.UserInfo, id uint) (*restapi.Forum, error) {
+	err := r.checkForum(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var forum model.Forum
+	err = r.in.Forum.GetByID(ctx, &forum, id)
+	if err != nil {
+		return nil, restNotFound(err, "forum not found")
+	}
+
+	res := restForum(forum)
+	return &res, nil
+}
+
+type RestDiscussionListReq struct {
+	restapi.Page
+
+	ForumID uint                 `form:"forum_id"`
+	Type    model.DiscussionType `form:"type"`
+}
+
+func restDiscussion(d model.DiscussionListItem) restapi.Discussion {
+	tagIDs := []int64(d.TagIDs)
+	if tagIDs == nil {
+		tagIDs = make([]int64, 0)
+	}
+
+	return restapi.Discussion{
+		ID:       d.ID,
+		UUID:     d.UUID,
+		ForumID:  d.ForumID,
+		Type:     string(d.Type),
+		Title:    d.Title,
+		Summary:  d.Summary,
+		TagIDs:   tagIDs,
+		Resolved: d.Resolved == model.DiscussionStateResolved,
+		Closed:   d.Resolved == model.DiscussionStateClosed,
+		Author: restapi.UserRef{
+			ID:     d.UserID,
+			Name:   d.UserName,
+			Avatar: d.UserAvatar,
+		},
+		Like:        d.Like,
+		View:        d.View,
+		CommentsNum: d.Comment,
+		CreatedAt:   int64(d.CreatedAt),
+		UpdatedAt:   int64(d.UpdatedAt),
+	}
+}
+
+func (r *RestAPI) ListDiscussions(ctx context.Context, user model.UserInfo, req RestDiscussionListReq) (*restapi.List[restapi.Discussion], error) {
+	query, limit, err := restPageQuery(req.Page, "discussions.id", false)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ForumID > 0 {
+		err = r.checkForum(ctx, user, req.ForumID)
+		if err != nil {
+			return nil, err
+		}
+		query = append(query, repo.QueryWithEqual("discussions.forum_id", req.ForumID))
+	} else {
+		forumIDs, err := r.forumIDs(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		query = append(query, repo.QueryWithEqual("discussions.forum_id", forumIDs, repo.EqualOPEqAny))
+	}
+
+	if req.Type != "" {
+		query = append(query, repo.QueryWithEqual("discussions.type", req.Type))
+	}
+
+	var discs []model.DiscussionListItem
+	err = r.in.Disc.List(ctx, &discs, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]restapi.Discussion, 0, len(discs))
+	for _, d := range discs {
+		items = append(items, restDiscussion(d))
+	}
+
+	return restapi.NewList(items, limit, func(d restapi.Discussion) uint { return d.ID }), nil
+}
+
+func (r *RestAPI) getDiscussion(ctx context.Context, user model.UserInfo, id uint) (*model.DiscussionListItem, error) {
+	var disc model.DiscussionListItem
+	err := r.in.Disc.Get(ctx, &disc, repo.QueryWithEqual("discussions.id", id))
+	if err != nil {
+		return nil, restNotFound(err, "discussion not found")
+	}
+
+	err = r.checkForum(ctx, user, disc.ForumID)
+	if err != nil {
+		var restErr *restapi.Error
+		if errors.As(err, &restErr) {
+			return nil, restapi.NotFound("discussion not found")
+		}
+		return nil, err
+	}
+
+	return &disc, nil
+}
+
+func (r *RestAPI) GetDiscussion(ctx context.Context, user model.UserInfo, id uint) (*restapi.Discussion, error) {
+	disc, err := r.getDiscussion(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := restDiscussion(*disc)
+	res.Content = disc.Content
+	return &res, nil
+}
+
+func (r *RestAPI) ListComments(ctx context.Context, user model.UserInfo, discID uint, page restapi.Page) (*restapi.List[restapi.Comment], error) {
+	_, err := r.getDiscussion(ctx, user, discID)
+	if err != nil {
+		return nil, err
+	}
+
+	query, limit, err := restPageQuery(page, "comments.id", true)
+	if err != nil {
+		return nil, err
+	}
+	query = append(query, repo.QueryWithEqual("comments.discussion_id", discID))
+
+	var comments []model.CommentDetail
+	err = r.in.Comment.List(ctx, &comments, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make(model.Int64Array, 0, len(comments))
+	for _, c := range comments {
+		userIDs = append(userIDs, int64(c.UserID))
+	}
+	users, err := r.userRefs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]restapi.Comment, 0, len(comments))
+	for _, c := range comments {
+		items = append(items, restapi.Comment{
+			ID:           c.ID,
+			DiscussionID: c.DiscussionID,
+			ParentID:     c.ParentID,
+			Content:      c.Content,
+			Accepted:     c.Accepted,
+			Bot:          c.Bot,
+			Author:       users[c.UserID],
+			Like:         c.Like,
+			Dislike:      c.Dislike,
+			CreatedAt:    int64(c.CreatedAt),
+			UpdatedAt:    int64(c.UpdatedAt),
+		})
+	}
+
+	return restapi.NewList(items, limit, func(c restapi.Comment) uint { return c.ID }), nil
+}
+
+func (r *RestAPI) GetUser(ctx context.Context, user model.UserInfo, id uint) (*restapi.User, error) {
+	var u model.User
+	err := r.in.User.GetByID(ctx, &u, id)
+	if err != nil {
+		return nil, restNotFound(err, "user not found")
+	}
+
+	res := restapi.User{
+		ID:        u.ID,
+		Name:      u.Name,
+		Avatar:    u.Avatar,
+		Intro:     u.Intro,
+		Role:      restUserRoles[u.Role],
+		Point:     u.Point,
+		CreatedAt: int64(u.CreatedAt),
+	}
+	if user.AuthType == model.AuthTypeAPIToken {
+		res.Email = u.Email
+	}
+
+	return &res, nil
+}
+
+type RestDocumentListReq struct {
+	restapi.Page
+
+	KBID     uint `form:"kb_id"`
+	ParentID uint `form:"parent_id"`
+}
+
+func restDocument(d model.KBDocument) restapi.Document {
+	return restapi.Document{
+		ID:        d.ID,
+		KBID:      d.KBID,
+		ParentID:  d.ParentID,
+		Type:      restDocTypes[d.DocType],
+		Title:     d.Title,
+		Desc:      d.Desc,
+		CreatedAt: int64(d.CreatedAt),
+		UpdatedAt: int64(d.UpdatedAt),
+	}
+}
+
+func (r *RestAPI) ListDocuments(ctx context.Context, user model.UserInfo, req RestDocumentListReq) (*restapi.List[restapi.Document], error) {
+	err := restOnlyToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	query, limit, err := restPageQuery(req.Page, "id", false)
+	if err != nil {
+		return nil, err
+	}
+	query = append(query,
+		repo.QueryWithSelectColumn("id", "kb_id", "parent_id", "doc_type", "title", "desc", "created_at", "updated_at"),
+		repo.QueryWithEqual("status", model.DocStatusApplySuccess),
+	)
+	if req.KBID > 0 {
+		query = append(query, repo.QueryWithEqual("kb_id", req.KBID))
+	}
+	if req.ParentID > 0 {
+		query = append(query, repo.QueryWithEqual("parent_id", req.ParentID))
+	}
+
+	var docs []model.KBDocument
+	err = r.in.KBDoc.List(ctx, &docs, query...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]restapi.Document, 0, len(docs))
+	for _, d := range docs {
+		items = append(items, restDocument(d))
+	}
+
+	return restapi.NewList(items, limit, func(d restapi.Document) uint { return d.ID }), nil
+}
+
+func (r *RestAPI) GetDocument(ctx context.Context, user model.UserInfo, id uint) (*restapi.Document, error) {
+	err := restOnlyToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []model.KBDocument
+	err = r.in.KBDoc.List(ctx, &docs,
+		repo.QueryWithEqual("id", id),
+		repo.QueryWithEqual("status", model.DocStatusApplySuccess),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, restapi.NotFound("document not found")
+	}
+
+	res := restDocument(docs[0])
+	res.Markdown = string(docs[0].Markdown)
+	return &res, nil
+}
@@ -0,0 +1,844 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/scim"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/google/uuid"
+)
+
+const scimMaxCount = 200
+
+type SCIM struct {
+	logger        *glog.Logger
+	repoUser      *repo.User
+	repoUserThird *repo.UserThird
+	repoOrg       *repo.Org
+}
+
+type SCIMListReq struct {
+	Filter             string `form:"filter"`
+	StartIndex         int    `form:"startIndex"`
+	Count              *int   `form:"count"`
+	ExcludedAttributes string `form:"excludedAttributes"`
+}
+
+func (r *SCIMListReq) page() (offset int, limit int) {
+	if r.StartIndex < 1 {
+		r.StartIndex = 1
+	}
+
+	limit = scimMaxCount
+	if r.Count != nil && *r.Count >= 0 && *r.Count < scimMaxCount {
+		limit = *r.Count
+	}
+
+	return r.StartIndex - 1, limit
+}
+
+func (r *SCIMListReq) excluded(attr string) bool {
+	for _, item := range strings.Split(r.ExcludedAttributes, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), attr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type scimUser struct {
+	model.User
+
+	ThirdID    string `gorm:"column:third_id"`
+	ExternalID string `gorm:"column:external_id"`
+}
+
+type scimMember struct {
+	ID   uint   `gorm:"column:id"`
+	Name string `gorm:"column:name"`
+}
+
+var scimUserColumns = map[string]string{
+	"id":                "users.id",
+	"username":          "user_thirds.third_id",
+	"externalid":        "user_thirds.external_id",
+	"displayname":       "users.name",
+	"name.formatted":    "users.name",
+	"emails":            "users.email",
+	"emails.value":      "users.email",
+	"meta.created":      "users.created_at",
+	"meta.lastmodified": "users.updated_at",
+}
+
+var scimGroupColumns = map[string]string{
+	"id":                "orgs.id",
+	"displayname":       "orgs.name",
+	"meta.created":      "orgs.created_at",
+	"meta.lastmodified": "orgs.updated_at",
+}
+
+// scimFilterQuery 将 SCIM filter 转换为数据库查询条件
+func scimFilterQuery(filter string, columns map[string]string) ([]repo.QueryOptFunc, error) {
+	exprs, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var queryFuncs []repo.QueryOptFunc
+	for _, expr := range exprs {
+		if expr.Attr == "active" {
+			active, ok := expr.Value.(bool)
+			if !ok || (expr.Op != scim.OpEq && expr.Op != scim.OpNe) {
+				return nil, scim.BadRequest(scim.ErrorTypeInvalidFilter, "active only support eq/ne with boolean value")
+			}
+
+			if expr.Op == scim.OpNe {
+				active = !active
+			}
+
+			if active {
+				queryFuncs = append(queryFuncs, repo.QueryWithEqual("users.block_until", 0, repo.EqualOPGTE))
+			} else {
+				queryFuncs = append(queryFuncs, repo.QueryWithEqual("users.block_until", 0, repo.EqualOPLT))
+			}
+			continue
+		}
+
+		column, ok := columns[expr.Attr]
+		if !ok {
+			return nil, scim.BadRequest(scim.ErrorTypeInvalidFilter, "unsupported filter attribute: "+expr.Attr)
+		}
+
+		value, ok := expr.Value.(string)
+		if !ok && expr.Op != scim.OpPr {
+			return nil, scim.BadRequest(scim.ErrorTypeInvalidFilter, "unsupported filter value of "+expr.Attr)
+		}
+
+		var query string
+		switch expr.Op {
+		case scim.OpEq:
+			query = "LOWER(" + column + "::text) = LOWER(?)"
+		case scim.OpNe:
+			query = "LOWER(" + column + "::text) != LOWER(?)"
+		case scim.OpCo:
+			query, value = column+"::text ILIKE ?", "%"+util.EscapeLike(value)+"%"
+		case scim.OpSw:
+			query, value = column+"::text ILIKE ?", util.EscapeLike(value)+"%"
+		case scim.OpEw:
+			query, value = column+"::text ILIKE ?", "%"+util.EscapeLike(value)
+		case scim.OpPr:
+			query, value = "COALESCE("+column+"::text, '') != ?", ""
+		case scim.OpGt:
+			query = column + " > ?"
+		case scim.OpGe:
+			query = column + " >= ?"
+		case scim.OpLt:
+			query = column + " < ?"
+		case scim.OpLe:
+			query = column + " <= ?"
+		}
+
+		queryFuncs = append(queryFuncs, repo.QueryWithEqual(query, value, repo.EqualOPRaw))
+	}
+
+	return queryFuncs, nil
+}
+
+func scimID(id string) (uint, error) {
+	res, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || res == 0 {
+		return 0, scim.NotFound("resource " + id + " not found")
+	}
+
+	return uint(res), nil
+}
+
+func scimMeta(resourceType string, base model.Base) *scim.Meta {
+	return &scim.Meta{
+		ResourceType: resourceType,
+		Created:      base.CreatedAt.Time().UTC().Format(time.RFC3339),
+		LastModified: base.UpdatedAt.Time().UTC().Format(time.RFC3339),
+	}
+}
+
+// orgNames 返回可以通过 SCIM 管理的组织，管理员组织不对外暴露
+func (s *SCIM) orgNames(ctx context.Context) (map[int64]string, error) {
+	var orgs []model.Org
+	err := s.repoOrg.List(ctx, &orgs, repo.QueryWithEqual("orgs.type", model.OrgTypeAdmin, repo.EqualOPNE))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[int64]string, len(orgs))
+	for _, org := range orgs {
+		res[int64(org.ID)] = org.Name
+	}
+
+	return res, nil
+}
+
+func (s *SCIM) toUser(user *scimUser, orgNames map[int64]string) scim.User {
+	active := user.BlockUntil >= 0
+	res := scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          strconv.FormatUint(uint64(user.ID), 10),
+		ExternalID:  user.ExternalID,
+		UserName:    user.ThirdID,
+		DisplayName: user.Name,
+		Name:        &scim.Name{Formatted: user.Name},
+		Active:      &active,
+		Meta:        scimMeta(scim.ResourceTypeUser, user.Base),
+	}
+
+	if user.Email != "" {
+		res.Emails = []scim.Email{{Value: user.Email, Type: "work", Primary: true}}
+	}
+
+	for _, orgID := range user.OrgIDs {
+		name, ok := orgNames[orgID]
+		if !ok {
+			continue
+		}
+
+		res.Groups = append(res.Groups, scim.Ref{
+			Value:   strconv.FormatInt(orgID, 10),
+			Display: name,
+		})
+	}
+
+	return res
+}
+
+func (s *SCIM) getUser(ctx context.Context, id string) (*scimUser, error) {
+	uid, err := scimID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []scimUser
+	err = s.repoUser.ListWithThird(ctx, model.AuthTypeSCIM, &users, 0, 1, repo.QueryWithEqual("users.id", uid))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, scim.NotFound("user " + id + " not found")
+	}
+
+	return &users[0], nil
+}
+
+func (s *SCIM) ListUsers(ctx context.Context, req SCIMListReq) (*scim.ListResponse[scim.User], error) {
+	queryFuncs, err := scimFilterQuery(req.Filter, scimUserColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	err = s.repoUser.CountWithThird(ctx, model.AuthTypeSCIM, &total, queryFuncs...)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, limit := req.page()
+	var users []scimUser
+	err = s.repoUser.ListWithThird(ctx, model.AuthTypeSCIM, &users, offset, limit, queryFuncs...)
+	if err != nil {
+		return nil, err
+	}
+
+	orgNames, err := s.orgNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]scim.User, 0, len(users))
+	for i := range users {
+		item := s.toUser(&users[i], orgNames)
+		if req.excluded("groups") {
+			item.Groups = nil
+		}
+
+		items = append(items, item)
+	}
+
+	return scim.NewListResponse(items, total, req.StartIndex), nil
+}
+
+func (s *SCIM) GetUser(ctx context.Context, id string) (*scim.User, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	orgNames, err := s.orgNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := s.toUser(user, orgNames)
+	return &res, nil
+}
+
+func (s *SCIM) userNameUsed(ctx context.Context, userName string, excludeUID uint) error {
+	exist, err := s.repoUserThird.Exist(ctx,
+		repo.QueryWithEqual("type", model.AuthTypeSCIM),
+		repo.QueryWithEqual("LOWER(third_id) = LOWER(?)", userName, repo.EqualOPRaw),
+		repo.QueryWithEqual("user_id", excludeUID, repo.EqualOPNE),
+	)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		return scim.Conflict("userName " + userName + " already exists")
+	}
+
+	return nil
+}
+
+func (s *SCIM) emailUsed(ctx context.Context, email string, excludeUID uint) error {
+	exist, err := s.repoUser.Exist(ctx,
+		repo.QueryWithEqual("email", email),
+		repo.QueryWithEqual("id", excludeUID, repo.EqualOPNE),
+	)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		return scim.Conflict("email " + email + " already used")
+	}
+
+	return nil
+}
+
+func scimUserEmail(req *scim.User) string {
+	email := req.PrimaryEmail()
+	if email == "" && strings.Contains(req.UserName, "@") {
+		email = req.UserName
+	}
+
+	return email
+}
+
+// CreateUser 邮箱已存在且未被 SCIM 关联的用户会直接关联，例如之前通过单点登录注册的用户
+func (s *SCIM) CreateUser(ctx context.Context, req scim.User) (*scim.User, error) {
+	if req.UserName == "" {
+		return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "userName is required")
+	}
+
+	err := s.userNameUsed(ctx, req.UserName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	email := scimUserEmail(&req)
+	var user model.User
+	if email != "" {
+		err = s.repoUser.GetByEmail(ctx, &user, email)
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	adopt := user.ID > 0
+	if adopt {
+		exist, err := s.repoUserThird.Exist(ctx,
+			repo.QueryWithEqual("type", model.AuthTypeSCIM),
+			repo.QueryWithEqual("user_id", user.ID),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if exist {
+			return nil, scim.Conflict("email " + email + " already used")
+		}
+	} else {
+		org, err := s.repoOrg.GetDefaultOrg(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		name := req.FormattedName()
+		if name == "" {
+			name = req.UserName
+		}
+
+		user = model.User{
+			UserBasic: model.UserBasic{
+				Name:      name,
+				Email:     email,
+				Role:      model.UserRoleUser,
+				OrgIDs:    model.Int64Array{int64(org.ID)},
+				WebNotify: true,
+			},
+			Key: uuid.NewString(),
+		}
+		if !req.IsActive() {
+			user.BlockUntil = -1
+		}
+	}
+
+	err = s.repoUser.CreateWithThird(ctx, &user, &model.UserThird{
+		ThirdID:    req.UserName,
+		ExternalID: req.ExternalID,
+		Type:       model.AuthTypeSCIM,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithContext(ctx).With("user_id", user.ID).With("user_name", req.UserName).With("adopt", adopt).Info("scim user provisioned")
+
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	if adopt {
+		// 已有用户以 IdP 提供的信息为准
+		dbUser, err := s.getUser(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.saveUser(ctx, dbUser, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+// saveUser 将 SCIM 用户的属性写回数据库，停用用户时会使其登录失效
+func (s *SCIM) saveUser(ctx context.Context, user *scimUser, req scim.User) error {
+	if req.UserName == "" {
+		return scim.BadRequest(scim.ErrorTypeInvalidValue, "userName is required")
+	}
+
+	userM := make(map[string]any)
+	thirdM := make(map[string]any)
+
+	if req.UserName != user.ThirdID {
+		err := s.userNameUsed(ctx, req.UserName, user.ID)
+		if err != nil {
+			return err
+		}
+
+		thirdM["third_id"] = req.UserName
+	}
+
+	if req.ExternalID != user.ExternalID {
+		thirdM["external_id"] = req.ExternalID
+	}
+
+	if name := req.FormattedName(); name != "" && name != user.Name {
+		userM["name"] = name
+	}
+
+	if email := scimUserEmail(&req); email != "" && email != user.Email {
+		err := s.emailUsed(ctx, email, user.ID)
+		if err != nil {
+			return err
+		}
+
+		userM["email"] = email
+	}
+
+	if !req.IsActive() && user.BlockUntil >= 0 {
+		if user.Builtin {
+			return scim.BadRequest(scim.ErrorTypeMutability, "builtin user can not be deactivated")
+		}
+
+		userM["block_until"] = -1
+		userM["key"] = uuid.NewString()
+	} else if req.IsActive() && user.BlockUntil < 0 {
+		userM["block_until"] = 0
+	}
+
+	if len(userM) == 0 && len(thirdM) == 0 {
+		return nil
+	}
+
+	if len(thirdM) > 0 {
+		thirdM["updated_at"] = time.Now()
+	}
+	userM["updated_at"] = time.Now()
+
+	return s.repoUser.UpdateWithThird(ctx, user.ID, model.AuthTypeSCIM, userM, thirdM)
+}
+
+func (s *SCIM) ReplaceUser(ctx context.Context, id string, req scim.User) (*scim.User, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.saveUser(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+func (s *SCIM) PatchUser(ctx context.Context, id string, req scim.PatchRequest) (*scim.User, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patchUser := s.toUser(user, nil)
+	err = patchUser.Patch(req.Operations)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.saveUser(ctx, user, patchUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+func (s *SCIM) DeleteUser(ctx context.Context, id string) error {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user.Builtin {
+		return scim.BadRequest(scim.ErrorTypeMutability, "builtin user can not be deleted")
+	}
+
+	err = s.repoUser.DeleteByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	s.logger.WithContext(ctx).With("user_id", user.ID).With("user_name", user.ThirdID).Info("scim user deleted")
+	return nil
+}
+
+func (s *SCIM) toGroup(org *model.Org, members []scimMember) scim.Group {
+	res := scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          strconv.FormatUint(uint64(org.ID), 10),
+		DisplayName: org.Name,
+		Meta:        scimMeta(scim.ResourceTypeGroup, org.Base),
+	}
+
+	for _, member := range members {
+		res.Members = append(res.Members, scim.Ref{
+			Value:   strconv.FormatUint(uint64(member.ID), 10),
+			Display: member.Name,
+		})
+	}
+
+	return res
+}
+
+func (s *SCIM) getGroup(ctx context.Context, id string) (*model.Org, error) {
+	orgID, err := scimID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var org model.Org
+	err = s.repoOrg.GetByID(ctx, &org, orgID, repo.QueryWithEqual("type", model.OrgTypeAdmin, repo.EqualOPNE))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, scim.NotFound("group " + id + " not found")
+		}
+
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (s *SCIM) groupMembers(ctx context.Context, orgID uint) ([]scimMember, error) {
+	var members []scimMember
+	err := s.repoUser.List(ctx, &members,
+		repo.QueryWithSelectColumn("id", "name"),
+		repo.QueryWithEqual("org_ids", orgID, repo.EqualOPValIn),
+		repo.QueryWithOrderBy("id ASC"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (s *SCIM) ListGroups(ctx context.Context, req SCIMListReq) (*scim.ListResponse[scim.Group], error) {
+	queryFuncs, err := scimFilterQuery(req.Filter, scimGroupColumns)
+	if err != nil {
+		return nil, err
+	}
+	queryFuncs = append(queryFuncs, repo.QueryWithEqual("orgs.type", model.OrgTypeAdmin, repo.EqualOPNE))
+
+	var orgs []model.Org
+	err = s.repoOrg.List(ctx, &orgs, append(queryFuncs, repo.QueryWithOrderBy("orgs.id ASC"))...)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, limit := req.page()
+	total := int64(len(orgs))
+	orgs = orgs[min(offset, len(orgs)):min(offset+limit, len(orgs))]
+
+	items := make([]scim.Group, 0, len(orgs))
+	for i := range orgs {
+		var members []scimMember
+		if !req.excluded("members") {
+			members, err = s.groupMembers(ctx, orgs[i].ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		items = append(items, s.toGroup(&orgs[i], members))
+	}
+
+	return scim.NewListResponse(items, total, req.StartIndex), nil
+}
+
+func (s *SCIM) GetGroup(ctx context.Context, id string) (*scim.Group, error) {
+	org, err := s.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.groupMembers(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := s.toGroup(org, members)
+	return &res, nil
+}
+
+func (s *SCIM) groupNameUsed(ctx context.Context, name string, excludeID uint) error {
+	exist, err := s.repoOrg.Exist(ctx,
+		repo.QueryWithEqual("name", name),
+		repo.QueryWithEqual("id", excludeID, repo.EqualOPNE),
+	)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		return scim.Conflict("group " + name + " already exists")
+	}
+
+	return nil
+}
+
+func (s *SCIM) CreateGroup(ctx context.Context, req scim.Group) (*scim.Group, error) {
+	if req.DisplayName == "" {
+		return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "displayName is required")
+	}
+
+	err := s.groupNameUsed(ctx, req.DisplayName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	org := model.Org{
+		Name:     req.DisplayName,
+		ForumIDs: model.Int64Array{},
+	}
+	err = s.repoOrg.Create(ctx, &org)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.saveGroup(ctx, &org, nil, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithContext(ctx).With("org_id", org.ID).With("name", org.Name).Info("scim group provisioned")
+	return s.GetGroup(ctx, strconv.FormatUint(uint64(org.ID), 10))
+}
+
+// saveGroup 更新组织名称，并根据成员差异调整用户所属组织
+func (s *SCIM) saveGroup(ctx context.Context, org *model.Org, members []scimMember, req scim.Group) error {
+	if req.DisplayName == "" {
+		return scim.BadRequest(scim.ErrorTypeInvalidValue, "displayName is required")
+	}
+
+	if req.DisplayName != org.Name {
+		if org.Builtin {
+			return scim.BadRequest(scim.ErrorTypeMutability, "builtin group can not be renamed")
+		}
+
+		err := s.groupNameUsed(ctx, req.DisplayName, org.ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.repoOrg.Update(ctx, map[string]any{
+			"name":       req.DisplayName,
+			"updated_at": time.Now(),
+		}, repo.QueryWithEqual("id", org.ID))
+		if err != nil {
+			return err
+		}
+	}
+
+	var (
+		current = make(model.Int64Array, 0, len(members))
+		target  = make(model.Int64Array, 0, len(req.Members))
+	)
+	for _, member := range members {
+		current = append(current, int64(member.ID))
+	}
+	for _, member := range req.Members {
+		uid, err := strconv.ParseInt(member.Value, 10, 64)
+		if err != nil {
+			return scim.BadRequest(scim.ErrorTypeInvalidValue, "invalid member "+member.Value)
+		}
+
+		target = append(target, uid)
+	}
+
+	var add, remove model.Int64Array
+	for _, uid := range target {
+		if !slices.Contains(current, uid) {
+			add = append(add, uid)
+		}
+	}
+	for _, uid := range current {
+		if !slices.Contains(target, uid) {
+			remove = append(remove, uid)
+		}
+	}
+
+	err := s.repoOrg.AddUsers(ctx, org.ID, add)
+	if err != nil {
+		return err
+	}
+
+	return s.repoOrg.RemoveUsers(ctx, org.ID, remove)
+}
+
+func (s *SCIM) ReplaceGroup(ctx context.Context, id string, req scim.Group) (*scim.Group, error) {
+	org, err := s.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.groupMembers(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.saveGroup(ctx, org, members, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, id)
+}
+
+func (s *SCIM) PatchGroup(ctx context.Context, id string, req scim.PatchRequest) (*scim.Group, error) {
+	org, err := s.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.groupMembers(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	patchGroup := s.toGroup(org, members)
+	err = patchGroup.Patch(req.Operations)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.saveGroup(ctx, org, members, patchGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, id)
+}
+
+func (s *SCIM) DeleteGroup(ctx context.Context, id string) error {
+	org, err := s.getGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if org.Builtin {
+		return scim.BadRequest(scim.ErrorTypeMutability, "builtin group can not be deleted")
+	}
+
+	return s.repoOrg.Delete(ctx, org.ID)
+}
+
+func (s *SCIM) ServiceProviderConfig() map[string]any {
+	return map[string]any{
+		"schemas":        []string{scim.SchemaServiceProviderConfig},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": map[string]any{"supported": false},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "API Token",
+			"description": "使用后台创建的 API Token 进行认证",
+			"primary":     true,
+		}},
+	}
+}
+
+func (s *SCIM) ResourceTypes() *scim.ListResponse[map[string]any] {
+	items := []map[string]any{
+		{
+			"schemas":  []string{scim.SchemaResourceType},
+			"id":       scim.ResourceTypeUser,
+			"name":     scim.ResourceTypeUser,
+			"endpoint": "/Users",
+			"schema":   scim.SchemaUser,
+		},
+		{
+			"schemas":  []string{scim.SchemaResourceType},
+			"id":       scim.ResourceTypeGroup,
+			"name":     scim.ResourceTypeGroup,
+			"endpoint": "/Groups",
+			"schema":   scim.SchemaGroup,
+		},
+	}
+
+	return scim.NewListResponse(items, int64(len(items)), 1)
+}
+
+func newSCIM(user *repo.User, userThird *repo.UserThird, org *repo.Org) *SCIM {
+	return &SCIM{
+		logger:        glog.Module("svc", "scim"),
+		repoUser:      user,
+		repoUserThird: userThird,
+		repoOrg:       org,
+	}
+}
+
+func init() {
+	registerSvc(newSCIM)
+}
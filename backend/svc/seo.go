@@ -4,42 +4,46 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/cache"
 	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
 	"github.com/chaitin/koalaqa/repo"
 )
 
 type SEO struct {
-	repoSys  *repo.System
-	cacheSEO *model.SystemSEO
-	lock     sync.Mutex
+	repoSys     *repo.System
+	broadcaster cache.Broadcaster
+	logger      *glog.Logger
+	cacheSEO    atomic.Pointer[model.SystemSEO]
+	lock        sync.Mutex
 }
 
+const cacheTopicSEO = "seo"
+
 func (s *SEO) Get(ctx context.Context) (*model.SystemSEO, error) {
-	if s.cacheSEO != nil {
-		return s.cacheSEO, nil
+	if seo := s.cacheSEO.Load(); seo != nil {
+		return seo, nil
 	}
 
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	if s.cacheSEO != nil {
-		return s.cacheSEO, nil
+	if seo := s.cacheSEO.Load(); seo != nil {
+		return seo, nil
 	}
 
 	var seo model.SystemSEO
 	err := s.repoSys.GetValueByKey(ctx, &seo, model.SystemKeySEO)
 	if err != nil {
-		if errors.Is(err, database.ErrRecordNotFound) {
-			s.cacheSEO = &model.SystemSEO{}
-			return &model.SystemSEO{}, nil
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			return nil, err
 		}
-
-		return nil, err
 	}
 
-	s.cacheSEO = &seo
+	s.cacheSEO.Store(&seo)
 
 	return &seo, nil
 }
@@ -56,16 +60,31 @@ func (s *SEO) Update(ctx context.Context, seo model.SystemSEO) error {
 		return err
 	}
 
-	s.cacheSEO = &seo
+	s.cacheSEO.Store(&seo)
+
+	err = s.broadcaster.Broadcast(ctx, cacheTopicSEO)
+	if err != nil {
+		s.logger.WithContext(ctx).WithErr(err).Warn("broadcast seo cache invalidate failed")
+	}
 
 	return nil
 }
 
-func newSEO(sys *repo.System) *SEO {
-	return &SEO{
-		repoSys: sys,
-		lock:    sync.Mutex{},
+func newSEO(sys *repo.System, broadcaster cache.Broadcaster) *SEO {
+	s := &SEO{
+		repoSys:     sys,
+		broadcaster: broadcaster,
+		logger:      glog.Module("svc", "seo"),
+		lock:        sync.Mutex{},
 	}
+
+	broadcaster.Subscribe(cacheTopicSEO, func(context.Context) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		s.cacheSEO.Store(nil)
+	})
+	return s
 }
 
 func init() {
@@ -3,19 +3,27 @@ package svc
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/chaitin/koalaqa/model"
 	"github.com/chaitin/koalaqa/pkg/batch"
+	"github.com/chaitin/koalaqa/pkg/export"
 	"github.com/chaitin/koalaqa/pkg/util"
 	"github.com/chaitin/koalaqa/repo"
 )
 
 type Stat struct {
-	batcher  batch.Batcher[model.StatInfo]
-	repoStat *repo.Stat
-	repoDisc *repo.Discussion
-	repoComm *repo.Comment
+	batcher       batch.Batcher[model.StatInfo]
+	repoStat      *repo.Stat
+	repoDisc      *repo.Discussion
+	repoComm      *repo.Comment
+	repoForum     *repo.Forum
+	repoGroupItem *repo.GroupItem
+	repoTag       *repo.DiscussionTag
+	repoAsk       *repo.AskSession
+	repoSite      *repo.WidgetSite
 }
 
 func (s *Stat) UpdateStat(ctx context.Context, key string) error {
@@ -32,6 +40,8 @@ func (s *Stat) Count(ctx context.Context, t model.StatType, req StatReq) (count
 	err = s.repoStat.Count(ctx, &count,
 		repo.QueryWithEqual("type", t),
 		repo.QueryWithEqual("ts", req.Begin, repo.EqualOPGTE),
+		repo.QueryWithEqual("ts", req.end(), repo.EqualOPLT),
+		repo.QueryWithStatDimension(req.dimension()),
 	)
 
 	return
@@ -41,6 +51,8 @@ func (s *Stat) Sum(ctx context.Context, t model.StatType, req StatReq) (count in
 	err = s.repoStat.Sum(ctx, &count,
 		repo.QueryWithEqual("type", t),
 		repo.QueryWithEqual("ts", req.Begin, repo.EqualOPGTE),
+		repo.QueryWithEqual("ts", req.end(), repo.EqualOPLT),
+		repo.QueryWithStatDimension(req.dimension()),
 	)
 
 	return
@@ -54,8 +66,49 @@ func (s *Stat) PV(ctx context.Context, req StatReq) (int64, error) {
 	return s.Sum(ctx, model.StatTypeVisit, req)
 }
 
+// StatReq 统计区间为 [begin, end)，访问和搜索统计没有帖子维度，不受板块、分类、标签筛选影响
 type StatReq struct {
 	Begin int64 `form:"begin" binding:"required"`
+	// End 为空时统计到当前时间
+	End      int64            `form:"end" binding:"omitempty,gtfield=Begin"`
+	ForumID  uint             `form:"forum_id"`
+	GroupIDs model.Int64Array `form:"group_ids"`
+	TagIDs   model.Int64Array `form:"tag_ids"`
+	// Compare 同时返回上一个等长周期的数据
+	Compare bool `form:"compare"`
+}
+
+func (r StatReq) end() int64 {
+	if r.End > 0 {
+		return r.End
+	}
+
+	return time.Now().Unix()
+}
+
+func (r StatReq) beginTime() time.Time {
+	return time.Unix(r.Begin, 0)
+}
+
+func (r StatReq) endTime() time.Time {
+	return time.Unix(r.end(), 0)
+}
+
+func (r StatReq) dimension() repo.DiscDimension {
+	return repo.DiscDimension{
+		ForumID:  r.ForumID,
+		GroupIDs: r.GroupIDs,
+		TagIDs:   r.TagIDs,
+	}
+}
+
+// previous 紧邻当前区间之前的等长区间
+func (r StatReq) previous() StatReq {
+	end := r.end()
+	r.End = r.Begin
+	r.Begin -= end - r.Begin
+	r.Compare = false
+	return r
 }
 
 type StateTrendGroup uint
@@ -63,6 +116,8 @@ type StateTrendGroup uint
 const (
 	StateTrendGroupHour = iota + 1
 	StateTrendGroupDay
+	StateTrendGroupWeek
+	StateTrendGroupMonth
 )
 
 type StatTrendReq struct {
@@ -75,6 +130,24 @@ type StatTrendReq struct {
 type StatVisitRes struct {
 	UV int64 `json:"uv"`
 	PV int64 `json:"pv"`
+
+	Previous *StatVisitRes `json:"previous,omitempty"`
+}
+
+func (r *StatVisitRes) Tables() []export.Table {
+	tables := []export.Table{{
+		Name:   "访问",
+		Header: []string{"UV", "PV"},
+		Rows:   [][]string{{fmt.Sprint(r.UV), fmt.Sprint(r.PV)}},
+	}}
+
+	if r.Previous != nil {
+		prev := r.Previous.Tables()[0]
+		prev.Name = "上一周期访问"
+		tables = append(tables, prev)
+	}
+
+	return tables
 }
 
 func (s *Stat) Visit(ctx context.Context, req StatReq) (*StatVisitRes, error) {
@@ -88,29 +161,81 @@ func (s *Stat) Visit(ctx context.Context, req StatReq) (*StatVisitRes, error) {
 		return nil, err
 	}
 
-	return &StatVisitRes{
+	res := StatVisitRes{
 		UV: uv,
 		PV: pv,
-	}, nil
+	}
+
+	if req.Compare {
+		res.Previous, err = s.Visit(ctx, req.previous())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
 }
 
 func (s *Stat) SearchCount(ctx context.Context, req StatReq) (int64, error) {
 	return s.Sum(ctx, model.StatTypeSearch, req)
 }
 
+type StatSearchRes struct {
+	Count    int64  `json:"count"`
+	Previous *int64 `json:"previous,omitempty"`
+}
+
+func (r *StatSearchRes) Tables() []export.Table {
+	table := export.Table{
+		Name:   "搜索",
+		Header: []string{"搜索次数"},
+	}
+
+	row := []any{r.Count}
+	if r.Previous != nil {
+		table.Header = append(table.Header, "上一周期搜索次数")
+		row = append(row, *r.Previous)
+	}
+	table.Append(row...)
+
+	return []export.Table{table}
+}
+
+func (s *Stat) Search(ctx context.Context, req StatReq) (*StatSearchRes, error) {
+	count, err := s.SearchCount(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res := StatSearchRes{Count: count}
+	if req.Compare {
+		prev, err := s.SearchCount(ctx, req.previous())
+		if err != nil {
+			return nil, err
+		}
+
+		res.Previous = &prev
+	}
+
+	return &res, nil
+}
+
 func (s *Stat) Accept(ctx context.Context, onlyBot bool, req StatReq) (count int64, err error) {
-	beginTime := time.Unix(req.Begin, 0)
+	beginTime := req.beginTime()
+	endTime := req.endTime()
 	query := []repo.QueryOptFunc{
 		repo.QueryWithEqual("parent_id", 0),
 		repo.QueryWithEqual("accepted", true),
 		repo.QueryWithEqual("created_at", beginTime, repo.EqualOPGTE),
+		repo.QueryWithEqual("created_at", endTime, repo.EqualOPLT),
+		repo.QueryWithDiscDimension("discussion_id", "id", req.dimension()),
 	}
 
 	if onlyBot {
 		query = append(query, repo.QueryWithEqual("bot", true))
 	}
 
-	err = s.repoComm.CountDiscussion(ctx, &count, beginTime, query...)
+	err = s.repoComm.CountDiscussion(ctx, &count, beginTime, endTime, query...)
 
 	return
 }
@@ -120,6 +245,8 @@ func (s *Stat) HumanResponseTime(ctx context.Context, req StatReq) (int64, error
 	err := s.repoStat.List(ctx, &stats,
 		repo.QueryWithEqual("type", model.StatTypeBotUnknown),
 		repo.QueryWithEqual("ts", req.Begin, repo.EqualOPGTE),
+		repo.QueryWithEqual("ts", req.end(), repo.EqualOPLT),
+		repo.QueryWithDiscDimension("key", "uuid", req.dimension()),
 	)
 	if err != nil {
 		return 0, err
@@ -192,20 +319,67 @@ type StatDiscussionRes struct {
 	Accept        int64                               `json:"accept"`
 	BotAccept     int64                               `json:"bot_accept"`
 	HumanRespTime int64                               `json:"human_resp_time"`
+
+	Previous *StatDiscussionRes `json:"previous,omitempty"`
+}
+
+func (r *StatDiscussionRes) Tables() []export.Table {
+	summary := export.Table{
+		Name:   "帖子",
+		Header: []string{"AI 无法回答", "采纳", "AI 回答被采纳", "人工响应总时长(秒)"},
+	}
+	summary.Append(r.BotUnknown, r.Accept, r.BotAccept, r.HumanRespTime)
+
+	types := export.Table{
+		Name:   "帖子类型",
+		Header: []string{"类型", "数量"},
+	}
+	for _, item := range r.Discussions {
+		types.Append(item.Key.Name(), item.Count)
+	}
+
+	tables := []export.Table{summary, types}
+	if r.Previous != nil {
+		for _, table := range r.Previous.Tables() {
+			table.Name = "上一周期" + table.Name
+			tables = append(tables, table)
+		}
+	}
+
+	return tables
 }
 
 func (s *Stat) Discussion(ctx context.Context, req StatReq) (*StatDiscussionRes, error) {
 	var res StatDiscussionRes
-	t := time.Unix(req.Begin, 0)
 	var err error
-	res.Discussions, err = s.repoDisc.ListType(ctx,
-		repo.QueryWithEqual("created_at", util.DayTrunc(t), repo.EqualOPGTE),
+
+	// 未指定 end 时保持原有逻辑，帖子类型从 begin 当天开始统计且不限制结束时间
+	var (
+		typeBegin = req.beginTime()
+		end       time.Time
 	)
+	if req.End > 0 {
+		end = req.endTime()
+	} else {
+		typeBegin = util.DayTrunc(typeBegin)
+	}
+
+	typeQuery := []repo.QueryOptFunc{
+		repo.QueryWithEqual("created_at", typeBegin, repo.EqualOPGTE),
+		req.dimension().Query(),
+	}
+	if !end.IsZero() {
+		typeQuery = append(typeQuery, repo.QueryWithEqual("created_at", end, repo.EqualOPLT))
+	}
+
+	res.Discussions, err = s.repoDisc.ListType(ctx, typeQuery...)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.repoStat.BotUnknown(ctx, &res.BotUnknown, t)
+	err = s.repoStat.BotUnknown(ctx, &res.BotUnknown, req.beginTime(), end,
+		repo.QueryWithDiscDimension("key", "uuid", req.dimension()),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -224,6 +398,13 @@ func (s *Stat) Discussion(ctx context.Context, req StatReq) (*StatDiscussionRes,
 		return nil, err
 	}
 
+	if req.Compare {
+		res.Previous, err = s.Discussion(ctx, req.previous())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &res, nil
 }
 
@@ -233,36 +414,398 @@ type StatTrendItem struct {
 	Count int64          `json:"count"`
 }
 
-func (s *Stat) Trend(ctx context.Context, req StatTrendReq) (*model.ListRes[model.StatTrend], error) {
+type StatTrendRes struct {
+	model.ListRes[model.StatTrend]
+
+	Previous []model.StatTrend `json:"previous,omitempty"`
+}
+
+func trendTable(name string, types []model.StatType, items []model.StatTrend) export.Table {
+	table := export.Table{
+		Name:   name,
+		Header: []string{"时间"},
+	}
+	for _, t := range types {
+		table.Header = append(table.Header, t.Name())
+	}
+
+	for _, item := range items {
+		row := []any{time.Unix(item.Ts, 0).Format(time.DateTime)}
+		for _, t := range types {
+			var count int64
+			for _, typeItem := range item.Items.Inner() {
+				if typeItem.Type == t {
+					count = typeItem.Count
+					break
+				}
+			}
+
+			row = append(row, count)
+		}
+
+		table.Append(row...)
+	}
+
+	return table
+}
+
+func (r *StatTrendRes) Tables(types []model.StatType) []export.Table {
+	tables := []export.Table{trendTable("趋势", types, r.Items)}
+	if r.Previous != nil {
+		tables = append(tables, trendTable("上一周期趋势", types, r.Previous))
+	}
+
+	return tables
+}
+
+// mergeTrend 将按天聚合的数据合并到周或月
+func mergeTrend(items []model.StatTrendDayItem, trunc func(time.Time) time.Time) []model.StatTrend {
 	var (
-		res model.ListRes[model.StatTrend]
-		err error
+		tss    []int64
+		counts = make(map[int64]map[model.StatType]int64)
 	)
 
+	for _, item := range items {
+		ts := trunc(time.Unix(item.Ts, 0)).Unix()
+		if _, ok := counts[ts]; !ok {
+			tss = append(tss, ts)
+			counts[ts] = make(map[model.StatType]int64)
+		}
+
+		counts[ts][item.Type] += item.Count
+	}
+
+	slices.Sort(tss)
+
+	res := make([]model.StatTrend, len(tss))
+	for i, ts := range tss {
+		var trendItems []model.StatTrendItem
+		for t, count := range counts[ts] {
+			trendItems = append(trendItems, model.StatTrendItem{Type: t, Count: count})
+		}
+
+		slices.SortFunc(trendItems, func(a, b model.StatTrendItem) int {
+			return int(a.Type) - int(b.Type)
+		})
+
+		res[i] = model.StatTrend{
+			Ts:    ts,
+			Items: model.NewJSONB(trendItems),
+		}
+	}
+
+	return res
+}
+
+func monthTrunc(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func (s *Stat) trend(ctx context.Context, req StatTrendReq) ([]model.StatTrend, error) {
 	queryFuncs := []repo.QueryOptFunc{
 		repo.QueryWithEqual("ts", req.Begin, repo.EqualOPGTE),
+		repo.QueryWithEqual("ts", req.end(), repo.EqualOPLT),
 		repo.QueryWithEqual("type", req.StatTypes, repo.EqualOPIn),
+		repo.QueryWithStatDimension(req.dimension()),
 	}
 
 	switch req.StatGroup {
 	case StateTrendGroupHour:
-		res.Items, err = s.repoStat.Trend(ctx, queryFuncs...)
+		return s.repoStat.Trend(ctx, queryFuncs...)
 	case StateTrendGroupDay:
-		res.Items, err = s.repoStat.TrendDay(ctx, queryFuncs...)
+		return s.repoStat.TrendDay(ctx, queryFuncs...)
+	case StateTrendGroupWeek, StateTrendGroupMonth:
+		items, err := s.repoStat.TrendDayItems(ctx, queryFuncs...)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.StatGroup == StateTrendGroupWeek {
+			return mergeTrend(items, util.WeekTrunc), nil
+		}
+
+		return mergeTrend(items, monthTrunc), nil
 	default:
-		err = errors.New("unsupported stat group")
+		return nil, errors.New("unsupported stat group")
 	}
+}
+
+func (s *Stat) Trend(ctx context.Context, req StatTrendReq) (*StatTrendRes, error) {
+	var (
+		res StatTrendRes
+		err error
+	)
+
+	res.Items, err = s.trend(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
 	res.Total = int64(len(res.Items))
 
+	if req.Compare {
+		prevReq := req
+		prevReq.StatReq = req.previous()
+		res.Previous, err = s.trend(ctx, prevReq)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
+}
+
+type StatDimension uint
+
+const (
+	StatDimensionForum StatDimension = iota + 1
+	StatDimensionGroup
+	StatDimensionTag
+)
+
+type StatBreakdownReq struct {
+	StatReq
+
+	Dimension StatDimension `form:"dimension" binding:"required,min=1,max=3"`
+}
+
+type StatBreakdownRes struct {
+	Items    []model.StatBreakdown `json:"items"`
+	Previous []model.StatBreakdown `json:"previous,omitempty"`
+}
+
+func breakdownTable(name string, items []model.StatBreakdown) export.Table {
+	table := export.Table{
+		Name:   name,
+		Header: []string{"ID", "名称", "帖子数", "AI 无法回答", "采纳", "AI 回答被采纳"},
+	}
+	for _, item := range items {
+		table.Append(item.ID, item.Name, item.Discussions, item.BotUnknown, item.Accept, item.BotAccept)
+	}
+
+	return table
+}
+
+func (r *StatBreakdownRes) Tables() []export.Table {
+	tables := []export.Table{breakdownTable("维度统计", r.Items)}
+	if r.Previous != nil {
+		tables = append(tables, breakdownTable("上一周期维度统计", r.Previous))
+	}
+
+	return tables
+}
+
+func (s *Stat) breakdownNames(ctx context.Context, dim StatDimension, items []model.StatBreakdown) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make(model.Int64Array, len(items))
+	for i := range items {
+		ids[i] = int64(items[i].ID)
+	}
+
+	names := make(map[uint]string)
+	query := repo.QueryWithEqual("id", ids, repo.EqualOPEqAny)
+
+	switch dim {
+	case StatDimensionForum:
+		var forums []model.Forum
+		err := s.repoForum.List(ctx, &forums, query)
+		if err != nil {
+			return err
+		}
+
+		for _, forum := range forums {
+			names[forum.ID] = forum.Name
+		}
+	case StatDimensionGroup:
+		var groupItems []model.GroupItem
+		err := s.repoGroupItem.List(ctx, &groupItems, query)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range groupItems {
+			names[item.ID] = item.Name
+		}
+	case StatDimensionTag:
+		var tags []model.DiscussionTag
+		err := s.repoTag.List(ctx, &tags, query)
+		if err != nil {
+			return err
+		}
+
+		for _, tag := range tags {
+			names[tag.ID] = tag.Name
+		}
+	}
+
+	for i := range items {
+		items[i].Name = names[items[i].ID]
+	}
+
+	return nil
+}
+
+func (s *Stat) breakdown(ctx context.Context, req StatBreakdownReq) ([]model.StatBreakdown, error) {
+	var column string
+	switch req.Dimension {
+	case StatDimensionForum:
+		column = "forum_id"
+	case StatDimensionGroup:
+		column = "unnest(group_ids)"
+	case StatDimensionTag:
+		column = "unnest(tag_ids)"
+	default:
+		return nil, errors.New("unsupported stat dimension")
+	}
+
+	items, err := s.repoDisc.StatBreakdown(ctx, column,
+		repo.QueryWithEqual("created_at", req.beginTime(), repo.EqualOPGTE),
+		repo.QueryWithEqual("created_at", req.endTime(), repo.EqualOPLT),
+		req.dimension().Query(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.breakdownNames(ctx, req.Dimension, items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Breakdown 按板块、分类或标签拆分帖子统计
+func (s *Stat) Breakdown(ctx context.Context, req StatBreakdownReq) (*StatBreakdownRes, error) {
+	var (
+		res StatBreakdownRes
+		err error
+	)
+
+	res.Items, err = s.breakdown(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Compare {
+		prevReq := req
+		prevReq.StatReq = req.previous()
+		res.Previous, err = s.breakdown(ctx, prevReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Previous == nil {
+			res.Previous = []model.StatBreakdown{}
+		}
+	}
+
+	return &res, nil
+}
+
+type StatWidgetSiteRes struct {
+	Items    []model.StatWidgetSite `json:"items"`
+	Previous []model.StatWidgetSite `json:"previous,omitempty"`
+}
+
+func widgetSiteTable(name string, items []model.StatWidgetSite) export.Table {
+	table := export.Table{
+		Name:   name,
+		Header: []string{"站点 ID", "站点名称", "会话数", "提问数", "转人工会话数"},
+	}
+	for _, item := range items {
+		table.Append(item.SiteID, item.Name, item.Sessions, item.Questions, item.NeedHuman)
+	}
+
+	return table
+}
+
+func (r *StatWidgetSiteRes) Tables() []export.Table {
+	tables := []export.Table{widgetSiteTable("挂件站点", r.Items)}
+	if r.Previous != nil {
+		tables = append(tables, widgetSiteTable("上一周期挂件站点", r.Previous))
+	}
+
+	return tables
+}
+
+func (s *Stat) widgetSite(ctx context.Context, req StatReq) ([]model.StatWidgetSite, error) {
+	items, err := s.repoAsk.StatBySite(ctx,
+		repo.QueryWithEqual("created_at", req.beginTime(), repo.EqualOPGTE),
+		repo.QueryWithEqual("created_at", req.endTime(), repo.EqualOPLT),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return []model.StatWidgetSite{}, nil
+	}
+
+	ids := make(model.Int64Array, len(items))
+	for i := range items {
+		ids[i] = int64(items[i].SiteID)
+	}
+
+	var sites []model.WidgetSite
+	err = s.repoSite.List(ctx, &sites,
+		repo.QueryWithSelectColumn("id", "name"),
+		repo.QueryWithEqual("id", ids, repo.EqualOPEqAny),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string, len(sites))
+	for _, site := range sites {
+		names[site.ID] = site.Name
+	}
+
+	for i := range items {
+		items[i].Name = names[items[i].SiteID]
+	}
+
+	return items, nil
+}
+
+// WidgetSite 按网页挂件站点统计提问，不受板块、分类、标签筛选影响
+func (s *Stat) WidgetSite(ctx context.Context, req StatReq) (*StatWidgetSiteRes, error) {
+	var (
+		res StatWidgetSiteRes
+		err error
+	)
+
+	res.Items, err = s.widgetSite(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Compare {
+		res.Previous, err = s.widgetSite(ctx, req.previous())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &res, nil
 }
 
-func newStat(batcher batch.Batcher[model.StatInfo], s *repo.Stat, disc *repo.Discussion, comm *repo.Comment) *Stat {
-	return &Stat{batcher: batcher, repoStat: s, repoDisc: disc, repoComm: comm}
+func newStat(batcher batch.Batcher[model.StatInfo], s *repo.Stat, disc *repo.Discussion, comm *repo.Comment,
+	forum *repo.Forum, groupItem *repo.GroupItem, tag *repo.DiscussionTag, ask *repo.AskSession, site *repo.WidgetSite) *Stat {
+	return &Stat{
+		batcher:       batcher,
+		repoStat:      s,
+		repoDisc:      disc,
+		repoComm:      comm,
+		repoForum:     forum,
+		repoGroupItem: groupItem,
+		repoTag:       tag,
+		repoAsk:       ask,
+		repoSite:      site,
+	}
 }
 
 func init() {
@@ -0,0 +1,464 @@
+package svc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/tracker"
+	"github.com/chaitin/koalaqa/repo"
+	"go.uber.org/fx"
+)
+
+type trackerIn struct {
+	fx.In
+
+	TrackerRepo      *repo.Tracker
+	TrackerIssueRepo *repo.TrackerIssue
+	TrackerCommRepo  *repo.TrackerComment
+	DiscRepo         *repo.Discussion
+	CommRepo         *repo.Comment
+	UserRepo         *repo.User
+	ForumRepo        *repo.Forum
+	Disc             *Discussion
+	Issue            *Issue
+	Bot              *Bot
+	PublicAddr       *PublicAddress
+}
+
+type Tracker struct {
+	in     trackerIn
+	logger *glog.Logger
+}
+
+func newTracker(in trackerIn) *Tracker {
+	return &Tracker{
+		in:     in,
+		logger: glog.Module("svc", "tracker"),
+	}
+}
+
+type TrackerGetReq struct {
+	ForumID uint `form:"forum_id" binding:"required"`
+}
+
+func (t *Tracker) Get(ctx context.Context, req TrackerGetReq) (*model.Tracker, error) {
+	var res model.Tracker
+	err := t.in.TrackerRepo.Get(ctx, &res, repo.QueryWithEqual("forum_id", req.ForumID))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+type TrackerUpsertReq struct {
+	ForumID    uint `json:"forum_id" binding:"required"`
+	Enabled    bool `json:"enabled"`
+	AutoCreate bool `json:"auto_create"`
+	model.TrackerConfig
+}
+
+func (t *Tracker) Upsert(ctx context.Context, req TrackerUpsertReq) error {
+	_, err := tracker.New(req.TrackerConfig)
+	if err != nil {
+		return err
+	}
+
+	exist, err := t.in.ForumRepo.ExistByID(ctx, req.ForumID)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return errors.New("forum not found")
+	}
+
+	return t.in.TrackerRepo.Upsert(ctx, &model.Tracker{
+		ForumID:       req.ForumID,
+		Enabled:       req.Enabled,
+		AutoCreate:    req.AutoCreate,
+		TrackerConfig: req.TrackerConfig,
+	})
+}
+
+func (t *Tracker) Delete(ctx context.Context, id uint) error {
+	return t.in.TrackerRepo.DeleteWithIssue(ctx, id)
+}
+
+// enabledTracker 论坛启用的对接配置，未配置或未启用时返回 nil
+func (t *Tracker) enabledTracker(ctx context.Context, forumID uint) (*model.Tracker, tracker.Tracker, error) {
+	var cfg model.Tracker
+	err := t.in.TrackerRepo.Get(ctx, &cfg, repo.QueryWithEqual("forum_id", forumID))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, err
+	}
+
+	if !cfg.Enabled {
+		return nil, nil, nil
+	}
+
+	tr, err := tracker.New(cfg.TrackerConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, tr, nil
+}
+
+func (t *Tracker) GetIssue(ctx context.Context, userID uint, discUUID string) (*model.TrackerIssue, error) {
+	disc, err := t.in.DiscRepo.GetByUUID(ctx, discUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := t.in.UserRepo.HasForumPermission(ctx, userID, disc.ForumID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errPermission
+	}
+
+	var res model.TrackerIssue
+	err = t.in.TrackerIssueRepo.Get(ctx, &res, repo.QueryWithEqual("discussion_id", disc.ID))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (t *Tracker) CreateIssue(ctx context.Context, user model.UserInfo, discUUID string) (*model.TrackerIssue, error) {
+	if !user.CanOperator(0) {
+		return nil, errPermission
+	}
+
+	disc, err := t.in.DiscRepo.GetByUUID(ctx, discUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.createIssue(ctx, disc)
+}
+
+// AutoCreateIssue 论坛开启自动同步时，为新建的 Issue 创建外部 Issue
+func (t *Tracker) AutoCreateIssue(ctx context.Context, discID uint) error {
+	var disc model.Discussion
+	err := t.in.DiscRepo.GetByID(ctx, &disc, discID)
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := t.enabledTracker(ctx, disc.ForumID)
+	if err != nil {
+		return err
+	}
+	if cfg == nil || !cfg.AutoCreate {
+		return nil
+	}
+
+	_, err = t.createIssue(ctx, &disc)
+	return err
+}
+
+func (t *Tracker) createIssue(ctx context.Context, disc *model.Discussion) (*model.TrackerIssue, error) {
+	if disc.Type != model.DiscussionTypeIssue {
+		return nil, errors.New("invalid discussion")
+	}
+
+	exist, err := t.in.TrackerIssueRepo.Exist(ctx, repo.QueryWithEqual("discussion_id", disc.ID))
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return nil, errors.New("issue already synced")
+	}
+
+	cfg, tr, err := t.enabledTracker(ctx, disc.ForumID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, errors.New("tracker not enabled")
+	}
+
+	var forum model.Forum
+	err = t.in.ForumRepo.GetByID(ctx, &forum, disc.ForumID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := disc.Content
+	addr, err := t.in.PublicAddr.Callback(ctx, fmt.Sprintf("/%s/%s", forum.RouteName, disc.UUID))
+	if err != nil {
+		t.logger.WithContext(ctx).WithErr(err).Warn("get public address failed")
+	} else {
+		body += "\n\n---\n来源：" + addr
+	}
+
+	issue, err := tr.CreateIssue(ctx, disc.Title, body)
+	if err != nil {
+		return nil, err
+	}
+
+	res := model.TrackerIssue{
+		TrackerID:    cfg.ID,
+		DiscussionID: disc.ID,
+		ExternalID:   issue.ID,
+		URL:          issue.URL,
+	}
+	err = t.in.TrackerIssueRepo.Create(ctx, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// SyncComment 将 KoalaQA 评论同步到外部 Issue，机器人评论（包含从外部同步回来的评论）不同步
+func (t *Tracker) SyncComment(ctx context.Context, commentID uint) error {
+	var comment model.Comment
+	err := t.in.CommRepo.GetByID(ctx, &comment, commentID)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if comment.Bot {
+		return nil
+	}
+
+	var issue model.TrackerIssue
+	err = t.in.TrackerIssueRepo.Get(ctx, &issue, repo.QueryWithEqual("discussion_id", comment.DiscussionID))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	var cfg model.Tracker
+	err = t.in.TrackerRepo.GetByID(ctx, &cfg, issue.TrackerID)
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	tr, err := tracker.New(cfg.TrackerConfig)
+	if err != nil {
+		return err
+	}
+
+	var user model.User
+	err = t.in.UserRepo.GetByID(ctx, &user, comment.UserID)
+	if err != nil {
+		return err
+	}
+
+	synced, err := t.in.TrackerCommRepo.Exist(ctx,
+		repo.QueryWithEqual("tracker_issue_id", issue.ID),
+		repo.QueryWithEqual("comment_id", comment.ID),
+	)
+	if err != nil {
+		return err
+	}
+	if synced {
+		return nil
+	}
+
+	// 先记录同步中的评论，外部系统的回调可能早于 CreateComment 返回，回调根据内容认领后不会重复创建评论
+	body := fmt.Sprintf("**%s**：\n\n%s", user.Name, comment.Content)
+	pending := model.TrackerComment{
+		TrackerIssueID: issue.ID,
+		CommentID:      comment.ID,
+		ExternalID:     trackerPendingID(body),
+	}
+	claimed, err := t.in.TrackerCommRepo.Claim(ctx, &pending)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errors.New("same comment is syncing")
+	}
+
+	externalID, err := tr.CreateComment(ctx, issue.ExternalID, body)
+	if err != nil {
+		if delErr := t.in.TrackerCommRepo.DeleteByID(ctx, pending.ID); delErr != nil {
+			t.logger.WithContext(ctx).WithErr(delErr).With("comment_id", comment.ID).Warn("delete pending tracker comment failed")
+		}
+
+		return err
+	}
+
+	return t.in.TrackerCommRepo.Update(ctx, map[string]any{
+		"external_id": externalID,
+	}, repo.QueryWithEqual("id", pending.ID))
+}
+
+// trackerPendingID 同步中评论的临时外部 id，由评论内容计算
+func trackerPendingID(body string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(body)))
+	return "pending:" + hex.EncodeToString(sum[:16])
+}
+
+// Webhook 处理外部系统回调，状态变更映射到 Issue 状态，评论以机器人身份同步
+func (t *Tracker) Webhook(ctx context.Context, trackerID uint, header http.Header, body []byte) error {
+	var cfg model.Tracker
+	err := t.in.TrackerRepo.GetByID(ctx, &cfg, trackerID)
+	if err != nil {
+		return err
+	}
+
+	tr, err := tracker.New(cfg.TrackerConfig)
+	if err != nil {
+		return err
+	}
+
+	event, err := tr.ParseWebhook(header, body)
+	if err != nil {
+		return err
+	}
+
+	logger := t.logger.WithContext(ctx).With("tracker_id", trackerID).With("event", event)
+	if event == nil || !cfg.Enabled {
+		logger.Debug("ignore tracker webhook")
+		return nil
+	}
+
+	var issue model.TrackerIssue
+	err = t.in.TrackerIssueRepo.Get(ctx, &issue,
+		repo.QueryWithEqual("tracker_id", cfg.ID),
+		repo.QueryWithEqual("external_id", event.IssueID),
+	)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			logger.Debug("external issue not synced, ignore")
+			return nil
+		}
+
+		return err
+	}
+
+	var disc model.Discussion
+	err = t.in.DiscRepo.GetByID(ctx, &disc, issue.DiscussionID)
+	if err != nil {
+		return err
+	}
+
+	bot, err := t.in.Bot.Get(ctx)
+	if err != nil {
+		return err
+	}
+	botUser := model.UserInfo{
+		UserCore: model.UserCore{
+			UID: bot.UserID,
+		},
+		UserBasic: model.UserBasic{
+			Role: model.UserRoleOperator,
+		},
+	}
+
+	switch event.Type {
+	case tracker.EventTypeState:
+		return t.applyState(ctx, botUser, &disc, event.State)
+	case tracker.EventTypeComment:
+		// KoalaQA 同步过去的评论的回调
+		echo, err := t.in.TrackerCommRepo.ClaimPending(ctx, issue.ID, trackerPendingID(event.Body), event.CommentID)
+		if err != nil {
+			return err
+		}
+		if echo {
+			logger.Debug("comment synced from koala, ignore")
+			return nil
+		}
+
+		claimed, err := t.in.TrackerCommRepo.Claim(ctx, &model.TrackerComment{
+			TrackerIssueID: issue.ID,
+			ExternalID:     event.CommentID,
+		})
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			logger.Debug("comment already synced, ignore")
+			return nil
+		}
+
+		commentID, err := t.in.Disc.CreateComment(ctx, bot.UserID, disc.UUID, CommentCreateReq{
+			Content: fmt.Sprintf("**%s**：\n\n%s", event.Author, event.Body),
+			Bot:     true,
+		})
+		if err != nil {
+			return err
+		}
+
+		return t.in.TrackerCommRepo.Update(ctx, map[string]any{
+			"comment_id": commentID,
+		}, repo.QueryWithEqual("tracker_issue_id", issue.ID), repo.QueryWithEqual("external_id", event.CommentID))
+	}
+
+	return nil
+}
+
+func (t *Tracker) applyState(ctx context.Context, user model.UserInfo, disc *model.Discussion, state tracker.State) error {
+	switch state {
+	case tracker.StateOpen:
+		if disc.Resolved == model.DiscussionStateNone {
+			return nil
+		}
+
+		// 内置状态无法回退，只有配置了自定义状态的论坛才处理重新打开
+		_, err := t.in.Issue.TransitCategory(ctx, user, disc, model.DiscussionStateNone)
+		return err
+	case tracker.StateInProgress:
+		if disc.Resolved == model.DiscussionStateInProgress {
+			return nil
+		}
+
+		return t.in.Disc.ResolveIssue(ctx, user, disc.UUID, ResolveIssueReq{Resolve: model.DiscussionStateInProgress})
+	case tracker.StateClosed:
+		if disc.Resolved == model.DiscussionStateResolved {
+			return nil
+		}
+
+		if disc.IssueStateID == 0 && disc.Resolved == model.DiscussionStateNone {
+			err := t.in.Disc.ResolveIssue(ctx, user, disc.UUID, ResolveIssueReq{Resolve: model.DiscussionStateInProgress})
+			if err != nil {
+				return err
+			}
+		}
+
+		return t.in.Disc.ResolveIssue(ctx, user, disc.UUID, ResolveIssueReq{Resolve: model.DiscussionStateResolved})
+	}
+
+	return nil
+}
+
+func init() {
+	registerSvc(newTracker)
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 
 	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/mq"
+	"github.com/chaitin/koalaqa/pkg/topic"
 	"github.com/chaitin/koalaqa/repo"
 )
 
 type Trend struct {
 	svcUser   *User
 	repoTrend *repo.Trend
+	pub       mq.Publisher
 }
 
 type TrendListReq struct {
@@ -56,13 +59,20 @@ func (t *Trend) List(ctx context.Context, curUserID uint, req TrendListReq) (*mo
 }
 
 func (t *Trend) Create(ctx context.Context, trend *model.Trend) error {
-	return t.repoTrend.Create(ctx, trend)
+	err := t.repoTrend.Create(ctx, trend)
+	if err != nil {
+		return err
+	}
+
+	t.pub.Publish(ctx, topic.TopicUserBadge, topic.MsgUserBadge{UserID: trend.UserID})
+	return nil
 }
 
-func newTrend(t *repo.Trend, u *User) *Trend {
+func newTrend(t *repo.Trend, u *User, pub mq.Publisher) *Trend {
 	return &Trend{
 		svcUser:   u,
 		repoTrend: t,
+		pub:       pub,
 	}
 }
 
@@ -0,0 +1,252 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/config"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/oss"
+	"github.com/chaitin/koalaqa/pkg/upload"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+var (
+	errUploadQuota = errors.New("upload quota exceeded")
+	errUploadCount = errors.New("upload count exceeded")
+)
+
+type Upload struct {
+	oc         oss.Client
+	scanner    upload.Scanner
+	repoUpload *repo.Upload
+	repoDesc   *repo.ImageDescription
+	cfg        config.Upload
+	logger     *glog.Logger
+}
+
+type uploadReq struct {
+	UserID uint
+	Scene  upload.Scene
+	Dir    string
+	File   *multipart.FileHeader
+	// Data 未传 File 时上传的文件内容，如邮件附件，Filename 为其文件名
+	Data     []byte
+	Filename string
+	// Quota 是否校验用户配额，管理员上传知识库文件时不校验
+	Quota bool
+}
+
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error {
+	return nil
+}
+
+func (r *uploadReq) open() (multipart.File, string, int64, error) {
+	if r.File == nil {
+		return bytesFile{bytes.NewReader(r.Data)}, r.Filename, int64(len(r.Data)), nil
+	}
+
+	f, err := r.File.Open()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return f, r.File.Filename, r.File.Size, nil
+}
+
+func (u *Upload) checkQuota(ctx context.Context, uid uint, size int64) error {
+	var usage model.UploadUsage
+	err := u.repoUpload.Usage(ctx, &usage, uid)
+	if err != nil {
+		return err
+	}
+
+	if u.cfg.DailyCount > 0 && usage.Today >= int64(u.cfg.DailyCount) {
+		return errUploadCount
+	}
+
+	if u.cfg.UserQuota > 0 && usage.Size+size > u.cfg.UserQuota {
+		return errUploadQuota
+	}
+
+	return nil
+}
+
+// upload 校验文件类型、扫描病毒、去除图片元数据后上传，并记录上传文件
+func (u *Upload) upload(ctx context.Context, req uploadReq) (string, error) {
+	f, filename, fileSize, err := req.open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if req.Quota {
+		err := u.checkQuota(ctx, req.UserID, fileSize)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	contentType, ext, err := upload.Sniff(req.Scene, head[:n], filepath.Ext(filename))
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	err = u.scanner.Scan(ctx, f)
+	if err != nil {
+		var infected *upload.InfectedError
+		if errors.As(err, &infected) {
+			u.logger.WithContext(ctx).With("user_id", req.UserID).With("filename", filename).
+				With("signature", infected.Signature).Warn("reject infected file")
+		}
+		return "", err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		reader io.Reader = f
+		size             = fileSize
+	)
+	if upload.IsImage(contentType) {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return "", err
+		}
+
+		data, err = upload.StripMetadata(data, contentType)
+		if err != nil {
+			return "", err
+		}
+
+		reader = bytes.NewReader(data)
+		size = int64(len(data))
+	}
+
+	var path string
+	if upload.Public(req.Scene, contentType) {
+		path, err = u.oc.Upload(ctx, req.Dir, reader,
+			oss.WithExt(ext),
+			oss.WithFileSize(int(size)),
+			oss.WithLimitSize(),
+			oss.WithPublic(),
+		)
+	} else {
+		path, err = u.oc.Upload(ctx, req.Dir, reader,
+			oss.WithExt(ext),
+			oss.WithFileSize(int(size)),
+			oss.WithLimitSize(),
+		)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = u.repoUpload.Create(ctx, &model.Upload{
+		UserID:      req.UserID,
+		Scene:       req.Scene,
+		Path:        path,
+		Size:        size,
+		ContentType: contentType,
+	})
+	if err != nil {
+		u.logger.WithContext(ctx).WithErr(err).With("path", path).Warn("record upload failed")
+	}
+
+	return path, nil
+}
+
+func (u *Upload) Usage(ctx context.Context, uid uint) (*model.UploadUsage, error) {
+	var res model.UploadUsage
+	err := u.repoUpload.Usage(ctx, &res, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// CleanOrphan 删除上传超过一天仍未被引用的文件
+func (u *Upload) CleanOrphan(ctx context.Context) (int, error) {
+	var (
+		afterID uint
+		total   int
+		before  = time.Now().AddDate(0, 0, -1)
+	)
+
+	for {
+		var uploads []model.Upload
+		err := u.repoUpload.ListOrphan(ctx, &uploads, before, afterID, 100)
+		if err != nil {
+			return total, err
+		}
+
+		if len(uploads) == 0 {
+			return total, nil
+		}
+
+		for _, item := range uploads {
+			afterID = item.ID
+
+			bucket, _, _ := strings.Cut(strings.TrimPrefix(item.Path, "/"), "/")
+			err = u.oc.Delete(ctx, util.TrimFirstDir(item.Path), oss.WithBucket(bucket))
+			if err != nil {
+				u.logger.WithContext(ctx).WithErr(err).With("path", item.Path).Warn("delete orphan object failed")
+				continue
+			}
+
+			err = u.repoUpload.DeleteByID(ctx, item.ID)
+			if err != nil {
+				return total, err
+			}
+
+			err = u.repoDesc.Delete(ctx, repo.QueryWithEqual("path", item.Path))
+			if err != nil {
+				u.logger.WithContext(ctx).WithErr(err).With("path", item.Path).Warn("delete image description failed")
+			}
+
+			total++
+		}
+	}
+}
+
+func newUpload(cfg config.Config, oc oss.Client, scanner upload.Scanner, repoUpload *repo.Upload, repoDesc *repo.ImageDescription) *Upload {
+	return &Upload{
+		oc:         oc,
+		scanner:    scanner,
+		repoUpload: repoUpload,
+		repoDesc:   repoDesc,
+		cfg:        cfg.Upload,
+		logger:     glog.Module("svc", "upload"),
+	}
+}
+
+func init() {
+	registerSvc(newUpload)
+}
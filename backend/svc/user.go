@@ -15,11 +15,12 @@ import (
 	"time"
 
 	"github.com/chaitin/koalaqa/model"
+	koalaCache "github.com/chaitin/koalaqa/pkg/cache"
 	"github.com/chaitin/koalaqa/pkg/database"
 	"github.com/chaitin/koalaqa/pkg/glog"
-	"github.com/chaitin/koalaqa/pkg/jwt"
 	"github.com/chaitin/koalaqa/pkg/mq"
 	"github.com/chaitin/koalaqa/pkg/oss"
+	"github.com/chaitin/koalaqa/pkg/ratelimit"
 	"github.com/chaitin/koalaqa/pkg/third_auth"
 	"github.com/chaitin/koalaqa/pkg/topic"
 	"github.com/chaitin/koalaqa/pkg/util"
@@ -34,7 +35,6 @@ import (
 )
 
 type User struct {
-	jwt            *jwt.Generator
 	repoUser       *repo.User
 	repoNotifySub  *repo.MessageNotifySub
 	repoDisc       *repo.Discussion
@@ -46,6 +46,10 @@ type User struct {
 	oc             oss.Client
 	repoOrg        *repo.Org
 	repoUserReview *repo.UserReview
+	repoTOTP       *repo.UserTOTP
+	svcSession     *UserSession
+	limiter        ratelimit.Limiter
+	totpChallenges koalaCache.Cache[totpChallenge]
 	pub            mq.Publisher
 	logger         *glog.Logger
 }
@@ -593,36 +597,48 @@ func (u *User) LoginMethod(ctx context.Context) (*AuthFrontendGetRes, error) {
 	return u.svcAuth.FrontendGet(ctx)
 }
 
-func (u *User) Login(ctx context.Context, req UserLoginReq, cors bool) (string, error) {
+func (u *User) Login(ctx context.Context, req UserLoginReq, cors bool) (*UserLoginRes, error) {
 	ok, err := u.canAuth(ctx, model.AuthTypePassword)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if !ok {
-		return "", errors.New("password login disabled")
+		return nil, errors.New("password login disabled")
 	}
 
 	var user model.User
 	err = u.repoUser.GetByEmail(ctx, &user, req.Email)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	err = u.checkPassword(req.Password, user.Password)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	token, err := u.jwt.Gen(ctx, model.UserCore{
-		UID:      user.ID,
-		AuthType: model.AuthTypePassword,
-		Cors:     cors,
-		Key:      user.Key,
-		Salt:     util.RandomString(16),
-	})
+	res, err := u.loginTOTP(ctx, &user, cors)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if res != nil {
+		return res, nil
+	}
+
+	token, err := u.genLoginToken(ctx, &user, model.AuthTypePassword, cors)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserLoginRes{Token: token}, nil
+}
+
+func (u *User) genLoginToken(ctx context.Context, user *model.User, typ model.AuthType, cors bool) (*LoginToken, error) {
+	token, err := u.svcSession.Create(ctx, user, typ, cors)
+	if err != nil {
+		return nil, err
 	}
 
 	err = u.repoUser.Update(ctx, map[string]any{
@@ -630,17 +646,41 @@ func (u *User) Login(ctx context.Context, req UserLoginReq, cors bool) (string,
 		"last_login": time.Now(),
 	}, repo.QueryWithEqual("id", user.ID))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	return token, nil
 }
 
-func (u *User) Logout(ctx context.Context, uid uint) error {
-	return u.repoUser.Update(ctx, map[string]any{
+// Logout 只注销当前会话，升级前签发的 token 没有会话，仍通过重置 key 注销
+func (u *User) Logout(ctx context.Context, user model.UserCore) error {
+	if user.SessionID > 0 {
+		return u.svcSession.Revoke(ctx, user.UID, user.SessionID)
+	}
+
+	return u.ForceLogout(ctx, user.UID)
+}
+
+type UserRefreshReq struct {
+	// RefreshToken 为空时使用 cookie 中的 refresh token
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (u *User) RefreshToken(ctx context.Context, refreshToken string) (*LoginToken, error) {
+	return u.svcSession.Refresh(ctx, refreshToken)
+}
+
+// ForceLogout 注销用户在所有设备上的登录
+func (u *User) ForceLogout(ctx context.Context, uid uint) error {
+	err := u.repoUser.Update(ctx, map[string]any{
 		"key":        uuid.NewString(),
 		"updated_at": time.Now(),
 	}, repo.QueryWithEqual("id", uid))
+	if err != nil {
+		return err
+	}
+
+	return u.svcSession.RevokeAll(ctx, uid, 0)
 }
 
 type LoginThirdURLReq struct {
@@ -774,29 +814,60 @@ type LoginThirdCallbackReq struct {
 	Code  string `form:"code" binding:"required"`
 }
 
-func (u *User) LoginThirdCallback(ctx context.Context, typ model.AuthType, req LoginThirdCallbackReq, cors bool) (string, error) {
+func (u *User) LoginThirdCallback(ctx context.Context, typ model.AuthType, req LoginThirdCallbackReq, cors bool) (*LoginToken, error) {
+	return u.loginThird(ctx, typ, req.Code, "", cors)
+}
+
+type LoginSAMLCallbackReq struct {
+	SAMLResponse string `form:"SAMLResponse" binding:"required"`
+	RelayState   string `form:"RelayState"`
+}
+
+// LoginSAMLCallback state 为空时表示 IdP 发起的登录
+func (u *User) LoginSAMLCallback(ctx context.Context, req LoginSAMLCallbackReq, state string, cors bool) (*LoginToken, error) {
+	return u.loginThird(ctx, model.AuthTypeSAML, req.SAMLResponse, state, cors)
+}
+
+func (u *User) SAMLMetadata(ctx context.Context) ([]byte, error) {
+	ok, err := u.canAuth(ctx, model.AuthTypeSAML)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errors.New("saml login disabled")
+	}
+
+	return u.authMgmt.Metadata(ctx, model.AuthTypeSAML)
+}
+
+func (u *User) loginThird(ctx context.Context, typ model.AuthType, code string, state string, cors bool) (*LoginToken, error) {
 	ok, err := u.canAuth(ctx, typ)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if !ok {
-		return "", errors.New("third login disabled")
+		return nil, errors.New("third login disabled")
 	}
 
-	auth, err := u.svcAuth.Get(ctx)
+	user, err := u.authMgmt.User(ctx, typ, code, third_auth.UserWithState(state))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	org, err := u.repoOrg.GetDefaultOrg(ctx)
+	return u.loginThirdUser(ctx, typ, user, cors)
+}
+
+func (u *User) loginThirdUser(ctx context.Context, typ model.AuthType, user *third_auth.User, cors bool) (*LoginToken, error) {
+	auth, err := u.svcAuth.Get(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	user, err := u.authMgmt.User(ctx, typ, req.Code)
+	org, err := u.repoOrg.GetDefaultOrg(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if auth.NeedReview {
@@ -805,16 +876,77 @@ func (u *User) LoginThirdCallback(ctx context.Context, typ model.AuthType, req L
 
 	dbUser, err := u.repoUser.CreateThird(ctx, org.ID, user, auth.CanRegister(typ))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return u.jwt.Gen(ctx, model.UserCore{
-		UID:      dbUser.ID,
-		AuthType: typ,
-		Cors:     cors,
-		Key:      dbUser.Key,
-		Salt:     util.RandomString(16),
-	})
+	return u.svcSession.Create(ctx, dbUser, typ, cors)
+}
+
+type UserLoginLDAPReq struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (u *User) LoginLDAP(ctx context.Context, req UserLoginLDAPReq, cors bool) (*LoginToken, error) {
+	ok, err := u.canAuth(ctx, model.AuthTypeLDAP)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errors.New("ldap login disabled")
+	}
+
+	// 每次登录都会请求目录服务，限制尝试次数，避免暴力破解以及触发 AD 的账号锁定
+	ip, _ := requestMeta(ctx)
+	if !u.limiter.AllowStrict("ldap_login:user:"+strings.ToLower(req.Username), time.Minute, 5) ||
+		!u.limiter.AllowStrict("ldap_login:ip:"+ip, time.Minute, 20) {
+		return nil, errors.New("too many attempts, try again later")
+	}
+
+	password, err := u.decryptReqPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.authMgmt.Verify(ctx, model.AuthTypeLDAP, req.Username, string(password))
+	if err != nil {
+		return nil, err
+	}
+
+	return u.loginThirdUser(ctx, model.AuthTypeLDAP, user, cors)
+}
+
+func (u *User) SyncLDAP(ctx context.Context) error {
+	ok, err := u.canAuth(ctx, model.AuthTypeLDAP)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	users, err := u.authMgmt.ListUsers(ctx, model.AuthTypeLDAP)
+	if err != nil {
+		return err
+	}
+
+	// 目录返回空列表多半是配置或网络问题，避免误禁用全部用户
+	if len(users) == 0 {
+		return errors.New("ldap directory returned no user")
+	}
+
+	synced, disabled, err := u.repoUser.SyncThird(ctx, model.AuthTypeLDAP, users)
+	if err != nil {
+		if errors.Is(err, repo.ErrSyncTooManyMissing) {
+			u.logger.WithContext(ctx).With("synced", synced).With("directory_users", len(users)).Warn("sync ldap users without disabling")
+		}
+		return err
+	}
+
+	u.logger.WithContext(ctx).With("synced", synced).With("disabled", disabled).Info("sync ldap users")
+	return nil
 }
 
 type UserStatisticsRes struct {
@@ -1035,11 +1167,12 @@ func (u *User) WechatOfficialAccountQrcode(ctx context.Context, uid uint) ([]byt
 	return io.ReadAll(resp.Body)
 }
 
-func newUser(repoUser *repo.User, genrator *jwt.Generator, auth *Auth, notifySub *repo.MessageNotifySub,
+func newUser(repoUser *repo.User, session *UserSession, auth *Auth, notifySub *repo.MessageNotifySub,
 	authMgmt *third_auth.Manager, oc oss.Client, org *repo.Org, userPoint *repo.UserPointRecord, publicAddr *PublicAddress,
-	disc *repo.Discussion, comm *repo.Comment, review *repo.UserReview, pub mq.Publisher) *User {
+	disc *repo.Discussion, comm *repo.Comment, review *repo.UserReview, pub mq.Publisher, userTOTP *repo.UserTOTP,
+	limiter ratelimit.Limiter, cacheBackend koalaCache.Backend) *User {
 	return &User{
-		jwt:            genrator,
+		svcSession:     session,
 		repoUser:       repoUser,
 		repoNotifySub:  notifySub,
 		svcAuth:        auth,
@@ -1049,6 +1182,9 @@ func newUser(repoUser *repo.User, genrator *jwt.Generator, auth *Auth, notifySub
 		repoDisc:       disc,
 		repoComment:    comm,
 		repoUserReview: review,
+		repoTOTP:       userTOTP,
+		limiter:        limiter,
+		totpChallenges: koalaCache.NewShared[totpChallenge](cacheBackend, "totp_challenge", 5*time.Minute),
 		pub:            pub,
 		repoUserPoint:  userPoint,
 		svcPublicAddr:  publicAddr,
@@ -0,0 +1,129 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+type UserPointRule struct {
+	repoRule  *repo.UserPointRule
+	repoSys   *repo.System
+	repoForum *repo.Forum
+}
+
+func newUserPointRule(rule *repo.UserPointRule, sys *repo.System, forum *repo.Forum) *UserPointRule {
+	return &UserPointRule{
+		repoRule:  rule,
+		repoSys:   sys,
+		repoForum: forum,
+	}
+}
+
+type UserPointRuleListReq struct {
+	ForumID *uint `form:"forum_id"`
+}
+
+func (u *UserPointRule) List(ctx context.Context, req UserPointRuleListReq) (*model.ListRes[model.UserPointRule], error) {
+	var res model.ListRes[model.UserPointRule]
+	err := u.repoRule.List(ctx, &res.Items,
+		repo.QueryWithEqual("forum_id", req.ForumID),
+		repo.QueryWithOrderBy("forum_id ASC, type ASC"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Total = int64(len(res.Items))
+	return &res, nil
+}
+
+// UserPointRuleDefault 未配置规则时使用的默认积分
+type UserPointRuleDefault struct {
+	Type  model.UserPointType `json:"type"`
+	Point int                 `json:"point"`
+}
+
+func (u *UserPointRule) Default() []UserPointRuleDefault {
+	res := make([]UserPointRuleDefault, 0, len(model.UserPointTypePointM))
+	for typ, point := range model.UserPointTypePointM {
+		res = append(res, UserPointRuleDefault{
+			Type:  typ,
+			Point: point,
+		})
+	}
+
+	slices.SortFunc(res, func(a, b UserPointRuleDefault) int {
+		return int(a.Type) - int(b.Type)
+	})
+
+	return res
+}
+
+// UserPointRuleUpsertReq 悬赏积分随帖子设置，Type 只能为 UserPointTypePointM 中的类型
+type UserPointRuleUpsertReq struct {
+	ForumID  uint                `json:"forum_id"`
+	Type     model.UserPointType `json:"type" binding:"required"`
+	Point    int                 `json:"point"`
+	DailyCap int                 `json:"daily_cap" binding:"min=0"`
+	Disabled bool                `json:"disabled"`
+}
+
+func (u *UserPointRule) Upsert(ctx context.Context, req UserPointRuleUpsertReq) error {
+	if _, ok := model.UserPointTypePointM[req.Type]; !ok {
+		return errors.New("invalid point type")
+	}
+
+	if req.ForumID > 0 {
+		exist, err := u.repoForum.Exist(ctx, repo.QueryWithEqual("id", req.ForumID))
+		if err != nil {
+			return err
+		}
+
+		if !exist {
+			return errors.New("forum not found")
+		}
+	}
+
+	return u.repoRule.Upsert(ctx, &model.UserPointRule{
+		ForumID:  req.ForumID,
+		Type:     req.Type,
+		Point:    req.Point,
+		DailyCap: req.DailyCap,
+		Disabled: req.Disabled,
+	})
+}
+
+func (u *UserPointRule) Delete(ctx context.Context, id uint) error {
+	return u.repoRule.DeleteByID(ctx, id)
+}
+
+func (u *UserPointRule) GetSystem(ctx context.Context) (*model.SystemUserPoint, error) {
+	var res model.SystemUserPoint
+	err := u.repoSys.GetValueByKey(ctx, &res, model.SystemKeyUserPoint)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			// 默认每天最多获得 100 积分
+			return &model.SystemUserPoint{DailyCap: 100}, nil
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (u *UserPointRule) UpdateSystem(ctx context.Context, req model.SystemUserPoint) error {
+	return u.repoSys.Upsert(ctx, &model.System[any]{
+		Key:   model.SystemKeyUserPoint,
+		Value: model.NewJSONBAny(req),
+	})
+}
+
+func init() {
+	registerSvc(newUserPointRule)
+}
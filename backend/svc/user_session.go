@@ -0,0 +1,382 @@
+package svc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	koalaCache "github.com/chaitin/koalaqa/pkg/cache"
+	"github.com/chaitin/koalaqa/pkg/config"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/jwt"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/chaitin/koalaqa/repo"
+	"github.com/gin-gonic/gin"
+)
+
+// refreshGrace refresh token 轮换后的宽限期，同一页面并发请求可能同时使用旧的 refresh token
+const refreshGrace = 30 * time.Second
+
+type UserSession struct {
+	jwt         *jwt.Generator
+	expire      time.Duration
+	repoSession *repo.UserSession
+	repoUser    *repo.User
+	logger      *glog.Logger
+
+	// active 最近校验过仍有效的会话，避免每次请求都查询数据库
+	active koalaCache.Cache[bool]
+}
+
+type LoginToken struct {
+	AccessToken string `json:"access_token"`
+	// RefreshToken 每次使用后轮换，宽限期内重复使用旧 token 时为空
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresIn access token 有效期，单位秒
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestMeta 获取登录请求的客户端信息，非 http 请求时为空
+func requestMeta(ctx context.Context) (string, string) {
+	gCtx, ok := ctx.Value(gin.ContextKey).(*gin.Context)
+	if !ok || gCtx.Request == nil {
+		return "", ""
+	}
+
+	return gCtx.ClientIP(), gCtx.Request.UserAgent()
+}
+
+// sessionDevice 从 user agent 中粗略识别系统和浏览器，仅用于展示
+func sessionDevice(ua string) string {
+	var (
+		os      string
+		browser string
+	)
+
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		os = "iOS"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+
+	switch {
+	case strings.Contains(ua, "MicroMessenger"):
+		browser = "WeChat"
+	case strings.Contains(ua, "DingTalk"):
+		browser = "DingTalk"
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+
+	switch {
+	case os == "":
+		return browser
+	case browser == "":
+		return os
+	default:
+		return os + " " + browser
+	}
+}
+
+func (s *UserSession) genAccessToken(ctx context.Context, user *model.User, session *model.UserSession) (string, error) {
+	return s.jwt.Gen(ctx, model.UserCore{
+		UID:       user.ID,
+		AuthType:  session.AuthType,
+		Cors:      session.Cors,
+		Key:       user.Key,
+		Salt:      util.RandomString(16),
+		SessionID: session.ID,
+	})
+}
+
+// Create 创建登录会话并签发 token
+func (s *UserSession) Create(ctx context.Context, user *model.User, typ model.AuthType, cors bool) (*LoginToken, error) {
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ip, ua := requestMeta(ctx)
+	session := model.UserSession{
+		UserID:      user.ID,
+		AuthType:    typ,
+		Cors:        cors,
+		Device:      sessionDevice(ua),
+		IP:          ip,
+		UserAgent:   ua,
+		LastSeen:    model.Timestamp(now.Unix()),
+		ExpireAt:    model.Timestamp(now.Add(s.expire).Unix()),
+		Key:         user.Key,
+		RefreshHash: hashToken(refreshToken),
+		RotatedAt:   model.Timestamp(now.Unix()),
+	}
+	err = s.repoSession.Create(ctx, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.genAccessToken(ctx, user, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginToken{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.jwt.TokenExpire(cors).Seconds()),
+	}, nil
+}
+
+// Refresh 使用 refresh token 换取新的 token，旧的 refresh token 宽限期后再次使用视为泄露，会话直接失效
+func (s *UserSession) Refresh(ctx context.Context, refreshToken string) (*LoginToken, error) {
+	hash := hashToken(refreshToken)
+
+	var session model.UserSession
+	err := s.repoSession.GetByRefreshHash(ctx, &session, hash)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, errors.New("invalid refresh token")
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.After(session.ExpireAt.Time()) {
+		return nil, s.revoke(ctx, session.ID, errors.New("session expired"))
+	}
+
+	var user model.User
+	err = s.repoUser.GetByID(ctx, &user, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Key != session.Key {
+		return nil, s.revoke(ctx, session.ID, errors.New("session revoked"))
+	}
+
+	res := LoginToken{
+		ExpiresIn: int64(s.jwt.TokenExpire(session.Cors).Seconds()),
+	}
+
+	if session.RefreshHash != hash {
+		if now.Sub(session.RotatedAt.Time()) > refreshGrace {
+			s.logger.WithContext(ctx).With("session_id", session.ID).With("user_id", session.UserID).Warn("refresh token reused, revoke session")
+			return nil, s.revoke(ctx, session.ID, errors.New("refresh token reused"))
+		}
+
+		res.AccessToken, err = s.genAccessToken(ctx, &user, &session)
+		if err != nil {
+			return nil, err
+		}
+
+		return &res, nil
+	}
+
+	res.RefreshToken, err = randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ip, ua := requestMeta(ctx)
+	rotated, err := s.repoSession.Rotate(ctx, session.ID, hash, map[string]any{
+		"refresh_hash":      hashToken(res.RefreshToken),
+		"prev_refresh_hash": hash,
+		"rotated_at":        now,
+		"last_seen":         now,
+		"ip":                ip,
+		"user_agent":        ua,
+		"device":            sessionDevice(ua),
+		"updated_at":        now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 并发刷新时其他请求已经完成轮换，新的 refresh token 没有保存，按宽限期处理只返回 access token
+	if !rotated {
+		var current model.UserSession
+		err = s.repoSession.GetByID(ctx, &current, session.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if current.PrevRefreshHash != hash || now.Sub(current.RotatedAt.Time()) > refreshGrace {
+			return nil, errors.New("invalid refresh token")
+		}
+
+		res.RefreshToken = ""
+	}
+
+	res.AccessToken, err = s.genAccessToken(ctx, &user, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (s *UserSession) revoke(ctx context.Context, id uint, reason error) error {
+	err := s.repoSession.DeleteByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s.active.Delete(strconv.FormatUint(uint64(id), 10))
+	return reason
+}
+
+// Active 校验会话是否已被注销，校验通过的会话会缓存一段时间
+func (s *UserSession) Active(ctx context.Context, core model.UserCore) error {
+	if core.SessionID == 0 {
+		return nil
+	}
+
+	key := strconv.FormatUint(uint64(core.SessionID), 10)
+	if _, ok := s.active.Get(key); ok {
+		return nil
+	}
+
+	var session model.UserSession
+	err := s.repoSession.GetByID(ctx, &session, core.SessionID)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return errors.New("session revoked")
+		}
+
+		return err
+	}
+
+	if session.UserID != core.UID || time.Now().After(session.ExpireAt.Time()) {
+		return errors.New("session expired")
+	}
+
+	err = s.repoSession.Update(ctx, map[string]any{
+		"last_seen": time.Now(),
+	}, repo.QueryWithEqual("id", session.ID))
+	if err != nil {
+		s.logger.WithContext(ctx).WithErr(err).With("session_id", session.ID).Warn("update session last_seen failed")
+	}
+
+	s.active.Set(key, true)
+	return nil
+}
+
+type UserSessionListItem struct {
+	model.UserSession
+
+	Current bool `json:"current"`
+}
+
+func (s *UserSession) List(ctx context.Context, user model.UserCore) ([]UserSessionListItem, error) {
+	var sessions []model.UserSession
+	err := s.repoSession.List(ctx, &sessions,
+		repo.QueryWithEqual("user_id", user.UID),
+		repo.QueryWithEqual("expire_at", time.Now(), repo.EqualOPGT),
+		repo.QueryWithOrderBy("last_seen DESC"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]UserSessionListItem, len(sessions))
+	for i, session := range sessions {
+		res[i] = UserSessionListItem{
+			UserSession: session,
+			Current:     session.ID == user.SessionID,
+		}
+	}
+
+	return res, nil
+}
+
+func (s *UserSession) Revoke(ctx context.Context, uid uint, id uint) error {
+	exist, err := s.repoSession.Exist(ctx, repo.QueryWithEqual("id", id), repo.QueryWithEqual("user_id", uid))
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		return errors.New("session not found")
+	}
+
+	return s.revoke(ctx, id, nil)
+}
+
+// RevokeAll 注销用户的全部会话，except 不为 0 时保留该会话
+func (s *UserSession) RevokeAll(ctx context.Context, uid uint, except uint) error {
+	var sessions []model.UserSession
+	err := s.repoSession.List(ctx, &sessions,
+		repo.QueryWithSelectColumn("id"),
+		repo.QueryWithEqual("user_id", uid),
+		repo.QueryWithEqual("id", except, repo.EqualOPNE),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		err = s.revoke(ctx, session.ID, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *UserSession) CleanExpired(ctx context.Context) error {
+	return s.repoSession.Delete(ctx, repo.QueryWithEqual("expire_at", time.Now(), repo.EqualOPLT))
+}
+
+func newUserSession(cfg config.Config, generator *jwt.Generator, session *repo.UserSession, user *repo.User, cacheBackend koalaCache.Backend) *UserSession {
+	return &UserSession{
+		jwt:         generator,
+		expire:      time.Duration(cfg.JWT.Expire) * time.Second,
+		repoSession: session,
+		repoUser:    user,
+		logger:      glog.Module("svc", "user_session"),
+		active:      koalaCache.NewShared[bool](cacheBackend, "user_session_active", time.Minute),
+	}
+}
+
+func init() {
+	registerSvc(newUserSession)
+}
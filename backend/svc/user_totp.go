@@ -0,0 +1,429 @@
+package svc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/totp"
+	"github.com/chaitin/koalaqa/repo"
+)
+
+const (
+	totpIssuer        = "KoalaQA"
+	totpRecoveryCount = 10
+)
+
+// totpChallenge 密码校验通过后等待二次验证的登录状态
+type totpChallenge struct {
+	UID    uint
+	Cors   bool
+	Enroll bool
+}
+
+type UserLoginRes struct {
+	Token *LoginToken `json:"-"`
+	// TOTPToken 不为空时需要调用 /user/login/totp 完成二次验证
+	TOTPToken string `json:"totp_token,omitempty"`
+	// TOTPEnroll 策略要求开启两步验证但用户尚未绑定，需要先调用 /user/login/totp/enroll
+	TOTPEnroll bool `json:"totp_enroll,omitempty"`
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+func (u *User) totpRequired(ctx context.Context, role model.UserRole) (bool, error) {
+	auth, err := u.svcAuth.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return auth.RequireTOTP && (role == model.UserRoleAdmin || role == model.UserRoleOperator), nil
+}
+
+func (u *User) getTOTP(ctx context.Context, uid uint) (*model.UserTOTP, error) {
+	var res model.UserTOTP
+	err := u.repoTOTP.GetByUserID(ctx, &res, uid)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (u *User) newTOTPChallenge(challenge totpChallenge) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = u.totpChallenges.Set(token, challenge)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// loginTOTP 密码校验通过后判断是否需要二次验证
+func (u *User) loginTOTP(ctx context.Context, user *model.User, cors bool) (*UserLoginRes, error) {
+	userTOTP, err := u.getTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := userTOTP != nil && userTOTP.Enabled
+	if !enabled {
+		required, err := u.totpRequired(ctx, user.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		if !required {
+			return nil, nil
+		}
+	}
+
+	token, err := u.newTOTPChallenge(totpChallenge{
+		UID:    user.ID,
+		Cors:   cors,
+		Enroll: !enabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserLoginRes{
+		TOTPToken:  token,
+		TOTPEnroll: !enabled,
+	}, nil
+}
+
+// verifyTOTP 校验动态验证码，allowRecovery 时也接受恢复码，恢复码使用后失效
+func (u *User) verifyTOTP(ctx context.Context, userTOTP *model.UserTOTP, code string, allowRecovery bool) error {
+	if !u.limiter.AllowStrict(fmt.Sprintf("totp:%d", userTOTP.UserID), time.Minute, 5) {
+		return errors.New("too many attempts, try again later")
+	}
+
+	counter, ok := totp.Validate(userTOTP.Secret, code, time.Now())
+	if ok {
+		used, err := u.repoTOTP.UseCounter(ctx, userTOTP.ID, counter)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errors.New("code already used")
+		}
+
+		userTOTP.LastCounter = counter
+		return nil
+	}
+
+	if allowRecovery && userTOTP.Enabled {
+		hash := hashRecoveryCode(code)
+		if i := slices.Index(userTOTP.RecoveryCodes, hash); i >= 0 {
+			used, err := u.repoTOTP.UseRecoveryCode(ctx, userTOTP.ID, hash)
+			if err != nil {
+				return err
+			}
+			if !used {
+				return errors.New("code already used")
+			}
+
+			userTOTP.RecoveryCodes = slices.Delete(userTOTP.RecoveryCodes, i, i+1)
+			return nil
+		}
+	}
+
+	return errors.New("invalid code")
+}
+
+func (u *User) genRecoveryCodes(ctx context.Context, id uint) ([]string, error) {
+	codes, err := totp.RecoveryCodes(totpRecoveryCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(model.StringArray, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	err = u.repoTOTP.Update(ctx, map[string]any{
+		"recovery_codes": hashes,
+		"updated_at":     time.Now(),
+	}, repo.QueryWithEqual("id", id))
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// enableTOTP 校验绑定时的验证码并启用，返回恢复码
+func (u *User) enableTOTP(ctx context.Context, uid uint, code string) ([]string, error) {
+	userTOTP, err := u.getTOTP(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if userTOTP == nil {
+		return nil, errors.New("totp not setup")
+	}
+
+	if userTOTP.Enabled {
+		return nil, errors.New("totp already enabled")
+	}
+
+	err = u.verifyTOTP(ctx, userTOTP, code, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.repoTOTP.Update(ctx, map[string]any{
+		"enabled":    true,
+		"updated_at": time.Now(),
+	}, repo.QueryWithEqual("id", userTOTP.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return u.genRecoveryCodes(ctx, userTOTP.ID)
+}
+
+type UserTOTPSetupRes struct {
+	Secret string `json:"secret"`
+	// URI otpauth 链接，前端生成二维码
+	URI string `json:"uri"`
+}
+
+func (u *User) setupTOTP(ctx context.Context, uid uint) (*UserTOTPSetupRes, error) {
+	userTOTP, err := u.getTOTP(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if userTOTP != nil && userTOTP.Enabled {
+		return nil, errors.New("totp already enabled")
+	}
+
+	var user model.User
+	err = u.repoUser.GetByID(ctx, &user, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.repoTOTP.Upsert(ctx, uid, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Name
+	}
+
+	return &UserTOTPSetupRes{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, account, secret),
+	}, nil
+}
+
+type UserLoginTOTPReq struct {
+	TOTPToken string `json:"totp_token" binding:"required"`
+	// Code 动态验证码，已绑定的用户也可以使用恢复码
+	Code string `json:"code" binding:"required"`
+}
+
+type UserLoginTOTPRes struct {
+	// Token 仅 cors 登录时返回
+	Token *LoginToken `json:"token,omitempty"`
+	// RecoveryCodes 登录时完成绑定才会返回，只展示一次
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+
+	cors bool
+}
+
+func (r *UserLoginTOTPRes) Cors() bool {
+	return r.cors
+}
+
+func (u *User) LoginTOTP(ctx context.Context, req UserLoginTOTPReq) (*UserLoginTOTPRes, error) {
+	challenge, ok := u.totpChallenges.Get(req.TOTPToken)
+	if !ok {
+		return nil, errors.New("totp token expired")
+	}
+
+	var res UserLoginTOTPRes
+	if challenge.Enroll {
+		codes, err := u.enableTOTP(ctx, challenge.UID, req.Code)
+		if err != nil {
+			return nil, err
+		}
+
+		res.RecoveryCodes = codes
+	} else {
+		userTOTP, err := u.getTOTP(ctx, challenge.UID)
+		if err != nil {
+			return nil, err
+		}
+
+		if userTOTP == nil || !userTOTP.Enabled {
+			u.totpChallenges.Delete(req.TOTPToken)
+			return nil, errors.New("totp not enabled")
+		}
+
+		err = u.verifyTOTP(ctx, userTOTP, req.Code, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	u.totpChallenges.Delete(req.TOTPToken)
+
+	var user model.User
+	err := u.repoUser.GetByID(ctx, &user, challenge.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Token, err = u.genLoginToken(ctx, &user, model.AuthTypePassword, challenge.Cors)
+	if err != nil {
+		return nil, err
+	}
+	res.cors = challenge.Cors
+
+	return &res, nil
+}
+
+type UserLoginTOTPEnrollReq struct {
+	TOTPToken string `json:"totp_token" binding:"required"`
+}
+
+// LoginTOTPEnroll 策略强制开启两步验证时，在登录流程中完成绑定
+func (u *User) LoginTOTPEnroll(ctx context.Context, req UserLoginTOTPEnrollReq) (*UserTOTPSetupRes, error) {
+	challenge, ok := u.totpChallenges.Get(req.TOTPToken)
+	if !ok {
+		return nil, errors.New("totp token expired")
+	}
+
+	if !challenge.Enroll {
+		return nil, errors.New("totp already enabled")
+	}
+
+	return u.setupTOTP(ctx, challenge.UID)
+}
+
+type UserTOTPStatusRes struct {
+	Enabled  bool `json:"enabled"`
+	Required bool `json:"required"`
+	// RecoveryCodes 剩余可用的恢复码数量
+	RecoveryCodes int `json:"recovery_codes"`
+}
+
+func (u *User) TOTPStatus(ctx context.Context, user model.UserInfo) (*UserTOTPStatusRes, error) {
+	required, err := u.totpRequired(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	res := UserTOTPStatusRes{Required: required}
+
+	userTOTP, err := u.getTOTP(ctx, user.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	if userTOTP != nil && userTOTP.Enabled {
+		res.Enabled = true
+		res.RecoveryCodes = len(userTOTP.RecoveryCodes)
+	}
+
+	return &res, nil
+}
+
+func (u *User) TOTPSetup(ctx context.Context, uid uint) (*UserTOTPSetupRes, error) {
+	return u.setupTOTP(ctx, uid)
+}
+
+type UserTOTPCodeReq struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func (u *User) TOTPEnable(ctx context.Context, uid uint, req UserTOTPCodeReq) ([]string, error) {
+	return u.enableTOTP(ctx, uid, req.Code)
+}
+
+func (u *User) getEnabledTOTP(ctx context.Context, uid uint) (*model.UserTOTP, error) {
+	userTOTP, err := u.getTOTP(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if userTOTP == nil || !userTOTP.Enabled {
+		return nil, errors.New("totp not enabled")
+	}
+
+	return userTOTP, nil
+}
+
+func (u *User) TOTPDisable(ctx context.Context, user model.UserInfo, req UserTOTPCodeReq) error {
+	required, err := u.totpRequired(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+
+	if required {
+		return errors.New("totp is required for your role")
+	}
+
+	userTOTP, err := u.getEnabledTOTP(ctx, user.UID)
+	if err != nil {
+		return err
+	}
+
+	err = u.verifyTOTP(ctx, userTOTP, req.Code, true)
+	if err != nil {
+		return err
+	}
+
+	return u.repoTOTP.DeleteByID(ctx, userTOTP.ID)
+}
+
+// TOTPRecoveryCodes 重新生成恢复码，旧的恢复码全部失效
+func (u *User) TOTPRecoveryCodes(ctx context.Context, uid uint, req UserTOTPCodeReq) ([]string, error) {
+	userTOTP, err := u.getEnabledTOTP(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.verifyTOTP(ctx, userTOTP, req.Code, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.genRecoveryCodes(ctx, userTOTP.ID)
+}
+
+// ResetTOTP 管理员重置用户的两步验证，用于用户丢失设备且没有恢复码的情况
+func (u *User) ResetTOTP(ctx context.Context, userID uint) error {
+	return u.repoTOTP.Delete(ctx, repo.QueryWithEqual("user_id", userID))
+}
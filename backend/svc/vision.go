@@ -0,0 +1,227 @@
+package svc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/database"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/llm"
+	"github.com/chaitin/koalaqa/pkg/oss"
+	"github.com/chaitin/koalaqa/pkg/upload"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/chaitin/koalaqa/repo"
+	einoModel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// visionMaxImages 单次最多识别的图片数量
+	visionMaxImages = 5
+	visionMaxSize   = 10 << 20
+	// visionTimeout 单次识别的总耗时上限，超时后未识别的图片直接跳过
+	visionTimeout = 20 * time.Second
+	// visionDiscussionPrefix 只识别帖子中上传的公开图片
+	visionDiscussionPrefix = "public/assets/discussion/"
+)
+
+var errVisionImageTooLarge = errors.New("image too large")
+
+// Vision 使用 analysis-vl 模型识别内容中的图片，识别结果按 OSS 对象缓存
+type Vision struct {
+	kit        *ModelKit
+	oc         oss.Client
+	repoDesc   *repo.ImageDescription
+	repoUpload *repo.Upload
+	logger     *glog.Logger
+}
+
+// allowedPaths 过滤出通过帖子上传接口上传的图片，避免读取其他 bucket 或私有对象
+func (v *Vision) allowedPaths(ctx context.Context, paths []string) ([]string, error) {
+	var candidates []string
+	for _, p := range paths {
+		_, key, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+		if !strings.HasPrefix(key, visionDiscussionPrefix) || strings.Contains(key, "..") {
+			continue
+		}
+
+		candidates = append(candidates, p)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	var uploads []model.Upload
+	err := v.repoUpload.List(ctx, &uploads,
+		repo.QueryWithSelectColumn("path"),
+		repo.QueryWithEqual("path", candidates, repo.EqualOPEqAny),
+		repo.QueryWithEqual("scene", upload.SceneDiscussion),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := make(map[string]bool, len(uploads))
+	for _, item := range uploads {
+		uploaded[item.Path] = true
+	}
+
+	res := make([]string, 0, len(candidates))
+	for _, p := range candidates {
+		if uploaded[p] {
+			res = append(res, p)
+		}
+	}
+
+	return res, nil
+}
+
+// Describe 识别 Markdown 内容中的站内图片，返回追加了图片描述的内容
+// 未启用 analysis-vl 模型或识别失败时返回原内容
+func (v *Vision) Describe(ctx context.Context, content string) string {
+	var paths []string
+	for _, u := range llm.ImageURLs(content) {
+		if strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//") {
+			paths = append(paths, u)
+		}
+	}
+	if len(paths) == 0 {
+		return content
+	}
+
+	logger := v.logger.WithContext(ctx)
+
+	paths, err := v.allowedPaths(ctx, paths)
+	if err != nil {
+		logger.WithErr(err).Warn("check image path failed")
+		return content
+	}
+	if len(paths) == 0 {
+		return content
+	}
+	if len(paths) > visionMaxImages {
+		paths = paths[:visionMaxImages]
+	}
+
+	var cached []model.ImageDescription
+	err = v.repoDesc.List(ctx, &cached, repo.QueryWithEqual("path", paths, repo.EqualOPEqAny))
+	if err != nil {
+		logger.WithErr(err).Warn("list image description failed")
+		return content
+	}
+
+	descriptions := make(map[string]string, len(paths))
+	for _, item := range cached {
+		descriptions[item.Path] = item.Content
+	}
+
+	var (
+		cm   einoModel.BaseChatModel
+		vl   *model.LLM
+		done bool
+	)
+
+	describeCtx, cancel := context.WithTimeout(ctx, visionTimeout)
+	defer cancel()
+
+	for _, p := range paths {
+		if _, ok := descriptions[p]; ok {
+			continue
+		}
+
+		if describeCtx.Err() != nil {
+			logger.With("path", p).Warn("describe image timeout, skip")
+			break
+		}
+
+		if !done {
+			done = true
+			cm, vl, err = v.kit.GetVisionModel(ctx)
+			if err != nil {
+				if !errors.Is(err, database.ErrRecordNotFound) {
+					logger.WithErr(err).Warn("get vision model failed")
+				}
+				break
+			}
+		}
+
+		desc, err := v.describe(describeCtx, cm, p)
+		if err != nil {
+			logger.WithErr(err).With("path", p).Warn("describe image failed")
+			continue
+		}
+
+		descriptions[p] = desc
+		err = v.repoDesc.Upsert(ctx, &model.ImageDescription{
+			Path:    p,
+			Model:   vl.Model,
+			Content: desc,
+		})
+		if err != nil {
+			logger.WithErr(err).With("path", p).Warn("save image description failed")
+		}
+	}
+
+	return llm.InlineImageDescriptions(content, descriptions)
+}
+
+func (v *Vision) describe(ctx context.Context, cm einoModel.BaseChatModel, path string) (string, error) {
+	bucket, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	reader, err := v.oc.Download(ctx, util.TrimFirstDir(path), oss.WithBucket(bucket))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, visionMaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > visionMaxSize {
+		return "", errVisionImageTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !upload.IsImage(contentType) {
+		return "", upload.ErrTypeNotAllowed
+	}
+
+	dataURL := "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	res, err := cm.Generate(ctx, []*schema.Message{
+		{
+			Role: schema.User,
+			UserInputMultiContent: []schema.MessageInputPart{
+				{Type: schema.ChatMessagePartTypeText, Text: llm.ImageDescribePrompt},
+				{Type: schema.ChatMessagePartTypeImageURL, Image: &schema.MessageInputImage{
+					MessagePartCommon: schema.MessagePartCommon{URL: &dataURL},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	v.logger.WithContext(ctx).With("path", path).WithText("content", res.Content).Debug("describe image success")
+	return util.CleanContentForLLM(strings.TrimSpace(res.Content), 1000), nil
+}
+
+func newVision(kit *ModelKit, oc oss.Client, repoDesc *repo.ImageDescription, repoUpload *repo.Upload) *Vision {
+	return &Vision{
+		kit:        kit,
+		oc:         oc,
+		repoDesc:   repoDesc,
+		repoUpload: repoUpload,
+		logger:     glog.Module("svc", "vision"),
+	}
+}
+
+func init() {
+	registerSvc(newVision)
+}
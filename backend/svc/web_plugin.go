@@ -3,18 +3,25 @@ package svc
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 
 	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/cache"
+	"github.com/chaitin/koalaqa/pkg/glog"
 	"github.com/chaitin/koalaqa/repo"
 	"gorm.io/gorm"
 )
 
 type WebPlugin struct {
-	cache *model.SystemWebPlugin
+	cache atomic.Pointer[model.SystemWebPlugin]
 
-	repoSys *repo.System
+	repoSys     *repo.System
+	broadcaster cache.Broadcaster
+	logger      *glog.Logger
 }
 
+const cacheTopicWebPlugin = "web_plugin"
+
 func (w *WebPlugin) CustomerServiceEnabled(ctx context.Context) (bool, error) {
 	plugin, err := w.Get(ctx)
 	if err != nil {
@@ -25,24 +32,21 @@ func (w *WebPlugin) CustomerServiceEnabled(ctx context.Context) (bool, error) {
 }
 
 func (w *WebPlugin) Get(ctx context.Context) (*model.SystemWebPlugin, error) {
-	if w.cache != nil {
-		return w.cache, nil
+	if plugin := w.cache.Load(); plugin != nil {
+		return plugin, nil
 	}
 
 	var data model.SystemWebPlugin
 	err := w.repoSys.GetValueByKey(ctx, &data, model.SystemKeyWebPlugin)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			w.cache = &model.SystemWebPlugin{}
-			return w.cache, nil
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
 		}
-
-		return nil, err
 	}
 
-	w.cache = &data
+	w.cache.Store(&data)
 
-	return w.cache, nil
+	return &data, nil
 }
 
 func (w *WebPlugin) Update(ctx context.Context, req model.SystemWebPlugin) error {
@@ -59,15 +63,27 @@ func (w *WebPlugin) Update(ctx context.Context, req model.SystemWebPlugin) error
 	if err != nil {
 		return err
 	}
-	w.cache = &req
+	w.cache.Store(&req)
+
+	err = w.broadcaster.Broadcast(ctx, cacheTopicWebPlugin)
+	if err != nil {
+		w.logger.WithContext(ctx).WithErr(err).Warn("broadcast web plugin cache invalidate failed")
+	}
 
 	return nil
 }
 
-func newWebPlugin(sys *repo.System) *WebPlugin {
-	return &WebPlugin{
-		repoSys: sys,
+func newWebPlugin(sys *repo.System, broadcaster cache.Broadcaster) *WebPlugin {
+	w := &WebPlugin{
+		repoSys:     sys,
+		broadcaster: broadcaster,
+		logger:      glog.Module("svc", "web_plugin"),
 	}
+
+	broadcaster.Subscribe(cacheTopicWebPlugin, func(context.Context) {
+		w.cache.Store(nil)
+	})
+	return w
 }
 
 func init() {
@@ -0,0 +1,366 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/chaitin/koalaqa/model"
+	"github.com/chaitin/koalaqa/pkg/cache"
+	"github.com/chaitin/koalaqa/pkg/glog"
+	"github.com/chaitin/koalaqa/pkg/llm"
+	"github.com/chaitin/koalaqa/pkg/util"
+	"github.com/chaitin/koalaqa/repo"
+	"go.uber.org/fx"
+)
+
+const (
+	cacheTopicWidgetSite = "widget_site"
+	widgetSiteKeyPrefix  = "ws_"
+)
+
+var errWidgetSiteNotFound = errors.New("widget site not found")
+
+// WidgetSite 多站点网页挂件，每个站点有独立的 site key、来源白名单、检索范围和品牌配置
+type WidgetSite struct {
+	in     widgetSiteIn
+	logger *glog.Logger
+
+	mu    sync.RWMutex
+	sites map[string]*model.WidgetSite
+}
+
+type widgetSiteIn struct {
+	fx.In
+
+	Repo        *repo.WidgetSite
+	AskSession  *repo.AskSession
+	Disc        *Discussion
+	Rank        *Rank
+	Broadcaster cache.Broadcaster
+}
+
+func newWidgetSite(in widgetSiteIn) *WidgetSite {
+	w := &WidgetSite{
+		in:     in,
+		logger: glog.Module("svc", "widget_site"),
+	}
+
+	in.Broadcaster.Subscribe(cacheTopicWidgetSite, func(context.Context) {
+		w.mu.Lock()
+		w.sites = nil
+		w.mu.Unlock()
+	})
+
+	return w
+}
+
+func init() {
+	registerSvc(newWidgetSite)
+}
+
+func (w *WidgetSite) invalidate(ctx context.Context) {
+	w.mu.Lock()
+	w.sites = nil
+	w.mu.Unlock()
+
+	err := w.in.Broadcaster.Broadcast(ctx, cacheTopicWidgetSite)
+	if err != nil {
+		w.logger.WithContext(ctx).WithErr(err).Warn("broadcast widget site cache invalidate failed")
+	}
+}
+
+// GetByKey 返回启用的站点，挂件的请求都会经过这里，结果缓存在内存中
+func (w *WidgetSite) GetByKey(ctx context.Context, key string) (*model.WidgetSite, error) {
+	w.mu.RLock()
+	sites := w.sites
+	w.mu.RUnlock()
+
+	if sites == nil {
+		var items []model.WidgetSite
+		err := w.in.Repo.List(ctx, &items, repo.QueryWithEqual("enabled", true))
+		if err != nil {
+			return nil, err
+		}
+
+		sites = make(map[string]*model.WidgetSite, len(items))
+		for i := range items {
+			sites[items[i].SiteKey] = &items[i]
+		}
+
+		w.mu.Lock()
+		w.sites = sites
+		w.mu.Unlock()
+	}
+
+	site, ok := sites[key]
+	if !ok {
+		return nil, errWidgetSiteNotFound
+	}
+
+	return site, nil
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+func matchOrigin(allowed string, origin string) bool {
+	allowed = normalizeOrigin(allowed)
+	if allowed == "*" || allowed == origin {
+		return true
+	}
+
+	// 支持 https://*.example.com 匹配子域名
+	scheme, host, ok := strings.Cut(allowed, "://*.")
+	if !ok {
+		return false
+	}
+
+	suffix, ok := strings.CutPrefix(origin, scheme+"://")
+	return ok && strings.HasSuffix(suffix, "."+host)
+}
+
+// AllowOrigin 校验跨域请求来源，与当前服务同源的请求总是允许
+func (w *WidgetSite) AllowOrigin(ctx context.Context, key string, origin string, host string) bool {
+	site, err := w.GetByKey(ctx, key)
+	if err != nil {
+		return false
+	}
+
+	origin = normalizeOrigin(origin)
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, host) {
+		return true
+	}
+
+	for _, allowed := range site.AllowedOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *WidgetSite) List(ctx context.Context) (*model.ListRes[model.WidgetSite], error) {
+	var res model.ListRes[model.WidgetSite]
+	err := w.in.Repo.List(ctx, &res.Items, repo.QueryWithOrderBy("created_at DESC, id DESC"))
+	if err != nil {
+		return nil, err
+	}
+
+	res.Total = int64(len(res.Items))
+	return &res, nil
+}
+
+type WidgetSiteReq struct {
+	Name             string                    `json:"name" binding:"required"`
+	Enabled          bool                      `json:"enabled"`
+	AllowedOrigins   model.StringArray         `json:"allowed_origins" binding:"dive,required"`
+	ForumIDs         model.Int64Array          `json:"forum_ids"`
+	GroupIDs         model.Int64Array          `json:"group_ids"`
+	Brand            model.WidgetSiteBrand     `json:"brand"`
+	QuestionType     model.SuggestQuestionType `json:"question_type" binding:"max=2"`
+	SuggestQuestions model.StringArray         `json:"suggest_questions"`
+}
+
+func (r *WidgetSiteReq) fix() {
+	if r.AllowedOrigins == nil {
+		r.AllowedOrigins = make(model.StringArray, 0)
+	}
+	for i := range r.AllowedOrigins {
+		r.AllowedOrigins[i] = normalizeOrigin(r.AllowedOrigins[i])
+	}
+	if r.ForumIDs == nil {
+		r.ForumIDs = make(model.Int64Array, 0)
+	}
+	if r.GroupIDs == nil {
+		r.GroupIDs = make(model.Int64Array, 0)
+	}
+	if r.QuestionType != model.SuggestQuestionTypeCustomize || r.SuggestQuestions == nil {
+		r.SuggestQuestions = make(model.StringArray, 0)
+	}
+}
+
+func (w *WidgetSite) Create(ctx context.Context, req WidgetSiteReq) (uint, error) {
+	req.fix()
+
+	site := model.WidgetSite{
+		Name:             req.Name,
+		SiteKey:          widgetSiteKeyPrefix + util.RandomString(24),
+		Enabled:          req.Enabled,
+		AllowedOrigins:   req.AllowedOrigins,
+		ForumIDs:         req.ForumIDs,
+		GroupIDs:         req.GroupIDs,
+		Brand:            model.NewJSONB(req.Brand),
+		QuestionType:     req.QuestionType,
+		SuggestQuestions: req.SuggestQuestions,
+	}
+	err := w.in.Repo.Create(ctx, &site)
+	if err != nil {
+		return 0, err
+	}
+
+	w.invalidate(ctx)
+	return site.ID, nil
+}
+
+func (w *WidgetSite) Update(ctx context.Context, id uint, req WidgetSiteReq) error {
+	req.fix()
+
+	err := w.in.Repo.Update(ctx, map[string]any{
+		"name":              req.Name,
+		"enabled":           req.Enabled,
+		"allowed_origins":   req.AllowedOrigins,
+		"forum_ids":         req.ForumIDs,
+		"group_ids":         req.GroupIDs,
+		"brand":             model.NewJSONB(req.Brand),
+		"question_type":     req.QuestionType,
+		"suggest_questions": req.SuggestQuestions,
+	}, repo.QueryWithEqual("id", id))
+	if err != nil {
+		return err
+	}
+
+	w.invalidate(ctx)
+	return nil
+}
+
+// ResetKey 重新生成 site key，旧的嵌入代码会立即失效
+func (w *WidgetSite) ResetKey(ctx context.Context, id uint) (string, error) {
+	key := widgetSiteKeyPrefix + util.RandomString(24)
+	err := w.in.Repo.Update(ctx, map[string]any{
+		"site_key": key,
+	}, repo.QueryWithEqual("id", id))
+	if err != nil {
+		return "", err
+	}
+
+	w.invalidate(ctx)
+	return key, nil
+}
+
+func (w *WidgetSite) Delete(ctx context.Context, id uint) error {
+	err := w.in.Repo.Delete(ctx, repo.QueryWithEqual("id", id))
+	if err != nil {
+		return err
+	}
+
+	w.invalidate(ctx)
+	return nil
+}
+
+type WidgetSiteConfig struct {
+	Name             string                    `json:"name"`
+	Brand            model.WidgetSiteBrand     `json:"brand"`
+	QuestionType     model.SuggestQuestionType `json:"question_type"`
+	SuggestQuestions []string                  `json:"suggest_questions"`
+}
+
+// Config 挂件加载时获取的公开配置
+func (w *WidgetSite) Config(ctx context.Context, key string) (*WidgetSiteConfig, error) {
+	site, err := w.GetByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := WidgetSiteConfig{
+		Name:             site.Name,
+		Brand:            site.Brand.Inner(),
+		QuestionType:     site.QuestionType,
+		SuggestQuestions: site.SuggestQuestions,
+	}
+
+	if site.QuestionType == model.SuggestQuestionTypeHot {
+		hot, err := w.in.Rank.LastHotQuestions(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		res.SuggestQuestions = make([]string, 0, len(hot.Items))
+		for _, item := range hot.Items {
+			res.SuggestQuestions = append(res.SuggestQuestions, item.Content)
+		}
+	}
+
+	if res.SuggestQuestions == nil {
+		res.SuggestQuestions = make([]string, 0)
+	}
+
+	return &res, nil
+}
+
+type WidgetSessionReq struct {
+	SessionID *string `form:"session_id"`
+}
+
+// Session 挂件的会话都是匿名会话，只能在创建它的站点继续使用
+func (w *WidgetSite) Session(ctx context.Context, key string, req WidgetSessionReq) (string, error) {
+	site, err := w.GetByKey(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	return w.in.Disc.CreateOrLastSession(ctx, 0, CreateOrLastSessionReq{
+		SessionID: req.SessionID,
+		SiteID:    site.ID,
+	}, false)
+}
+
+func (w *WidgetSite) checkSession(ctx context.Context, site *model.WidgetSite, sessionID string) error {
+	exist, err := w.in.AskSession.Exist(ctx,
+		repo.QueryWithEqual("uuid", sessionID),
+		repo.QueryWithEqual("user_id", 0),
+		repo.QueryWithEqual("site_id", site.ID),
+	)
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		return errAskSessionClosed
+	}
+
+	return nil
+}
+
+type WidgetAskReq struct {
+	SessionID string `json:"session_id" binding:"required,uuid"`
+	Question  string `json:"question" binding:"required"`
+}
+
+func (w *WidgetSite) Ask(ctx context.Context, key string, req WidgetAskReq) (*llm.Stream[llm.AskSessionStreamItem], error) {
+	site, err := w.GetByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.checkSession(ctx, site, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.in.Disc.Ask(ctx, 0, DiscussionAskReq{
+		SessionID: req.SessionID,
+		Question:  req.Question,
+		Source:    model.AskSessionSourcePlugin,
+		Site:      site,
+	})
+}
+
+func (w *WidgetSite) StopAsk(ctx context.Context, key string, req StopAskSessionReq) error {
+	site, err := w.GetByKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	err = w.checkSession(ctx, site, req.SeesionID)
+	if err != nil {
+		return err
+	}
+
+	return w.in.Disc.StopAskSession(ctx, 0, req)
+}